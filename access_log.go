@@ -0,0 +1,146 @@
+//go:build !devtrace_minimal
+
+package devtrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat selects the line format of an AccessLog
+type AccessLogFormat int
+
+const (
+	// AccessLogCombined is the Apache/nginx combined log format followed by
+	// trace_id="..." and top_frame="..." fields
+	AccessLogCombined AccessLogFormat = iota
+	// AccessLogJSON writes one JSON object per line
+	AccessLogJSON
+)
+
+// AccessLog writes one line per request served by its Middleware, carrying the
+// devtrace trace ID and the slowest frame the request entered, so access logs
+// and traces can be joined without a separate access-logging middleware
+type AccessLog struct {
+	Out    io.Writer
+	Format AccessLogFormat
+
+	mu sync.Mutex
+}
+
+// AccessLogEntry is what an AccessLog line records about a request
+type AccessLogEntry struct {
+	Time       time.Time     `json:"time"`
+	RemoteAddr string        `json:"remote_addr"`
+	User       string        `json:"user,omitempty"`
+	Method     string        `json:"method"`
+	URI        string        `json:"uri"`
+	Proto      string        `json:"proto"`
+	Status     int           `json:"status"`
+	Bytes      int64         `json:"bytes"`
+	Referer    string        `json:"referer,omitempty"`
+	UserAgent  string        `json:"user_agent,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	Operation  string        `json:"operation,omitempty"`
+	TraceID    string        `json:"trace_id,omitempty"`
+	TopFrame   string        `json:"top_frame,omitempty"` // slowest frame directly below the request, with its duration
+}
+
+// Middleware is OperationMiddleware that also writes an access log line once
+// the request has been served
+func (a *AccessLog) Middleware(name func(r *http.Request) string, next http.Handler) http.Handler {
+	return operationHandler(name, next, a)
+}
+
+// log writes the line for a request served in tc; tc is nil when tracing was off
+func (a *AccessLog) log(w *accessLogWriter, r *http.Request, start time.Time, op string, tc *TraceContext) {
+	entry := AccessLogEntry{
+		Time:       start,
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		URI:        r.RequestURI,
+		Proto:      r.Proto,
+		Status:     w.status,
+		Bytes:      w.bytes,
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+		Duration:   time.Since(start),
+		Operation:  op,
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		entry.RemoteAddr = host
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		entry.User = user
+	}
+	if entry.URI == "" {
+		entry.URI = r.URL.RequestURI()
+	}
+	if entry.Status == 0 {
+		entry.Status = http.StatusOK
+	}
+	if tc != nil {
+		entry.TraceID = tc.TraceID
+		if tc.topChild != "" {
+			entry.TopFrame = fmt.Sprintf("%s %v", tc.topChild, tc.topChildDuration.Round(time.Microsecond))
+		}
+	}
+	a.write(entry)
+}
+
+func (a *AccessLog) write(entry AccessLogEntry) {
+	var line []byte
+	if a.Format == AccessLogJSON {
+		line, _ = json.Marshal(entry)
+		line = append(line, '\n')
+	} else {
+		line = []byte(fmt.Sprintf("%s - %s [%s] %q %d %d %q %q trace_id=%q top_frame=%q\n",
+			entry.RemoteAddr, orDash(entry.User), entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+			entry.Method+" "+entry.URI+" "+entry.Proto, entry.Status, entry.Bytes,
+			orDash(entry.Referer), orDash(entry.UserAgent), entry.TraceID, entry.TopFrame))
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Out.Write(line)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// accessLogWriter records the status and size of a response
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
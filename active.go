@@ -0,0 +1,136 @@
+package devtrace
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// activeContexts tracks trace contexts that currently have open frames
+var (
+	activeMu       sync.Mutex
+	activeContexts = make(map[*TraceContext]struct{})
+)
+
+// openArgsMu guards the args of frames that are still open: their goroutine
+// may set one (Span.SetAttr, WrapTransport, TraceMarshal) while
+// DumpActiveContexts or the watchdog reads them from another goroutine
+var openArgsMu sync.RWMutex
+
+// setOpenArg sets an arg of a frame other goroutines may be reading
+func setOpenArg(frame *Frame, key string, value interface{}) {
+	openArgsMu.Lock()
+	defer openArgsMu.Unlock()
+	if frame.Args == nil {
+		frame.Args = make(map[string]interface{})
+	}
+	frame.Args[key] = value
+}
+
+// openFrameCopy copies what diagnostics show of a frame that may still be
+// running on another goroutine, so it can be formatted without racing it
+func openFrameCopy(frame *Frame) *Frame {
+	openArgsMu.RLock()
+	defer openArgsMu.RUnlock()
+
+	copied := &Frame{
+		Function:   frame.Function,
+		Signature:  frame.Signature,
+		File:       frame.File,
+		Line:       frame.Line,
+		StartTime:  frame.StartTime,
+		Class:      frame.Class,
+		Goroutine:  frame.Goroutine,
+		Operation:  frame.Operation,
+		Doc:        frame.Doc,
+		Invocation: frame.Invocation,
+		callerPC:   frame.callerPC,
+	}
+	if len(frame.Args) > 0 {
+		copied.Args = make(map[string]interface{}, len(frame.Args))
+		for name, value := range frame.Args {
+			copied.Args[name] = value
+		}
+	}
+	return copied
+}
+
+func markActive(tc *TraceContext) {
+	activeMu.Lock()
+	activeContexts[tc] = struct{}{}
+	activeMu.Unlock()
+}
+
+func markIdle(tc *TraceContext) {
+	activeMu.Lock()
+	delete(activeContexts, tc)
+	activeMu.Unlock()
+}
+
+// ActiveContexts returns every trace context that has at least one open frame,
+// oldest first. Their owners keep entering and leaving frames, so read them
+// through Stack and GetDepth, which are safe to call from any goroutine.
+func ActiveContexts() []*TraceContext {
+	activeMu.Lock()
+	contexts := make([]*TraceContext, 0, len(activeContexts))
+	for tc := range activeContexts {
+		contexts = append(contexts, tc)
+	}
+	activeMu.Unlock()
+
+	sort.Slice(contexts, func(i, j int) bool {
+		return contexts[i].StartAt.Before(contexts[j].StartAt)
+	})
+	return contexts
+}
+
+// DumpActiveContexts renders the open frames of every active trace context,
+// with how long each frame has been running and its captured args
+func DumpActiveContexts() string {
+	contexts := ActiveContexts()
+	now := time.Now()
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("🧵 %d active trace context(s)", len(contexts)))
+
+	for i, tc := range contexts {
+		frames := tc.Stack()
+		builder.WriteString(fmt.Sprintf("\n\nContext #%d (started %v ago, depth %d", i+1, now.Sub(tc.StartAt).Round(time.Microsecond), tc.GetDepth()))
+		if id := tc.Goroutine(); id != 0 {
+			builder.WriteString(fmt.Sprintf(", goroutine %d", id))
+		}
+		builder.WriteString(")")
+		for j, frame := range frames {
+			builder.WriteString("\n")
+			builder.WriteString(formatOpenFrame(openFrameCopy(frame), j, now))
+		}
+	}
+
+	return builder.String()
+}
+
+// LogActiveContexts writes DumpActiveContexts to GlobalLogger
+func LogActiveContexts() {
+	if GlobalLogger != nil {
+		GlobalLogger.Warn("%s", DumpActiveContexts())
+	}
+}
+
+func formatOpenFrame(frame *Frame, index int, now time.Time) string {
+	name := frame.Function
+	if name == "" {
+		name = "<anonymous>"
+	}
+
+	line := fmt.Sprintf("  %d. %s:%d → %s", index+1, filepath.Base(frame.File), frame.Line, name)
+	if !frame.StartTime.IsZero() {
+		line += fmt.Sprintf(" (open %v)", now.Sub(frame.StartTime).Round(time.Microsecond))
+	}
+	if len(frame.Args) > 0 {
+		line += "\n     Vars: " + NewDebugVars(frame.Args).String()
+	}
+	return line
+}
@@ -0,0 +1,74 @@
+package devtrace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// AnnotationSuffix is appended to a session file's path to name its notes file.
+// Notes live beside the session so they survive re-encryption or recompression of the data.
+const AnnotationSuffix = ".notes.jsonl"
+
+// Annotation is a free-text note attached to a stored session
+type Annotation struct {
+	Time   time.Time `json:"time"`
+	Author string    `json:"author,omitempty"`
+	Text   string    `json:"text"`
+}
+
+// AnnotateSession appends a note to the session stored at path
+func AnnotateSession(path string, note Annotation) error {
+	note.Text = strings.TrimSpace(note.Text)
+	if note.Text == "" {
+		return errors.New("annotation text is empty")
+	}
+	if note.Time.IsZero() {
+		note.Time = time.Now()
+	}
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path+AnnotationSuffix, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SessionAnnotations returns the notes of the session stored at path, oldest first
+func SessionAnnotations(path string) ([]Annotation, error) {
+	data, err := os.ReadFile(path + AnnotationSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []Annotation
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var note Annotation
+		if err := json.Unmarshal([]byte(line), &note); err != nil {
+			return nil, fmt.Errorf("%s%s line %d: %v", path, AnnotationSuffix, i+1, err)
+		}
+		notes = append(notes, note)
+	}
+	return notes, nil
+}
@@ -0,0 +1,32 @@
+package devtrace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnnotateSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AnnotateSession(path, Annotation{Author: "dana", Text: "this is the bad run"}); err != nil {
+		t.Fatalf("AnnotateSession: %v", err)
+	}
+	if err := AnnotateSession(path, Annotation{Text: "  "}); err == nil {
+		t.Fatalf("expected empty note to be rejected")
+	}
+	if err := AnnotateSession(path+".missing", Annotation{Text: "x"}); err == nil {
+		t.Fatalf("expected note on a missing session to fail")
+	}
+
+	session, err := ReadSessionFile(path)
+	if err != nil {
+		t.Fatalf("ReadSessionFile: %v", err)
+	}
+	if len(session.Annotations) != 1 || session.Annotations[0].Text != "this is the bad run" || session.Annotations[0].Time.IsZero() {
+		t.Fatalf("unexpected annotations: %+v", session.Annotations)
+	}
+}
@@ -0,0 +1,97 @@
+package devtrace
+
+import "reflect"
+
+// Bounds of the argument copies made with Config.SnapshotArgs
+const (
+	maxSnapshotDepth  = 8     // used when Config.MaxValueDepth is 0
+	maxSnapshotValues = 10000 // elements and entries copied per frame
+)
+
+// argCopier deep-copies captured values so later mutations by the traced code
+// do not show up in the frame. Past the depth bound, and once the budget of
+// copied values is spent, values are shared with the caller as before.
+type argCopier struct {
+	maxDepth int
+	budget   int
+}
+
+// snapshotArgs replaces every entry of an args map with a deep copy
+func snapshotArgs(args map[string]interface{}, cfg DevTraceConfig) {
+	c := argCopier{maxDepth: cfg.MaxValueDepth, budget: maxSnapshotValues}
+	if c.maxDepth <= 0 {
+		c.maxDepth = maxSnapshotDepth
+	}
+	for name, value := range args {
+		if value == nil {
+			continue
+		}
+		if copied := c.copy(reflect.ValueOf(value), 0); copied.CanInterface() {
+			args[name] = copied.Interface()
+		}
+	}
+}
+
+func (c *argCopier) copy(rv reflect.Value, depth int) reflect.Value {
+	if depth >= c.maxDepth || c.budget <= 0 {
+		return rv
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return rv
+		}
+		c.budget--
+		copied := reflect.New(rv.Type().Elem())
+		copied.Elem().Set(c.copy(rv.Elem(), depth+1))
+		return copied
+	case reflect.Interface:
+		if rv.IsNil() {
+			return rv
+		}
+		copied := reflect.New(rv.Type()).Elem()
+		copied.Set(c.copy(rv.Elem(), depth))
+		return copied
+	case reflect.Struct:
+		// unexported fields are copied as they are, exported ones deeply
+		copied := reflect.New(rv.Type()).Elem()
+		copied.Set(rv)
+		for i := 0; i < rv.NumField(); i++ {
+			if copied.Field(i).CanSet() {
+				copied.Field(i).Set(c.copy(rv.Field(i), depth+1))
+			}
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(rv.Type()).Elem()
+		copied.Set(rv)
+		for i := 0; i < rv.Len() && c.budget > 0; i++ {
+			copied.Index(i).Set(c.copy(rv.Index(i), depth+1))
+		}
+		return copied
+	case reflect.Slice:
+		if rv.IsNil() || rv.Len() > c.budget {
+			return rv
+		}
+		c.budget -= rv.Len()
+		copied := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		reflect.Copy(copied, rv)
+		for i := 0; i < rv.Len(); i++ {
+			copied.Index(i).Set(c.copy(rv.Index(i), depth+1))
+		}
+		return copied
+	case reflect.Map:
+		if rv.IsNil() || rv.Len() > c.budget {
+			return rv
+		}
+		c.budget -= rv.Len()
+		copied := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), c.copy(iter.Value(), depth+1))
+		}
+		return copied
+	}
+	return rv
+}
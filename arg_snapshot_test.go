@@ -0,0 +1,23 @@
+package devtrace
+
+import "testing"
+
+func TestSnapshotArgsKeepsCallTimeValues(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled, c.SnapshotArgs = true, true })
+
+	user := &testUser{Name: "ann"}
+	tags := map[string][]string{"roles": {"admin"}}
+	frame := CreateFrame("pkg.Rename", "", "user.go", 1, map[string]interface{}{"user": user, "tags": tags})
+	user.Name = "bob"
+	tags["roles"][0] = "guest"
+	tags["new"] = nil
+
+	if got := FormatValue(frame.Args["user"]); got != "&{Name:ann Email:}" {
+		t.Fatalf("user arg = %s, want the value at call time", got)
+	}
+	if got := FormatValue(frame.Args["tags"]); got != "map[roles:[admin]]" {
+		t.Fatalf("tags arg = %s, want the value at call time", got)
+	}
+}
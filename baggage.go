@@ -0,0 +1,97 @@
+package devtrace
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// BaggageHeader is the W3C header carrying baggage between services
+const BaggageHeader = "baggage"
+
+// SetBaggage attaches request-scoped metadata, such as a user, tenant or request
+// ID, to the trace context. It is appended to every LogWithStack output for the
+// context, kept with its recorded frames, inherited by forked goroutines and
+// sent along by InjectTraceHeaders. An empty value removes the key.
+func (tc *TraceContext) SetBaggage(key, value string) {
+	if tc == nil || key == "" {
+		return
+	}
+	// Copy on write: a map already handed out to the recorder or a fork never changes
+	baggage := make(map[string]string, len(tc.baggage)+1)
+	for k, v := range tc.baggage {
+		baggage[k] = v
+	}
+	if value == "" {
+		delete(baggage, key)
+	} else {
+		baggage[key] = value
+	}
+	tc.baggage = baggage
+}
+
+// Baggage returns a copy of the baggage of the trace context
+func (tc *TraceContext) Baggage() map[string]string {
+	if tc == nil || len(tc.baggage) == 0 {
+		return nil
+	}
+	baggage := make(map[string]string, len(tc.baggage))
+	for k, v := range tc.baggage {
+		baggage[k] = v
+	}
+	return baggage
+}
+
+// formatBaggage renders baggage as "key=value, ..." sorted by key, redacted
+func formatBaggage(baggage map[string]string) string {
+	keys := make([]string, 0, len(baggage))
+	for key := range baggage {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + "=" + scrubSecrets(fmt.Sprintf("%v", Redact(key, baggage[key])))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// encodeBaggage renders baggage as a W3C baggage header value
+func encodeBaggage(baggage map[string]string) string {
+	keys := make([]string, 0, len(baggage))
+	for key := range baggage {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = url.PathEscape(key) + "=" + url.PathEscape(baggage[key])
+	}
+	return strings.Join(parts, ",")
+}
+
+// decodeBaggage parses a W3C baggage header value, skipping malformed members
+// and dropping member properties
+func decodeBaggage(value string) map[string]string {
+	var baggage map[string]string
+	for _, member := range strings.Split(value, ",") {
+		member, _, _ = strings.Cut(member, ";")
+		key, val, ok := strings.Cut(member, "=")
+		if !ok {
+			continue
+		}
+		key, errKey := url.PathUnescape(strings.TrimSpace(key))
+		val, errVal := url.PathUnescape(strings.TrimSpace(val))
+		if errKey != nil || errVal != nil || key == "" || val == "" {
+			continue
+		}
+		if baggage == nil {
+			baggage = make(map[string]string)
+		}
+		baggage[key] = val
+	}
+	return baggage
+}
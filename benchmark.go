@@ -0,0 +1,192 @@
+package devtrace
+
+import (
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// Benchmark runs a function multiple times and returns statistics
+type BenchmarkResult struct {
+	Iterations  int
+	TotalTime   time.Duration
+	AverageTime time.Duration
+	MinTime     time.Duration
+	MaxTime     time.Duration
+	P50         time.Duration
+	P90         time.Duration
+	P95         time.Duration
+	P99         time.Duration
+	StdDev      time.Duration
+	Samples     []time.Duration       // per-iteration durations in execution order
+	AllocsPerOp uint64                // heap allocations per measured iteration
+	BytesPerOp  uint64                // heap bytes allocated per measured iteration
+	Environment *BenchmarkEnvironment // machine and runtime the samples were taken on
+}
+
+// BenchmarkOptions configures BenchmarkFuncWithOptions
+type BenchmarkOptions struct {
+	Iterations int           // measured iterations; ignored when Duration is set
+	Warmup     int           // unmeasured iterations run before measuring
+	Duration   time.Duration // run measured iterations until this much time has elapsed
+
+	// Name and GoBenchOutput emit the result in Go benchmark text format, for
+	// benchstat and dashboards that ingest `go test -bench` output
+	Name          string
+	GoBenchOutput io.Writer
+}
+
+// BenchmarkFunc runs a function multiple times and returns performance statistics
+func BenchmarkFunc(fn func(), iterations int) *BenchmarkResult {
+	return BenchmarkFuncWithOptions(fn, BenchmarkOptions{Iterations: iterations})
+}
+
+// BenchmarkFuncWithOptions runs a function with warmup and either a fixed
+// iteration count or a target duration, and returns performance statistics
+func BenchmarkFuncWithOptions(fn func(), opts BenchmarkOptions) *BenchmarkResult {
+	if !IsEnabled() || (opts.Iterations <= 0 && opts.Duration <= 0) {
+		return &BenchmarkResult{}
+	}
+
+	result := measureFunc(fn, opts)
+	result.Environment = CaptureBenchmarkEnvironment()
+
+	if GlobalLogger != nil {
+		GlobalLogger.Info("📊 Benchmark: %d iterations, avg: %v, min: %v, max: %v, p50: %v, p95: %v, p99: %v, stddev: %v, total: %v, %d allocs/op, %d B/op (%s)",
+			result.Iterations, result.AverageTime, result.MinTime, result.MaxTime,
+			result.P50, result.P95, result.P99, result.StdDev, result.TotalTime,
+			result.AllocsPerOp, result.BytesPerOp, result.Environment)
+	}
+
+	if opts.GoBenchOutput != nil {
+		if err := result.WriteGoBenchmark(opts.GoBenchOutput, opts.Name); err != nil && GlobalLogger != nil {
+			GlobalLogger.Warn("Benchmark output failed: %v", err)
+		}
+	}
+
+	return result
+}
+
+// measureFunc takes the samples of a benchmark run and summarizes them
+func measureFunc(fn func(), opts BenchmarkOptions) *BenchmarkResult {
+	for i := 0; i < opts.Warmup; i++ {
+		fn()
+	}
+
+	capacity := opts.Iterations
+	if opts.Duration > 0 {
+		capacity = 1024
+	}
+	samples := make([]time.Duration, 0, capacity)
+
+	// A duration run can outgrow the samples slice; the growth is the
+	// harness's allocation, not fn's, so it is left out of the per-op counts
+	var harnessAllocs, harnessBytes uint64
+	measure := func() {
+		if len(samples) == cap(samples) {
+			paused := readAllocStats()
+			samples = append(make([]time.Duration, 0, 2*cap(samples)+1), samples...)
+			allocs, bytes := readAllocStats().since(paused)
+			harnessAllocs += allocs
+			harnessBytes += bytes
+		}
+		start := time.Now()
+		fn()
+		samples = append(samples, time.Since(start))
+	}
+
+	before := readAllocStats()
+
+	if opts.Duration > 0 {
+		deadline := time.Now().Add(opts.Duration)
+		for len(samples) == 0 || time.Now().Before(deadline) {
+			measure()
+		}
+	} else {
+		for i := 0; i < opts.Iterations; i++ {
+			measure()
+		}
+	}
+
+	allocs, bytes := readAllocStats().since(before)
+	allocs -= harnessAllocs
+	bytes -= harnessBytes
+
+	result := summarizeSamples(samples)
+	result.AllocsPerOp = allocs / uint64(len(samples))
+	result.BytesPerOp = bytes / uint64(len(samples))
+	return result
+}
+
+// summarizeSamples computes aggregate statistics over a set of iteration durations
+func summarizeSamples(samples []time.Duration) *BenchmarkResult {
+	if len(samples) == 0 {
+		return &BenchmarkResult{}
+	}
+
+	totalTime := time.Duration(0)
+	for _, sample := range samples {
+		totalTime += sample
+	}
+	avgTime := totalTime / time.Duration(len(samples))
+
+	variance := 0.0
+	for _, sample := range samples {
+		diff := float64(sample - avgTime)
+		variance += diff * diff
+	}
+	variance /= float64(len(samples))
+
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return &BenchmarkResult{
+		Iterations:  len(samples),
+		TotalTime:   totalTime,
+		AverageTime: avgTime,
+		MinTime:     sorted[0],
+		MaxTime:     sorted[len(sorted)-1],
+		P50:         percentile(sorted, 50),
+		P90:         percentile(sorted, 90),
+		P95:         percentile(sorted, 95),
+		P99:         percentile(sorted, 99),
+		StdDev:      time.Duration(math.Sqrt(variance)),
+		Samples:     samples,
+	}
+}
+
+// percentile returns the nearest-rank percentile p (0-100) of an ascending slice
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// allocStats is a snapshot of cumulative process-wide heap allocation counters
+type allocStats struct {
+	mallocs    uint64
+	totalAlloc uint64
+}
+
+func readAllocStats() allocStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return allocStats{mallocs: ms.Mallocs, totalAlloc: ms.TotalAlloc}
+}
+
+// since returns the allocation count and bytes allocated after the earlier snapshot
+func (a allocStats) since(earlier allocStats) (allocs, bytes uint64) {
+	return a.mallocs - earlier.mallocs, a.totalAlloc - earlier.totalAlloc
+}
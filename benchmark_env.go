@@ -0,0 +1,91 @@
+package devtrace
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// BenchmarkEnvironment describes the machine and runtime a benchmark ran on, so
+// results from different machines can be compared. Fields that can't be probed
+// on the current platform are left empty; the CPU details come from /proc and
+// /sys on Linux.
+type BenchmarkEnvironment struct {
+	GoVersion   string `json:"go_version"`
+	GOOS        string `json:"goos"`
+	GOARCH      string `json:"goarch"`
+	GOMAXPROCS  int    `json:"gomaxprocs"`
+	NumCPU      int    `json:"num_cpu"`
+	CPUModel    string `json:"cpu_model,omitempty"`
+	CPUAffinity string `json:"cpu_affinity,omitempty"` // CPUs the process may run on, e.g. "0-3,8"
+	CPUGovernor string `json:"cpu_governor,omitempty"` // cpufreq scaling governor of CPU 0, e.g. "performance"
+	Hostname    string `json:"hostname,omitempty"`
+}
+
+// CaptureBenchmarkEnvironment probes the current runtime environment
+func CaptureBenchmarkEnvironment() *BenchmarkEnvironment {
+	env := &BenchmarkEnvironment{
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+	}
+	env.Hostname, _ = os.Hostname()
+
+	if runtime.GOOS == "linux" {
+		env.CPUModel = procField("/proc/cpuinfo", "model name")
+		if env.CPUModel == "" {
+			env.CPUModel = procField("/proc/cpuinfo", "Model") // arm
+		}
+		env.CPUAffinity = procField("/proc/self/status", "Cpus_allowed_list")
+		env.CPUGovernor = readTrimmed("/sys/devices/system/cpu/cpu0/cpufreq/scaling_governor")
+	}
+	return env
+}
+
+// String renders the environment on one line
+func (e *BenchmarkEnvironment) String() string {
+	if e == nil {
+		return ""
+	}
+	s := fmt.Sprintf("%s %s/%s, GOMAXPROCS=%d, %d CPUs", e.GoVersion, e.GOOS, e.GOARCH, e.GOMAXPROCS, e.NumCPU)
+	if e.CPUModel != "" {
+		s += ", " + e.CPUModel
+	}
+	if e.CPUAffinity != "" {
+		s += ", affinity " + e.CPUAffinity
+	}
+	if e.CPUGovernor != "" {
+		s += ", governor " + e.CPUGovernor
+	}
+	return s
+}
+
+// procField returns the value of the first "key: value" line for key in a /proc file
+func procField(path, key string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok && strings.TrimSpace(name) == key {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+func readTrimmed(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
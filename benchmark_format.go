@@ -0,0 +1,92 @@
+package devtrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MarshalJSON encodes the result with snake_case keys. Durations are integer
+// nanoseconds, like every other duration devtrace exports; ns_per_op is the
+// fractional mean a dashboard would plot.
+func (r BenchmarkResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Iterations  int                   `json:"iterations"`
+		NsPerOp     float64               `json:"ns_per_op"`
+		TotalTime   time.Duration         `json:"total"`
+		AverageTime time.Duration         `json:"average"`
+		MinTime     time.Duration         `json:"min"`
+		MaxTime     time.Duration         `json:"max"`
+		P50         time.Duration         `json:"p50"`
+		P90         time.Duration         `json:"p90"`
+		P95         time.Duration         `json:"p95"`
+		P99         time.Duration         `json:"p99"`
+		StdDev      time.Duration         `json:"stddev"`
+		AllocsPerOp uint64                `json:"allocs_per_op"`
+		BytesPerOp  uint64                `json:"bytes_per_op"`
+		Samples     []time.Duration       `json:"samples,omitempty"`
+		Environment *BenchmarkEnvironment `json:"environment,omitempty"`
+	}{
+		Iterations:  r.Iterations,
+		NsPerOp:     r.nsPerOp(),
+		TotalTime:   r.TotalTime,
+		AverageTime: r.AverageTime,
+		MinTime:     r.MinTime,
+		MaxTime:     r.MaxTime,
+		P50:         r.P50,
+		P90:         r.P90,
+		P95:         r.P95,
+		P99:         r.P99,
+		StdDev:      r.StdDev,
+		AllocsPerOp: r.AllocsPerOp,
+		BytesPerOp:  r.BytesPerOp,
+		Samples:     r.Samples,
+		Environment: r.Environment,
+	})
+}
+
+func (r *BenchmarkResult) nsPerOp() float64 {
+	if r.Iterations == 0 {
+		return 0
+	}
+	return float64(r.TotalTime) / float64(r.Iterations)
+}
+
+// GoBenchmarkLine formats the result as a line of `go test -bench` output:
+//
+//	BenchmarkParse-8   	     100	    123456 ns/op	     512 B/op	       3 allocs/op
+//
+// name gets the Benchmark prefix if it lacks one, and the -GOMAXPROCS suffix
+// the testing package adds when GOMAXPROCS is above 1.
+func (r *BenchmarkResult) GoBenchmarkLine(name string) string {
+	if !strings.HasPrefix(name, "Benchmark") {
+		name = "Benchmark" + name
+	}
+	name = strings.ReplaceAll(name, " ", "_") // benchstat splits fields on spaces
+	if r.Environment != nil && r.Environment.GOMAXPROCS > 1 {
+		name = fmt.Sprintf("%s-%d", name, r.Environment.GOMAXPROCS)
+	}
+
+	return fmt.Sprintf("%-8s\t%8d\t%10.1f ns/op\t%8d B/op\t%8d allocs/op",
+		name, r.Iterations, r.nsPerOp(), r.BytesPerOp, r.AllocsPerOp)
+}
+
+// WriteGoBenchmark writes the result in the Go benchmark text format that
+// benchstat reads: goos, goarch and cpu configuration lines from the recorded
+// environment, then the GoBenchmarkLine
+func (r *BenchmarkResult) WriteGoBenchmark(w io.Writer, name string) error {
+	var b strings.Builder
+	if env := r.Environment; env != nil {
+		fmt.Fprintf(&b, "goos: %s\ngoarch: %s\n", env.GOOS, env.GOARCH)
+		if env.CPUModel != "" {
+			fmt.Fprintf(&b, "cpu: %s\n", env.CPUModel)
+		}
+	}
+	b.WriteString(r.GoBenchmarkLine(name))
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
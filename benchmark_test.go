@@ -0,0 +1,123 @@
+package devtrace
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarizeSamplesPercentiles(t *testing.T) {
+	samples := make([]time.Duration, 0, 100)
+	for i := 100; i >= 1; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+
+	result := summarizeSamples(samples)
+
+	if result.Iterations != 100 || result.MinTime != time.Millisecond || result.MaxTime != 100*time.Millisecond {
+		t.Fatalf("unexpected bounds: %+v", result)
+	}
+	if result.P50 != 50*time.Millisecond || result.P90 != 90*time.Millisecond ||
+		result.P95 != 95*time.Millisecond || result.P99 != 99*time.Millisecond {
+		t.Fatalf("unexpected percentiles: p50=%v p90=%v p95=%v p99=%v", result.P50, result.P90, result.P95, result.P99)
+	}
+	if result.StdDev < 28*time.Millisecond || result.StdDev > 29*time.Millisecond {
+		t.Fatalf("unexpected stddev: %v", result.StdDev)
+	}
+	if result.Samples[0] != 100*time.Millisecond {
+		t.Fatalf("samples should keep execution order")
+	}
+}
+
+func TestBenchmarkFuncRecordsEnvironment(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	result := BenchmarkFunc(func() {}, 3)
+
+	env := result.Environment
+	if env == nil || env.GoVersion != runtime.Version() || env.GOMAXPROCS != runtime.GOMAXPROCS(0) || env.NumCPU < 1 {
+		t.Fatalf("expected the runtime environment to be recorded, got %+v", env)
+	}
+	if !strings.Contains(env.String(), "GOMAXPROCS=") {
+		t.Fatalf("unexpected environment summary: %s", env)
+	}
+}
+
+func TestBenchmarkFuncCountsOnlyAllocationsOfFn(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	sum := 0
+	add := func() { sum++ }
+	var sink []byte
+	allocate := func() { sink = make([]byte, 64) }
+
+	// A duration run takes far more samples than the initial capacity
+	for _, opts := range []BenchmarkOptions{{Iterations: 5000}, {Duration: 20 * time.Millisecond}} {
+		if result := BenchmarkFuncWithOptions(add, opts); result.AllocsPerOp != 0 || result.BytesPerOp != 0 {
+			t.Errorf("%+v: expected 0 allocs/op for a function that does not allocate, got %d (%d B/op) over %d iterations",
+				opts, result.AllocsPerOp, result.BytesPerOp, result.Iterations)
+		}
+		if result := BenchmarkFuncWithOptions(allocate, opts); result.AllocsPerOp != 1 || result.BytesPerOp < 64 {
+			t.Errorf("%+v: expected 1 alloc/op of 64 bytes, got %d (%d B/op)", opts, result.AllocsPerOp, result.BytesPerOp)
+		}
+	}
+	_ = sink
+}
+
+func TestBenchmarkResultFormats(t *testing.T) {
+	result := summarizeSamples([]time.Duration{100, 300})
+	result.AllocsPerOp, result.BytesPerOp = 2, 64
+	result.Environment = &BenchmarkEnvironment{GOOS: "linux", GOARCH: "amd64", GOMAXPROCS: 8, CPUModel: "Test CPU"}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["ns_per_op"] != 200.0 || decoded["p99"] != 300.0 || decoded["allocs_per_op"] != 2.0 {
+		t.Fatalf("unexpected JSON: %s", data)
+	}
+
+	var out strings.Builder
+	if err := result.WriteGoBenchmark(&out, "Parse request"); err != nil {
+		t.Fatal(err)
+	}
+	want := "goos: linux\ngoarch: amd64\ncpu: Test CPU\n" +
+		"BenchmarkParse_request-8\t       2\t     200.0 ns/op\t      64 B/op\t       2 allocs/op\n"
+	if out.String() != want {
+		t.Fatalf("unexpected benchmark output:\n%q\nwant\n%q", out.String(), want)
+	}
+}
+
+func TestMeasureOverheadReportsEveryCase(t *testing.T) {
+	original := CurrentConfig()
+	originalLogger := GlobalLogger
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = false; c.ShowArgs = true })
+
+	report := MeasureOverheadWithOptions(BenchmarkOptions{Iterations: 5})
+
+	if len(report.Cases) != 6 || report.Baseline.NsPerOp <= 0 || report.Environment == nil {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	for _, c := range report.Cases {
+		if c.NsPerOp <= 0 || c.OverheadNs != c.NsPerOp-report.Baseline.NsPerOp {
+			t.Fatalf("unexpected case: %+v", c)
+		}
+	}
+	if !strings.Contains(report.String(), "enter/leave with args") {
+		t.Fatalf("unexpected table:\n%s", report)
+	}
+	if CurrentConfig().Enabled || GlobalLogger != originalLogger {
+		t.Fatalf("expected config and logger to be restored")
+	}
+}
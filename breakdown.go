@@ -0,0 +1,167 @@
+package devtrace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LatencyBreakdown splits the time of a recorded session between application code,
+// the standard library and each third-party module
+type LatencyBreakdown struct {
+	Total        time.Duration
+	App          time.Duration
+	Stdlib       time.Duration
+	Unknown      time.Duration
+	Dependencies map[string]time.Duration // self time per dependency module path
+}
+
+// BuildLatencyBreakdown attributes the self time of each completed frame
+// (its duration minus that of frames nested inside it) to the frame's origin.
+// Frames are nested by their start and end times, so the input should come from
+// a single goroutine, e.g. the result of RecordCalls.
+func BuildLatencyBreakdown(frames []*Frame) *LatencyBreakdown {
+	breakdown := &LatencyBreakdown{Dependencies: make(map[string]time.Duration)}
+
+	completed := make([]*Frame, 0, len(frames))
+	for _, frame := range frames {
+		if frame != nil && !frame.StartTime.IsZero() && !frame.EndTime.IsZero() {
+			completed = append(completed, frame)
+		}
+	}
+	sort.SliceStable(completed, func(i, j int) bool {
+		if completed[i].StartTime.Equal(completed[j].StartTime) {
+			return completed[i].Duration > completed[j].Duration
+		}
+		return completed[i].StartTime.Before(completed[j].StartTime)
+	})
+
+	selfTime := make(map[*Frame]time.Duration, len(completed))
+	open := make([]*Frame, 0)
+	for _, frame := range completed {
+		for len(open) > 0 && !open[len(open)-1].EndTime.After(frame.StartTime) {
+			open = open[:len(open)-1]
+		}
+
+		selfTime[frame] += frame.Duration
+		if len(open) > 0 {
+			selfTime[open[len(open)-1]] -= frame.Duration
+		} else {
+			breakdown.Total += frame.Duration
+		}
+		open = append(open, frame)
+	}
+
+	for _, frame := range completed {
+		self := selfTime[frame]
+		if self < 0 {
+			self = 0
+		}
+
+		switch ClassifyFrame(frame) {
+		case FrameClassApp:
+			breakdown.App += self
+		case FrameClassStdlib:
+			breakdown.Stdlib += self
+		case FrameClassDependency:
+			breakdown.Dependencies[dependencyModule(frame.Function)] += self
+		default:
+			breakdown.Unknown += self
+		}
+	}
+
+	return breakdown
+}
+
+// SessionLatencyBreakdown is BuildLatencyBreakdown over stored sessions. The
+// frames of each goroutine of each session are nested separately, and keep the
+// class they were recorded with, since the process reading the sessions is
+// not the one that ran the code.
+func SessionLatencyBreakdown(sessions ...*Session) *LatencyBreakdown {
+	total := &LatencyBreakdown{Dependencies: make(map[string]time.Duration)}
+	for _, session := range sessions {
+		byGoroutine := make(map[uint64][]*Frame)
+		for _, snapshot := range session.Frames {
+			if snapshot.Unfinished {
+				continue
+			}
+			byGoroutine[snapshot.Goroutine] = append(byGoroutine[snapshot.Goroutine], &Frame{
+				Function:  snapshot.Function,
+				File:      snapshot.File,
+				Line:      snapshot.Line,
+				Class:     snapshot.Class,
+				StartTime: snapshot.StartTime,
+				EndTime:   snapshot.EndTime,
+				Duration:  snapshot.Duration,
+			})
+		}
+		for _, frames := range byGoroutine {
+			total.add(BuildLatencyBreakdown(frames))
+		}
+	}
+	return total
+}
+
+func (b *LatencyBreakdown) add(other *LatencyBreakdown) {
+	b.Total += other.Total
+	b.App += other.App
+	b.Stdlib += other.Stdlib
+	b.Unknown += other.Unknown
+	for module, dur := range other.Dependencies {
+		b.Dependencies[module] += dur
+	}
+}
+
+// dependencyModule returns the build-info module that provides a function, or its package path
+func dependencyModule(function string) string {
+	pkg := functionPackage(function)
+
+	best := ""
+	for _, dep := range currentBuildLayout().deps {
+		if hasPathPrefix(pkg, dep) && len(dep) > len(best) {
+			best = dep
+		}
+	}
+	if best != "" {
+		return best
+	}
+	if pkg != "" {
+		return pkg
+	}
+	return "<unknown module>"
+}
+
+// String renders the breakdown as a table sorted by time spent
+func (b *LatencyBreakdown) String() string {
+	if b == nil || b.Total <= 0 {
+		return "Latency breakdown: no completed frames"
+	}
+
+	type row struct {
+		name string
+		dur  time.Duration
+	}
+
+	rows := []row{{"app", b.App}, {"stdlib", b.Stdlib}}
+	for mod, dur := range b.Dependencies {
+		rows = append(rows, row{mod, dur})
+	}
+	if b.Unknown > 0 {
+		rows = append(rows, row{"unknown", b.Unknown})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].dur == rows[j].dur {
+			return rows[i].name < rows[j].name
+		}
+		return rows[i].dur > rows[j].dur
+	})
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Latency breakdown (total %v):\n", b.Total))
+	for _, r := range rows {
+		share := float64(r.dur) / float64(b.Total) * 100
+		builder.WriteString(fmt.Sprintf("  %-40s %12v %6.1f%%\n", r.name, r.dur, share))
+	}
+	return strings.TrimRight(builder.String(), "\n")
+}
@@ -0,0 +1,107 @@
+package devtrace
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildLatencyBreakdown(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	frame := func(function string, class FrameClass, from, to time.Duration) *Frame {
+		return &Frame{
+			Function:  function,
+			Class:     class,
+			StartTime: start.Add(from),
+			EndTime:   start.Add(to),
+			Duration:  to - from,
+		}
+	}
+	ms := time.Millisecond
+
+	for _, tc := range []struct {
+		name   string
+		frames []*Frame
+		want   LatencyBreakdown
+	}{
+		{
+			name:   "no completed frames",
+			frames: []*Frame{nil, {Function: "main.open", StartTime: start}},
+			want:   LatencyBreakdown{},
+		},
+		{
+			name: "self time of nested frames",
+			frames: []*Frame{
+				frame("main.handle", FrameClassApp, 0, 100*ms),
+				frame("encoding/json.Marshal", FrameClassStdlib, 10*ms, 30*ms),
+				frame("github.com/acme/db.Query", FrameClassDependency, 40*ms, 90*ms),
+				frame("net.Dial", FrameClassStdlib, 50*ms, 70*ms),
+			},
+			want: LatencyBreakdown{
+				Total:        100 * ms,
+				App:          30 * ms,
+				Stdlib:       40 * ms,
+				Dependencies: map[string]time.Duration{"github.com/acme/db": 30 * ms},
+			},
+		},
+		{
+			name: "sequential roots add up",
+			frames: []*Frame{
+				frame("main.first", FrameClassApp, 0, 20*ms),
+				frame("main.second", FrameClassApp, 20*ms, 50*ms),
+				frame("plugin.run", FrameClassUnknown, 60*ms, 70*ms),
+			},
+			want: LatencyBreakdown{Total: 60 * ms, App: 50 * ms, Unknown: 10 * ms},
+		},
+		{
+			name: "children longer than their parent leave it no self time",
+			frames: []*Frame{
+				frame("main.spawn", FrameClassApp, 0, 10*ms),
+				frame("main.child", FrameClassApp, 0, 10*ms),
+				frame("time.Sleep", FrameClassStdlib, 1*ms, 10*ms),
+			},
+			want: LatencyBreakdown{Total: 10 * ms, App: 1 * ms, Stdlib: 9 * ms},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := BuildLatencyBreakdown(tc.frames)
+			if got.Total != tc.want.Total || got.App != tc.want.App || got.Stdlib != tc.want.Stdlib || got.Unknown != tc.want.Unknown {
+				t.Fatalf("got total %v app %v stdlib %v unknown %v, want %+v", got.Total, got.App, got.Stdlib, got.Unknown, tc.want)
+			}
+			if len(got.Dependencies) != len(tc.want.Dependencies) {
+				t.Fatalf("got dependencies %v, want %v", got.Dependencies, tc.want.Dependencies)
+			}
+			for module, dur := range tc.want.Dependencies {
+				if got.Dependencies[module] != dur {
+					t.Fatalf("got dependencies %v, want %v", got.Dependencies, tc.want.Dependencies)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionLatencyBreakdownNestsPerGoroutine(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	snapshot := func(function string, class FrameClass, goroutine uint64, from, to time.Duration) FrameSnapshot {
+		return FrameSnapshot{
+			Function:  function,
+			Class:     class,
+			Goroutine: goroutine,
+			StartTime: start.Add(from),
+			EndTime:   start.Add(to),
+			Duration:  to - from,
+		}
+	}
+	ms := time.Millisecond
+	session := &Session{Frames: []FrameSnapshot{
+		snapshot("main.handle", FrameClassApp, 1, 0, 100*ms),
+		// runs on another goroutine while main.handle is open, so it is not nested in it
+		snapshot("main.worker", FrameClassApp, 2, 10*ms, 50*ms),
+		snapshot("io.Copy", FrameClassStdlib, 2, 20*ms, 40*ms),
+		{Function: "main.stuck", Class: FrameClassApp, Goroutine: 3, StartTime: start, Unfinished: true},
+	}}
+
+	got := SessionLatencyBreakdown(session, session)
+	if got.Total != 280*ms || got.App != 240*ms || got.Stdlib != 40*ms {
+		t.Fatalf("unexpected breakdown:\n%s", got)
+	}
+}
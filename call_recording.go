@@ -0,0 +1,446 @@
+package devtrace
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CallRecordingSchemaVersion is the schema field of every line a CallRecorder
+// writes; ReadCallRecording refuses files of a newer schema
+const CallRecordingSchemaVersion = 1
+
+// RecordedCall is one line of a call recording: a traced call with its
+// arguments and results encoded as JSON, so that it can be replayed. Error
+// results are recorded as their message.
+type RecordedCall struct {
+	Schema   int               `json:"schema"`
+	Function string            `json:"function"`         // runtime name of the traced function
+	Params   []string          `json:"params,omitempty"` // parameter names, when the source was found
+	Args     []json.RawMessage `json:"args"`             // null for context.Context arguments
+	Results  []json.RawMessage `json:"results,omitempty"`
+	Error    string            `json:"error,omitempty"` // last non-nil error result
+	Panic    string            `json:"panic,omitempty"`
+	Time     time.Time         `json:"time"`
+	Duration time.Duration     `json:"duration"`
+
+	// Unencodable lists the arguments JSON could not encode, such as funcs and
+	// channels; calls with any are not replayed
+	Unencodable []int `json:"unencodable,omitempty"`
+}
+
+// CallRecorderOptions configures StartCallRecording
+type CallRecorderOptions struct {
+	// Functions limits recording to traced functions whose name contains one
+	// of these substrings; empty records every traced call
+	Functions []string
+}
+
+// CallRecorder appends every call made through Trace wrappers and
+// TracedFunc.Call to a JSON lines file while tracing is on. Arguments are
+// redacted like frame args, so redacted values replay as the replacement.
+type CallRecorder struct {
+	opts CallRecorderOptions
+
+	mu   sync.Mutex
+	file *os.File
+	err  error // first write error, reported by Close
+}
+
+var (
+	callRecordersMu sync.RWMutex
+	callRecorders   []*CallRecorder
+)
+
+// StartCallRecording opens path for appending and records traced calls to it until
+// Close is called
+func StartCallRecording(path string, opts *CallRecorderOptions) (*CallRecorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	r := &CallRecorder{file: file}
+	if opts != nil {
+		r.opts = *opts
+	}
+
+	callRecordersMu.Lock()
+	callRecorders = append(callRecorders, r)
+	callRecordersMu.Unlock()
+	return r, nil
+}
+
+// Close stops recording and closes the file, reporting the first write error
+func (r *CallRecorder) Close() error {
+	callRecordersMu.Lock()
+	for i, recorder := range callRecorders {
+		if recorder == r {
+			callRecorders = append(callRecorders[:i:i], callRecorders[i+1:]...)
+			break
+		}
+	}
+	callRecordersMu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return r.err
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	r.file = nil
+	return r.err
+}
+
+func (r *CallRecorder) wants(function string) bool {
+	if len(r.opts.Functions) == 0 {
+		return true
+	}
+	for _, pattern := range r.opts.Functions {
+		if strings.Contains(function, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *CallRecorder) write(line []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return
+	}
+	if _, err := r.file.Write(line); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// recordCall hands a completed traced call to every active CallRecorder
+func recordCall(tf *TracedFunc, args, results []interface{}, err error, recovered interface{}, start time.Time, duration time.Duration) {
+	callRecordersMu.RLock()
+	recorders := callRecorders
+	callRecordersMu.RUnlock()
+	if len(recorders) == 0 {
+		return
+	}
+
+	call := RecordedCall{
+		Schema:   CallRecordingSchemaVersion,
+		Function: tf.funcName,
+		Params:   tf.ParamNames,
+		Time:     start,
+		Duration: duration,
+	}
+	for i, arg := range args {
+		if _, isCtx := arg.(context.Context); isCtx {
+			call.Args = append(call.Args, json.RawMessage("null"))
+			continue
+		}
+		encoded, ok := encodeRecordedValue(Redact(tf.argName(i), arg))
+		if !ok {
+			call.Unencodable = append(call.Unencodable, i)
+		}
+		call.Args = append(call.Args, encoded)
+	}
+	call.Results = encodeRecordedValues(results)
+	if err != nil && recovered == nil {
+		call.Error = err.Error()
+	}
+	if recovered != nil {
+		call.Panic = fmt.Sprintf("%v", recovered)
+	}
+
+	line, marshalErr := json.Marshal(call)
+	if marshalErr != nil {
+		return
+	}
+	line = append(line, '\n')
+	for _, r := range recorders {
+		if r.wants(call.Function) || r.wants(tf.Name) {
+			r.write(line)
+		}
+	}
+}
+
+// encodeRecordedValue encodes v as JSON, errors as their message; values JSON
+// cannot encode become null
+func encodeRecordedValue(v interface{}) (json.RawMessage, bool) {
+	if err, ok := v.(error); ok && err != nil {
+		v = err.Error()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage("null"), false
+	}
+	return data, true
+}
+
+func encodeRecordedValues(values []interface{}) []json.RawMessage {
+	encoded := make([]json.RawMessage, len(values))
+	for i, v := range values {
+		encoded[i], _ = encodeRecordedValue(v)
+	}
+	return encoded
+}
+
+// ReadCallRecording reads a file written by a CallRecorder
+func ReadCallRecording(path string) ([]RecordedCall, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	calls, err := readCallRecording(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return calls, nil
+}
+
+func readCallRecording(r io.Reader) ([]RecordedCall, error) {
+	var calls []RecordedCall
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), MaxCollectorMessage)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var call RecordedCall
+		if err := json.Unmarshal(line, &call); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		if call.Schema > CallRecordingSchemaVersion {
+			return nil, fmt.Errorf("line %d: schema %d is newer than this version of devtrace reads (%d)", lineNo, call.Schema, CallRecordingSchemaVersion)
+		}
+		calls = append(calls, call)
+	}
+	return calls, scanner.Err()
+}
+
+var (
+	replayMu    sync.RWMutex
+	replayFuncs = make(map[string]reflect.Value)
+)
+
+// RegisterReplay makes fn replayable under its runtime name, the name its
+// Trace wrapper records calls under. Method values such as svc.GetUser work
+// as long as they are registered the same way they were traced.
+func RegisterReplay(fn interface{}) {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func {
+		panic("RegisterReplay: argument must be a function")
+	}
+	name := ""
+	if f := runtime.FuncForPC(value.Pointer()); f != nil {
+		name = f.Name()
+	}
+	RegisterReplayAs(name, fn)
+}
+
+// RegisterReplayAs makes fn replay the recorded calls of function name
+func RegisterReplayAs(name string, fn interface{}) {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func {
+		panic("RegisterReplayAs: argument must be a function")
+	}
+
+	replayMu.Lock()
+	defer replayMu.Unlock()
+	replayFuncs[name] = value
+}
+
+// ReplayResult is the outcome of replaying one recorded call
+type ReplayResult struct {
+	Call    RecordedCall      `json:"call"`
+	Results []json.RawMessage `json:"results,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Panic   string            `json:"panic,omitempty"`
+	Diffs   []string          `json:"diffs,omitempty"`   // how the replayed outputs differ from the recorded ones
+	Skipped string            `json:"skipped,omitempty"` // why the call was not replayed
+}
+
+// OK reports whether the call was replayed and produced the recorded outputs
+func (r ReplayResult) OK() bool {
+	return r.Skipped == "" && len(r.Diffs) == 0
+}
+
+// ReplayCall invokes the function registered for call with its recorded
+// arguments, passing ctx for context.Context parameters, and compares the
+// results, error and panic with the recorded ones
+func ReplayCall(ctx context.Context, call RecordedCall) (result ReplayResult) {
+	result.Call = call
+
+	replayMu.RLock()
+	fn, ok := replayFuncs[call.Function]
+	replayMu.RUnlock()
+	if !ok {
+		result.Skipped = "no function registered with RegisterReplay"
+		return result
+	}
+	if len(call.Unencodable) > 0 {
+		result.Skipped = fmt.Sprintf("arguments %v were not recorded", call.Unencodable)
+		return result
+	}
+
+	args, err := decodeReplayArgs(ctx, fn.Type(), call.Args)
+	if err != nil {
+		result.Skipped = err.Error()
+		return result
+	}
+
+	var results []interface{}
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result.Panic = fmt.Sprintf("%v", r)
+			}
+		}()
+		for _, value := range fn.Call(args) {
+			results = append(results, value.Interface())
+		}
+	}()
+	if result.Panic == "" {
+		result.Results = encodeRecordedValues(results)
+		if err := lastError(results); err != nil {
+			result.Error = err.Error()
+		}
+	}
+
+	result.Diffs = diffReplay(call, result)
+	return result
+}
+
+func decodeReplayArgs(ctx context.Context, fnType reflect.Type, recorded []json.RawMessage) ([]reflect.Value, error) {
+	numIn := fnType.NumIn()
+	if len(recorded) < numIn-1 || (!fnType.IsVariadic() && len(recorded) != numIn) {
+		return nil, fmt.Errorf("recorded %d arguments, the registered function takes %d", len(recorded), numIn)
+	}
+
+	ctxType := reflect.TypeOf((*context.Context)(nil)).Elem()
+	args := make([]reflect.Value, len(recorded))
+	for i, raw := range recorded {
+		typ := fnType.In(min(i, numIn-1))
+		if fnType.IsVariadic() && i >= numIn-1 {
+			typ = fnType.In(numIn - 1).Elem()
+		}
+		if typ == ctxType {
+			args[i] = reflect.ValueOf(&ctx).Elem()
+			continue
+		}
+		value := reflect.New(typ)
+		if err := json.Unmarshal(raw, value.Interface()); err != nil {
+			return nil, fmt.Errorf("argument %d: %v", i, err)
+		}
+		args[i] = value.Elem()
+	}
+	return args, nil
+}
+
+// diffReplay lists the outputs of a replay that differ from the recording
+func diffReplay(call RecordedCall, replay ReplayResult) []string {
+	var diffs []string
+	if call.Panic != replay.Panic {
+		diffs = append(diffs, fmt.Sprintf("panic: recorded %q, replayed %q", call.Panic, replay.Panic))
+	}
+	if call.Panic != "" || replay.Panic != "" {
+		return diffs
+	}
+	if call.Error != replay.Error {
+		diffs = append(diffs, fmt.Sprintf("error: recorded %q, replayed %q", call.Error, replay.Error))
+	}
+	for i := 0; i < len(call.Results) || i < len(replay.Results); i++ {
+		var recorded, replayed json.RawMessage
+		if i < len(call.Results) {
+			recorded = call.Results[i]
+		}
+		if i < len(replay.Results) {
+			replayed = replay.Results[i]
+		}
+		if !jsonEqual(recorded, replayed) {
+			diffs = append(diffs, fmt.Sprintf("result %d: recorded %s, replayed %s", i, recorded, replayed))
+		}
+	}
+	return diffs
+}
+
+// jsonEqual compares two JSON documents by value, so key order and spacing don't matter
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// ReplayFile replays every call of a recording, in order
+func ReplayFile(ctx context.Context, path string) ([]ReplayResult, error) {
+	calls, err := ReadCallRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ReplayResult, len(calls))
+	for i, call := range calls {
+		results[i] = ReplayCall(ctx, call)
+	}
+	return results, nil
+}
+
+// WriteReplayReport writes one line per replayed call, with the differences
+// of those that changed, followed by totals; it returns how many differed
+func WriteReplayReport(w io.Writer, results []ReplayResult) (failed int, err error) {
+	var b strings.Builder
+	skipped := make(map[string]int)
+	skippedTotal := 0
+	for _, result := range results {
+		switch {
+		case result.Skipped != "":
+			skipped[result.Skipped]++
+			skippedTotal++
+		case result.OK():
+			fmt.Fprintf(&b, "ok    %s\n", result.Call.Function)
+		default:
+			failed++
+			fmt.Fprintf(&b, "DIFF  %s args=%s\n", result.Call.Function, formatRecordedArgs(result.Call))
+			for _, diff := range result.Diffs {
+				fmt.Fprintf(&b, "      %s\n", diff)
+			}
+		}
+	}
+
+	reasons := make([]string, 0, len(skipped))
+	for reason := range skipped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(&b, "skip  %d call(s): %s\n", skipped[reason], reason)
+	}
+	fmt.Fprintf(&b, "%d replayed, %d differed, %d skipped\n", len(results)-skippedTotal, failed, skippedTotal)
+
+	_, err = io.WriteString(w, b.String())
+	return failed, err
+}
+
+func formatRecordedArgs(call RecordedCall) string {
+	parts := make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		parts[i] = string(arg)
+		if i < len(call.Params) && call.Params[i] != "" {
+			parts[i] = call.Params[i] + "=" + parts[i]
+		}
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
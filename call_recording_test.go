@@ -0,0 +1,72 @@
+package devtrace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var recordedDiscountRate = 10
+
+func recordedDiscount(ctx context.Context, price int, code string) (int, error) {
+	if code == "" {
+		return 0, errors.New("no code")
+	}
+	return price - price*recordedDiscountRate/100, nil
+}
+
+func TestCallRecordingReplaysAndDiffs(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	path := filepath.Join(t.TempDir(), "calls.jsonl")
+	recorder, err := StartCallRecording(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	discount := TraceTyped(recordedDiscount, nil)
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	discount(ctx, 200, "SPRING")
+	discount(ctx, 50, "")
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	discount(ctx, 1, "after close")
+
+	calls, err := ReadCallRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || string(calls[0].Args[0]) != "null" || string(calls[0].Args[2]) != `"SPRING"` ||
+		string(calls[0].Results[0]) != "180" || calls[1].Error != "no code" {
+		t.Fatalf("unexpected recording: %+v", calls)
+	}
+
+	RegisterReplay(recordedDiscount)
+	results, err := ReplayFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if !result.OK() {
+			t.Fatalf("replay of unchanged code differs: %+v", result)
+		}
+	}
+
+	recordedDiscountRate = 20
+	t.Cleanup(func() { recordedDiscountRate = 10 })
+	results, _ = ReplayFile(context.Background(), path)
+	if results[0].OK() || results[0].Diffs[0] != "result 0: recorded 180, replayed 160" || !results[1].OK() {
+		t.Fatalf("expected only the first call to differ: %+v", results)
+	}
+
+	var report bytes.Buffer
+	failed, err := WriteReplayReport(&report, results)
+	if err != nil || failed != 1 || !strings.Contains(report.String(), "2 replayed, 1 differed, 0 skipped") {
+		t.Fatalf("report (%d failed, %v):\n%s", failed, err, report.String())
+	}
+}
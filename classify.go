@@ -0,0 +1,134 @@
+package devtrace
+
+import (
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// FrameClass tells where the code of a frame comes from
+type FrameClass string
+
+const (
+	FrameClassUnknown    FrameClass = ""
+	FrameClassApp        FrameClass = "app"
+	FrameClassDependency FrameClass = "dependency"
+	FrameClassStdlib     FrameClass = "stdlib"
+)
+
+// buildLayout describes the running binary, resolved once from build info
+type buildLayout struct {
+	mainModule string
+	deps       []string
+	gorootSrc  string
+}
+
+var (
+	buildLayoutOnce sync.Once
+	layout          buildLayout
+)
+
+func currentBuildLayout() buildLayout {
+	buildLayoutOnce.Do(func() {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			layout.mainModule = info.Main.Path
+			for _, dep := range info.Deps {
+				layout.deps = append(layout.deps, dep.Path)
+			}
+		}
+		layout.gorootSrc = detectGorootSrc()
+	})
+	return layout
+}
+
+// detectGorootSrc locates GOROOT/src from the recorded file of a standard library function,
+// which also works for binaries built with -trimpath.
+func detectGorootSrc() string {
+	pc := reflect.ValueOf(strings.Index).Pointer()
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+
+	file, _ := fn.FileLine(pc)
+	file = filepath.ToSlash(file)
+	if idx := strings.LastIndex(file, "/strings/"); idx != -1 {
+		return file[:idx]
+	}
+	return ""
+}
+
+// MainModule returns the main module path from build info, or "" when unavailable
+func MainModule() string {
+	return currentBuildLayout().mainModule
+}
+
+// DetectAppPattern returns an AppPattern matching the main module, falling back to "/"
+func DetectAppPattern() string {
+	if mod := MainModule(); mod != "" {
+		return mod
+	}
+	return "/"
+}
+
+// ClassifyFrame determines whether a frame belongs to the application, a dependency
+// or the standard library, and caches the answer in frame.Class.
+func ClassifyFrame(frame *Frame) FrameClass {
+	if frame == nil {
+		return FrameClassUnknown
+	}
+	if frame.Class == FrameClassUnknown {
+		frame.Class = classify(frame.Function, frame.File)
+	}
+	return frame.Class
+}
+
+func classify(function, file string) FrameClass {
+	l := currentBuildLayout()
+	file = filepath.ToSlash(file)
+	pkg := functionPackage(function)
+
+	if pkg == "main" || (l.mainModule != "" && hasPathPrefix(pkg, l.mainModule)) {
+		return FrameClassApp
+	}
+	for _, dep := range l.deps {
+		if hasPathPrefix(pkg, dep) {
+			return FrameClassDependency
+		}
+	}
+
+	switch {
+	case file == "":
+		return FrameClassUnknown
+	case l.gorootSrc != "" && strings.HasPrefix(file, l.gorootSrc+"/"):
+		return FrameClassStdlib
+	case strings.Contains(file, "/pkg/mod/") || strings.Contains(file, "/vendor/") || strings.Contains(file, "@v"):
+		return FrameClassDependency
+	case !strings.Contains(file, "/"):
+		// Bare file names come from instrumented application code
+		return FrameClassApp
+	case !filepath.IsAbs(file) && !strings.Contains(strings.SplitN(file, "/", 2)[0], "."):
+		// -trimpath stdlib files look like "net/http/server.go"
+		return FrameClassStdlib
+	}
+
+	return FrameClassApp
+}
+
+// functionPackage extracts the import path from a runtime function name such as
+// "github.com/org/repo/pkg.(*T).Method"
+func functionPackage(function string) string {
+	slash := strings.LastIndex(function, "/")
+	dot := strings.Index(function[slash+1:], ".")
+	if dot == -1 {
+		return ""
+	}
+	return function[:slash+1+dot]
+}
+
+func hasPathPrefix(pkg, prefix string) bool {
+	return pkg == prefix || strings.HasPrefix(pkg, prefix+"/")
+}
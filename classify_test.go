@@ -0,0 +1,28 @@
+package devtrace
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestClassifyFrame(t *testing.T) {
+	pc, file, line, _ := runtime.Caller(0)
+	own := &Frame{Function: runtime.FuncForPC(pc).Name(), File: file, Line: line}
+	if got := ClassifyFrame(own); got != FrameClassApp {
+		t.Fatalf("test frame classified as %q", got)
+	}
+
+	stdlib := &Frame{Function: "strings.Index", File: currentBuildLayout().gorootSrc + "/strings/strings.go"}
+	if got := ClassifyFrame(stdlib); got != FrameClassStdlib {
+		t.Fatalf("stdlib frame classified as %q", got)
+	}
+
+	dep := &Frame{Function: "github.com/other/lib.Do", File: "/home/u/go/pkg/mod/github.com/other/lib@v1.2.0/lib.go"}
+	if got := ClassifyFrame(dep); got != FrameClassDependency {
+		t.Fatalf("dependency frame classified as %q", got)
+	}
+
+	if DetectAppPattern() != "github.com/skulidropek/gotrace" {
+		t.Fatalf("unexpected app pattern: %s", DetectAppPattern())
+	}
+}
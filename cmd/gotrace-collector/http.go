@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// newHandler serves the query API and a page over it:
+//
+//	/                 recent frames of every service, with a filter form
+//	/api/services     frames, errors and last contact per service
+//	/api/frames       frames, newest first (?service=, ?fn=, ?trace=, ?min=100ms, ?errors=1, ?limit=)
+//	/api/traces/<id>  every frame of one trace across services, in start order
+func newHandler(s *store) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/services", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.summaries())
+	})
+	mux.HandleFunc("/api/frames", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.query(queryFromRequest(r)))
+	})
+	mux.HandleFunc("/api/traces/", func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Path[len("/api/traces/"):]
+		if id == "" {
+			http.Error(w, "trace ID required", http.StatusBadRequest)
+			return
+		}
+		frames := s.query(frameQuery{TraceID: id})
+		sort.SliceStable(frames, func(i, j int) bool { return frames[i].StartTime.Before(frames[j].StartTime) })
+		writeJSON(w, frames)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		q := queryFromRequest(r)
+		data := struct {
+			Query    frameQuery
+			Min      string
+			Services []serviceSummary
+			Frames   []collectedFrame
+		}{Query: q, Min: r.URL.Query().Get("min"), Services: s.summaries(), Frames: s.query(q)}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := pageTemplate.Execute(w, data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	return mux
+}
+
+func queryFromRequest(r *http.Request) frameQuery {
+	values := r.URL.Query()
+	q := frameQuery{
+		Service:    values.Get("service"),
+		Function:   values.Get("fn"),
+		TraceID:    values.Get("trace"),
+		OnlyErrors: values.Get("errors") == "1" || values.Get("errors") == "true",
+		Limit:      200,
+	}
+	if min, err := time.ParseDuration(values.Get("min")); err == nil {
+		q.MinDuration = min
+	}
+	if limit, err := strconv.Atoi(values.Get("limit")); err == nil && limit > 0 {
+		q.Limit = limit
+	}
+	return q
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var pageTemplate = template.Must(template.New("page").Funcs(template.FuncMap{
+	"round": func(d time.Duration) time.Duration { return d.Round(time.Microsecond) },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>gotrace collector</title>
+<style>
+body{font:13px/1.4 ui-monospace,monospace;margin:16px;color:#222}
+table{border-collapse:collapse}td,th{padding:2px 8px;text-align:left;border-bottom:1px solid #eee}
+.err{color:#c0392b}.dim{color:#888}form{margin:12px 0}input,select{font:inherit}
+</style></head><body>
+<h1>gotrace collector</h1>
+<table><tr><th>service</th><th>frames</th><th>errors</th><th>last seen</th></tr>
+{{range .Services}}<tr><td><a href="?service={{.Service}}">{{.Service}}</a></td><td>{{.Frames}}</td><td>{{.Errors}}</td><td>{{.LastSeen.Format "15:04:05"}}</td></tr>
+{{else}}<tr><td colspan="4" class="dim">no process has connected yet</td></tr>{{end}}
+</table>
+<form>
+<select name="service"><option value="">all services</option>{{range .Services}}<option{{if eq .Service $.Query.Service}} selected{{end}}>{{.Service}}</option>{{end}}</select>
+<input name="fn" placeholder="function" value="{{.Query.Function}}">
+<input name="trace" placeholder="trace ID" value="{{.Query.TraceID}}" size="34">
+<input name="min" placeholder="min e.g. 10ms" value="{{.Min}}" size="10">
+<label><input type="checkbox" name="errors" value="1"{{if .Query.OnlyErrors}} checked{{end}}> errors</label>
+<button>filter</button>
+</form>
+<table><tr><th>time</th><th>service</th><th>call path</th><th>duration</th><th>trace</th><th>error</th></tr>
+{{range .Frames}}<tr><td class="dim">{{.StartTime.Format "15:04:05.000"}}</td><td>{{.Service}}</td>
+<td>{{range $i, $p := .Path}}{{if $i}} → {{end}}{{$p}}{{end}}</td><td>{{round .Duration}}</td>
+<td>{{if .TraceID}}<a href="?trace={{.TraceID}}">{{printf "%.8s" .TraceID}}</a>{{end}}</td><td class="err">{{.Error}}</td></tr>
+{{else}}<tr><td colspan="6" class="dim">no frames match</td></tr>{{end}}
+</table></body></html>
+`))
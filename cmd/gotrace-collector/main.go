@@ -0,0 +1,82 @@
+// gotrace-collector receives completed frames from processes running
+// devtrace.NewCollectorClient, stores them per service and serves them for
+// querying, so the API, workers and cron jobs of a local stack can be traced
+// together
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+func main() {
+	listen := flag.String("listen", "tcp://localhost:7070", "Where processes connect: tcp://host:port or unix:///path/to.sock")
+	httpAddr := flag.String("http", "localhost:7071", "Address of the query API and UI")
+	dir := flag.String("dir", "collected", "Directory for <service>.jsonl session files; empty keeps frames in memory only")
+	keep := flag.Int("keep", 20000, "Frames kept in memory for queries")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gotrace-collector [-listen tcp://localhost:7070] [-http localhost:7071] [-dir collected]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	store, err := newStore(*keep, *dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	network, addr, err := parseListen(*listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if network == "unix" {
+		os.Remove(addr)
+	}
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go serveCollector(listener, store)
+
+	log.Printf("collecting frames on %s, querying on http://%s/", *listen, *httpAddr)
+	log.Fatal(http.ListenAndServe(*httpAddr, newHandler(store)))
+}
+
+// parseListen splits tcp://host:port or unix:///path into a network and address
+func parseListen(listen string) (network, addr string, err error) {
+	network, addr, ok := strings.Cut(listen, "://")
+	if !ok {
+		return "tcp", listen, nil
+	}
+	if network != "tcp" && network != "unix" {
+		return "", "", fmt.Errorf("unsupported network %q (want tcp or unix)", network)
+	}
+	return network, addr, nil
+}
+
+// serveCollector accepts client connections and stores what they send
+func serveCollector(listener net.Listener, store *store) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			defer conn.Close()
+			err := devtrace.ReadCollectorStream(conn, func(hello devtrace.CollectorHello, frame devtrace.ExportedFrame) error {
+				return store.add(hello, frame)
+			})
+			if err != nil {
+				log.Printf("%s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
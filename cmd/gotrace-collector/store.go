@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+// collectedFrame is a frame with the process that sent it
+type collectedFrame struct {
+	Service  string    `json:"service"`
+	Host     string    `json:"host,omitempty"`
+	PID      int       `json:"pid,omitempty"`
+	Received time.Time `json:"received"`
+	devtrace.ExportedFrame
+}
+
+// serviceSummary is what /api/services reports about one service
+type serviceSummary struct {
+	Service  string    `json:"service"`
+	Frames   int64     `json:"frames"`
+	Errors   int64     `json:"errors"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// frameQuery selects frames; zero fields match everything
+type frameQuery struct {
+	Service     string
+	Function    string // substring
+	TraceID     string
+	MinDuration time.Duration
+	OnlyErrors  bool
+	Limit       int
+}
+
+// store keeps the latest frames in a ring buffer and appends every frame to
+// the session file of its service
+type store struct {
+	mu       sync.Mutex
+	frames   []collectedFrame
+	next     int
+	full     bool
+	services map[string]*serviceSummary
+
+	dir   string
+	files map[string]*os.File
+}
+
+func newStore(keep int, dir string) (*store, error) {
+	if keep <= 0 {
+		keep = 1
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &store{
+		frames:   make([]collectedFrame, keep),
+		services: make(map[string]*serviceSummary),
+		dir:      dir,
+		files:    make(map[string]*os.File),
+	}, nil
+}
+
+func (s *store) add(hello devtrace.CollectorHello, frame devtrace.ExportedFrame) error {
+	entry := collectedFrame{Service: hello.Service, Host: hello.Host, PID: hello.PID, Received: time.Now(), ExportedFrame: frame}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.frames[s.next] = entry
+	s.next = (s.next + 1) % len(s.frames)
+	if s.next == 0 {
+		s.full = true
+	}
+
+	summary := s.services[entry.Service]
+	if summary == nil {
+		summary = &serviceSummary{Service: entry.Service}
+		s.services[entry.Service] = summary
+	}
+	summary.Frames++
+	if frame.Error != "" {
+		summary.Errors++
+	}
+	summary.LastSeen = entry.Received
+
+	if s.dir == "" {
+		return nil
+	}
+	file, err := s.file(entry.Service)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// file opens the session file of a service on first use
+func (s *store) file(service string) (*os.File, error) {
+	if file, ok := s.files[service]; ok {
+		return file, nil
+	}
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, service)
+	if name == "" || name == "." || name == ".." {
+		name = "unnamed"
+	}
+	file, err := os.OpenFile(filepath.Join(s.dir, name+".jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.files[service] = file
+	return file, nil
+}
+
+// query returns matching frames, newest first
+func (s *store) query(q frameQuery) []collectedFrame {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := s.next
+	if s.full {
+		size = len(s.frames)
+	}
+	var out []collectedFrame
+	for i := 0; i < size; i++ {
+		entry := s.frames[(s.next-1-i+len(s.frames))%len(s.frames)]
+		if q.Service != "" && entry.Service != q.Service {
+			continue
+		}
+		if q.Function != "" && !strings.Contains(entry.Function, q.Function) {
+			continue
+		}
+		if q.TraceID != "" && entry.TraceID != q.TraceID {
+			continue
+		}
+		if entry.Duration < q.MinDuration || (q.OnlyErrors && entry.Error == "") {
+			continue
+		}
+		out = append(out, entry)
+		if q.Limit > 0 && len(out) == q.Limit {
+			break
+		}
+	}
+	return out
+}
+
+func (s *store) summaries() []serviceSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]serviceSummary, 0, len(s.services))
+	for _, summary := range s.services {
+		out = append(out, *summary)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Service < out[j].Service })
+	return out
+}
+
+func (s *store) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, file := range s.files {
+		file.Close()
+	}
+}
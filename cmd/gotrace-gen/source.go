@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// funcDecl is what the generator needs to know about the function under test,
+// read from its source declaration
+type funcDecl struct {
+	pkg      string
+	name     string // Func or Type.Method
+	recvName string // receiver variable name, "" for functions
+	recvType string // e.g. "*UserService"
+	params   []declField
+	results  []declField
+	variadic bool
+	imports  []string // import specs the parameter and result types need
+}
+
+type declField struct {
+	name string
+	typ  string
+}
+
+// findFuncDecl parses the non-test files of the package in dir and returns the
+// declaration of name, given as Func or Type.Method
+func findFuncDecl(dir, name string) (*funcDecl, error) {
+	recv, fn := "", name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		recv, fn = name[:i], name[i+1:]
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(pkgs))
+	for pkgName := range pkgs {
+		names = append(names, pkgName)
+	}
+	sort.Strings(names)
+	for _, pkgName := range names {
+		for _, file := range pkgs[pkgName].Files {
+			for _, d := range file.Decls {
+				decl, ok := d.(*ast.FuncDecl)
+				if !ok || decl.Name.Name != fn || receiverTypeName(decl) != recv {
+					continue
+				}
+				if decl.Type.TypeParams != nil && decl.Type.TypeParams.NumFields() > 0 {
+					return nil, fmt.Errorf("%s is generic: generate tests for an instantiation by hand", name)
+				}
+				return newFuncDecl(fset, pkgName, name, file, decl), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no declaration of %s in %s", name, dir)
+}
+
+// receiverTypeName is the base type name of a method's receiver, "" for functions
+func receiverTypeName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return ""
+	}
+	typ := decl.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	switch t := typ.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.IndexExpr:
+		if id, ok := t.X.(*ast.Ident); ok {
+			return id.Name
+		}
+	}
+	return ""
+}
+
+func newFuncDecl(fset *token.FileSet, pkgName, name string, file *ast.File, decl *ast.FuncDecl) *funcDecl {
+	fd := &funcDecl{pkg: pkgName, name: name}
+	used := make(map[string]bool)
+
+	if decl.Recv != nil && len(decl.Recv.List) > 0 {
+		field := decl.Recv.List[0]
+		fd.recvType = exprString(fset, field.Type)
+		fd.recvName = "s"
+		if len(field.Names) > 0 && field.Names[0].Name != "_" {
+			fd.recvName = field.Names[0].Name
+		}
+	}
+
+	for _, field := range decl.Type.Params.List {
+		typ := field.Type
+		if ellipsis, ok := typ.(*ast.Ellipsis); ok {
+			fd.variadic = true
+			typ = &ast.ArrayType{Elt: ellipsis.Elt}
+		}
+		collectPackages(typ, used)
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{{Name: ""}}
+		}
+		for _, n := range names {
+			fd.params = append(fd.params, declField{name: n.Name, typ: exprString(fset, typ)})
+		}
+	}
+	if decl.Type.Results != nil {
+		for _, field := range decl.Type.Results.List {
+			collectPackages(field.Type, used)
+			count := len(field.Names)
+			if count == 0 {
+				count = 1
+			}
+			for i := 0; i < count; i++ {
+				fd.results = append(fd.results, declField{typ: exprString(fset, field.Type)})
+			}
+		}
+	}
+
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		local := importName(importPath)
+		if spec.Name != nil {
+			local = spec.Name.Name
+		}
+		if used[local] {
+			fd.imports = append(fd.imports, strings.TrimSpace(exprString(fset, spec.Name)+" "+spec.Path.Value))
+		}
+	}
+	return fd
+}
+
+// collectPackages records the package names qualifying types in expr
+func collectPackages(expr ast.Expr, used map[string]bool) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+}
+
+// importName guesses the package name of an import path: its last element,
+// skipping a /vN major version suffix
+func importName(importPath string) string {
+	name := path.Base(importPath)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(importPath))
+	}
+	return strings.TrimPrefix(name, "go-")
+}
+
+func exprString(fset *token.FileSet, node ast.Node) string {
+	if node == nil || node == (*ast.Ident)(nil) {
+		return ""
+	}
+	var b bytes.Buffer
+	if err := format.Node(&b, fset, node); err != nil {
+		return ""
+	}
+	return b.String()
+}
@@ -0,0 +1,40 @@
+// Package users is the package gotrace-gen generates tests for in the golden test
+package users
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// User is a stored user
+type User struct {
+	ID    int      `json:"id"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// ErrNotFound is returned for unknown IDs
+var ErrNotFound = errors.New("user not found")
+
+var stored = map[int]*User{
+	1: {ID: 1, Name: "Ada", Roles: []string{"admin"}},
+	2: {ID: 2, Name: "Grace"},
+}
+
+// UserService looks users up
+type UserService struct{}
+
+// GetUser returns the user with the given ID
+func (s *UserService) GetUser(ctx context.Context, id int) (*User, error) {
+	user, ok := stored[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return user, nil
+}
+
+// Greeting addresses a user by name and titles
+func Greeting(name string, titles ...string) string {
+	return strings.TrimSpace(strings.Join(titles, " ") + " " + name)
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+func runTests(args []string) error {
+	fs := flag.NewFlagSet("tests", flag.ContinueOnError)
+	from := fs.String("from", "", "Recording written by devtrace.StartCallRecording")
+	function := fs.String("func", "", "Function to generate tests for: Func or Type.Method")
+	src := fs.String("src", ".", "Directory of the package declaring the function")
+	out := fs.String("o", "", "Output file (default: stdout)")
+	max := fs.Int("max", 50, "Maximum number of test cases (0 for all)")
+	keepDups := fs.Bool("keep-duplicates", false, "Keep calls whose arguments repeat an earlier call")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *function == "" {
+		return fmt.Errorf("usage: gotrace-gen tests -from calls.jsonl -func Type.Method [-src dir] [-o file]")
+	}
+
+	decl, err := findFuncDecl(*src, *function)
+	if err != nil {
+		return err
+	}
+	calls, err := devtrace.ReadCallRecording(*from)
+	if err != nil {
+		return err
+	}
+
+	cases, skipped := selectCases(calls, decl, *keepDups, *max)
+	if len(cases) == 0 {
+		return fmt.Errorf("%s has no replayable calls of %s (%d skipped)", *from, *function, skipped)
+	}
+	code, err := generateTests(decl, cases, *from)
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d calls that panicked, did not match the declaration or had arguments that were not recorded\n", skipped)
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	return os.WriteFile(*out, code, 0o644)
+}
+
+// recordedName turns a runtime function name such as
+// github.com/acme/app/users.(*UserService).GetUser-fm into UserService.GetUser
+func recordedName(function string) string {
+	if i := strings.LastIndex(function, "/"); i >= 0 {
+		function = function[i+1:]
+	}
+	if i := strings.Index(function, "."); i >= 0 {
+		function = function[i+1:]
+	}
+	function = strings.TrimSuffix(function, "-fm")
+	return strings.NewReplacer("(*", "", "(", "", ")", "").Replace(function)
+}
+
+// selectCases keeps the calls of decl that can become test cases, without
+// repeated argument lists unless keepDups is set
+func selectCases(calls []devtrace.RecordedCall, decl *funcDecl, keepDups bool, max int) (cases []devtrace.RecordedCall, skipped int) {
+	seen := make(map[string]bool)
+	for _, call := range calls {
+		if recordedName(call.Function) != decl.name {
+			continue
+		}
+		if call.Panic != "" || len(call.Unencodable) > 0 || !argsFit(call, decl) || len(call.Results) != len(decl.results) {
+			skipped++
+			continue
+		}
+		key := string(joinRaw(call.Args))
+		if seen[key] && !keepDups {
+			continue
+		}
+		seen[key] = true
+		if max > 0 && len(cases) == max {
+			break
+		}
+		cases = append(cases, call)
+	}
+	return cases, skipped
+}
+
+func argsFit(call devtrace.RecordedCall, decl *funcDecl) bool {
+	if decl.variadic {
+		return len(call.Args) >= len(decl.params)-1
+	}
+	return len(call.Args) == len(decl.params)
+}
+
+func joinRaw(values []json.RawMessage) []byte {
+	parts := make([][]byte, len(values))
+	for i, v := range values {
+		parts[i] = v
+	}
+	return append(append([]byte("["), bytes.Join(parts, []byte(","))...), ']')
+}
+
+// column is one field of the generated test table
+type column struct {
+	field string
+	typ   string
+}
+
+func generateTests(decl *funcDecl, cases []devtrace.RecordedCall, from string) ([]byte, error) {
+	base := strings.ReplaceAll(decl.name, ".", "")
+	testName := "Test" + strings.ReplaceAll(decl.name, ".", "_") + "_Recorded"
+	decodeFunc := "decode" + base + "Recorded"
+	sameFunc := "same" + base + "JSON"
+
+	taken := map[string]bool{"name": true}
+	var params []column
+	for i, p := range decl.params {
+		if p.typ == "context.Context" {
+			params = append(params, column{})
+			continue
+		}
+		field := p.name
+		if field == "" || field == "_" {
+			field = fmt.Sprintf("arg%d", i)
+		}
+		if taken[field] || strings.HasPrefix(field, "want") {
+			field += "Arg"
+		}
+		taken[field] = true
+		params = append(params, column{field: field, typ: p.typ})
+	}
+
+	hasErr := len(decl.results) > 0 && decl.results[len(decl.results)-1].typ == "error"
+	values := decl.results
+	if hasErr {
+		values = values[:len(values)-1]
+	}
+	wants := make([]column, len(values))
+	for i, r := range values {
+		wants[i] = column{field: "want", typ: r.typ}
+		if i > 0 {
+			wants[i].field = fmt.Sprintf("want%d", i)
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "func %s(t *testing.T) {\n", testName)
+	fmt.Fprintf(&b, "tests := []struct {\nname string\n")
+	for _, p := range params {
+		if p.field != "" {
+			fmt.Fprintf(&b, "%s %s\n", p.field, p.typ)
+		}
+	}
+	for _, w := range wants {
+		fmt.Fprintf(&b, "%s %s\n", w.field, w.typ)
+	}
+	if hasErr {
+		fmt.Fprintf(&b, "wantErr string\n")
+	}
+	fmt.Fprintf(&b, "}{\n")
+
+	for _, call := range cases {
+		fmt.Fprintf(&b, "{name: %s", strconv.Quote(caseName(decl, call)))
+		for i, p := range params {
+			if p.field == "" {
+				continue
+			}
+			raw := json.RawMessage("null")
+			switch {
+			case decl.variadic && i == len(params)-1:
+				raw = joinRaw(call.Args[i:])
+			case i < len(call.Args):
+				raw = call.Args[i]
+			}
+			fmt.Fprintf(&b, ", %s: %s", p.field, goValue(p.typ, raw, decodeFunc))
+		}
+		if hasErr && call.Error != "" {
+			fmt.Fprintf(&b, ", wantErr: %s},\n", strconv.Quote(call.Error))
+			continue
+		}
+		for i, w := range wants {
+			fmt.Fprintf(&b, ", %s: %s", w.field, goValue(w.typ, call.Results[i], decodeFunc))
+		}
+		fmt.Fprintf(&b, "},\n")
+	}
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "for _, tt := range tests {\nt.Run(tt.name, func(t *testing.T) {\n")
+	callee := decl.name
+	if decl.recvType != "" {
+		typ := strings.TrimPrefix(decl.recvType, "*")
+		if strings.HasPrefix(decl.recvType, "*") {
+			fmt.Fprintf(&b, "%s := &%s{} // set up the dependencies the recorded calls ran with\n", decl.recvName, typ)
+		} else {
+			fmt.Fprintf(&b, "var %s %s // set up the dependencies the recorded calls ran with\n", decl.recvName, typ)
+		}
+		callee = decl.recvName + "." + decl.name[strings.LastIndex(decl.name, ".")+1:]
+	}
+
+	var gots []string
+	for i := range wants {
+		gots = append(gots, fmt.Sprintf("got%s", strings.TrimPrefix(wants[i].field, "want")))
+	}
+	if hasErr {
+		gots = append(gots, "err")
+	}
+	var callArgs []string
+	for i, p := range params {
+		switch {
+		case p.field == "":
+			callArgs = append(callArgs, "context.Background()")
+		case decl.variadic && i == len(params)-1:
+			callArgs = append(callArgs, "tt."+p.field+"...")
+		default:
+			callArgs = append(callArgs, "tt."+p.field)
+		}
+	}
+	callExpr := fmt.Sprintf("%s(%s)", callee, strings.Join(callArgs, ", "))
+	if len(gots) > 0 {
+		fmt.Fprintf(&b, "%s := %s\n", strings.Join(gots, ", "), callExpr)
+	} else {
+		fmt.Fprintf(&b, "%s\n", callExpr)
+	}
+
+	if hasErr {
+		fmt.Fprintf(&b, "if tt.wantErr != \"\" {\n")
+		fmt.Fprintf(&b, "if err == nil || err.Error() != tt.wantErr {\nt.Fatalf(\"error = %%v, want %%q\", err, tt.wantErr)\n}\nreturn\n}\n")
+		fmt.Fprintf(&b, "if err != nil {\nt.Fatalf(\"unexpected error: %%v\", err)\n}\n")
+	}
+	for i, w := range wants {
+		fmt.Fprintf(&b, "if !%s(%s, tt.%s) {\nt.Errorf(\"%s = %%+v, want %%+v\", %s, tt.%s)\n}\n", sameFunc, gots[i], w.field, gots[i], gots[i], w.field)
+	}
+	fmt.Fprintf(&b, "})\n}\n}\n")
+
+	decodes := bytes.Contains(b.Bytes(), []byte(decodeFunc+"["))
+	if decodes {
+		fmt.Fprintf(&b, "\n// %s decodes a value as it was recorded\n", decodeFunc)
+		fmt.Fprintf(&b, "func %s[T any](data string) T {\nvar v T\nif err := json.Unmarshal([]byte(data), &v); err != nil {\npanic(err)\n}\nreturn v\n}\n", decodeFunc)
+	}
+	if len(wants) > 0 {
+		fmt.Fprintf(&b, "\n// %s compares values the way they were recorded: as JSON\n", sameFunc)
+		fmt.Fprintf(&b, "func %s(got, want interface{}) bool {\ng, err1 := json.Marshal(got)\nw, err2 := json.Marshal(want)\nreturn err1 == nil && err2 == nil && string(g) == string(w)\n}\n", sameFunc)
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "// Generated by gotrace-gen tests from %s: recorded calls of %s.\n", from, decl.name)
+	fmt.Fprintf(&file, "// The expected values are what the calls returned when they were recorded.\n\n")
+	fmt.Fprintf(&file, "package %s\n\n", decl.pkg)
+	writeImports(&file, decl, params, decodes || len(wants) > 0)
+	file.Write(b.Bytes())
+
+	code, err := format.Source(file.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not parse: %v\n%s", err, file.Bytes())
+	}
+	return code, nil
+}
+
+func writeImports(b *bytes.Buffer, decl *funcDecl, params []column, usesJSON bool) {
+	specs := []string{`"testing"`}
+	if usesJSON {
+		specs = append(specs, `"encoding/json"`)
+	}
+	for _, p := range params {
+		if p.field == "" {
+			specs = append(specs, `"context"`)
+			break
+		}
+	}
+	seen := make(map[string]bool)
+	fmt.Fprintf(b, "import (\n")
+	for _, spec := range append(specs, decl.imports...) {
+		if !seen[spec] {
+			seen[spec] = true
+			fmt.Fprintf(b, "%s\n", spec)
+		}
+	}
+	fmt.Fprintf(b, ")\n\n")
+}
+
+var basicTypes = map[string]bool{
+	"string": true, "bool": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"float32": true, "float64": true, "byte": true, "rune": true,
+}
+
+// goValue writes a recorded value of type typ as Go: a literal for basic
+// types, otherwise a call decoding the recorded JSON
+func goValue(typ string, raw json.RawMessage, decodeFunc string) string {
+	if basicTypes[typ] {
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err == nil {
+			switch v := v.(type) {
+			case string:
+				if typ == "string" {
+					return strconv.Quote(v)
+				}
+			case bool:
+				if typ == "bool" {
+					return strconv.FormatBool(v)
+				}
+			case float64:
+				if typ != "string" && typ != "bool" {
+					return string(raw)
+				}
+			}
+		}
+	}
+	data := string(raw)
+	if strings.Contains(data, "`") {
+		return fmt.Sprintf("%s[%s](%s)", decodeFunc, typ, strconv.Quote(data))
+	}
+	return fmt.Sprintf("%s[%s](`%s`)", decodeFunc, typ, data)
+}
+
+// caseName names a test case after the call, e.g. GetUser(42), shortened to
+// fit on a line
+func caseName(decl *funcDecl, call devtrace.RecordedCall) string {
+	var args []string
+	for i, arg := range call.Args {
+		if i < len(decl.params) && decl.params[i].typ == "context.Context" {
+			continue
+		}
+		args = append(args, string(arg))
+	}
+	name := decl.name[strings.LastIndex(decl.name, ".")+1:] + "(" + strings.Join(args, ", ") + ")"
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 60 {
+		name = string(runes[:57]) + "..."
+	}
+	return name
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The fixture recording holds calls of the testdata/users package: GetUser
+// found, not found, repeated and panicking, and Greeting with and without
+// variadic titles
+const recording = "testdata/calls.jsonl"
+
+var generatedCases = []struct {
+	function string
+	golden   string
+}{
+	{function: "UserService.GetUser", golden: "getuser_test.golden"},
+	{function: "Greeting", golden: "greeting_test.golden"},
+}
+
+func TestGenerateTestsGolden(t *testing.T) {
+	update := os.Getenv("DEVTRACE_UPDATE_GOLDEN") != ""
+	src, err := os.ReadFile("testdata/users/users.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	module := t.TempDir()
+	files := map[string]string{
+		"go.mod":   "module example.com/users\n\ngo 1.21\n",
+		"users.go": string(src),
+	}
+
+	for _, tc := range generatedCases {
+		out := filepath.Join(t.TempDir(), "generated_test.go")
+		if err := runTests([]string{"-from", recording, "-func", tc.function, "-src", "testdata/users", "-o", out}); err != nil {
+			t.Fatalf("%s: %v", tc.function, err)
+		}
+		code, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join("testdata", tc.golden)
+		if update {
+			if err := os.WriteFile(path, code, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(code) != string(want) {
+			t.Errorf("%s: output differs from %s:\n%s", tc.function, path, code)
+		}
+		files[strings.TrimSuffix(tc.golden, ".golden")+".go"] = string(code)
+	}
+
+	if testing.Short() {
+		t.Skip("runs the generated tests")
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(module, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out, err := goTest(module); err != nil {
+		t.Fatalf("the generated tests fail against the code they were recorded from: %v\n%s", err, out)
+	}
+
+	// They check what the calls returned: a changed result fails them
+	changed := strings.Replace(files["users.go"], `Name: "Ada"`, `Name: "Ada Lovelace"`, 1)
+	if err := os.WriteFile(filepath.Join(module, "users.go"), []byte(changed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := goTest(module)
+	if err == nil || !strings.Contains(out, "TestUserService_GetUser_Recorded/GetUser(1)") {
+		t.Fatalf("expected the GetUser(1) case to fail once Ada's name changed, got %v:\n%s", err, out)
+	}
+}
+
+func TestGenerateTestsRejectsBadInput(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"-func", "Greeting"}, want: "usage: gotrace-gen tests"},
+		{args: []string{"-from", recording, "-func", "UserService.Delete", "-src", "testdata/users"}, want: "no declaration of UserService.Delete"},
+		{args: []string{"-from", "testdata/missing.jsonl", "-func", "Greeting", "-src", "testdata/users"}, want: "missing.jsonl"},
+	} {
+		if err := runTests(tt.args); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: expected an error containing %q, got %v", tt.args, tt.want, err)
+		}
+	}
+}
+
+func goTest(dir string) (string, error) {
+	cmd := exec.Command("go", "test", "-count=1", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
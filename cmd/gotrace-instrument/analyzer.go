@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dave/dst/decorator"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer reports functions that -add-trace would instrument but that carry no
+// devtrace preamble, each with a suggested fix adding it. go vet runs it when
+// gotrace-instrument is passed as the vet tool:
+//
+//	go vet -vettool=$(which gotrace-instrument) -gotrace.min-lines=20 ./...
+//
+// which lets CI list the uninstrumented functions worth tracing, and editors
+// that show suggested fixes offer to instrument a function in place.
+var Analyzer = &analysis.Analyzer{
+	Name: "gotrace",
+	Doc:  "report functions not instrumented with gotrace, with a fix instrumenting them",
+	Run:  runAnalyzer,
+}
+
+var analyzerFlags struct {
+	includeFunc  string
+	excludeFunc  string
+	exportedOnly bool
+	minLines     int
+	modulePath   string
+}
+
+func init() {
+	Analyzer.Flags.StringVar(&analyzerFlags.includeFunc, "include-func", "", "only report functions whose name (Func or Type.Method) matches this regexp")
+	Analyzer.Flags.StringVar(&analyzerFlags.excludeFunc, "exclude-func", "", "don't report functions whose name (Func or Type.Method) matches this regexp")
+	Analyzer.Flags.BoolVar(&analyzerFlags.exportedOnly, "exported-only", false, "only report exported functions and methods")
+	Analyzer.Flags.IntVar(&analyzerFlags.minLines, "min-lines", 0, "only report functions whose body spans at least this many lines")
+	Analyzer.Flags.StringVar(&analyzerFlags.modulePath, "module-path", "", "import path of the devtrace package (default: resolved from go.mod)")
+}
+
+// invokedAsVetTool tells whether go vet started the binary: it asks for the
+// tool's version and flags, then passes a single .cfg file per package
+func invokedAsVetTool(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "-V=full", "-flags":
+		return true
+	}
+	return strings.HasSuffix(args[len(args)-1], ".cfg")
+}
+
+func runAnalyzer(pass *analysis.Pass) (interface{}, error) {
+	includeFunc, err := compileFuncFilter("include-func", analyzerFlags.includeFunc)
+	if err != nil {
+		return nil, err
+	}
+	excludeFunc, err := compileFuncFilter("exclude-func", analyzerFlags.excludeFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range pass.Files {
+		filename := pass.Fset.File(file.Pos()).Name()
+		if strings.HasSuffix(filename, "_test.go") || ast.IsGenerated(file) {
+			continue
+		}
+
+		importPath := analyzerFlags.modulePath
+		if importPath == "" {
+			importPath = ResolveImportPath(filepath.Dir(filename))
+		}
+		if pass.Pkg.Path() == importPath {
+			return nil, nil // devtrace itself is never instrumented
+		}
+
+		if err := analyzeFile(pass, file, filename, &ASTTransformer{
+			FileSet:      pass.Fset,
+			AddTrace:     true,
+			IncludeFunc:  includeFunc,
+			ExcludeFunc:  excludeFunc,
+			ExportedOnly: analyzerFlags.exportedOnly,
+			MinLines:     analyzerFlags.minLines,
+			ImportPath:   importPath,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// analyzeFile instruments a decorated copy of file and reports every function
+// the transformer added a preamble to. The fix for a function is the part of
+// the instrumented file's diff inside that function, plus the import changes.
+func analyzeFile(pass *analysis.Pass, file *ast.File, filename string, transformer *ASTTransformer) error {
+	dec := decorator.NewDecorator(pass.Fset)
+	decorated, err := dec.DecorateFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to decorate %s: %v", filename, err)
+	}
+	transformer.Decorator = dec
+	if !transformer.Transform(decorated) || len(transformer.added) == 0 {
+		return nil
+	}
+
+	original, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	instrumented, err := transformer.Render(decorated)
+	if err != nil {
+		return fmt.Errorf("failed to render %s: %v", filename, err)
+	}
+	tokFile := pass.Fset.File(file.Pos())
+	hunks := lineEdits(tokFile, original, instrumented)
+
+	// Everything changed above the first declaration past the imports belongs
+	// to the import block
+	importEnd := tokFile.LineCount() + 1
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); !ok || gen.Tok != token.IMPORT {
+			importEnd = tokFile.Line(decl.Pos())
+			break
+		}
+	}
+	var importEdits []analysis.TextEdit
+	for _, hunk := range hunks {
+		if hunk.line < importEnd {
+			importEdits = append(importEdits, hunk.edit)
+		}
+	}
+
+	for _, fn := range transformer.added {
+		node, ok := dec.Ast.Nodes[fn]
+		if !ok {
+			continue
+		}
+		decl := node.(*ast.FuncDecl)
+		first, last := tokFile.Line(decl.Pos()), tokFile.Line(decl.End())
+
+		edits := append([]analysis.TextEdit(nil), importEdits...)
+		for _, hunk := range hunks {
+			if hunk.line >= first && hunk.line <= last {
+				edits = append(edits, hunk.edit)
+			}
+		}
+
+		name := transformer.funcDeclName(fn)
+		pass.Report(analysis.Diagnostic{
+			Pos:     decl.Name.Pos(),
+			End:     decl.Name.End(),
+			Message: fmt.Sprintf("%s is not instrumented with gotrace", name),
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   fmt.Sprintf("Instrument %s with gotrace", name),
+				TextEdits: edits,
+			}},
+		})
+	}
+	return nil
+}
+
+// lineEdit is one change of a line diff, anchored at the original line it starts on
+type lineEdit struct {
+	line int
+	edit analysis.TextEdit
+}
+
+// lineEdits turns the line diff from original to instrumented into text edits
+// against tokFile
+func lineEdits(tokFile *token.File, original, instrumented []byte) []lineEdit {
+	lineStart := func(line int) token.Pos {
+		if line > tokFile.LineCount() {
+			return tokFile.Pos(tokFile.Size())
+		}
+		return tokFile.LineStart(line)
+	}
+
+	var edits []lineEdit
+	var current *lineEdit
+	line := 1
+	for _, l := range diffLines(splitLines(string(original)), splitLines(string(instrumented))) {
+		if l.op == diffEqual {
+			current = nil
+			line++
+			continue
+		}
+		if current == nil {
+			edits = append(edits, lineEdit{line: line, edit: analysis.TextEdit{Pos: lineStart(line), End: lineStart(line)}})
+			current = &edits[len(edits)-1]
+		}
+		if l.op == diffDelete {
+			line++
+			current.edit.End = lineStart(line)
+		} else {
+			current.edit.NewText = append(current.edit.NewText, l.text+"\n"...)
+		}
+	}
+	return edits
+}
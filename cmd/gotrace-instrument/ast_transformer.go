@@ -3,33 +3,76 @@ package main
 import (
 	"bytes"
 	"fmt"
-	"go/ast"
 	"go/format"
 	"go/token"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
 )
 
+// ASTTransformer rewrites a decorated syntax tree (github.com/dave/dst), so the
+// comments and blank lines of instrumented files stay where they were
 type ASTTransformer struct {
-	FileSet     *token.FileSet
-	AddTrace    bool
-	AddLogging  bool
-	Verbose     bool
-	modified    bool
-	hasDevtrace bool
-	packageName string
-	fileName    string
+	FileSet         *token.FileSet
+	Decorator       *decorator.Decorator // decorator the file was produced by; maps nodes back to positions
+	AddTrace        bool
+	AddLogging      bool
+	TraceGoroutines bool
+	CaptureResults  bool           // record return values on the frame, naming unnamed results
+	CapturePanics   bool           // mark the frame as panicked before the panic propagates
+	IncludeFunc     *regexp.Regexp // only instrument functions whose name matches
+	ExcludeFunc     *regexp.Regexp // never instrument functions whose name matches
+	ExportedOnly    bool           // only instrument exported functions and methods
+	MinLines        int            // only instrument functions with at least this many body lines
+	Closures        bool           // also instrument function literals, named like GetUser.func1
+	ConvertFmt      bool           // turn fmt.Println/Printf debug output into EnhancedLogger calls
+	FmtExclude      *regexp.Regexp // functions whose fmt output is user-facing and left alone
+	Verbose         bool
+	ImportPath      string // import path of the devtrace package; DefaultImportPath if empty
+	modified        bool
+	instrumented    int             // functions and closures given a preamble by the last Transform
+	added           []*dst.FuncDecl // functions the last Transform instrumented for the first time
+	hasDevtrace     bool
+	packageName     string
+	fileName        string
+	devtraceName    string // local name the devtrace package is referred to by in the file
+
+	contextName  string     // local name of the "context" import, "" if not imported
+	fmtName      string     // local name of the "fmt" import, "" if not imported
+	fmtConverted bool       // a fmt call was converted, so the import may have become unused
+	logName      string     // local name of the "log" import, "" if not imported
+	logConverted bool       // a log call was rewritten, so the import may have become unused
+	needsContext bool       // context.Background() was injected and "context" must be imported
+	nodes        []dst.Node // path from the file to the node being visited
+
+	closureNames map[*dst.FuncLit]string // frame names given to the function literals seen so far
+	closureCount map[dst.Node]int        // function literals numbered so far per enclosing function
 }
 
-func (t *ASTTransformer) Transform(file *ast.File) bool {
+func (t *ASTTransformer) Transform(file *dst.File) bool {
 	t.modified = false
+	t.instrumented = 0
+	t.added = t.added[:0]
 	t.hasDevtrace = false
+	t.devtraceName = "devtrace"
 	t.packageName = file.Name.Name
+	t.contextName = ""
+	t.fmtName = ""
+	t.fmtConverted = false
+	t.logName = ""
+	t.logConverted = false
+	t.needsContext = false
+	t.nodes = t.nodes[:0]
+	t.closureNames = make(map[*dst.FuncLit]string)
+	t.closureCount = make(map[dst.Node]int)
 
-	if pos := t.FileSet.Position(file.Pos()); pos.IsValid() {
+	if pos := t.position(file); pos.IsValid() {
 		t.fileName = filepath.Base(pos.Filename)
 	}
 
@@ -37,39 +80,138 @@ func (t *ASTTransformer) Transform(file *ast.File) bool {
 	t.checkExistingImports(file)
 
 	// Visit all nodes in the AST
-	ast.Inspect(file, t.visit)
+	dst.Inspect(file, t.visit)
 
 	// Add devtrace import if we made modifications and it's not already imported
 	if t.modified && !t.hasDevtrace {
 		t.addDevtraceImport(file)
 	}
+	if t.fmtConverted {
+		t.removeUnusedImport(file, t.fmtName, "fmt")
+	}
+	if t.logConverted {
+		t.removeUnusedImport(file, t.logName, "log")
+	}
+	if t.needsContext && t.contextName == "" {
+		t.addImport(file, "", "context")
+		t.contextName = "context"
+	}
 
 	return t.modified
 }
 
-func (t *ASTTransformer) checkExistingImports(file *ast.File) {
+// position returns the source position of a node that came from the parsed file
+func (t *ASTTransformer) position(node dst.Node) token.Position {
+	if t.Decorator == nil {
+		return token.Position{}
+	}
+	if original, ok := t.Decorator.Ast.Nodes[node]; ok {
+		return t.FileSet.Position(original.Pos())
+	}
+	return token.Position{}
+}
+
+func (t *ASTTransformer) importPath() string {
+	if t.ImportPath != "" {
+		return t.ImportPath
+	}
+	return DefaultImportPath
+}
+
+func (t *ASTTransformer) checkExistingImports(file *dst.File) {
+	taken := make(map[string]bool)
 	for _, imp := range file.Imports {
-		if imp.Path.Value == `"github.com/skulidropek/gotrace"` {
-			t.hasDevtrace = true
-			break
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
 		}
+
+		name := importName(imp, path)
+		if path == t.importPath() && imp.Name == nil {
+			name = "devtrace" // the package clause name, whatever the path is
+		}
+		taken[name] = true
+
+		switch path {
+		case t.importPath():
+			// Reuse an existing (possibly aliased) import; blank and dot imports can't be referenced
+			if name != "_" && name != "." {
+				t.hasDevtrace = true
+				t.devtraceName = name
+			}
+		case "context":
+			t.contextName = "context"
+			if imp.Name != nil {
+				t.contextName = imp.Name.Name
+			}
+			if t.contextName == "_" || t.contextName == "." {
+				t.contextName = ""
+			}
+		case "fmt":
+			if name != "_" && name != "." {
+				t.fmtName = name
+			}
+		case "log":
+			if name != "_" && name != "." {
+				t.logName = name
+			}
+		}
+	}
+
+	// Pick a free name for the import we may add
+	if !t.hasDevtrace {
+		for i := 2; taken[t.devtraceName]; i++ {
+			t.devtraceName = fmt.Sprintf("devtrace%d", i)
+		}
+	}
+}
+
+// importName returns the name an import is referred to by: its alias, or the last
+// path element with any major version suffix stripped
+func importName(imp *dst.ImportSpec, path string) string {
+	if imp.Name != nil {
+		return imp.Name.Name
 	}
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && isMajorVersion(name) {
+		name = parts[len(parts)-2]
+	}
+	return name
+}
+
+func isMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(elem[1:])
+	return err == nil
+}
+
+func (t *ASTTransformer) addDevtraceImport(file *dst.File) {
+	t.addImport(file, t.devtraceName, t.importPath())
+}
+
+func (t *ASTTransformer) devtraceSelector(name string) *dst.SelectorExpr {
+	return &dst.SelectorExpr{X: dst.NewIdent(t.devtraceName), Sel: dst.NewIdent(name)}
 }
 
-func (t *ASTTransformer) addDevtraceImport(file *ast.File) {
+func (t *ASTTransformer) addImport(file *dst.File, name, path string) {
 	// Create new import spec
-	importSpec := &ast.ImportSpec{
-		Name: ast.NewIdent("devtrace"),
-		Path: &ast.BasicLit{
+	importSpec := &dst.ImportSpec{
+		Path: &dst.BasicLit{
 			Kind:  token.STRING,
-			Value: `"github.com/skulidropek/gotrace"`,
+			Value: strconv.Quote(path),
 		},
 	}
+	if name != "" {
+		importSpec.Name = dst.NewIdent(name)
+	}
 
 	// Find or create import declaration
-	var importDecl *ast.GenDecl
+	var importDecl *dst.GenDecl
 	for _, decl := range file.Decls {
-		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+		if genDecl, ok := decl.(*dst.GenDecl); ok && genDecl.Tok == token.IMPORT {
 			importDecl = genDecl
 			break
 		}
@@ -77,41 +219,96 @@ func (t *ASTTransformer) addDevtraceImport(file *ast.File) {
 
 	if importDecl == nil {
 		// Create new import declaration
-		importDecl = &ast.GenDecl{
+		importDecl = &dst.GenDecl{
 			Tok:   token.IMPORT,
-			Specs: []ast.Spec{importSpec},
+			Specs: []dst.Spec{importSpec},
 		}
+		importDecl.Decs.Before = dst.EmptyLine
+		importDecl.Decs.After = dst.EmptyLine
 
 		// Insert at the beginning of declarations
-		newDecls := make([]ast.Decl, len(file.Decls)+1)
+		newDecls := make([]dst.Decl, len(file.Decls)+1)
 		newDecls[0] = importDecl
 		copy(newDecls[1:], file.Decls)
 		file.Decls = newDecls
 	} else {
-		// Add to existing import declaration
-		importDecl.Specs = append(importDecl.Specs, importSpec)
+		// Add to existing import declaration, keeping goimports-style groups:
+		// standard library imports join the first group, others get a group of their own
+		importDecl.Lparen = true
+		importSpec.Decs.Before = dst.NewLine
+		if isStdlibImport(path) {
+			importDecl.Specs = append([]dst.Spec{importSpec}, importDecl.Specs...)
+			if len(importDecl.Specs) > 1 && importDecl.Specs[1].Decorations().Before == dst.None {
+				importDecl.Specs[1].Decorations().Before = dst.NewLine
+			}
+		} else {
+			last := importDecl.Specs[len(importDecl.Specs)-1].(*dst.ImportSpec)
+			if lastPath, err := strconv.Unquote(last.Path.Value); err != nil || isStdlibImport(lastPath) {
+				importSpec.Decs.Before = dst.EmptyLine
+			}
+			importDecl.Specs = append(importDecl.Specs, importSpec)
+		}
 	}
+	file.Imports = append(file.Imports, importSpec)
 
 	if t.Verbose {
-		log.Printf("Added devtrace import to %s", t.fileName)
+		log.Printf("Added %s import to %s", path, t.fileName)
 	}
 }
 
-func (t *ASTTransformer) visit(node ast.Node) bool {
+// isStdlibImport uses the goimports rule: standard library paths have no dot in
+// their first element
+func isStdlibImport(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+func (t *ASTTransformer) visit(node dst.Node) bool {
+	// dst.Inspect calls visit(nil) after a node's children, which lets us keep the path
+	if node == nil {
+		t.nodes = t.nodes[:len(t.nodes)-1]
+		return true
+	}
+	t.nodes = append(t.nodes, node)
+
 	switch n := node.(type) {
-	case *ast.FuncDecl:
+	case *dst.FuncDecl:
 		if t.AddTrace {
 			t.instrumentFunction(n)
 		}
-	case *ast.CallExpr:
+	case *dst.FuncLit:
+		if t.AddTrace {
+			t.instrumentClosure(n)
+		}
+	case *dst.CallExpr:
 		if t.AddLogging {
 			t.instrumentLogCall(n)
 		}
+		if t.ConvertFmt {
+			t.convertFmtCall(n)
+		}
+	case *dst.BlockStmt:
+		if t.TraceGoroutines {
+			t.rewriteGoStmts(n.List)
+		}
+	case *dst.CaseClause:
+		if t.TraceGoroutines {
+			t.rewriteGoStmts(n.Body)
+		}
+	case *dst.CommClause:
+		if t.TraceGoroutines {
+			t.rewriteGoStmts(n.Body)
+		}
 	}
 	return true
 }
 
-func (t *ASTTransformer) instrumentFunction(fn *ast.FuncDecl) {
+func (t *ASTTransformer) instrumentFunction(fn *dst.FuncDecl) {
+	if fn.Name.Name == "init" && fn.Recv == nil {
+		t.instrumentInit(fn)
+		return
+	}
+
 	// Skip functions that are already instrumented or shouldn't be instrumented
 	if t.shouldSkipFunction(fn) {
 		return
@@ -122,6 +319,38 @@ func (t *ASTTransformer) instrumentFunction(fn *ast.FuncDecl) {
 		return
 	}
 
+	// A function instrumented by an earlier run gets a fresh preamble, so args
+	// follow code changes made since. It keeps its line: positions in an
+	// instrumented file are shifted by the instrumentation above them.
+	previousLine, refreshed := t.stripInstrumentation(fn.Body, 0)
+
+	functionName := t.funcDeclName(fn)
+	if len(fn.Body.List) == 0 || !t.matchesFilters(functionName, fn.Name.IsExported(), fn.Body, 0) {
+		t.removedInstrumentation(refreshed, "function", functionName)
+		return
+	}
+
+	line := t.position(fn).Line
+	if previousLine > 0 {
+		line = previousLine
+	}
+
+	t.instrumentBody(functionName, t.buildSignature(fn.Name.Name, fn.Type), line, fn.Type, fn.Body, 0)
+	if !refreshed {
+		t.added = append(t.added, fn)
+	}
+
+	if t.Verbose {
+		verb := "Instrumented"
+		if refreshed {
+			verb = "Refreshed"
+		}
+		log.Printf("%s function: %s in %s:%d", verb, functionName, t.fileName, line)
+	}
+}
+
+// funcDeclName is the frame name of fn: Func, or Type.Method for methods
+func (t *ASTTransformer) funcDeclName(fn *dst.FuncDecl) string {
 	functionName := fn.Name.Name
 	if fn.Recv != nil && len(fn.Recv.List) > 0 {
 		// Method - include receiver type
@@ -130,48 +359,296 @@ func (t *ASTTransformer) instrumentFunction(fn *ast.FuncDecl) {
 			functionName = typeName + "." + functionName
 		}
 	}
+	return functionName
+}
 
-	// Get position information
-	pos := t.FileSet.Position(fn.Pos())
+// removedInstrumentation notes a function that was instrumented before but is
+// filtered out now, and so lost its preamble
+func (t *ASTTransformer) removedInstrumentation(refreshed bool, kind, functionName string) {
+	if !refreshed {
+		return
+	}
+	t.modified = true
+	if t.Verbose {
+		log.Printf("Removed instrumentation from %s: %s in %s", kind, functionName, t.fileName)
+	}
+}
 
+// instrumentBody inserts the preamble into body at index start: the enter call,
+// the deferred leave and, with CapturePanics, the deferred panic capture
+func (t *ASTTransformer) instrumentBody(functionName, signature string, line int, fnType *dst.FuncType, body *dst.BlockStmt, start int) {
 	// Create arguments map for tracing
-	argsMap := t.createArgsMapForFunction(fn)
-
-	signature := t.buildSignatureForFunction(fn)
+	argsMap := t.createArgsMap(fnType)
 
-	// Create the frame creation statement
-	frameStmt := t.createFrameStatement(functionName, signature, pos.Line, argsMap)
+	// Create the frame creation statement; functions with a ctx parameter
+	// attach the frame to that ctx's trace context
+	ctxName := t.contextParam(fnType)
+	frameStmt := t.createFrameStatement(functionName, signature, line, argsMap, ctxName)
 
 	// Create defer statement for leaving the trace
-	deferStmt := &ast.DeferStmt{
-		Call: &ast.CallExpr{
-			Fun: &ast.SelectorExpr{
-				X:   ast.NewIdent("devtrace"),
-				Sel: ast.NewIdent("GlobalLeave"),
-			},
-		},
-	}
+	deferStmt := t.createLeaveStatement(fnType, ctxName)
 
 	// Add statements to the beginning of function body
-	newStmts := make([]ast.Stmt, 0, len(fn.Body.List)+2)
+	newStmts := make([]dst.Stmt, 0, len(body.List)+3)
+	newStmts = append(newStmts, body.List[:start]...)
 	newStmts = append(newStmts, frameStmt, deferStmt)
-	newStmts = append(newStmts, fn.Body.List...)
-	fn.Body.List = newStmts
+	if t.CapturePanics {
+		// Deferred after the leave so it runs first, while the frame is still current
+		deferStmt = t.createPanicStatement(ctxName)
+		newStmts = append(newStmts, deferStmt)
+	}
+	deferStmt.Decs.After = dst.EmptyLine // keep the instrumentation apart from the original body
+	newStmts = append(newStmts, body.List[start:]...)
+	body.List = newStmts
 
 	t.modified = true
+	t.instrumented++
+}
 
-	if t.Verbose {
-		log.Printf("Instrumented function: %s in %s:%d", functionName, t.fileName, pos.Line)
+// stripInstrumentation removes the preamble an earlier run put into body at
+// index start: the enter call, the deferred leave and the deferred panic
+// capture. It reports whether there was one, and the line its frame was
+// created with (0 if that isn't a literal).
+func (t *ASTTransformer) stripInstrumentation(body *dst.BlockStmt, start int) (int, bool) {
+	stmts := body.List
+	if len(stmts) <= start {
+		return 0, false
+	}
+	enter, ok := stmts[start].(*dst.ExprStmt)
+	if !ok || !t.isDevtraceCall(enter.X, "GlobalEnter", "EnterContext") {
+		return 0, false
+	}
+
+	n := start + 1
+	for n < len(stmts) && n < start+3 {
+		deferStmt, ok := stmts[n].(*dst.DeferStmt)
+		if !ok || !t.isInstrumentationDefer(deferStmt) {
+			break
+		}
+		n++
 	}
+	if n == start+1 {
+		return 0, false // an enter without its leave was written by hand
+	}
+
+	body.List = append(stmts[:start:start], stmts[n:]...)
+	return t.frameLine(enter.X.(*dst.CallExpr)), true
 }
 
-func (t *ASTTransformer) shouldSkipFunction(fn *ast.FuncDecl) bool {
-	name := fn.Name.Name
+// frameLine returns the line literal of the CreateFrame call passed to an
+// enter call built by createFrameStatement, or 0
+func (t *ASTTransformer) frameLine(enter *dst.CallExpr) int {
+	if len(enter.Args) == 0 {
+		return 0
+	}
+	create, ok := enter.Args[len(enter.Args)-1].(*dst.CallExpr)
+	if !ok || !t.isDevtraceCall(create, "CreateFrame") || len(create.Args) < 4 {
+		return 0
+	}
+	lit, ok := create.Args[3].(*dst.BasicLit)
+	if !ok || lit.Kind != token.INT {
+		return 0
+	}
+	line, _ := strconv.Atoi(lit.Value)
+	return line
+}
 
-	// Skip init functions
-	if name == "init" {
+// isInstrumentationDefer matches the deferred statements createLeaveStatement
+// and createPanicStatement build
+func (t *ASTTransformer) isInstrumentationDefer(deferStmt *dst.DeferStmt) bool {
+	if t.isDevtraceCall(deferStmt.Call, "GlobalLeave", "LeaveContext") {
 		return true
 	}
+	lit, ok := deferStmt.Call.Fun.(*dst.FuncLit)
+	if !ok || len(lit.Body.List) != 1 {
+		return false
+	}
+	inner, ok := lit.Body.List[0].(*dst.ExprStmt)
+	return ok && t.isDevtraceCall(inner.X, "GlobalLeaveResults", "LeaveContextResults", "RecordPanic", "RecordPanicContext")
+}
+
+// isDevtraceCall reports whether expr calls one of the named devtrace functions
+func (t *ASTTransformer) isDevtraceCall(expr dst.Expr, names ...string) bool {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok {
+		return false
+	}
+	if ident, ok := sel.X.(*dst.Ident); !ok || ident.Name != t.devtraceName {
+		return false
+	}
+	for _, name := range names {
+		if sel.Sel.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// instrumentInit times a package init function for the startup waterfall:
+//
+//	defer devtrace.TraceInit("pkg (file.go:12)")()
+func (t *ASTTransformer) instrumentInit(fn *dst.FuncDecl) {
+	if fn.Body == nil || len(fn.Body.List) == 0 {
+		return
+	}
+
+	// Skip init functions that are already timed
+	if first, ok := fn.Body.List[0].(*dst.DeferStmt); ok {
+		if inner, ok := first.Call.Fun.(*dst.CallExpr); ok {
+			if sel, ok := inner.Fun.(*dst.SelectorExpr); ok && sel.Sel.Name == "TraceInit" {
+				return
+			}
+		}
+	}
+
+	line := t.position(fn).Line
+	label := fmt.Sprintf("%s (%s:%d)", t.packageName, t.fileName, line)
+	deferStmt := &dst.DeferStmt{Call: &dst.CallExpr{Fun: &dst.CallExpr{
+		Fun:  t.devtraceSelector("TraceInit"),
+		Args: []dst.Expr{&dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(label)}},
+	}}}
+	deferStmt.Decs.After = dst.EmptyLine
+
+	fn.Body.List = append([]dst.Stmt{deferStmt}, fn.Body.List...)
+	t.modified = true
+
+	if t.Verbose {
+		log.Printf("Instrumented init function in %s:%d", t.fileName, line)
+	}
+}
+
+// createLeaveStatement builds the deferred leave call. Without results it is
+//
+//	defer devtrace.GlobalLeave()
+//
+// and with results, which are named first if needed so the deferred call sees
+// the values actually returned,
+//
+//	defer func() { devtrace.GlobalLeaveResults(r0, err) }()
+//
+// The ctx variants LeaveContext and LeaveContextResults are used when ctxName is set.
+func (t *ASTTransformer) createLeaveStatement(fnType *dst.FuncType, ctxName string) *dst.DeferStmt {
+	var results []string
+	if t.CaptureResults {
+		results = nameResults(fnType)
+	}
+
+	leaveName := "GlobalLeave"
+	var args []dst.Expr
+	if ctxName != "" {
+		leaveName = "LeaveContext"
+		args = append(args, dst.NewIdent(ctxName))
+	}
+	if len(results) == 0 {
+		return &dst.DeferStmt{Call: &dst.CallExpr{Fun: t.devtraceSelector(leaveName), Args: args}}
+	}
+
+	for _, name := range results {
+		args = append(args, dst.NewIdent(name))
+	}
+	leave := &dst.ExprStmt{X: &dst.CallExpr{Fun: t.devtraceSelector(leaveName + "Results"), Args: args}}
+	return &dst.DeferStmt{Call: &dst.CallExpr{Fun: &dst.FuncLit{
+		Type: &dst.FuncType{Params: &dst.FieldList{}},
+		Body: &dst.BlockStmt{List: []dst.Stmt{leave}},
+	}}}
+}
+
+// createPanicStatement builds the deferred panic capture. recover has to be
+// called by the deferred function itself, so the helper gets its value and
+// re-panics:
+//
+//	defer func() { devtrace.RecordPanic(recover()) }()
+//
+// RecordPanicContext(ctx, recover()) is used when ctxName is set.
+func (t *ASTTransformer) createPanicStatement(ctxName string) *dst.DeferStmt {
+	name := "RecordPanic"
+	var args []dst.Expr
+	if ctxName != "" {
+		name = "RecordPanicContext"
+		args = append(args, dst.NewIdent(ctxName))
+	}
+	args = append(args, &dst.CallExpr{Fun: dst.NewIdent("recover")})
+
+	record := &dst.ExprStmt{X: &dst.CallExpr{Fun: t.devtraceSelector(name), Args: args}}
+	return &dst.DeferStmt{Call: &dst.CallExpr{Fun: &dst.FuncLit{
+		Type: &dst.FuncType{Params: &dst.FieldList{}},
+		Body: &dst.BlockStmt{List: []dst.Stmt{record}},
+	}}}
+}
+
+// nameResults gives every result of fnType a usable name and returns the names.
+// Unnamed and blank results become __devtraceR<i>; naming results doesn't change
+// what the function returns since every return statement already lists values.
+func nameResults(fnType *dst.FuncType) []string {
+	if fnType.Results == nil || len(fnType.Results.List) == 0 {
+		return nil
+	}
+
+	var names []string
+	index := 0
+	for _, field := range fnType.Results.List {
+		if len(field.Names) == 0 {
+			name := resultVarPf + strconv.Itoa(index)
+			field.Names = []*dst.Ident{dst.NewIdent(name)}
+			names = append(names, name)
+			index++
+			continue
+		}
+		for _, ident := range field.Names {
+			if ident.Name == "_" {
+				ident.Name = resultVarPf + strconv.Itoa(index)
+			}
+			names = append(names, ident.Name)
+			index++
+		}
+	}
+
+	// gofmt needs parentheses once results have names
+	fnType.Results.Opening = true
+	fnType.Results.Closing = true
+	return names
+}
+
+// matchesFilters applies the -include-func, -exclude-func, -exported-only and
+// -min-lines selection to the statements of body from index start. Patterns
+// match Func or Type.Method, pointer receivers included without their *.
+func (t *ASTTransformer) matchesFilters(functionName string, exported bool, body *dst.BlockStmt, start int) bool {
+	functionName = strings.TrimPrefix(functionName, "*")
+	if t.IncludeFunc != nil && !t.IncludeFunc.MatchString(functionName) {
+		return false
+	}
+	if t.ExcludeFunc != nil && t.ExcludeFunc.MatchString(functionName) {
+		return false
+	}
+	if t.ExportedOnly && !exported {
+		return false
+	}
+	if t.MinLines > 0 && t.bodyLines(body.List[start:]) < t.MinLines {
+		return false
+	}
+	return true
+}
+
+// bodyLines counts the source lines from the first of stmts to the end of the
+// last, so an earlier run's preamble (already stripped) doesn't count
+func (t *ASTTransformer) bodyLines(stmts []dst.Stmt) int {
+	if t.Decorator == nil || len(stmts) == 0 {
+		return 0
+	}
+	first, ok := t.Decorator.Ast.Nodes[stmts[0]]
+	last, ok2 := t.Decorator.Ast.Nodes[stmts[len(stmts)-1]]
+	if !ok || !ok2 {
+		return 0
+	}
+	return t.FileSet.Position(last.End()).Line - t.FileSet.Position(first.Pos()).Line + 1
+}
+
+func (t *ASTTransformer) shouldSkipFunction(fn *dst.FuncDecl) bool {
+	name := fn.Name.Name
 
 	// Skip main function in main package
 	if t.packageName == "main" && name == "main" {
@@ -179,7 +656,7 @@ func (t *ASTTransformer) shouldSkipFunction(fn *ast.FuncDecl) bool {
 	}
 
 	// Skip test functions
-	if strings.HasPrefix(name, "Test") || strings.HasPrefix(name, "Benchmark") || strings.HasPrefix(name, "Example") {
+	if isTestFunction(name) {
 		return true
 	}
 
@@ -191,74 +668,150 @@ func (t *ASTTransformer) shouldSkipFunction(fn *ast.FuncDecl) bool {
 	return false
 }
 
-func (t *ASTTransformer) createArgsMapForFunction(fn *ast.FuncDecl) *ast.CompositeLit {
-	var elts []ast.Expr
+func isTestFunction(name string) bool {
+	return strings.HasPrefix(name, "Test") || strings.HasPrefix(name, "Benchmark") || strings.HasPrefix(name, "Example")
+}
+
+func (t *ASTTransformer) createArgsMap(fnType *dst.FuncType) *dst.CompositeLit {
+	var elts []dst.Expr
 
-	if fn.Type.Params != nil {
-		for _, field := range fn.Type.Params.List {
+	if fnType.Params != nil {
+		for _, field := range fnType.Params.List {
 			for _, name := range field.Names {
+				if name.Name == "_" || name.Name == forkVar {
+					continue
+				}
 				// Create key-value pair for the map
-				kvExpr := &ast.KeyValueExpr{
-					Key:   &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(name.Name)},
-					Value: name,
+				kvExpr := &dst.KeyValueExpr{
+					Key:   &dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(name.Name)},
+					Value: dst.NewIdent(name.Name),
 				}
 				elts = append(elts, kvExpr)
 			}
 		}
 	}
 
-	return &ast.CompositeLit{
-		Type: &ast.MapType{
-			Key: &ast.Ident{Name: "string"},
-			Value: &ast.InterfaceType{
-				Methods: &ast.FieldList{},
+	return &dst.CompositeLit{
+		Type: &dst.MapType{
+			Key: &dst.Ident{Name: "string"},
+			Value: &dst.InterfaceType{
+				Methods: &dst.FieldList{Opening: true, Closing: true},
 			},
 		},
 		Elts: elts,
 	}
 }
 
-func (t *ASTTransformer) createFrameStatement(functionName, signature string, line int, argsMap *ast.CompositeLit) ast.Stmt {
+func (t *ASTTransformer) createFrameStatement(functionName, signature string, line int, argsMap *dst.CompositeLit, ctxName string) dst.Stmt {
 	// Create: devtrace.GlobalEnter(devtrace.CreateFrame("functionName", "signature", "filename", line, argsMap))
-	return &ast.ExprStmt{
-		X: &ast.CallExpr{
-			Fun: &ast.SelectorExpr{
-				X:   ast.NewIdent("devtrace"),
-				Sel: ast.NewIdent("GlobalEnter"),
+	// or, with a ctx parameter: devtrace.EnterContext(ctx, devtrace.CreateFrame(...))
+	enter := "GlobalEnter"
+	var leading []dst.Expr
+	if ctxName != "" {
+		enter = "EnterContext"
+		leading = append(leading, dst.NewIdent(ctxName))
+	}
+
+	return &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   dst.NewIdent(t.devtraceName),
+				Sel: dst.NewIdent(enter),
 			},
-			Args: []ast.Expr{
-				&ast.CallExpr{
-					Fun: &ast.SelectorExpr{
-						X:   ast.NewIdent("devtrace"),
-						Sel: ast.NewIdent("CreateFrame"),
+			Args: append(leading,
+				&dst.CallExpr{
+					Fun: &dst.SelectorExpr{
+						X:   dst.NewIdent(t.devtraceName),
+						Sel: dst.NewIdent("CreateFrame"),
 					},
-					Args: []ast.Expr{
-						&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(functionName)},
-						&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(signature)},
-						&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(t.fileName)},
-						&ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(line)},
+					Args: []dst.Expr{
+						&dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(functionName)},
+						&dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(signature)},
+						&dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(t.fileName)},
+						&dst.BasicLit{Kind: token.INT, Value: strconv.Itoa(line)},
 						argsMap,
 					},
-				},
-			},
+				}),
 		},
 	}
 }
 
-func (t *ASTTransformer) buildSignatureForFunction(fn *ast.FuncDecl) string {
+// contextParam returns the name of the first context.Context parameter of fnType, if any
+func (t *ASTTransformer) contextParam(fnType *dst.FuncType) string {
+	if t.contextName == "" || fnType == nil || fnType.Params == nil {
+		return ""
+	}
+
+	for _, field := range fnType.Params.List {
+		sel, ok := field.Type.(*dst.SelectorExpr)
+		if !ok || sel.Sel.Name != "Context" {
+			continue
+		}
+		if pkg, ok := sel.X.(*dst.Ident); !ok || pkg.Name != t.contextName {
+			continue
+		}
+		for _, name := range field.Names {
+			if name.Name != "_" {
+				return name.Name
+			}
+		}
+	}
+	return ""
+}
+
+// enclosingContext returns the ctx parameter of the innermost function around the
+// node being visited. Closures see the ctx of the function they are declared in.
+func (t *ASTTransformer) enclosingContext() string {
+	for i := len(t.nodes) - 1; i >= 0; i-- {
+		var fnType *dst.FuncType
+		switch fn := t.nodes[i].(type) {
+		case *dst.FuncDecl:
+			fnType = fn.Type
+		case *dst.FuncLit:
+			fnType = fn.Type
+		default:
+			continue
+		}
+		if name := t.contextParam(fnType); name != "" {
+			return name
+		}
+		if _, isDecl := t.nodes[i].(*dst.FuncDecl); isDecl {
+			return ""
+		}
+	}
+	return ""
+}
+
+func (t *ASTTransformer) buildSignature(name string, fnType *dst.FuncType) string {
 	var builder strings.Builder
-	builder.WriteString(fn.Name.Name)
+	builder.WriteString(name)
+	if fnType.TypeParams != nil && len(fnType.TypeParams.List) > 0 {
+		typeParams := make([]string, 0, len(fnType.TypeParams.List))
+		for _, field := range fnType.TypeParams.List {
+			names := make([]string, len(field.Names))
+			for i, ident := range field.Names {
+				names[i] = ident.Name
+			}
+			typeParams = append(typeParams, strings.Join(names, ", ")+" "+t.renderExpr(field.Type))
+		}
+		builder.WriteString("[")
+		builder.WriteString(strings.Join(typeParams, ", "))
+		builder.WriteString("]")
+	}
 	builder.WriteString("(")
 
 	params := make([]string, 0)
-	if fn.Type.Params != nil {
-		for _, field := range fn.Type.Params.List {
+	if fnType.Params != nil {
+		for _, field := range fnType.Params.List {
 			typeStr := t.renderExpr(field.Type)
 			if len(field.Names) == 0 {
 				params = append(params, typeStr)
 				continue
 			}
 			for _, name := range field.Names {
+				if name.Name == forkVar {
+					continue // added by the go statement rewrite
+				}
 				params = append(params, fmt.Sprintf("%s %s", name.Name, typeStr))
 			}
 		}
@@ -266,20 +819,40 @@ func (t *ASTTransformer) buildSignatureForFunction(fn *ast.FuncDecl) string {
 	builder.WriteString(strings.Join(params, ", "))
 	builder.WriteString(")")
 
-	if fn.Type.Results != nil && len(fn.Type.Results.List) > 0 {
-		results := make([]string, 0)
-		for _, field := range fn.Type.Results.List {
+	if fnType.Results != nil && len(fnType.Results.List) > 0 {
+		// Names given by nameResults on an earlier run are left out, so the
+		// signature stays what the author wrote
+		var types, names []string
+		named := false
+		for _, field := range fnType.Results.List {
 			typeStr := t.renderExpr(field.Type)
 			if len(field.Names) == 0 {
-				results = append(results, typeStr)
+				types, names = append(types, typeStr), append(names, "")
 				continue
 			}
 			for _, name := range field.Names {
-				results = append(results, fmt.Sprintf("%s %s", name.Name, typeStr))
+				if strings.HasPrefix(name.Name, resultVarPf) {
+					types, names = append(types, typeStr), append(names, "")
+					continue
+				}
+				types, names = append(types, typeStr), append(names, name.Name)
+				named = true
 			}
 		}
 
-		if len(fn.Type.Results.List) == 1 && len(fn.Type.Results.List[0].Names) == 0 {
+		results := make([]string, len(types))
+		for i, typeStr := range types {
+			switch {
+			case !named:
+				results[i] = typeStr
+			case names[i] == "":
+				results[i] = "_ " + typeStr
+			default:
+				results[i] = names[i] + " " + typeStr
+			}
+		}
+
+		if len(results) == 1 && !named {
 			builder.WriteString(" ")
 			builder.WriteString(results[0])
 		} else {
@@ -292,20 +865,26 @@ func (t *ASTTransformer) buildSignatureForFunction(fn *ast.FuncDecl) string {
 	return builder.String()
 }
 
-func (t *ASTTransformer) renderExpr(expr ast.Expr) string {
-	if expr == nil {
+func (t *ASTTransformer) renderExpr(expr dst.Expr) string {
+	if expr == nil || t.Decorator == nil {
+		return ""
+	}
+
+	// Render the original node so the text matches the source exactly
+	original, ok := t.Decorator.Ast.Nodes[expr]
+	if !ok {
 		return ""
 	}
 
 	var buf bytes.Buffer
-	if err := format.Node(&buf, t.FileSet, expr); err != nil {
+	if err := format.Node(&buf, t.FileSet, original); err != nil {
 		return ""
 	}
 
 	return buf.String()
 }
 
-func (t *ASTTransformer) instrumentLogCall(call *ast.CallExpr) {
+func (t *ASTTransformer) instrumentLogCall(call *dst.CallExpr) {
 	// Check if this is a log call (log.Print, log.Printf, etc.)
 	if !t.isLogCall(call) {
 		return
@@ -316,98 +895,132 @@ func (t *ASTTransformer) instrumentLogCall(call *ast.CallExpr) {
 		return
 	}
 
-	// Add devtrace enhanced logging
-	// Transform log.Print(args...) to devtrace.GlobalEnhancedLogger.Info(context.Background(), msg, args...)
-	if _, ok := call.Fun.(*ast.SelectorExpr); ok {
-		// Change the call to use devtrace enhanced logger
-		call.Fun = &ast.SelectorExpr{
-			X: &ast.SelectorExpr{
-				X:   ast.NewIdent("devtrace"),
-				Sel: ast.NewIdent("GlobalEnhancedLogger"),
-			},
-			Sel: ast.NewIdent("Info"),
-		}
+	selector, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok {
+		return
+	}
 
-		// Add context.Background() as first argument
-		contextCall := &ast.CallExpr{
-			Fun: &ast.SelectorExpr{
-				X:   ast.NewIdent("context"),
-				Sel: ast.NewIdent("Background"),
-			},
-		}
+	// log.Printf(format, args...) becomes devtrace.PrintfWithStack(ctx, format, args...),
+	// and so on: each helper formats like the log function it replaces, and
+	// log.Fatal and log.Panic keep their exit and panic
+	call.Fun = t.devtraceSelector(logRewrites[selector.Sel.Name])
 
-		// Prepend context to arguments
-		newArgs := make([]ast.Expr, 0, len(call.Args)+1)
-		newArgs = append(newArgs, contextCall)
-		newArgs = append(newArgs, call.Args...)
-		call.Args = newArgs
+	// Prepend context to arguments
+	newArgs := make([]dst.Expr, 0, len(call.Args)+1)
+	newArgs = append(newArgs, t.contextExpr())
+	newArgs = append(newArgs, call.Args...)
+	call.Args = newArgs
 
-		t.modified = true
+	t.modified = true
+	t.logConverted = true
 
-		if t.Verbose {
-			log.Printf("Instrumented log call in %s", t.fileName)
-		}
+	if t.Verbose {
+		log.Printf("Instrumented log.%s call in %s", selector.Sel.Name, t.fileName)
+	}
+}
+
+// contextExpr is the context passed to an injected logger call: the enclosing
+// function's ctx, or context.Background() when there is none
+func (t *ASTTransformer) contextExpr() dst.Expr {
+	if ctxName := t.enclosingContext(); ctxName != "" {
+		return dst.NewIdent(ctxName)
+	}
+	pkg := t.contextName
+	if pkg == "" {
+		pkg = "context"
+		t.needsContext = true
+	}
+	return &dst.CallExpr{
+		Fun: &dst.SelectorExpr{
+			X:   dst.NewIdent(pkg),
+			Sel: dst.NewIdent("Background"),
+		},
 	}
 }
 
-func (t *ASTTransformer) isLogCall(call *ast.CallExpr) bool {
-	if selector, ok := call.Fun.(*ast.SelectorExpr); ok {
-		if ident, ok := selector.X.(*ast.Ident); ok {
-			return ident.Name == "log" && (selector.Sel.Name == "Print" ||
-				selector.Sel.Name == "Printf" ||
-				selector.Sel.Name == "Println" ||
-				selector.Sel.Name == "Fatal" ||
-				selector.Sel.Name == "Fatalf" ||
-				selector.Sel.Name == "Fatalln" ||
-				selector.Sel.Name == "Panic" ||
-				selector.Sel.Name == "Panicf" ||
-				selector.Sel.Name == "Panicln")
+func (t *ASTTransformer) isLogCall(call *dst.CallExpr) bool {
+	if selector, ok := call.Fun.(*dst.SelectorExpr); ok {
+		if ident, ok := selector.X.(*dst.Ident); ok {
+			_, rewritten := logRewrites[selector.Sel.Name]
+			return t.logName != "" && ident.Name == t.logName && rewritten
 		}
 	}
 	return false
 }
 
-func (t *ASTTransformer) isAlreadyInstrumentedLog(call *ast.CallExpr) bool {
-	if selector, ok := call.Fun.(*ast.SelectorExpr); ok {
-		if nestedSelector, ok := selector.X.(*ast.SelectorExpr); ok {
-			if ident, ok := nestedSelector.X.(*ast.Ident); ok {
-				return ident.Name == "devtrace"
+// logRewrites maps the log package functions instrumentLogCall rewrites to
+// the devtrace helpers that replace them
+var logRewrites = map[string]string{
+	"Print":   "PrintWithStack",
+	"Printf":  "PrintfWithStack",
+	"Println": "PrintlnWithStack",
+	"Fatal":   "FatalWithStack",
+	"Fatalf":  "FatalfWithStack",
+	"Fatalln": "FatallnWithStack",
+	"Panic":   "PanicWithStack",
+	"Panicf":  "PanicfWithStack",
+	"Panicln": "PaniclnWithStack",
+}
+
+func (t *ASTTransformer) isAlreadyInstrumentedLog(call *dst.CallExpr) bool {
+	if selector, ok := call.Fun.(*dst.SelectorExpr); ok {
+		if nestedSelector, ok := selector.X.(*dst.SelectorExpr); ok {
+			if ident, ok := nestedSelector.X.(*dst.Ident); ok {
+				return ident.Name == t.devtraceName
 			}
 		}
 	}
 	return false
 }
 
-func (t *ASTTransformer) getTypeName(expr ast.Expr) string {
+func (t *ASTTransformer) getTypeName(expr dst.Expr) string {
 	switch e := expr.(type) {
-	case *ast.Ident:
+	case *dst.Ident:
 		return e.Name
-	case *ast.StarExpr:
+	case *dst.StarExpr:
 		return "*" + t.getTypeName(e.X)
-	case *ast.SelectorExpr:
+	case *dst.SelectorExpr:
 		return t.getTypeName(e.X) + "." + e.Sel.Name
+	case *dst.IndexExpr:
+		// receiver of a generic type: List[T]
+		return t.getTypeName(e.X) + "[" + t.getTypeName(e.Index) + "]"
+	case *dst.IndexListExpr:
+		params := make([]string, len(e.Indices))
+		for i, index := range e.Indices {
+			params[i] = t.getTypeName(index)
+		}
+		return t.getTypeName(e.X) + "[" + strings.Join(params, ", ") + "]"
+	case *dst.ParenExpr:
+		return t.getTypeName(e.X)
 	default:
 		return "Unknown"
 	}
 }
 
-func (t *ASTTransformer) WriteFile(outputPath string, file *ast.File) error {
+// Render returns the formatted source of file
+func (t *ASTTransformer) Render(file *dst.File) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := decorator.Fprint(&buf, file); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (t *ASTTransformer) WriteFile(outputPath string, file *dst.File) error {
 	// Create output directory if it doesn't exist
 	dir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory %s: %v", dir, err)
 	}
 
-	// Open output file
-	outFile, err := os.Create(outputPath)
+	// Render before touching the output so a failure never leaves a truncated file
+	src, err := t.Render(file)
 	if err != nil {
-		return fmt.Errorf("failed to create output file %s: %v", outputPath, err)
+		return fmt.Errorf("failed to write formatted code to %s: %v", outputPath, err)
 	}
-	defer outFile.Close()
 
-	// Format and write the AST
-	if err := format.Node(outFile, t.FileSet, file); err != nil {
-		return fmt.Errorf("failed to write formatted code to %s: %v", outputPath, err)
+	if err := os.WriteFile(outputPath, src, 0644); err != nil {
+		return fmt.Errorf("failed to create output file %s: %v", outputPath, err)
 	}
 
 	if t.Verbose {
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dave/dst/decorator"
+)
+
+// instrument runs the transformer over src the way gotrace-instrument does
+// with tracing, logging and every opt-in rewrite of a preamble or go
+// statement on, after configure adjusts it, and returns the output
+func instrument(t *testing.T, src string, configure func(*ASTTransformer)) string {
+	t.Helper()
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := decorator.NewDecorator(fset)
+	file, err := dec.DecorateFile(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transformer := &ASTTransformer{
+		FileSet:         fset,
+		Decorator:       dec,
+		AddTrace:        true,
+		AddLogging:      true,
+		TraceGoroutines: true,
+		CaptureResults:  true,
+		CapturePanics:   true,
+	}
+	if configure != nil {
+		configure(transformer)
+	}
+	transformer.Transform(file)
+
+	out, err := transformer.Render(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// assertCompiles builds src as the main package of a module that uses the
+// devtrace package of this repository
+func assertCompiles(t *testing.T, src string) {
+	t.Helper()
+	dir := testModule(t, map[string]string{"main.go": src})
+	goCommand(t, dir, "vet", ".")
+}
+
+// testModule writes files into a new module that requires the devtrace
+// package of this repository, and returns its directory
+func testModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds a module")
+	}
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files["go.mod"] = "module example.com/instrumented\n\ngo 1.21\n\n" +
+		"require " + DefaultImportPath + " v0.0.0\n\n" +
+		"replace " + DefaultImportPath + " => " + filepath.ToSlash(root) + "\n"
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// goCommand runs the go command in dir and fails the test if it fails
+func goCommand(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+func TestTransformExampleCompiles(t *testing.T) {
+	src, err := os.ReadFile("../../example/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := instrument(t, string(src), nil)
+	if strings.Contains(out, "\"log\"") {
+		t.Errorf("expected the log import to go with the last log call:\n%s", out)
+	}
+	assertCompiles(t, out)
+}
+
+func TestTransformKeepsLogImportStillInUse(t *testing.T) {
+	out := instrument(t, `package main
+
+import "log"
+
+func main() {
+	log.SetFlags(0)
+	log.Printf("started")
+}
+`, nil)
+	if !strings.Contains(out, "\"log\"") || !strings.Contains(out, "log.SetFlags(0)") {
+		t.Errorf("expected log to stay imported for log.SetFlags:\n%s", out)
+	}
+	assertCompiles(t, out)
+}
+
+func TestTransformMapsLogCallsToFormattingHelpers(t *testing.T) {
+	out := instrument(t, `package main
+
+import "log"
+
+func main() {
+	log.Print("a", 1)
+	log.Printf("b %d", 2)
+	log.Println("c", 3)
+	log.Fatalln("d", 4)
+}
+`, func(tr *ASTTransformer) { tr.AddTrace = false })
+
+	for _, want := range []string{
+		`devtrace.PrintWithStack(context.Background(), "a", 1)`,
+		`devtrace.PrintfWithStack(context.Background(), "b %d", 2)`,
+		`devtrace.PrintlnWithStack(context.Background(), "c", 3)`,
+		`devtrace.FatallnWithStack(context.Background(), "d", 4)`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in:\n%s", want, out)
+		}
+	}
+	assertCompiles(t, out)
+}
+
+func TestTransformTwiceKeepsFrameLines(t *testing.T) {
+	src := `package main
+
+func main() {
+	greet("world")
+}
+
+func greet(name string) {
+	each([]string{name}, func(s string) {
+		s += "!"
+		println("hello", s)
+	})
+}
+
+func each(items []string, fn func(string)) {
+	for _, item := range items {
+		fn(item)
+	}
+}
+`
+	configure := func(tr *ASTTransformer) { tr.Closures = true }
+	once := instrument(t, src, configure)
+	for _, want := range []string{`"greet", "greet(name string)", "main.go", 7,`, `"greet.func1", "func1(s string)", "main.go", 8,`, `"each", "each(items []string, fn func(string))", "main.go", 14,`} {
+		if !strings.Contains(once, want) {
+			t.Fatalf("expected %s, the original line:\n%s", want, once)
+		}
+	}
+	if twice := instrument(t, once, configure); twice != once {
+		t.Errorf("a second run changed the output:\n%s", UnifiedDiff("once", "twice", []byte(once), []byte(twice)))
+	}
+	assertCompiles(t, once)
+}
+
+// goldenCases are the inputs in testdata/golden, each instrumented with the
+// settings of the feature it covers and compared with <name>.golden. Run with
+// DEVTRACE_UPDATE_GOLDEN=1, as for devtracetest.Golden, to rewrite them.
+var goldenCases = []struct {
+	name      string
+	configure func(*ASTTransformer)
+}{
+	{name: "context_logging"},
+	{name: "context_import"},
+	{name: "aliased_import"},
+	{name: "comments"},
+	{name: "results"},
+	{name: "panics", configure: func(tr *ASTTransformer) { tr.CaptureResults = false }},
+	{name: "filters", configure: func(tr *ASTTransformer) {
+		tr.ExcludeFunc = regexp.MustCompile(`\.Get$`)
+		tr.ExportedOnly = true
+		tr.MinLines = 3
+	}},
+	{name: "closures", configure: func(tr *ASTTransformer) { tr.Closures = true }},
+	{name: "generics", configure: func(tr *ASTTransformer) { tr.Closures = true }},
+	{name: "goroutines"},
+	{name: "goroutines_context"},
+	{name: "log_fatal"},
+	{name: "convert_fmt", configure: func(tr *ASTTransformer) {
+		tr.ConvertFmt = true
+		tr.FmtExclude = regexp.MustCompile(`^usage$`)
+	}},
+	{name: "init"},
+	{name: "refresh", configure: func(tr *ASTTransformer) { tr.Closures = true }},
+}
+
+func TestTransformGolden(t *testing.T) {
+	update := os.Getenv("DEVTRACE_UPDATE_GOLDEN") != ""
+	for _, tc := range goldenCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("testdata", "golden", tc.name+".go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := instrument(t, string(src), tc.configure)
+
+			path := filepath.Join("testdata", "golden", tc.name+".golden")
+			if update {
+				if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != string(want) {
+				t.Errorf("output differs from %s:\n%s", path, UnifiedDiff(path, "instrumented", want, []byte(out)))
+			}
+			assertCompiles(t, out)
+		})
+	}
+}
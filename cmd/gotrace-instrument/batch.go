@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Summary counts what InstrumentFiles did
+type Summary struct {
+	Scanned   int           // files parsed
+	Changed   int           // files that were (or, with DryRun, would be) rewritten
+	Functions int           // functions and closures given a preamble
+	Failed    int           // files that couldn't be instrumented
+	Elapsed   time.Duration // wall time of the whole run
+}
+
+func (s Summary) String() string {
+	out := fmt.Sprintf("%d files scanned, %d changed, %d functions instrumented", s.Scanned, s.Changed, s.Functions)
+	if s.Failed > 0 {
+		out += fmt.Sprintf(", %d failed", s.Failed)
+	}
+	return out + fmt.Sprintf(" in %v", s.Elapsed.Round(time.Millisecond))
+}
+
+// fileResult is the outcome of instrumenting one file
+type fileResult struct {
+	changed   bool
+	functions int
+	diff      string // unified diff, with DryRun
+	err       error
+}
+
+// InstrumentFiles instruments paths on up to workers goroutines, GOMAXPROCS
+// when workers is 0. A failing file doesn't stop the others; the returned
+// error joins every failure in input order. With DryRun the diffs are written
+// to stdout in input order too, so the output doesn't depend on scheduling.
+func (i *Instrumenter) InstrumentFiles(paths []string, workers int) (Summary, error) {
+	start := time.Now()
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(paths))
+
+	results := make([]fileResult, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				results[index] = i.instrumentFile(paths[index])
+			}
+		}()
+	}
+	for index := range paths {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	summary := Summary{Scanned: len(paths)}
+	var errs []error
+	for _, result := range results {
+		if result.err != nil {
+			summary.Failed++
+			errs = append(errs, result.err)
+			continue
+		}
+		if result.changed {
+			summary.Changed++
+			summary.Functions += result.functions
+		}
+		if result.diff != "" {
+			if _, err := io.WriteString(os.Stdout, result.diff); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	summary.Elapsed = time.Since(start)
+	return summary, errors.Join(errs...)
+}
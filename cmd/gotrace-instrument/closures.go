@@ -0,0 +1,114 @@
+package main
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/dave/dst"
+)
+
+// instrumentClosure instruments a function literal when Closures is set. Frames
+// are named after the enclosing function the way the runtime names closures:
+// GetUser.func1, GetUser.func1.1 for a closure inside it, and glob.func1 for
+// package-level ones. Literals whose body is a single expression statement,
+// such as the deferred calls added by instrumentation and the wrappers of the
+// go statement rewrite, are neither traced nor numbered, so names don't shift
+// between runs.
+//
+// Goroutine bodies keep the deferred Adopt first, so the frame nests under the
+// spawning trace. Only a ctx parameter of the literal itself is used; an outer
+// ctx may belong to a goroutine the closure doesn't run on.
+func (t *ASTTransformer) instrumentClosure(lit *dst.FuncLit) {
+	if lit.Body == nil {
+		return
+	}
+
+	start := 0
+	if len(lit.Body.List) > 0 && isAdoptStmt(lit.Body.List[0]) {
+		start = 1
+	}
+	previousLine, refreshed := t.stripInstrumentation(lit.Body, start)
+	if len(lit.Body.List)-start == 0 {
+		return
+	}
+	if len(lit.Body.List)-start == 1 {
+		if _, ok := lit.Body.List[start].(*dst.ExprStmt); ok {
+			return
+		}
+	}
+
+	parent, parentName, exported := t.enclosingFunction()
+	t.closureCount[parent]++
+	n := strconv.Itoa(t.closureCount[parent])
+	functionName := parentName + ".func" + n
+	if _, nested := parent.(*dst.FuncLit); nested {
+		functionName = parentName + "." + n
+	}
+	t.closureNames[lit] = functionName
+
+	if !t.Closures || isTestFunction(parentName) || !t.matchesFilters(functionName, exported, lit.Body, start) {
+		t.removedInstrumentation(refreshed, "closure", functionName)
+		return
+	}
+
+	line := t.position(lit).Line
+	if previousLine > 0 {
+		line = previousLine // see instrumentFunction
+	}
+	t.instrumentBody(functionName, t.buildSignature("func"+n, lit.Type), line, lit.Type, lit.Body, start)
+
+	if t.Verbose {
+		verb := "Instrumented"
+		if refreshed {
+			verb = "Refreshed"
+		}
+		log.Printf("%s closure: %s in %s:%d", verb, functionName, t.fileName, line)
+	}
+}
+
+// enclosingFunction returns the innermost function declaration or numbered
+// literal around the literal being visited, with its frame name and whether
+// the declaration it belongs to is exported. Outside any function it returns
+// a nil node named glob.
+func (t *ASTTransformer) enclosingFunction() (dst.Node, string, bool) {
+	for i := len(t.nodes) - 2; i >= 0; i-- {
+		switch fn := t.nodes[i].(type) {
+		case *dst.FuncLit:
+			if name, ok := t.closureNames[fn]; ok {
+				return fn, name, t.declExported()
+			}
+		case *dst.FuncDecl:
+			return fn, t.funcDeclName(fn), fn.Name.IsExported()
+		}
+	}
+	return nil, "glob", false
+}
+
+// declExported reports whether the function declaration being visited is exported
+func (t *ASTTransformer) declExported() bool {
+	for _, node := range t.nodes {
+		if fn, ok := node.(*dst.FuncDecl); ok {
+			return fn.Name.IsExported()
+		}
+	}
+	return false
+}
+
+// isAdoptStmt reports whether stmt is the defer __devtraceFork.Adopt()() added
+// by the go statement rewrite
+func isAdoptStmt(stmt dst.Stmt) bool {
+	deferStmt, ok := stmt.(*dst.DeferStmt)
+	if !ok {
+		return false
+	}
+	inner, ok := deferStmt.Call.Fun.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := inner.Fun.(*dst.SelectorExpr)
+	if !ok || sel.Sel.Name != "Adopt" {
+		return false
+	}
+	ident, ok := sel.X.(*dst.Ident)
+	return ok && ident.Name == forkVar
+}
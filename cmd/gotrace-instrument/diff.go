@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// ANSI colors for diff output on a terminal
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+type diffOp byte
+
+const (
+	diffEqual  diffOp = ' '
+	diffDelete diffOp = '-'
+	diffInsert diffOp = '+'
+)
+
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// UnifiedDiff returns a unified diff from a to b, "" if they are equal
+func UnifiedDiff(aName, bName string, a, b []byte) string {
+	lines := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	var out strings.Builder
+	for start := 0; start < len(lines); {
+		// Find the next change and the hunk around it
+		first := start
+		for first < len(lines) && lines[first].op == diffEqual {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		from := max(first-diffContext, start)
+		to := first
+		for to < len(lines) {
+			if lines[to].op != diffEqual {
+				to++
+				continue
+			}
+			// Stop once the equal run is too long to bridge to the next change
+			run := to
+			for run < len(lines) && lines[run].op == diffEqual {
+				run++
+			}
+			if run == len(lines) || run-to > 2*diffContext {
+				to = min(to+diffContext, len(lines))
+				break
+			}
+			to = run
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		writeHunk(&out, lines, from, to)
+		start = to
+	}
+	return out.String()
+}
+
+// writeHunk writes lines[from:to] with its @@ header
+func writeHunk(out *strings.Builder, lines []diffLine, from, to int) {
+	aStart, bStart := 1, 1
+	for _, line := range lines[:from] {
+		if line.op != diffInsert {
+			aStart++
+		}
+		if line.op != diffDelete {
+			bStart++
+		}
+	}
+	aCount, bCount := 0, 0
+	for _, line := range lines[from:to] {
+		if line.op != diffInsert {
+			aCount++
+		}
+		if line.op != diffDelete {
+			bCount++
+		}
+	}
+
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+	for _, line := range lines[from:to] {
+		out.WriteByte(byte(line.op))
+		out.WriteString(line.text)
+		out.WriteByte('\n')
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start-- // an empty range names the line before it
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes a shortest edit script with Myers' algorithm, which stays
+// cheap for instrumentation since it mostly inserts a few lines per function
+func diffLines(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+
+	for d := 0; d <= maxD; d++ {
+		// Step d only reads diagonals -d-1..d+1; keeping just those bounds memory
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1] // down: insert from b
+			} else {
+				x = v[offset+k-1] + 1 // right: delete from a
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, d, k)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the saved frontiers from the end back to the start
+func backtrack(trace [][]int, a, b []string, d, k int) []diffLine {
+	x, y := len(a), len(b)
+	var reversed []diffLine
+	for ; d > 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+		var prevK int
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, diffLine{diffEqual, a[x]})
+		}
+		if x == prevX {
+			y--
+			reversed = append(reversed, diffLine{diffInsert, b[y]})
+		} else {
+			x--
+			reversed = append(reversed, diffLine{diffDelete, a[x]})
+		}
+		k = prevK
+	}
+	for x > 0 {
+		x--
+		reversed = append(reversed, diffLine{diffEqual, a[x]})
+	}
+
+	lines := make([]diffLine, len(reversed))
+	for i, line := range reversed {
+		lines[len(reversed)-1-i] = line
+	}
+	return lines
+}
+
+// colorizeDiff adds terminal colors to a unified diff
+func colorizeDiff(diff string) string {
+	lines := strings.SplitAfter(diff, "\n")
+	inHunk := false
+	for i, line := range lines {
+		text := strings.TrimSuffix(line, "\n")
+		if text == "" {
+			continue
+		}
+		color := ""
+		switch {
+		case strings.HasPrefix(text, "@@"):
+			color = colorCyan
+			inHunk = true
+		case !inHunk:
+			color = colorBold // file header
+		case text[0] == '+':
+			color = colorGreen
+		case text[0] == '-':
+			color = colorRed
+		}
+		if color != "" {
+			lines[i] = color + text + colorReset + line[len(text):]
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// isTerminal reports whether f is a terminal and colors weren't turned off
+// with NO_COLOR
+func isTerminal(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "package main\n\nfunc f() {\n\tone()\n\ttwo()\n}\n"
+	b := "package main\n\nfunc f() {\n\tenter()\n\tone()\n\ttwo()\n}\n"
+
+	want := "--- a/main.go\n+++ b/main.go\n" +
+		"@@ -1,6 +1,7 @@\n" +
+		" package main\n" +
+		" \n" +
+		" func f() {\n" +
+		"+\tenter()\n" +
+		" \tone()\n" +
+		" \ttwo()\n" +
+		" }\n"
+	if got := UnifiedDiff("a/main.go", "b/main.go", []byte(a), []byte(b)); got != want {
+		t.Fatalf("unexpected diff:\n%s", got)
+	}
+	if got := UnifiedDiff("a", "b", []byte(a), []byte(a)); got != "" {
+		t.Fatalf("expected no diff for equal input, got:\n%s", got)
+	}
+}
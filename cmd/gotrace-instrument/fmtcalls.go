@@ -0,0 +1,179 @@
+package main
+
+import (
+	"go/token"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
+)
+
+// convertFmtCall rewrites a fmt.Println or fmt.Printf statement that looks like
+// debug output into an EnhancedLogger call, with the level read off the
+// message: errors and failures log at Error, warnings at Warn, the rest at
+// Debug. Output that reads like prose meant for the user (no value dumps,
+// no debug markers) is left alone, as is everything in functions matching
+// FmtExclude.
+func (t *ASTTransformer) convertFmtCall(call *dst.CallExpr) {
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok || t.fmtName == "" {
+		return
+	}
+	if ident, ok := sel.X.(*dst.Ident); !ok || ident.Name != t.fmtName {
+		return
+	}
+	if sel.Sel.Name != "Println" && sel.Sel.Name != "Printf" {
+		return
+	}
+	// Printf returns values; only a call whose results are dropped can change
+	if len(t.nodes) < 2 {
+		return
+	}
+	if _, ok := t.nodes[len(t.nodes)-2].(*dst.ExprStmt); !ok {
+		return
+	}
+
+	_, functionName, _ := t.enclosingFunction()
+	if t.FmtExclude != nil && t.FmtExclude.MatchString(functionName) {
+		return
+	}
+
+	var format string
+	var args []dst.Expr
+	if sel.Sel.Name == "Printf" {
+		format, args, ok = printfMessage(call.Args)
+	} else {
+		format, args, ok = printlnMessage(call.Args)
+	}
+	if !ok {
+		return
+	}
+	level := fmtLogLevel(format, len(args))
+	if level == "" {
+		return
+	}
+
+	call.Fun = &dst.SelectorExpr{X: t.devtraceSelector("GlobalEnhancedLogger"), Sel: dst.NewIdent(level)}
+	call.Args = append([]dst.Expr{t.contextExpr(), &dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(format)}}, args...)
+	call.Ellipsis = false
+	t.modified = true
+	t.fmtConverted = true
+
+	if t.Verbose {
+		log.Printf("Converted fmt.%s call to %s in %s", sel.Sel.Name, level, t.fileName)
+	}
+}
+
+// printfMessage returns the format of a Printf call without its trailing
+// newline; the format must be a literal
+func printfMessage(args []dst.Expr) (string, []dst.Expr, bool) {
+	if len(args) == 0 {
+		return "", nil, false
+	}
+	format, ok := stringLiteral(args[0])
+	if !ok {
+		return "", nil, false
+	}
+	return strings.TrimSuffix(format, "\n"), args[1:], true
+}
+
+// printlnMessage turns Println operands into a format: literal strings are
+// kept (with % escaped) and every other operand becomes %v, space separated
+// the way Println prints them
+func printlnMessage(args []dst.Expr) (string, []dst.Expr, bool) {
+	if len(args) == 0 {
+		return "", nil, false
+	}
+	parts := make([]string, 0, len(args))
+	var values []dst.Expr
+	for _, arg := range args {
+		if s, ok := stringLiteral(arg); ok {
+			parts = append(parts, strings.ReplaceAll(s, "%", "%%"))
+			continue
+		}
+		if _, spread := arg.(*dst.Ellipsis); spread {
+			return "", nil, false
+		}
+		parts = append(parts, "%v")
+		values = append(values, arg)
+	}
+	return strings.Join(parts, " "), values, true
+}
+
+// fmtLogLevel picks the EnhancedLogger method for a message, or "" when the
+// message looks like user-facing output rather than diagnostics
+func fmtLogLevel(format string, values int) string {
+	lower := strings.ToLower(format)
+	switch {
+	case containsAny(lower, "error", "err:", "err=", "failed", "failure", "panic"):
+		return "Error"
+	case strings.Contains(lower, "warn"):
+		return "Warn"
+	case containsAny(lower, "debug", "trace", "%v", "%+v", "%#v", "%t", ">>>", "-->", "***", "==="),
+		strings.HasPrefix(lower, "["),
+		values > 0 && (strings.Contains(lower, "=%") || strings.Contains(lower, ": %") || strings.Contains(lower, ":%")),
+		values > 0 && strings.TrimSpace(strings.ReplaceAll(lower, "%v", "")) == "":
+		return "Debug"
+	}
+	return ""
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func stringLiteral(expr dst.Expr) (string, bool) {
+	lit, ok := expr.(*dst.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// removeUnusedImport drops the import of path, referred to as name, once
+// rewriting calls (fmt conversion, log instrumentation) left no other use of it
+func (t *ASTTransformer) removeUnusedImport(file *dst.File, name, path string) {
+	quoted := strconv.Quote(path)
+	used := false
+	dst.Inspect(file, func(node dst.Node) bool {
+		if sel, ok := node.(*dst.SelectorExpr); ok {
+			if ident, ok := sel.X.(*dst.Ident); ok && ident.Name == name {
+				used = true
+			}
+		}
+		return !used
+	})
+	if used {
+		return
+	}
+
+	dstutil.Apply(file, func(c *dstutil.Cursor) bool {
+		if spec, ok := c.Node().(*dst.ImportSpec); ok && spec.Path.Value == quoted {
+			c.Delete()
+		}
+		return true
+	}, nil)
+	for i, spec := range file.Imports {
+		if spec.Path.Value == quoted {
+			file.Imports = append(file.Imports[:i], file.Imports[i+1:]...)
+			break
+		}
+	}
+
+	decls := file.Decls[:0]
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*dst.GenDecl); ok && gen.Tok == token.IMPORT && len(gen.Specs) == 0 {
+			continue
+		}
+		decls = append(decls, decl)
+	}
+	file.Decls = decls
+}
@@ -3,10 +3,12 @@ module github.com/skulidropek/gotrace/cmd/gotrace-instrument
 go 1.21
 
 require (
-	golang.org/x/tools v0.18.0
+	github.com/dave/dst v0.27.3
+	golang.org/x/tools v0.1.12
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	golang.org/x/mod v0.15.0 // indirect
-	golang.org/x/sync v0.6.0 // indirect
+	golang.org/x/mod v0.6.0-dev.0.20220419223038-86c51ed26bb4 // indirect
+	golang.org/x/sys v0.0.0-20220722155257-8c9f86f7a55f // indirect
 )
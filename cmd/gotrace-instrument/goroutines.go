@@ -0,0 +1,210 @@
+package main
+
+import (
+	"github.com/dave/dst"
+	"go/token"
+	"log"
+	"strconv"
+)
+
+const (
+	forkVar     = "__devtraceFork"
+	goFnVar     = "__devtraceFn"
+	goArgVarPf  = "__devtraceArg"
+	resultVarPf = "__devtraceR"
+)
+
+// builtins cannot be used as function values, so `go close(ch)` and friends are left alone
+var builtins = map[string]bool{
+	"append": true, "cap": true, "clear": true, "close": true, "complex": true, "copy": true,
+	"delete": true, "imag": true, "len": true, "make": true, "max": true, "min": true, "new": true,
+	"panic": true, "print": true, "println": true, "real": true, "recover": true,
+}
+
+// rewriteGoStmts makes every go statement in stmts continue the spawning goroutine's trace.
+//
+//	go func(x int) { ... }(v)  →  go func(__devtraceFork *devtrace.TraceFork, x int) {
+//	                                    defer __devtraceFork.Adopt()()
+//	                                    ...
+//	                                }(devtrace.Fork(), v)
+//
+//	go f(a, b)                 →  {
+//	                                    __devtraceArg0, __devtraceArg1 := a, b
+//	                                    go func(__devtraceFork *devtrace.TraceFork) {
+//	                                        defer __devtraceFork.Adopt()()
+//	                                        f(__devtraceArg0, __devtraceArg1)
+//	                                    }(devtrace.Fork())
+//	                                }
+//
+// In a function that takes a ctx, devtrace.ForkContext(ctx) replaces devtrace.Fork().
+// Arguments are still evaluated at the go statement. Literals and constants are
+// kept inline so untyped constants keep converting to the parameter types.
+func (t *ASTTransformer) rewriteGoStmts(stmts []dst.Stmt) {
+	for i, stmt := range stmts {
+		goStmt, ok := stmt.(*dst.GoStmt)
+		if !ok {
+			continue
+		}
+
+		if lit, ok := goStmt.Call.Fun.(*dst.FuncLit); ok {
+			if t.forkFuncLit(goStmt.Call, lit) {
+				t.markGoRewritten(goStmt)
+			}
+			continue
+		}
+
+		if block := t.forkCall(goStmt); block != nil {
+			stmts[i] = block
+			t.markGoRewritten(goStmt)
+		}
+	}
+}
+
+func (t *ASTTransformer) markGoRewritten(goStmt *dst.GoStmt) {
+	t.modified = true
+	if t.Verbose {
+		log.Printf("Instrumented go statement in %s:%d", t.fileName, t.position(goStmt).Line)
+	}
+}
+
+func (t *ASTTransformer) forkFuncLit(call *dst.CallExpr, lit *dst.FuncLit) bool {
+	params := lit.Type.Params.List
+	if len(params) > 0 && len(params[0].Names) > 0 && params[0].Names[0].Name == forkVar {
+		return false // already instrumented
+	}
+
+	forkParam := &dst.Field{
+		Names: []*dst.Ident{dst.NewIdent(forkVar)},
+		Type:  &dst.StarExpr{X: t.devtraceSelector("TraceFork")},
+	}
+	lit.Type.Params.List = append([]*dst.Field{forkParam}, params...)
+	lit.Body.List = append([]dst.Stmt{adoptStmt()}, lit.Body.List...)
+	call.Args = append([]dst.Expr{t.forkExpr()}, call.Args...)
+	return true
+}
+
+func (t *ASTTransformer) forkCall(goStmt *dst.GoStmt) dst.Stmt {
+	call := goStmt.Call
+	if ident, ok := call.Fun.(*dst.Ident); ok && ident.Obj == nil && builtins[ident.Name] {
+		return nil
+	}
+	if len(call.Args) == 1 {
+		// f(g()) may spread several results into f's parameters; a temporary can't hold them
+		if _, ok := call.Args[0].(*dst.CallExpr); ok {
+			if t.Verbose {
+				log.Printf("Skipping go statement with call argument in %s:%d", t.fileName, t.position(goStmt).Line)
+			}
+			return nil
+		}
+	}
+
+	var names, values []dst.Expr
+
+	fn := call.Fun
+	if !isStableExpr(fn) {
+		names = append(names, dst.NewIdent(goFnVar))
+		values = append(values, fn)
+		fn = dst.NewIdent(goFnVar)
+	}
+
+	args := make([]dst.Expr, len(call.Args))
+	for i, arg := range call.Args {
+		if isStableExpr(arg) {
+			args[i] = arg
+			continue
+		}
+		name := goArgVarPf + strconv.Itoa(i)
+		names = append(names, dst.NewIdent(name))
+		values = append(values, arg)
+		args[i] = dst.NewIdent(name)
+	}
+
+	lit := &dst.FuncLit{
+		Type: &dst.FuncType{Params: &dst.FieldList{}},
+		Body: &dst.BlockStmt{List: []dst.Stmt{
+			&dst.ExprStmt{
+				X:    &dst.CallExpr{Fun: fn, Args: args, Ellipsis: call.Ellipsis},
+				Decs: dst.ExprStmtDecorations{NodeDecs: dst.NodeDecs{Before: dst.NewLine, After: dst.NewLine}},
+			},
+		}},
+	}
+	spawn := &dst.GoStmt{Call: &dst.CallExpr{Fun: lit}}
+	t.forkFuncLit(spawn.Call, lit)
+
+	if len(names) == 0 {
+		spawn.Decs.NodeDecs = goStmt.Decs.NodeDecs
+		return spawn
+	}
+	block := &dst.BlockStmt{List: []dst.Stmt{
+		&dst.AssignStmt{Lhs: names, Tok: token.DEFINE, Rhs: values},
+		spawn,
+	}}
+	// Comments around the original statement now belong to the block
+	block.Decs.NodeDecs = goStmt.Decs.NodeDecs
+	return block
+}
+
+// isStableExpr reports whether evaluating expr later gives the same value: literals,
+// constants, functions and package-level names. Package-level variables declared in
+// other files can't be told apart from constants here and are treated as stable.
+func isStableExpr(expr dst.Expr) bool {
+	switch e := expr.(type) {
+	case *dst.BasicLit, *dst.FuncLit:
+		return true
+	case *dst.Ident:
+		return e.Obj == nil || e.Obj.Kind == dst.Con || e.Obj.Kind == dst.Fun || e.Obj.Kind == dst.Typ
+	case *dst.SelectorExpr:
+		// pkg.Name; a local variable on the left makes this a field read or method value
+		ident, ok := e.X.(*dst.Ident)
+		return ok && ident.Obj == nil
+	case *dst.ParenExpr:
+		return isStableExpr(e.X)
+	case *dst.UnaryExpr:
+		return e.Op != token.ARROW && e.Op != token.AND && isStableExpr(e.X)
+	case *dst.BinaryExpr:
+		return isStableExpr(e.X) && isStableExpr(e.Y)
+	case *dst.IndexExpr:
+		// generic instantiation f[int]
+		return isStableExpr(e.X) && isTypeExpr(e.Index)
+	case *dst.IndexListExpr:
+		if !isStableExpr(e.X) {
+			return false
+		}
+		for _, index := range e.Indices {
+			if !isTypeExpr(index) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isTypeExpr(expr dst.Expr) bool {
+	switch e := expr.(type) {
+	case *dst.Ident:
+		return e.Obj == nil || e.Obj.Kind == dst.Typ
+	case *dst.SelectorExpr, *dst.StarExpr, *dst.ArrayType, *dst.MapType, *dst.ChanType, *dst.FuncType, *dst.InterfaceType, *dst.StructType:
+		return true
+	}
+	return false
+}
+
+// forkExpr builds devtrace.ForkContext(ctx) inside functions that take a ctx,
+// so a trace carried on ctx reaches the goroutine, and devtrace.Fork() elsewhere
+func (t *ASTTransformer) forkExpr() *dst.CallExpr {
+	if ctxName := t.enclosingContext(); ctxName != "" {
+		return &dst.CallExpr{Fun: t.devtraceSelector("ForkContext"), Args: []dst.Expr{dst.NewIdent(ctxName)}}
+	}
+	return &dst.CallExpr{Fun: t.devtraceSelector("Fork")}
+}
+
+// adoptStmt builds defer __devtraceFork.Adopt()()
+func adoptStmt() dst.Stmt {
+	return &dst.DeferStmt{
+		Call: &dst.CallExpr{
+			Fun: &dst.CallExpr{Fun: &dst.SelectorExpr{X: dst.NewIdent(forkVar), Sel: dst.NewIdent("Adopt")}},
+		},
+		Decs: dst.DeferStmtDecorations{NodeDecs: dst.NodeDecs{Before: dst.NewLine, After: dst.NewLine}},
+	}
+}
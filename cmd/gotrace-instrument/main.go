@@ -2,35 +2,101 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"go/parser"
 	"go/token"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"golang.org/x/tools/go/analysis/unitchecker"
 )
 
 func main() {
+	if invokedAsVetTool(os.Args[1:]) {
+		unitchecker.Main(Analyzer)
+	}
+
 	var (
 		srcDir     = flag.String("src", ".", "Source directory to instrument")
 		outputDir  = flag.String("out", "", "Output directory (default: overwrite source)")
 		pattern    = flag.String("pattern", "*.go", "File pattern to match")
 		exclude    = flag.String("exclude", "_test.go,vendor/", "Comma-separated patterns to exclude")
-		dryRun     = flag.Bool("dry-run", false, "Show what would be changed without making changes")
+		dryRun     = flag.Bool("dry-run", false, "Print a unified diff of the changes without making them")
 		verbose    = flag.Bool("verbose", false, "Enable verbose logging")
 		addTrace   = flag.Bool("add-trace", true, "Add function tracing")
 		addLogging = flag.Bool("add-logging", true, "Add enhanced logging to existing log calls")
+		traceGo    = flag.Bool("trace-goroutines", false, "Rewrite go statements so new goroutines continue the caller's trace")
+		captureRes = flag.Bool("capture-results", false, "Record return values on instrumented frames (unnamed results get names)")
+		capturePan = flag.Bool("capture-panics", false, "Mark instrumented frames as panicked, with the value, before the panic propagates")
+		includeFn  = flag.String("include-func", "", "Only instrument functions whose name (Func or Type.Method) matches this regexp")
+		excludeFn  = flag.String("exclude-func", "", "Don't instrument functions whose name (Func or Type.Method) matches this regexp")
+		exported   = flag.Bool("exported-only", false, "Only instrument exported functions and methods")
+		minLines   = flag.Int("min-lines", 0, "Only instrument functions whose body spans at least this many lines")
+		convertFmt = flag.Bool("convert-fmt", false, "Turn fmt.Println/Printf calls that look like debug output into devtrace logger calls")
+		fmtExclude = flag.String("fmt-exclude", "", "Leave fmt output alone in functions whose name (Func or Type.Method) matches this regexp")
+		closures   = flag.Bool("closures", false, "Also instrument function literals (closures, goroutine bodies, handler funcs), named like GetUser.func1")
+		modulePath = flag.String("module-path", "", "Import path of the devtrace package (default: resolved from go.mod)")
+		overlay    = flag.String("overlay", "", "Write instrumented copies to a temp dir and a go build -overlay file here, leaving sources untouched")
+		overlayDir = flag.String("overlay-dir", "", "Directory for the -overlay copies (default: a new temp dir)")
+		workers    = flag.Int("workers", 0, "Number of files processed in parallel (default: GOMAXPROCS)")
+		watch      = flag.Bool("watch", false, "Keep running and re-instrument files as they change (needs -out, -overlay or -dry-run)")
+		interval   = flag.Duration("watch-interval", 500*time.Millisecond, "How often -watch checks the source tree for changes")
+		sourceMap  = flag.String("source-map", "", "Write a source map here mapping instrumented lines back to the original files (needs -out or -overlay)")
+		configPath = flag.String("config", "", "Instrumentation profile (default: the nearest "+ProjectConfigFile+" up to the repository root; \"none\" to ignore it)")
 	)
 	flag.Parse()
 
+	project, err := loadProject(*configPath, *srcDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if project != nil && *verbose {
+		log.Printf("Using instrumentation profile %s", filepath.Join(project.dir, ProjectConfigFile))
+	}
+
+	if *watch && *outputDir == "" && *overlay == "" && !*dryRun {
+		log.Fatal("-watch needs -out, -overlay or -dry-run: rewriting the sources being edited would fight the editor")
+	}
+	if *sourceMap != "" && (*dryRun || *outputDir == "" && *overlay == "") {
+		log.Fatal("-source-map needs -out or -overlay: instrumenting in place leaves no original to map back to")
+	}
 	if *outputDir == "" {
 		*outputDir = *srcDir
 	}
 
 	excludePatterns := strings.Split(*exclude, ",")
 
+	includeFunc, err := compileFuncFilter("include-func", *includeFn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	excludeFunc, err := compileFuncFilter("exclude-func", *excludeFn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmtExcludeFunc, err := compileFuncFilter("fmt-exclude", *fmtExclude)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	importPath := *modulePath
+	if importPath == "" {
+		importPath = ResolveImportPath(*srcDir)
+	}
+	if *verbose {
+		log.Printf("Using devtrace import path %s", importPath)
+	}
+
 	instrumenter := &Instrumenter{
 		OutputDir:       *outputDir,
 		ExcludePatterns: excludePatterns,
@@ -38,45 +104,101 @@ func main() {
 		Verbose:         *verbose,
 		AddTrace:        *addTrace,
 		AddLogging:      *addLogging,
+		TraceGoroutines: *traceGo,
+		CaptureResults:  *captureRes,
+		CapturePanics:   *capturePan,
+		IncludeFunc:     includeFunc,
+		ExcludeFunc:     excludeFunc,
+		ExportedOnly:    *exported,
+		MinLines:        *minLines,
+		Closures:        *closures,
+		ConvertFmt:      *convertFmt,
+		FmtExclude:      fmtExcludeFunc,
+		ImportPath:      importPath,
+		Color:           isTerminal(os.Stdout),
 	}
 
-	err := filepath.Walk(*srcDir, func(path string, info os.FileInfo, err error) error {
+	if *overlay != "" {
+		ov, err := NewOverlay(*overlayDir)
 		if err != nil {
-			return err
+			log.Fatalf("Error creating overlay directory: %v", err)
 		}
+		instrumenter.Overlay = ov
+	}
 
-		if !info.Mode().IsRegular() {
-			return nil
-		}
+	if *sourceMap != "" {
+		instrumenter.SourceMap = NewSourceMap()
+	}
 
-		if !strings.HasSuffix(path, ".go") {
-			return nil
-		}
+	collect := func(verbose bool) ([]string, error) {
+		return collectFiles(*srcDir, *pattern, excludePatterns, project, verbose)
+	}
 
-		if match, matchErr := filepath.Match(*pattern, filepath.Base(path)); matchErr != nil {
-			return matchErr
-		} else if !match {
-			return nil
+	if *watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// Output written below the source tree must not be picked up as source
+		var outputs []string
+		if *outputDir != *srcDir {
+			outputs = append(outputs, *outputDir)
+		}
+		if instrumenter.Overlay != nil {
+			outputs = append(outputs, instrumenter.Overlay.Dir)
 		}
 
-		// Check exclusion patterns
-		for _, excludePattern := range excludePatterns {
-			if excludePattern != "" && strings.Contains(path, excludePattern) {
-				if *verbose {
-					log.Printf("Excluding: %s (matches %s)", path, excludePattern)
-				}
-				return nil
-			}
+		passes := 0
+		watcher := &Watcher{
+			Instrumenter: instrumenter,
+			Collect: func() ([]string, error) {
+				passes++
+				paths, err := collect(*verbose && passes == 1) // exclusions are logged once
+				return withoutDirs(paths, outputs), err
+			},
+			Interval:      *interval,
+			Workers:       *workers,
+			OverlayFile:   *overlay,
+			SourceMapFile: *sourceMap,
+		}
+		if instrumenter.Overlay != nil {
+			fmt.Fprintf(os.Stderr, "Build with: go build -overlay %s\n", *overlay)
+		}
+		if err := watcher.Run(ctx); err != nil {
+			log.Fatal(err)
 		}
+		return
+	}
 
-		return instrumenter.InstrumentFile(path)
-	})
+	paths, err := collect(*verbose)
+	if err != nil {
+		log.Fatalf("Error scanning %s: %v", *srcDir, err)
+	}
 
+	summary, err := instrumenter.InstrumentFiles(paths, *workers)
 	if err != nil {
-		log.Fatalf("Error instrumenting files: %v", err)
+		log.Fatalf("Error instrumenting files (%s):\n%v", summary, err)
 	}
 
-	fmt.Println("Instrumentation complete!")
+	if instrumenter.Overlay != nil {
+		if err := instrumenter.Overlay.WriteFile(*overlay); err != nil {
+			log.Fatalf("Error writing overlay: %v", err)
+		}
+		fmt.Printf("Overlay written to %s (%d files); build with: go build -overlay %s\n", *overlay, len(instrumenter.Overlay.Replace), *overlay)
+	}
+
+	if instrumenter.SourceMap != nil {
+		if err := instrumenter.SourceMap.WriteFile(*sourceMap); err != nil {
+			log.Fatalf("Error writing source map: %v", err)
+		}
+		fmt.Printf("Source map written to %s; load it with devtrace.LoadSourceMap or DEVTRACE_SOURCE_MAP\n", *sourceMap)
+	}
+
+	if *dryRun {
+		// stdout carries the diff
+		fmt.Fprintf(os.Stderr, "Dry run: %s\n", summary)
+	} else {
+		fmt.Printf("Instrumentation complete: %s\n", summary)
+	}
 }
 
 type Instrumenter struct {
@@ -86,9 +208,24 @@ type Instrumenter struct {
 	Verbose         bool
 	AddTrace        bool
 	AddLogging      bool
+	TraceGoroutines bool
+	CaptureResults  bool
+	CapturePanics   bool
+	IncludeFunc     *regexp.Regexp
+	ExcludeFunc     *regexp.Regexp
+	ExportedOnly    bool
+	MinLines        int
+	Closures        bool
+	ConvertFmt      bool
+	FmtExclude      *regexp.Regexp
+	ImportPath      string
+	Overlay         *Overlay   // when set, output goes to the overlay instead of OutputDir
+	Color           bool       // colorize -dry-run diffs
+	SourceMap       *SourceMap // when set, maps the lines of every written copy back to its source
 }
 
-func (i *Instrumenter) InstrumentFile(filePath string) error {
+// instrumentFile instruments one file, writing it out unless DryRun is set
+func (i *Instrumenter) instrumentFile(filePath string) fileResult {
 	if i.Verbose {
 		log.Printf("Processing: %s", filePath)
 	}
@@ -96,33 +233,180 @@ func (i *Instrumenter) InstrumentFile(filePath string) error {
 	fset := token.NewFileSet()
 	node, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
 	if err != nil {
-		return fmt.Errorf("failed to parse %s: %v", filePath, err)
+		return fileResult{err: fmt.Errorf("failed to parse %s: %v", filePath, err)}
+	}
+
+	dec := decorator.NewDecorator(fset)
+	file, err := dec.DecorateFile(node)
+	if err != nil {
+		return fileResult{err: fmt.Errorf("failed to decorate %s: %v", filePath, err)}
 	}
 
 	transformer := &ASTTransformer{
-		FileSet:    fset,
-		AddTrace:   i.AddTrace,
-		AddLogging: i.AddLogging,
-		Verbose:    i.Verbose,
+		FileSet:         fset,
+		Decorator:       dec,
+		AddTrace:        i.AddTrace,
+		AddLogging:      i.AddLogging,
+		TraceGoroutines: i.TraceGoroutines,
+		CaptureResults:  i.CaptureResults,
+		CapturePanics:   i.CapturePanics,
+		IncludeFunc:     i.IncludeFunc,
+		ExcludeFunc:     i.ExcludeFunc,
+		ExportedOnly:    i.ExportedOnly,
+		MinLines:        i.MinLines,
+		Closures:        i.Closures,
+		ConvertFmt:      i.ConvertFmt,
+		FmtExclude:      i.FmtExclude,
+		ImportPath:      i.ImportPath,
+		Verbose:         i.Verbose,
 	}
 
-	modified := transformer.Transform(node)
+	modified := transformer.Transform(file)
 
 	if !modified {
 		if i.Verbose {
 			log.Printf("No changes needed for: %s", filePath)
 		}
-		return nil
+		return fileResult{}
 	}
 
+	result := fileResult{changed: true, functions: transformer.instrumented}
 	if i.DryRun {
-		log.Printf("Would modify: %s", filePath)
-		return nil
+		result.diff, result.err = i.diff(filePath, transformer, file)
+		return result
 	}
 
 	// Write the modified file
 	outputPath := i.getOutputPath(filePath)
-	return transformer.WriteFile(outputPath, node)
+	if i.Overlay != nil {
+		if outputPath, err = i.Overlay.Add(filePath); err != nil {
+			result.err = err
+			return result
+		}
+	}
+	if result.err = transformer.WriteFile(outputPath, file); result.err != nil || i.SourceMap == nil {
+		return result
+	}
+
+	// The binary reports overlay copies under the source path
+	mapped := outputPath
+	if i.Overlay != nil {
+		mapped = filePath
+	}
+	original, err := os.ReadFile(filePath)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	instrumented, err := os.ReadFile(outputPath)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.err = i.SourceMap.Add(mapped, filePath, original, instrumented)
+	return result
+}
+
+// diff shows what instrumenting filePath would change
+func (i *Instrumenter) diff(filePath string, transformer *ASTTransformer, file *dst.File) (string, error) {
+	original, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", filePath, err)
+	}
+	instrumented, err := transformer.Render(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to render %s: %v", filePath, err)
+	}
+
+	name := filepath.ToSlash(filePath)
+	aName, bName := name, name
+	if !filepath.IsAbs(filePath) {
+		aName, bName = "a/"+name, "b/"+name
+	}
+	diff := UnifiedDiff(aName, bName, original, instrumented)
+	if i.Color {
+		diff = colorizeDiff(diff)
+	}
+	return diff, nil
+}
+
+// loadProject reads the instrumentation profile named by -config, or found
+// from srcDir, and applies it to the flags not given on the command line
+func loadProject(configPath, srcDir string) (*ProjectConfig, error) {
+	if configPath == "none" {
+		return nil, nil
+	}
+	if configPath == "" {
+		if configPath = FindProjectConfig(srcDir); configPath == "" {
+			return nil, nil
+		}
+	}
+
+	project, err := LoadProjectConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := project.ApplyFlags(flag.CommandLine); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// collectFiles lists the .go files under srcDir that match pattern and aren't
+// excluded by a pattern or the project profile
+func collectFiles(srcDir, pattern string, excludePatterns []string, project *ProjectConfig, verbose bool) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		if match, matchErr := filepath.Match(pattern, filepath.Base(path)); matchErr != nil {
+			return matchErr
+		} else if !match {
+			return nil
+		}
+
+		// Check exclusion patterns
+		for _, excludePattern := range excludePatterns {
+			if excludePattern != "" && strings.Contains(path, excludePattern) {
+				if verbose {
+					log.Printf("Excluding: %s (matches %s)", path, excludePattern)
+				}
+				return nil
+			}
+		}
+		if project != nil && !project.Selects(path) {
+			if verbose {
+				log.Printf("Excluding: %s (not selected by %s)", path, ProjectConfigFile)
+			}
+			return nil
+		}
+
+		paths = append(paths, path)
+		return nil
+	})
+	return paths, err
+}
+
+// compileFuncFilter compiles the regexp given to a function filter flag; "" means no filter
+func compileFuncFilter(flagName, pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -%s: %v", flagName, err)
+	}
+	return re, nil
 }
 
 func (i *Instrumenter) getOutputPath(inputPath string) string {
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const storeSource = `package store
+
+// Get returns the value stored under key
+func Get(m map[string]int, key string) int {
+	v := m[key]
+	return v
+}
+`
+
+const mainSource = `package main
+
+import (
+	"fmt"
+
+	"example.com/instrumented/store"
+)
+
+func main() {
+	fmt.Println(double(store.Get(map[string]int{"a": 21}, "a")))
+}
+
+func double(n int) int {
+	return n * 2
+}
+`
+
+func TestInstrumentFilesToOverlay(t *testing.T) {
+	dir := testModule(t, map[string]string{"main.go": mainSource, "store/store.go": storeSource})
+	paths := []string{filepath.Join(dir, "main.go"), filepath.Join(dir, "store", "store.go")}
+
+	overlay, err := NewOverlay(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	instrumenter := &Instrumenter{
+		AddTrace:       true,
+		CaptureResults: true,
+		ImportPath:     DefaultImportPath,
+		Overlay:        overlay,
+		SourceMap:      NewSourceMap(),
+	}
+	summary, err := instrumenter.InstrumentFiles(paths, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Scanned != 2 || summary.Changed != 2 || summary.Functions != 2 || summary.Failed != 0 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+
+	// The sources stay untouched and the copies build in their place
+	if src, _ := os.ReadFile(paths[1]); string(src) != storeSource {
+		t.Fatalf("the overlay build rewrote the source:\n%s", src)
+	}
+	overlayFile := filepath.Join(dir, "overlay.json")
+	if err := overlay.WriteFile(overlayFile); err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct{ Replace map[string]string }
+	data, _ := os.ReadFile(overlayFile)
+	if err := json.Unmarshal(data, &parsed); err != nil || len(parsed.Replace) != 2 {
+		t.Fatalf("unexpected overlay file (%v):\n%s", err, data)
+	}
+	copied, err := os.ReadFile(parsed.Replace[paths[1]])
+	if err != nil || !strings.Contains(string(copied), `devtrace.CreateFrame("Get"`) {
+		t.Fatalf("expected an instrumented copy of store.go (%v):\n%s", err, copied)
+	}
+	if out := goCommand(t, dir, "run", "-overlay", overlayFile, "."); strings.TrimSpace(out) != "42" {
+		t.Fatalf("unexpected output of the overlay build: %q", out)
+	}
+
+	// The source map sends the lines of each copy back to the original ones
+	sourceMapFile := filepath.Join(dir, "sourcemap.json")
+	if err := instrumenter.SourceMap.WriteFile(sourceMapFile); err != nil {
+		t.Fatal(err)
+	}
+	var sourceMap struct{ Files []SourceMapFile }
+	data, _ = os.ReadFile(sourceMapFile)
+	if err := json.Unmarshal(data, &sourceMap); err != nil || len(sourceMap.Files) != 2 {
+		t.Fatalf("unexpected source map (%v):\n%s", err, data)
+	}
+	store := sourceMap.Files[1]
+	copiedLines := strings.Split(string(copied), "\n")
+	for i, original := range store.Lines {
+		if original == 0 {
+			continue
+		}
+		if want := strings.Split(storeSource, "\n")[original-1]; copiedLines[i] != want && !strings.HasPrefix(copiedLines[i], "func Get") {
+			t.Errorf("line %d of the copy maps to line %d %q, but reads %q", i+1, original, want, copiedLines[i])
+		}
+	}
+	if store.File != paths[1] || store.Source != paths[1] || store.Lines[0] != 1 {
+		t.Errorf("unexpected mapping of store.go: %+v", store)
+	}
+}
+
+func TestInstrumentFilesKeepsGoingPastFailures(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.go")
+	broken := filepath.Join(dir, "broken.go")
+	os.WriteFile(good, []byte(storeSource), 0644)
+	os.WriteFile(broken, []byte("package store\n\nfunc {\n"), 0644)
+
+	overlay, err := NewOverlay(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	instrumenter := &Instrumenter{AddTrace: true, ImportPath: DefaultImportPath, Overlay: overlay}
+	summary, err := instrumenter.InstrumentFiles([]string{broken, good}, 0)
+	if err == nil || !strings.Contains(err.Error(), "failed to parse "+broken) {
+		t.Fatalf("expected the parse error of broken.go, got %v", err)
+	}
+	if summary.Scanned != 2 || summary.Changed != 1 || summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+}
+
+func TestInstrumentFilesSummaryDoesNotDependOnWorkers(t *testing.T) {
+	// file<n>.go declares n+1 functions; types.go has nothing to instrument
+	// and the two broken files fail, so the counts are known up front
+	dir := t.TempDir()
+	var paths []string
+	write := func(name, src string) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	for n := 0; n < 10; n++ {
+		src := "package store\n"
+		for f := 0; f <= n; f++ {
+			src += fmt.Sprintf("\nfunc f%d_%d(x int) int {\n\treturn x + %d\n}\n", n, f, f)
+		}
+		write(fmt.Sprintf("file%d.go", n), src)
+		if n == 3 || n == 7 {
+			write(fmt.Sprintf("broken%d.go", n), "package store\n\nfunc {\n")
+		}
+	}
+	write("types.go", "package store\n\ntype ID int\n")
+
+	want := Summary{Scanned: 13, Changed: 10, Functions: 55, Failed: 2}
+	var wantErr string
+	for _, workers := range []int{1, 2, 4, 8, 16} {
+		for run := 0; run < 5; run++ {
+			overlay, err := NewOverlay(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			instrumenter := &Instrumenter{AddTrace: true, ImportPath: DefaultImportPath, Overlay: overlay}
+			summary, err := instrumenter.InstrumentFiles(paths, workers)
+			summary.Elapsed = 0
+			if summary != want {
+				t.Fatalf("%d workers: got %s, want %s", workers, summary, want)
+			}
+			if len(overlay.Replace) != want.Changed {
+				t.Fatalf("%d workers: %d files in the overlay, want %d", workers, len(overlay.Replace), want.Changed)
+			}
+			// Failures are reported in input order, whichever worker hit them
+			if err == nil {
+				t.Fatalf("%d workers: expected the parse errors of the broken files", workers)
+			}
+			if wantErr == "" {
+				wantErr = err.Error()
+			} else if err.Error() != wantErr {
+				t.Fatalf("%d workers: got error\n%v\nwant\n%s", workers, err, wantErr)
+			}
+		}
+	}
+	if first, second := strings.Index(wantErr, "broken3.go"), strings.Index(wantErr, "broken7.go"); first < 0 || second < first {
+		t.Fatalf("expected broken3.go to be reported before broken7.go:\n%s", wantErr)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultImportPath is the import path of the devtrace package used when it can't
+// be resolved from go.mod
+const DefaultImportPath = "github.com/skulidropek/gotrace"
+
+// ResolveImportPath finds the go.mod that governs dir and returns the import path
+// of the devtrace package for that module: the module itself when it is gotrace
+// (or a fork of it), otherwise the gotrace module it requires. It falls back to
+// DefaultImportPath when there is no go.mod or nothing in it matches.
+func ResolveImportPath(dir string) string {
+	modFile := findGoMod(dir)
+	if modFile == "" {
+		return DefaultImportPath
+	}
+
+	module, requires, err := parseGoMod(modFile)
+	if err != nil {
+		return DefaultImportPath
+	}
+
+	if isGotraceModule(module) {
+		return module
+	}
+	for _, req := range requires {
+		if req == DefaultImportPath {
+			return req
+		}
+	}
+	for _, req := range requires {
+		if isGotraceModule(req) {
+			return req
+		}
+	}
+	return DefaultImportPath
+}
+
+// isGotraceModule reports whether path looks like gotrace or a fork of it
+func isGotraceModule(path string) bool {
+	return path == DefaultImportPath || strings.HasSuffix(path, "/gotrace")
+}
+
+func findGoMod(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		candidate := filepath.Join(dir, "go.mod")
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// parseGoMod extracts the module path and required module paths from a go.mod file.
+// Only the module and require directives are understood; everything else is ignored.
+func parseGoMod(path string) (module string, requires []string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	inRequire := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if inRequire {
+			if fields[0] == ")" {
+				inRequire = false
+			} else {
+				requires = append(requires, unquoteModPath(fields[0]))
+			}
+			continue
+		}
+
+		switch fields[0] {
+		case "module":
+			if len(fields) > 1 {
+				module = unquoteModPath(fields[1])
+			}
+		case "require":
+			if len(fields) > 1 && fields[1] == "(" {
+				inRequire = true
+			} else if len(fields) > 1 {
+				requires = append(requires, unquoteModPath(fields[1]))
+			}
+		}
+	}
+	return module, requires, scanner.Err()
+}
+
+func unquoteModPath(s string) string {
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveImportPath(t *testing.T) {
+	for _, tc := range []struct {
+		name, goMod, want string
+	}{
+		{"requires gotrace", "module example.com/app\n\nrequire github.com/skulidropek/gotrace v1.2.0\n", DefaultImportPath},
+		{"requires a fork", "module example.com/app\n\nrequire (\n\tgithub.com/acme/gotrace v0.1.0\n\tgolang.org/x/sync v0.1.0\n)\n", "github.com/acme/gotrace"},
+		{"is a fork", "module github.com/acme/gotrace\n", "github.com/acme/gotrace"},
+		{"doesn't use gotrace", "module example.com/app\n", DefaultImportPath},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(tc.goMod), 0644); err != nil {
+				t.Fatal(err)
+			}
+			pkg := filepath.Join(dir, "internal", "app")
+			if err := os.MkdirAll(pkg, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if got := ResolveImportPath(pkg); got != tc.want {
+				t.Fatalf("ResolveImportPath = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
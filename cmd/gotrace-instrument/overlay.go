@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Overlay collects instrumented copies of source files outside the working tree
+// and describes them in the format `go build -overlay` expects, so instrumented
+// builds don't need the instrumentation to be written back to the sources
+type Overlay struct {
+	Dir     string            // directory holding the instrumented copies
+	Replace map[string]string // absolute source path → absolute instrumented copy
+
+	mu sync.Mutex // guards Replace while files are instrumented concurrently
+}
+
+// NewOverlay creates an overlay storing its copies in dir, or in a new temporary
+// directory when dir is empty
+func NewOverlay(dir string) (*Overlay, error) {
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "gotrace-overlay-")
+		if err != nil {
+			return nil, err
+		}
+		dir = tmp
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Overlay{Dir: abs, Replace: make(map[string]string)}, nil
+}
+
+// Add registers a source file and returns where its instrumented copy goes.
+// Copies mirror the source's absolute path under Dir so equal base names in
+// different packages never collide.
+func (o *Overlay) Add(src string) (string, error) {
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return "", err
+	}
+
+	rel := abs
+	if volume := filepath.VolumeName(abs); volume != "" {
+		rel = abs[len(volume):]
+	}
+	copyPath := filepath.Join(o.Dir, rel)
+
+	o.mu.Lock()
+	o.Replace[abs] = copyPath
+	o.mu.Unlock()
+	return copyPath, nil
+}
+
+// Remove drops a source file from the overlay and deletes its copy
+func (o *Overlay) Remove(src string) error {
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	copyPath, ok := o.Replace[abs]
+	delete(o.Replace, abs)
+	o.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if err := os.Remove(copyPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// WriteFile writes the overlay JSON to path
+func (o *Overlay) WriteFile(path string) error {
+	data, err := json.MarshalIndent(struct {
+		Replace map[string]string
+	}{o.Replace}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write overlay %s: %v", path, err)
+	}
+	return nil
+}
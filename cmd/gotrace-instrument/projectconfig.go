@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFile is the instrumentation profile a team keeps at the root of
+// its repository, so everyone instruments with the same settings:
+//
+//	include: ["internal/**", "cmd/**"]
+//	exclude: ["**/*_test.go", "vendor/**", "**/mocks/**"]
+//	functions:
+//	  include: '^(\*?\w+Service)\.'
+//	  exported_only: true
+//	  min_lines: 5
+//	  closures: true
+//	logging: false
+//	convert_fmt: true
+//	fmt_exclude: '^(main|usage|\*CLI\..*)$'
+//	output:
+//	  mode: overlay
+//	  overlay: build/overlay.json
+//	module_path: github.com/acme/app/third_party/gotrace
+//
+// Flags given on the command line override the file.
+const ProjectConfigFile = ".gotrace.yaml"
+
+// ProjectConfig is the content of a ProjectConfigFile. Paths and globs are
+// relative to the directory holding the file.
+type ProjectConfig struct {
+	Include         []string        `yaml:"include"` // globs of files to instrument; all .go files when empty
+	Exclude         []string        `yaml:"exclude"` // globs of files to leave alone
+	Functions       FunctionFilters `yaml:"functions"`
+	Trace           *bool           `yaml:"trace"`   // -add-trace
+	Logging         *bool           `yaml:"logging"` // -add-logging: rewrite log calls
+	ConvertFmt      *bool           `yaml:"convert_fmt"`
+	FmtExclude      string          `yaml:"fmt_exclude"` // functions whose fmt output is user-facing
+	TraceGoroutines *bool           `yaml:"trace_goroutines"`
+	CaptureResults  *bool           `yaml:"capture_results"`
+	CapturePanics   *bool           `yaml:"capture_panics"`
+	Output          OutputConfig    `yaml:"output"`
+	ModulePath      string          `yaml:"module_path"`
+	Workers         *int            `yaml:"workers"`
+
+	dir string // directory the file was read from
+}
+
+// FunctionFilters mirror the function filtering flags
+type FunctionFilters struct {
+	Include      string `yaml:"include"` // -include-func
+	Exclude      string `yaml:"exclude"` // -exclude-func
+	ExportedOnly *bool  `yaml:"exported_only"`
+	MinLines     *int   `yaml:"min_lines"`
+	Closures     *bool  `yaml:"closures"`
+}
+
+// OutputConfig selects where instrumented files go
+type OutputConfig struct {
+	Mode    string `yaml:"mode"`    // write (default), dry-run or overlay
+	Dir     string `yaml:"dir"`     // -out when writing, -overlay-dir with an overlay
+	Overlay string `yaml:"overlay"` // overlay file for mode overlay
+}
+
+// FindProjectConfig looks for a ProjectConfigFile in dir and its parents, up to
+// the root of the repository dir is in. It returns "" when there is none.
+func FindProjectConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		candidate := filepath.Join(dir, ProjectConfigFile)
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "" // don't leave the repository
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig reads and checks a ProjectConfigFile. Unknown keys are
+// errors, so a typo doesn't silently fall back to a default.
+func LoadProjectConfig(file string) (*ProjectConfig, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ProjectConfig{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	config.dir = filepath.Dir(file)
+
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return config, nil
+}
+
+func (c *ProjectConfig) validate() error {
+	var errs []error
+	for _, glob := range append(append([]string(nil), c.Include...), c.Exclude...) {
+		if _, err := path.Match(strings.ReplaceAll(glob, "**", "*"), ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid glob %q: %v", glob, err))
+		}
+	}
+	switch c.Output.Mode {
+	case "", "write", "dry-run":
+	case "overlay":
+		if c.Output.Overlay == "" {
+			errs = append(errs, errors.New("output mode overlay needs output.overlay, the overlay file to write"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown output mode %q: use write, dry-run or overlay", c.Output.Mode))
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyFlags sets every flag the file configures and the command line didn't,
+// so explicit flags win over the shared profile
+func (c *ProjectConfig) ApplyFlags(flags *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	values := make(map[string]string)
+	setBool := func(name string, v *bool) {
+		if v != nil {
+			values[name] = strconv.FormatBool(*v)
+		}
+	}
+	setInt := func(name string, v *int) {
+		if v != nil {
+			values[name] = strconv.Itoa(*v)
+		}
+	}
+	setString := func(name, v string) {
+		if v != "" {
+			values[name] = v
+		}
+	}
+
+	setString("include-func", c.Functions.Include)
+	setString("exclude-func", c.Functions.Exclude)
+	setBool("exported-only", c.Functions.ExportedOnly)
+	setInt("min-lines", c.Functions.MinLines)
+	setBool("closures", c.Functions.Closures)
+	setBool("add-trace", c.Trace)
+	setBool("add-logging", c.Logging)
+	setBool("convert-fmt", c.ConvertFmt)
+	setString("fmt-exclude", c.FmtExclude)
+	setBool("trace-goroutines", c.TraceGoroutines)
+	setBool("capture-results", c.CaptureResults)
+	setBool("capture-panics", c.CapturePanics)
+	setString("module-path", c.ModulePath)
+	setInt("workers", c.Workers)
+	switch c.Output.Mode {
+	case "dry-run":
+		values["dry-run"] = "true"
+	case "overlay":
+		values["overlay"] = c.resolve(c.Output.Overlay)
+		if c.Output.Dir != "" {
+			values["overlay-dir"] = c.resolve(c.Output.Dir)
+		}
+	default:
+		if c.Output.Dir != "" {
+			values["out"] = c.resolve(c.Output.Dir)
+		}
+	}
+
+	// -dry-run and -overlay on the command line pick the output mode on their own
+	if explicit["dry-run"] || explicit["overlay"] {
+		delete(values, "dry-run")
+		delete(values, "overlay")
+		delete(values, "overlay-dir")
+		delete(values, "out")
+	}
+
+	for name, value := range values {
+		if explicit[name] {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("%s: invalid value for -%s: %v", ProjectConfigFile, name, err)
+		}
+	}
+	return nil
+}
+
+func (c *ProjectConfig) resolve(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(c.dir, p)
+}
+
+// Selects reports whether the include and exclude globs select file
+func (c *ProjectConfig) Selects(file string) bool {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(c.dir, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return len(c.Include) == 0
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, glob := range c.Exclude {
+		if matchGlob(glob, rel) {
+			return false
+		}
+	}
+	if len(c.Include) == 0 {
+		return true
+	}
+	for _, glob := range c.Include {
+		if matchGlob(glob, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated path against a glob in which ** stands
+// for any number of directories. A glob without a slash matches the base name,
+// and a glob naming a directory matches everything below it.
+func matchGlob(glob, name string) bool {
+	if !strings.Contains(glob, "/") && glob != "**" {
+		ok, _ := path.Match(glob, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.TrimSuffix(glob, "/"), "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(glob, name []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(glob[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], name[0]); !ok {
+			return false
+		}
+		glob, name = glob[1:], name[1:]
+	}
+	return true // the rest of name lies below the matched directory
+}
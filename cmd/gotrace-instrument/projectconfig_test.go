@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeProjectConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, ProjectConfigFile)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProjectConfigAppliesToFlagsNotGiven(t *testing.T) {
+	dir := t.TempDir()
+	config, err := LoadProjectConfig(writeProjectConfig(t, dir, `
+functions:
+  include: '^\w+Service\.'
+  min_lines: 5
+  closures: true
+logging: false
+output:
+  mode: overlay
+  overlay: build/overlay.json
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := flag.NewFlagSet("gotrace-instrument", flag.ContinueOnError)
+	includeFunc := flags.String("include-func", "", "")
+	minLines := flags.Int("min-lines", 0, "")
+	closures := flags.Bool("closures", false, "")
+	addLogging := flags.Bool("add-logging", true, "")
+	overlay := flags.String("overlay", "", "")
+	if err := flags.Parse([]string{"-min-lines", "10"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.ApplyFlags(flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if *includeFunc != `^\w+Service\.` || !*closures || *addLogging {
+		t.Errorf("expected the profile's settings, got include %q, closures %v, logging %v", *includeFunc, *closures, *addLogging)
+	}
+	if *minLines != 10 {
+		t.Errorf("expected -min-lines from the command line to win, got %d", *minLines)
+	}
+	if *overlay != filepath.Join(dir, "build", "overlay.json") {
+		t.Errorf("expected the overlay file relative to the profile, got %q", *overlay)
+	}
+}
+
+func TestLoadProjectConfigRejectsMistakes(t *testing.T) {
+	for _, tc := range []struct {
+		name, content, want string
+	}{
+		{"unknown key", "functions:\n  exported: true\n", "field exported not found"},
+		{"bad glob", "include: ['[']\n", `invalid glob "["`},
+		{"overlay without file", "output:\n  mode: overlay\n", "needs output.overlay"},
+		{"unknown mode", "output:\n  mode: inplace\n", `unknown output mode "inplace"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadProjectConfig(writeProjectConfig(t, t.TempDir(), tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestProjectConfigSelects(t *testing.T) {
+	dir := t.TempDir()
+	config := &ProjectConfig{
+		Include: []string{"internal/**", "cmd/**"},
+		Exclude: []string{"**/*_test.go", "**/mocks/**"},
+		dir:     dir,
+	}
+	for file, want := range map[string]bool{
+		"internal/user/service.go":      true,
+		"cmd/app/main.go":               true,
+		"internal/user/service_test.go": false,
+		"internal/user/mocks/repo.go":   false,
+		"pkg/util/strings.go":           false,
+	} {
+		if got := config.Selects(filepath.Join(dir, filepath.FromSlash(file))); got != want {
+			t.Errorf("Selects(%s) = %v, want %v", file, got, want)
+		}
+	}
+}
+
+func TestFindProjectConfigStopsAtRepositoryRoot(t *testing.T) {
+	outside := t.TempDir()
+	writeProjectConfig(t, outside, "logging: false\n")
+	repo := filepath.Join(outside, "repo")
+	pkg := filepath.Join(repo, "internal", "user")
+	if err := os.MkdirAll(pkg, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := FindProjectConfig(pkg); found != "" {
+		t.Fatalf("expected no profile inside the repository, found %s", found)
+	}
+	want := writeProjectConfig(t, repo, "logging: false\n")
+	if found := FindProjectConfig(pkg); found != want {
+		t.Fatalf("expected %s, found %q", want, found)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// SourceMap records, for every instrumented copy, which original line each of
+// its lines came from. devtrace.LoadSourceMap reads it so stack frames and
+// snippets of an -overlay or -out build point at the original sources.
+type SourceMap struct {
+	mu    sync.Mutex
+	files map[string]SourceMapFile
+}
+
+// SourceMapFile maps the lines of one instrumented file
+type SourceMapFile struct {
+	File   string `json:"file"`   // instrumented file, as the compiled binary reports it
+	Source string `json:"source"` // original file
+	Lines  []int  `json:"lines"`  // original line of each line of File; 0 for lines instrumentation added
+}
+
+// NewSourceMap returns an empty source map
+func NewSourceMap() *SourceMap {
+	return &SourceMap{files: make(map[string]SourceMapFile)}
+}
+
+// Add maps the lines of the instrumented copy of source. file is the name the
+// binary reports for the copy: the source path itself in an overlay build.
+func (m *SourceMap) Add(file, source string, original, instrumented []byte) error {
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+
+	// Within a change, rewritten lines (a func line whose results got names)
+	// map to the lines they replace, in order; the rest were added
+	var lines, replaced []int
+	originalLine := 0
+	for _, line := range diffLines(splitLines(string(original)), splitLines(string(instrumented))) {
+		switch line.op {
+		case diffEqual:
+			originalLine++
+			lines = append(lines, originalLine)
+			replaced = replaced[:0]
+		case diffDelete:
+			originalLine++
+			replaced = append(replaced, originalLine)
+		case diffInsert:
+			if len(replaced) > 0 {
+				lines = append(lines, replaced[0])
+				replaced = replaced[1:]
+			} else {
+				lines = append(lines, 0)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	m.files[absFile] = SourceMapFile{File: absFile, Source: absSource, Lines: lines}
+	m.mu.Unlock()
+	return nil
+}
+
+// Remove drops the mapping of the instrumented copy of source
+func (m *SourceMap) Remove(source string) {
+	abs, err := filepath.Abs(source)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for file, mapped := range m.files {
+		if mapped.Source == abs {
+			delete(m.files, file)
+		}
+	}
+}
+
+// WriteFile writes the source map JSON to path
+func (m *SourceMap) WriteFile(path string) error {
+	m.mu.Lock()
+	files := make([]SourceMapFile, 0, len(m.files))
+	for _, file := range m.files {
+		files = append(files, file)
+	}
+	m.mu.Unlock()
+	sort.Slice(files, func(i, j int) bool { return files[i].File < files[j].File })
+
+	data, err := json.Marshal(struct {
+		Files []SourceMapFile `json:"files"`
+	}{files})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write source map %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+
+	dt "github.com/skulidropek/gotrace"
+)
+
+func main() {
+	dt.UpdateConfig(func(c *dt.DevTraceConfig) { c.Enabled = true })
+	fmt.Println(sum(1, 2))
+}
+
+func sum(a, b int) int {
+	return a + b
+}
@@ -0,0 +1,32 @@
+package main
+
+import "fmt"
+
+type Counter struct{ n int }
+
+func (c *Counter) Inc() {
+	c.n++
+	fmt.Println("count", c.n)
+}
+
+func main() {
+	run()
+}
+
+func run() {
+	c := &Counter{}
+	inc := c.Inc // a method value is traced through Counter.Inc itself
+	each([]int{1, 2}, func(i int) {
+		inc()
+		fmt.Println("item", i)
+	})
+	defer func() {
+		fmt.Println("cleanup")
+	}()
+}
+
+func each(items []int, fn func(int)) {
+	for _, item := range items {
+		fn(item)
+	}
+}
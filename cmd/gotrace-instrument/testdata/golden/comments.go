@@ -0,0 +1,19 @@
+// Package main keeps its comments through instrumentation.
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(greet("world"))
+}
+
+// greet builds a greeting.
+//
+// The doc comment, the inline comments and the blank lines stay put.
+func greet(name string) string {
+	// a leading comment
+	prefix := "hello" // trailing comment
+
+	/* a block comment */
+	return prefix + ", " + name
+}
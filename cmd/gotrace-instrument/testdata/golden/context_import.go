@@ -0,0 +1,11 @@
+package main
+
+import "log"
+
+func main() {
+	report(2)
+}
+
+func report(count int) {
+	log.Printf("reported %d", count)
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"log"
+)
+
+func main() {
+	handle(context.Background(), 1)
+	report(2)
+}
+
+// handle logs with the request's own ctx
+func handle(ctx context.Context, id int) {
+	log.Printf("handling %d", id)
+	log.Println("done", id)
+}
+
+// report has no ctx, so its log call gets context.Background()
+func report(count int) {
+	log.Print("reported ", count)
+}
@@ -0,0 +1,19 @@
+package main
+
+import "fmt"
+
+func main() {
+	load(3)
+	usage()
+}
+
+func load(id int) {
+	fmt.Printf("DEBUG: loading id=%d\n", id)
+	fmt.Println("error: not found", id)
+	fmt.Println("Loaded.")
+}
+
+// usage prints for the user and is excluded with -fmt-exclude
+func usage() {
+	fmt.Printf("usage: app [flags] id=%d\n", 0)
+}
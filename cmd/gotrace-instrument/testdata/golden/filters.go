@@ -0,0 +1,34 @@
+package main
+
+import "fmt"
+
+type Store struct{ items map[string]int }
+
+func main() {
+	s := &Store{items: map[string]int{}}
+	s.Put("a", 1)
+	fmt.Println(s.Get("a"), s.size(), helper())
+}
+
+// Put is exported and long enough
+func (s *Store) Put(key string, value int) {
+	if s.items == nil {
+		s.items = map[string]int{}
+	}
+	s.items[key] = value
+}
+
+// Get is exported but excluded by -exclude-func
+func (s *Store) Get(key string) int {
+	v := s.items[key]
+	return v
+}
+
+// size is unexported, so -exported-only skips it
+func (s *Store) size() int {
+	n := len(s.items)
+	return n
+}
+
+// helper is too short for -min-lines
+func helper() string { return "ok" }
@@ -0,0 +1,23 @@
+package main
+
+import "fmt"
+
+type List[T any] struct{ items []T }
+
+func (l *List[T]) Push(v T) {
+	l.items = append(l.items, v)
+}
+
+func Map[T, U any](in []T, f func(T) U) []U {
+	out := make([]U, 0, len(in))
+	for _, v := range in {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+func main() {
+	l := &List[int]{}
+	l.Push(1)
+	fmt.Println(Map(l.items, func(i int) string { return fmt.Sprint(i) }))
+}
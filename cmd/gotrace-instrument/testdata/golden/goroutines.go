@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+func main() {
+	fanOut(3)
+}
+
+func fanOut(n int) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fmt.Println("worker", i)
+		}(i)
+	}
+	wg.Add(1)
+	go work(&wg, n)
+	wg.Wait()
+}
+
+func work(wg *sync.WaitGroup, n int) {
+	defer wg.Done()
+	fmt.Println("work", n)
+}
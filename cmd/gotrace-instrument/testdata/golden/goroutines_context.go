@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+func main() {
+	serve(context.Background(), []string{"a", "b"})
+}
+
+// serve hands ctx's trace to the goroutines it starts, whether they get ctx or not
+func serve(ctx context.Context, jobs []string) {
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job string) {
+			defer wg.Done()
+			fmt.Println("job", job)
+		}(job)
+	}
+	wg.Add(1)
+	go handle(ctx, &wg, len(jobs))
+	wg.Wait()
+}
+
+func handle(ctx context.Context, wg *sync.WaitGroup, n int) {
+	defer wg.Done()
+	fmt.Println("handled", n, ctx.Err())
+}
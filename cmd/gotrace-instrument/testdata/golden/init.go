@@ -0,0 +1,13 @@
+package main
+
+import "fmt"
+
+var ready bool
+
+func init() {
+	ready = true
+}
+
+func main() {
+	fmt.Println(ready)
+}
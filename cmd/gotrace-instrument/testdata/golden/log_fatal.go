@@ -0,0 +1,19 @@
+package main
+
+import "log"
+
+func main() {
+	check(nil)
+}
+
+func check(err error) {
+	if err != nil {
+		log.Fatalf("check: %v", err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("recovered", r)
+		}
+	}()
+	log.Panicln("unreachable", 1)
+}
@@ -0,0 +1,12 @@
+package main
+
+func main() {
+	mustPositive(1)
+}
+
+func mustPositive(n int) int {
+	if n < 0 {
+		panic("negative")
+	}
+	return n
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+type Counter struct{ n int }
+
+func (c *Counter) Inc() {
+	devtrace.GlobalEnter(devtrace.CreateFrame("*Counter.Inc", "Inc()", "main.go", 7, map[string]interface{}{}))
+	defer devtrace.GlobalLeave()
+	defer func() {
+		devtrace.RecordPanic(recover())
+	}()
+
+	c.n++
+	fmt.Println("count", c.n)
+}
+
+func main() {
+	run()
+}
+
+func run() {
+	devtrace.GlobalEnter(devtrace.CreateFrame("run", "run()", "main.go", 16, map[string]interface{}{}))
+	defer devtrace.GlobalLeave()
+	defer func() {
+		devtrace.RecordPanic(recover())
+	}()
+
+	c := &Counter{}
+	inc := c.Inc // a method value is traced through Counter.Inc itself
+	each([]int{1, 2}, func(i int) {
+		devtrace.GlobalEnter(devtrace.CreateFrame("run.func1", "func1(i int)", "main.go", 19, map[string]interface{}{"i": i}))
+		defer devtrace.GlobalLeave()
+		defer func() {
+			devtrace.RecordPanic(recover())
+		}()
+
+		inc()
+		fmt.Println("item", i)
+	})
+	defer func() {
+		fmt.Println("cleanup")
+	}()
+}
+
+func each(items []int, fn func(int)) {
+	devtrace.GlobalEnter(devtrace.CreateFrame("each", "each(items []int, fn func(int))", "main.go", 28, map[string]interface{}{"items": items, "fn": fn}))
+	defer devtrace.GlobalLeave()
+	defer func() {
+		devtrace.RecordPanic(recover())
+	}()
+
+	for _, item := range items {
+		fn(item)
+	}
+}
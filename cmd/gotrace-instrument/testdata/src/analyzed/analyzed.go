@@ -0,0 +1,7 @@
+package analyzed
+
+func Add(a, b int) int { // want "Add is not instrumented with gotrace"
+	return a + b
+}
+
+func _skipped() {}
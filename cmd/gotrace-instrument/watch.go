@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Watcher re-instruments source files as they are edited. It polls instead of
+// relying on file system notifications, so it behaves the same on every
+// platform and editor; a file whose size and modification time haven't moved
+// is not even read, and one whose content hash is unchanged (an editor saving
+// without edits, a branch switch back and forth) is not instrumented again.
+type Watcher struct {
+	Instrumenter  *Instrumenter
+	Collect       func() ([]string, error) // lists the files to instrument, rescanned every Interval
+	Interval      time.Duration
+	Workers       int
+	OverlayFile   string // rewritten after every pass when the instrumenter writes to an overlay
+	SourceMapFile string // rewritten after every pass when the instrumenter keeps a source map
+
+	files  map[string]watchedFile
+	passes int
+}
+
+// watchedFile is the state of a file after the pass that last looked at it
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	hash    [sha256.Size]byte
+}
+
+// Run instruments every collected file, then keeps re-instrumenting the ones
+// that change until ctx is done. A failing pass is reported and watching goes
+// on; the failed files are retried once they change again.
+func (w *Watcher) Run(ctx context.Context) error {
+	if w.Interval <= 0 {
+		w.Interval = 500 * time.Millisecond
+	}
+	w.files = make(map[string]watchedFile)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.pass(); err != nil {
+			log.Printf("Error instrumenting files:\n%v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// pass instruments the files changed since the previous pass and drops the
+// removed ones from the overlay
+func (w *Watcher) pass() error {
+	paths, err := w.Collect()
+	if err != nil {
+		return err
+	}
+
+	first := w.passes == 0
+	w.passes++
+	seen := make(map[string]bool, len(paths))
+	var changed []string
+	for _, path := range paths {
+		seen[path] = true
+		if w.changed(path) {
+			changed = append(changed, path)
+		}
+	}
+
+	var removed int
+	for path := range w.files {
+		if seen[path] {
+			continue
+		}
+		delete(w.files, path)
+		removed++
+		if overlay := w.Instrumenter.Overlay; overlay != nil {
+			if err := overlay.Remove(path); err != nil {
+				log.Printf("Error removing %s from the overlay: %v", path, err)
+			}
+		}
+		if sourceMap := w.Instrumenter.SourceMap; sourceMap != nil {
+			sourceMap.Remove(path)
+		}
+	}
+	if !first && len(changed) == 0 && removed == 0 {
+		return nil
+	}
+
+	summary, err := w.Instrumenter.InstrumentFiles(changed, w.Workers)
+	// Record the files as this pass left them, so output written back into
+	// the watched tree doesn't count as an edit
+	for _, path := range changed {
+		w.record(path)
+	}
+
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if overlay := w.Instrumenter.Overlay; overlay != nil {
+		if err := overlay.WriteFile(w.OverlayFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if sourceMap := w.Instrumenter.SourceMap; sourceMap != nil {
+		if err := sourceMap.WriteFile(w.SourceMapFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	status := summary.String()
+	if removed > 0 {
+		status += fmt.Sprintf(", %d removed", removed)
+	}
+	if first {
+		fmt.Fprintf(os.Stderr, "Watching %d files; %s\n", len(paths), status)
+	} else {
+		fmt.Fprintf(os.Stderr, "[%s] Re-instrumented: %s\n", time.Now().Format("15:04:05"), status)
+	}
+	return errors.Join(errs...)
+}
+
+// changed reports whether path differs from the content recorded for it
+func (w *Watcher) changed(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return true // let instrumenting report it
+	}
+	known, ok := w.files[path]
+	if !ok {
+		return true
+	}
+	if info.Size() == known.size && info.ModTime().Equal(known.modTime) {
+		return false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return true
+	}
+	if sha256.Sum256(data) != known.hash {
+		return true
+	}
+	known.size, known.modTime = info.Size(), info.ModTime()
+	w.files[path] = known
+	return false
+}
+
+func (w *Watcher) record(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		delete(w.files, path)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		delete(w.files, path)
+		return
+	}
+	w.files[path] = watchedFile{size: info.Size(), modTime: info.ModTime(), hash: sha256.Sum256(data)}
+}
+
+// withoutDirs drops the paths inside any of dirs
+func withoutDirs(paths, dirs []string) []string {
+	var absDirs []string
+	for _, dir := range dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			absDirs = append(absDirs, abs)
+		}
+	}
+
+	kept := paths[:0]
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		inside := false
+		for _, dir := range absDirs {
+			if rel, relErr := filepath.Rel(dir, abs); err == nil && relErr == nil && !strings.HasPrefix(rel, "..") {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			kept = append(kept, path)
+		}
+	}
+	return kept
+}
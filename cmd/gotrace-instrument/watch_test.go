@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWatcherReinstrumentsOnlyChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "store.go")
+	if err := os.WriteFile(src, []byte(storeSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	overlay, err := NewOverlay(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []string{src}
+	w := &Watcher{
+		Instrumenter: &Instrumenter{AddTrace: true, ImportPath: DefaultImportPath, Overlay: overlay},
+		Collect:      func() ([]string, error) { return files, nil },
+		OverlayFile:  filepath.Join(dir, "overlay.json"),
+		files:        make(map[string]watchedFile),
+	}
+
+	if err := w.pass(); err != nil {
+		t.Fatal(err)
+	}
+	copyPath, _ := overlay.Add(src)
+	if copied, err := os.ReadFile(copyPath); err != nil || !strings.Contains(string(copied), `CreateFrame("Get"`) {
+		t.Fatalf("expected the first pass to instrument store.go (%v)", err)
+	}
+
+	// A pass over unchanged files writes nothing
+	os.Remove(copyPath)
+	if err := w.pass(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(copyPath); !os.IsNotExist(err) {
+		t.Fatalf("expected an unchanged file to be left alone, got %v", err)
+	}
+
+	// An edit is picked up
+	edited := strings.Replace(storeSource, "func Get", "func Lookup", 1)
+	if err := os.WriteFile(src, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.pass(); err != nil {
+		t.Fatal(err)
+	}
+	if copied, err := os.ReadFile(copyPath); err != nil || !strings.Contains(string(copied), `CreateFrame("Lookup"`) {
+		t.Fatalf("expected the edit to be re-instrumented (%v)", err)
+	}
+
+	// A removed file leaves the overlay
+	files = nil
+	if err := w.pass(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := overlay.Replace[src]; ok {
+		t.Fatal("expected the removed file to leave the overlay")
+	}
+	if _, err := os.Stat(copyPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the copy of the removed file to be deleted, got %v", err)
+	}
+}
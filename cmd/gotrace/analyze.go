@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+// hotFunction is where one function's time went across the analyzed sessions
+type hotFunction struct {
+	name       string
+	calls      int
+	self       time.Duration // time in the function itself, children excluded
+	cumulative time.Duration // time with children, counting recursive calls once
+	max        time.Duration
+}
+
+// hotPath counts the calls made along one path from a root frame
+type hotPath struct {
+	path  string
+	calls int
+	total time.Duration
+}
+
+// hotReport is what gotrace analyze prints
+type hotReport struct {
+	sessions  int
+	frames    int
+	wall      time.Duration // total duration of root frames
+	functions []*hotFunction
+	paths     []*hotPath
+}
+
+func runAnalyze(args []string) error {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	top := fs.Int("top", 15, "Rows per table (0 for all)")
+	sortBy := fs.String("sort", "self", "Sort functions by: self, cumulative, calls, max")
+	pattern := fs.String("pattern", "*.jsonl*", "Glob for session files inside directory arguments")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gotrace analyze [-top N] [-sort self|cumulative|calls|max] <session-file-or-dir>...")
+		fmt.Fprintln(fs.Output(), "Reports where the time goes: functions by self and cumulative time, and the")
+		fmt.Fprintln(fs.Output(), "most frequent call paths.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("at least one session file or directory is required")
+	}
+
+	var sessions []*devtrace.Session
+	for _, arg := range fs.Args() {
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			found, err := loadSessions(arg, *pattern)
+			if err != nil {
+				return err
+			}
+			sessions = append(sessions, found...)
+			continue
+		}
+		session, err := devtrace.ReadSessionFile(arg)
+		if err != nil {
+			return err
+		}
+		sessions = append(sessions, session)
+	}
+
+	report := analyzeSessions(sessions)
+	if err := sortHotFunctions(report.functions, *sortBy); err != nil {
+		return err
+	}
+	printHotReport(os.Stdout, report, *top)
+	return nil
+}
+
+func analyzeSessions(sessions []*devtrace.Session) *hotReport {
+	report := &hotReport{sessions: len(sessions)}
+	functions := make(map[string]*hotFunction)
+	paths := make(map[string]*hotPath)
+
+	var visit func(node *devtrace.CallTreeNode, path []string, open map[string]int)
+	visit = func(node *devtrace.CallTreeNode, path []string, open map[string]int) {
+		frame := node.Frame
+		report.frames++
+
+		fn := functions[frame.Function]
+		if fn == nil {
+			fn = &hotFunction{name: frame.Function}
+			functions[frame.Function] = fn
+		}
+		fn.calls++
+		if frame.Duration > fn.max {
+			fn.max = frame.Duration
+		}
+		self := frame.Duration
+		for _, child := range node.Children {
+			self -= child.Frame.Duration
+		}
+		if self > 0 {
+			fn.self += self
+		}
+		if open[frame.Function] == 0 {
+			fn.cumulative += frame.Duration
+		}
+
+		path = append(path, frame.Function)
+		key := strings.Join(path, " → ")
+		p := paths[key]
+		if p == nil {
+			p = &hotPath{path: key}
+			paths[key] = p
+		}
+		p.calls++
+		p.total += frame.Duration
+
+		open[frame.Function]++
+		for _, child := range node.Children {
+			visit(child, path, open)
+		}
+		open[frame.Function]--
+	}
+	for _, session := range sessions {
+		for _, root := range devtrace.SessionCallTree(session) {
+			report.wall += root.Frame.Duration
+			visit(root, nil, make(map[string]int))
+		}
+	}
+
+	for _, fn := range functions {
+		report.functions = append(report.functions, fn)
+	}
+	for _, p := range paths {
+		report.paths = append(report.paths, p)
+	}
+	sort.Slice(report.paths, func(i, j int) bool {
+		a, b := report.paths[i], report.paths[j]
+		if a.calls != b.calls {
+			return a.calls > b.calls
+		}
+		if a.total != b.total {
+			return a.total > b.total
+		}
+		return a.path < b.path
+	})
+	return report
+}
+
+func sortHotFunctions(functions []*hotFunction, key string) error {
+	var value func(fn *hotFunction) int64
+	switch key {
+	case "self":
+		value = func(fn *hotFunction) int64 { return int64(fn.self) }
+	case "cumulative":
+		value = func(fn *hotFunction) int64 { return int64(fn.cumulative) }
+	case "calls":
+		value = func(fn *hotFunction) int64 { return int64(fn.calls) }
+	case "max":
+		value = func(fn *hotFunction) int64 { return int64(fn.max) }
+	default:
+		return fmt.Errorf("unknown sort key %q", key)
+	}
+
+	sort.Slice(functions, func(i, j int) bool {
+		if a, b := value(functions[i]), value(functions[j]); a != b {
+			return a > b
+		}
+		return functions[i].name < functions[j].name
+	})
+	return nil
+}
+
+func printHotReport(w io.Writer, report *hotReport, top int) {
+	fmt.Fprintf(w, "%d sessions, %d frames, %v in root frames\n\n", report.sessions, report.frames, report.wall.Round(time.Microsecond))
+	if report.frames == 0 {
+		return
+	}
+
+	percent := func(d time.Duration) string {
+		if report.wall <= 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f%%", float64(d)*100/float64(report.wall))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FUNCTION\tCALLS\tSELF\tSELF%\tCUMULATIVE\tCUM%\tAVG\tMAX")
+	for i, fn := range report.functions {
+		if top > 0 && i == top {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%s\t%v\t%s\t%v\t%v\n",
+			fn.name, fn.calls, fn.self.Round(time.Microsecond), percent(fn.self),
+			fn.cumulative.Round(time.Microsecond), percent(fn.cumulative),
+			(fn.cumulative / time.Duration(fn.calls)).Round(time.Microsecond), fn.max.Round(time.Microsecond))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CALL PATH\tCALLS\tTOTAL")
+	for i, p := range report.paths {
+		if top > 0 && i == top {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%v\n", p.path, p.calls, p.total.Round(time.Microsecond))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+func TestAnalyzeReportsSelfCumulativeAndPaths(t *testing.T) {
+	out, err := runCommand(t, runAnalyze, "", checkoutSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"1 sessions, 7 frames, 40ms in root frames",
+		"",
+		"FUNCTION CALLS SELF SELF% CUMULATIVE CUM% AVG MAX",
+		"callGateway 1 15ms 37.5% 15ms 37.5% 15ms 15ms",
+		"loadCart 3 13ms 32.5% 13ms 32.5% 4.333ms 5ms",
+		"handleCheckout 2 7ms 17.5% 40ms 100.0% 20ms 30ms",
+		"chargeCard 1 5ms 12.5% 20ms 50.0% 20ms 20ms",
+		"",
+		"CALL PATH CALLS TOTAL",
+		"handleCheckout → loadCart 3 13ms",
+		"handleCheckout 2 40ms",
+		"handleCheckout → chargeCard 1 20ms",
+		"handleCheckout → chargeCard → callGateway 1 15ms",
+	}
+	if got := rows(out); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestAnalyzeSortsAndLimits(t *testing.T) {
+	tests := []struct {
+		sort  string
+		first []string // the function rows, in order
+	}{
+		{sort: "self", first: []string{"callGateway", "loadCart"}},
+		{sort: "cumulative", first: []string{"handleCheckout", "chargeCard"}},
+		{sort: "calls", first: []string{"loadCart", "handleCheckout"}},
+		{sort: "max", first: []string{"handleCheckout", "chargeCard"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			out, err := runCommand(t, runAnalyze, "", "-sort", tt.sort, "-top", "2", checkoutSession)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := rows(out)
+			var functions []string
+			for _, line := range lines[3:5] {
+				functions = append(functions, strings.Fields(line)[0])
+			}
+			if strings.Join(functions, " ") != strings.Join(tt.first, " ") {
+				t.Errorf("expected %v first, got %v", tt.first, functions)
+			}
+			if len(lines) != 9 {
+				t.Errorf("expected two rows per table, got:\n%s", out)
+			}
+		})
+	}
+
+	if _, err := runCommand(t, runAnalyze, "", "-sort", "name", checkoutSession); err == nil || !strings.Contains(err.Error(), `unknown sort key "name"`) {
+		t.Errorf("expected an unknown sort key error, got %v", err)
+	}
+	if _, err := runCommand(t, runAnalyze, ""); err == nil {
+		t.Error("expected an error without session files")
+	}
+}
+
+func TestAnalyzeReadsDirectories(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile(checkoutSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.jsonl", "b.jsonl", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := runCommand(t, runAnalyze, "", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rows(out); got[0] != "2 sessions, 14 frames, 80ms in root frames" || got[3] != "callGateway 2 30ms 37.5% 30ms 37.5% 15ms 15ms" {
+		t.Errorf("expected both .jsonl sessions to be analyzed, got:\n%s", out)
+	}
+}
+
+func TestAnalyzeCountsRecursionOnce(t *testing.T) {
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	frame := func(function string, offset, duration time.Duration) devtrace.FrameSnapshot {
+		return devtrace.FrameSnapshot{Function: function, StartTime: start.Add(offset), Duration: duration, Goroutine: 1}
+	}
+	session := &devtrace.Session{Frames: []devtrace.FrameSnapshot{
+		frame("walk", 0, 10*time.Millisecond),
+		frame("walk", time.Millisecond, 6*time.Millisecond),
+		frame("walk", 2*time.Millisecond, 2*time.Millisecond),
+	}}
+
+	report := analyzeSessions([]*devtrace.Session{session})
+	if len(report.functions) != 1 {
+		t.Fatalf("expected one function, got %d", len(report.functions))
+	}
+	walk := report.functions[0]
+	if walk.calls != 3 || walk.cumulative != 10*time.Millisecond || walk.self != 10*time.Millisecond || walk.max != 10*time.Millisecond {
+		t.Errorf("expected 3 calls, 10ms cumulative and self, got %+v", walk)
+	}
+	if len(report.paths) != 3 || report.paths[0].path != "walk" || report.paths[2].path != "walk → walk → walk" {
+		t.Errorf("unexpected paths: %+v", report.paths)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+func runAnnotate(args []string) error {
+	fs := flag.NewFlagSet("annotate", flag.ContinueOnError)
+	author := fs.String("author", os.Getenv("USER"), "Author recorded with the note")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gotrace annotate [-author name] <session-file> [note text]")
+		fmt.Fprintln(fs.Output(), "Without note text, the session's existing notes are listed.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("session file is required")
+	}
+
+	path := fs.Arg(0)
+	if fs.NArg() == 1 {
+		notes, err := devtrace.SessionAnnotations(path)
+		if err != nil {
+			return err
+		}
+		for _, note := range notes {
+			fmt.Printf("%s  %-12s %s\n", note.Time.Format(time.RFC3339), note.Author, note.Text)
+		}
+		return nil
+	}
+
+	return devtrace.AnnotateSession(path, devtrace.Annotation{
+		Author: *author,
+		Text:   strings.Join(fs.Args()[1:], " "),
+	})
+}
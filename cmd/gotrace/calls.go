@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+// runCalls lists the calls of a recording made with devtrace.StartCallRecording.
+// Replaying needs the recorded functions, so it happens in the program or
+// its tests through devtrace.ReplayFile.
+func runCalls(args []string) error {
+	fs := flag.NewFlagSet("calls", flag.ContinueOnError)
+	function := fs.String("func", "", "Only list calls of functions whose name contains this")
+	failed := fs.Bool("failed", false, "Only list calls that returned an error or panicked")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: gotrace calls [-func name] [-failed] <recording.jsonl>")
+	}
+
+	calls, err := devtrace.ReadCallRecording(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var selected []devtrace.RecordedCall
+	for _, call := range calls {
+		if *function != "" && !strings.Contains(call.Function, *function) {
+			continue
+		}
+		if *failed && call.Error == "" && call.Panic == "" {
+			continue
+		}
+		selected = append(selected, call)
+	}
+	printCalls(os.Stdout, selected)
+	return nil
+}
+
+func printCalls(w io.Writer, calls []devtrace.RecordedCall) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tFUNCTION\tDURATION\tOUTCOME")
+	for _, call := range calls {
+		fmt.Fprintf(tw, "%s\t%s%s\t%v\t%s\n", call.Time.Format("15:04:05.000"), shortFuncName(call.Function), callArgs(call), call.Duration, callOutcome(call))
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d calls\n", len(calls))
+}
+
+func callArgs(call devtrace.RecordedCall) string {
+	parts := make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		parts[i] = string(arg)
+		if i < len(call.Params) && call.Params[i] != "" {
+			parts[i] = call.Params[i] + "=" + parts[i]
+		}
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func callOutcome(call devtrace.RecordedCall) string {
+	switch {
+	case call.Panic != "":
+		return "panic: " + call.Panic
+	case call.Error != "":
+		return "error: " + call.Error
+	}
+	results := make([]string, len(call.Results))
+	for i, result := range call.Results {
+		results[i] = string(result)
+	}
+	return strings.Join(results, ", ")
+}
+
+// shortFuncName drops the import path of a runtime function name
+func shortFuncName(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"bufio"
+	"debug/buildinfo"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+// defaultImportPath is the import path of the devtrace package itself
+const defaultImportPath = "github.com/skulidropek/gotrace"
+
+type doctorLevel int
+
+const (
+	doctorOK doctorLevel = iota
+	doctorSkip
+	doctorWarn
+	doctorFail
+)
+
+func (l doctorLevel) mark() string {
+	switch l {
+	case doctorSkip:
+		return "–"
+	case doctorWarn:
+		return "!"
+	case doctorFail:
+		return "✘"
+	}
+	return "✔"
+}
+
+// finding is one result of a doctor check, with how to fix it if it isn't OK
+type finding struct {
+	level   doctorLevel
+	message string
+	fix     string
+}
+
+// doctorProject is what the checks know about the project being examined
+type doctorProject struct {
+	dir         string
+	goMod       string
+	module      string
+	requires    []string
+	imports     map[string][]string // devtrace import path -> files importing it
+	files       []string
+	binary      string
+	collector   string
+	dialTimeout time.Duration
+}
+
+type doctorCheck struct {
+	name string
+	run  func(p *doctorProject) []finding
+}
+
+var doctorChecks = []doctorCheck{
+	{name: "import path", run: checkImportPath},
+	{name: "instrumentation", run: checkInstrumentation},
+	{name: "sources", run: checkSources},
+	{name: "environment", run: checkEnvironment},
+	{name: "collector", run: checkCollector},
+}
+
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	binary := fs.String("binary", "", "Built binary to check for source paths usable in snippets")
+	collector := fs.String("collector", os.Getenv("DEVTRACE_COLLECTOR"), "Collector address (host:port or URL) to check reachability of")
+	timeout := fs.Duration("timeout", 2*time.Second, "Timeout for reaching the collector")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gotrace doctor [-binary path] [-collector addr] [dir]")
+		fmt.Fprintln(fs.Output(), "Checks a project's devtrace setup and prints how to fix what is wrong.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	project, err := loadDoctorProject(dir)
+	if err != nil {
+		return err
+	}
+	project.binary = *binary
+	project.collector = *collector
+	project.dialTimeout = *timeout
+
+	failed := printDoctor(os.Stdout, project)
+	if failed > 0 {
+		return fmt.Errorf("%d problem(s) found", failed)
+	}
+	return nil
+}
+
+// printDoctor runs every check and returns how many failed
+func printDoctor(w io.Writer, project *doctorProject) int {
+	failed := 0
+	for _, check := range doctorChecks {
+		for _, f := range check.run(project) {
+			fmt.Fprintf(w, "%s %-15s %s\n", f.level.mark(), check.name, f.message)
+			if f.fix != "" && f.level >= doctorWarn {
+				fmt.Fprintf(w, "  %-15s fix: %s\n", "", f.fix)
+			}
+			if f.level == doctorFail {
+				failed++
+			}
+		}
+	}
+	return failed
+}
+
+func loadDoctorProject(dir string) (*doctorProject, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	project := &doctorProject{dir: abs, imports: make(map[string][]string)}
+
+	if project.goMod = findGoMod(abs); project.goMod != "" {
+		if project.module, project.requires, err = parseGoMod(project.goMod); err != nil {
+			return nil, err
+		}
+	}
+
+	err = filepath.Walk(abs, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if path != abs && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, ".go") {
+			project.files = append(project.files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	for _, path := range project.files {
+		file, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		if err != nil {
+			continue // reported by the instrumentation check
+		}
+		for _, spec := range file.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			importPath = strings.TrimSuffix(importPath, "/v1") // the v1 API lives in the same module
+			if isGotraceImport(importPath) {
+				project.imports[importPath] = append(project.imports[importPath], project.rel(path))
+			}
+		}
+	}
+	return project, nil
+}
+
+func (p *doctorProject) rel(path string) string {
+	if rel, err := filepath.Rel(p.dir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// isGotraceImport reports whether path is the devtrace package of gotrace or a fork
+func isGotraceImport(path string) bool {
+	return path == defaultImportPath || strings.HasSuffix(path, "/gotrace")
+}
+
+func checkImportPath(p *doctorProject) []finding {
+	if p.goMod == "" {
+		return []finding{{level: doctorFail, message: "no go.mod found in " + p.dir + " or its parents",
+			fix: "run gotrace doctor inside a Go module, or create one with go mod init"}}
+	}
+
+	var required []string
+	for _, req := range p.requires {
+		if isGotraceImport(req) {
+			required = append(required, req)
+		}
+	}
+	if isGotraceImport(p.module) {
+		required = append(required, p.module)
+	}
+
+	if len(p.imports) == 0 {
+		f := finding{level: doctorWarn, message: "no file imports devtrace yet",
+			fix: "run gotrace-instrument -src " + p.dir + " (add -dry-run to review the changes first)"}
+		if len(required) == 0 {
+			f.fix = "go get " + defaultImportPath + ", then " + f.fix
+		}
+		return []finding{f}
+	}
+
+	var findings []finding
+	for _, path := range sortedKeys(p.imports) {
+		files := p.imports[path]
+		if containsString(required, path) {
+			findings = append(findings, finding{level: doctorOK, message: fmt.Sprintf("%s (imported by %d file(s), required in go.mod)", path, len(files))})
+			continue
+		}
+
+		f := finding{level: doctorFail,
+			message: fmt.Sprintf("%s is imported by %s but go.mod does not require it", path, strings.Join(limitStrings(files, 3), ", ")),
+			fix:     "go get " + path}
+		if len(required) > 0 {
+			f.fix = fmt.Sprintf("go.mod requires %s; re-run gotrace-instrument -module-path %s, or go get %s", required[0], required[0], path)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+func checkInstrumentation(p *doctorProject) []finding {
+	var findings []finding
+	instrumented := 0
+
+	fset := token.NewFileSet()
+	for _, path := range p.files {
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			findings = append(findings, finding{level: doctorFail, message: err.Error(),
+				fix: "fix the syntax error; if gotrace-instrument wrote this file, restore it from version control and re-run it"})
+			continue
+		}
+		name := devtraceName(file)
+		if name == "" {
+			continue
+		}
+
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			enters, leaves := countFrameCalls(fn.Body, name)
+			if enters == 0 && leaves == 0 {
+				continue
+			}
+			instrumented++
+			if enters != leaves {
+				pos := fset.Position(fn.Pos())
+				findings = append(findings, finding{level: doctorFail,
+					message: fmt.Sprintf("%s:%d %s enters %d frame(s) but leaves %d", p.rel(pos.Filename), pos.Line, fn.Name.Name, enters, leaves),
+					fix:     "give every GlobalEnter/EnterContext a deferred GlobalLeave/LeaveContext, or restore the file and re-run gotrace-instrument"})
+			}
+		}
+	}
+
+	if len(findings) == 0 {
+		if instrumented == 0 {
+			return []finding{{level: doctorSkip, message: "no instrumented functions found"}}
+		}
+		return []finding{{level: doctorOK, message: fmt.Sprintf("%d instrumented function(s), every frame entered is left", instrumented)}}
+	}
+	return findings
+}
+
+// devtraceName is the local name the file refers to the devtrace package by
+func devtraceName(file *ast.File) string {
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || !isGotraceImport(path) {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return "devtrace"
+	}
+	return ""
+}
+
+// countFrameCalls counts the frames a function body enters and the deferred leaves
+// that close them; function literals other than deferred ones are skipped since
+// they run on their own
+func countFrameCalls(body *ast.BlockStmt, pkg string) (enters, leaves int) {
+	isCall := func(expr ast.Expr, names ...string) bool {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return false
+		}
+		if ident, ok := sel.X.(*ast.Ident); !ok || ident.Name != pkg {
+			return false
+		}
+		return containsString(names, sel.Sel.Name)
+	}
+	leaveNames := []string{"GlobalLeave", "LeaveContext", "GlobalLeaveResults", "LeaveContextResults"}
+
+	for _, stmt := range body.List {
+		switch stmt := stmt.(type) {
+		case *ast.ExprStmt:
+			if isCall(stmt.X, "GlobalEnter", "EnterContext") {
+				enters++
+			}
+		case *ast.DeferStmt:
+			if isCall(stmt.Call, leaveNames...) {
+				leaves++
+			} else if lit, ok := stmt.Call.Fun.(*ast.FuncLit); ok {
+				for _, inner := range lit.Body.List {
+					if expr, ok := inner.(*ast.ExprStmt); ok && isCall(expr.X, leaveNames...) {
+						leaves++
+					}
+				}
+			}
+		}
+	}
+	return enters, leaves
+}
+
+func checkSources(p *doctorProject) []finding {
+	if p.binary == "" {
+		return []finding{{level: doctorSkip, message: "no binary given; pass -binary to check it can show code snippets"}}
+	}
+
+	info, err := buildinfo.ReadFile(p.binary)
+	if err != nil {
+		return []finding{{level: doctorFail, message: fmt.Sprintf("can't read build info from %s: %v", p.binary, err),
+			fix: "build the binary with a module-aware go build (Go 1.18 or later)"}}
+	}
+
+	var findings []finding
+	for _, setting := range info.Settings {
+		if setting.Key == "-trimpath" && setting.Value == "true" {
+			findings = append(findings, finding{level: doctorFail, message: p.binary + " was built with -trimpath, so frames have no file paths to read snippets from",
+				fix: "build development binaries without -trimpath"})
+		}
+	}
+
+	linked := info.Main.Path != "" && isGotraceImport(info.Main.Path)
+	for _, dep := range info.Deps {
+		if isGotraceImport(dep.Path) {
+			linked = true
+		}
+	}
+	if !linked {
+		findings = append(findings, finding{level: doctorWarn, message: p.binary + " does not link devtrace",
+			fix: "import devtrace (gotrace-instrument does this) and rebuild"})
+	}
+
+	if p.module != "" && info.Main.Path != "" && info.Main.Path != p.module {
+		findings = append(findings, finding{level: doctorWarn,
+			message: fmt.Sprintf("%s was built from %s, not from %s in %s", p.binary, info.Main.Path, p.module, p.dir),
+			fix:     "run gotrace doctor in the directory of the module the binary is built from"})
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, finding{level: doctorOK, message: fmt.Sprintf("%s keeps source paths (built with %s)", p.binary, info.GoVersion)})
+	}
+	return findings
+}
+
+func checkEnvironment(p *doctorProject) []finding {
+	var findings []finding
+
+	raw, set := os.LookupEnv("DEVTRACE_ENABLED")
+	development := strings.EqualFold(os.Getenv("GO_ENV"), "development")
+	switch enabled, err := strconv.ParseBool(strings.TrimSpace(raw)); {
+	case set && err != nil && strings.TrimSpace(raw) != "":
+		findings = append(findings, finding{level: doctorFail, message: fmt.Sprintf("DEVTRACE_ENABLED=%q is not a boolean", raw),
+			fix: "export DEVTRACE_ENABLED=1"})
+	case enabled:
+		findings = append(findings, finding{level: doctorOK, message: "DEVTRACE_ENABLED is set"})
+	case development:
+		findings = append(findings, finding{level: doctorOK, message: "tracing is enabled by GO_ENV=development"})
+	default:
+		findings = append(findings, finding{level: doctorWarn, message: "DEVTRACE_ENABLED is not set, so tracing starts disabled",
+			fix: "export DEVTRACE_ENABLED=1 (or GO_ENV=development), or enable it in code with devtrace.UpdateConfig"})
+	}
+
+	// ConfigFromEnv warns about values it can't parse; the ones it accepts must still make sense
+	if err := devtrace.ConfigFromEnv().Validate(); err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			findings = append(findings, finding{level: doctorFail, message: "DEVTRACE_* variables give " + strings.TrimPrefix(line, "devtrace: "),
+				fix: "correct or unset the DEVTRACE_* variable for that field"})
+		}
+	}
+	return findings
+}
+
+func checkCollector(p *doctorProject) []finding {
+	if p.collector == "" {
+		return []finding{{level: doctorSkip, message: "no collector configured; pass -collector or set DEVTRACE_COLLECTOR to check one"}}
+	}
+
+	address, err := collectorAddress(p.collector)
+	if err != nil {
+		return []finding{{level: doctorFail, message: err.Error(), fix: "use host:port or a URL such as http://localhost:4318"}}
+	}
+
+	conn, err := net.DialTimeout("tcp", address, p.dialTimeout)
+	if err != nil {
+		return []finding{{level: doctorFail, message: fmt.Sprintf("can't reach %s: %v", address, err),
+			fix: "start the collector, or check the address and any firewall between here and it"}}
+	}
+	conn.Close()
+	return []finding{{level: doctorOK, message: address + " is reachable"}}
+}
+
+// collectorAddress turns host:port or a URL into a dialable host:port
+func collectorAddress(raw string) (string, error) {
+	if !strings.Contains(raw, "://") {
+		if _, _, err := net.SplitHostPort(raw); err != nil {
+			return "", fmt.Errorf("invalid collector address %q: %v", raw, err)
+		}
+		return raw, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return "", fmt.Errorf("invalid collector URL %q", raw)
+	}
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https", "grpcs":
+			port = "443"
+		case "http", "grpc":
+			port = "80"
+		default:
+			return "", fmt.Errorf("collector URL %q has no port", raw)
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+func findGoMod(dir string) string {
+	for {
+		candidate := filepath.Join(dir, "go.mod")
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// parseGoMod extracts the module path and required module paths from a go.mod file
+func parseGoMod(path string) (module string, requires []string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	inRequire := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if inRequire {
+			if fields[0] == ")" {
+				inRequire = false
+			} else {
+				requires = append(requires, unquoteModPath(fields[0]))
+			}
+			continue
+		}
+
+		switch {
+		case fields[0] == "module" && len(fields) > 1:
+			module = unquoteModPath(fields[1])
+		case fields[0] == "require" && len(fields) > 1 && fields[1] == "(":
+			inRequire = true
+		case fields[0] == "require" && len(fields) > 1:
+			requires = append(requires, unquoteModPath(fields[1]))
+		}
+	}
+	if module == "" && scanner.Err() == nil {
+		return "", nil, errors.New(path + ": no module directive")
+	}
+	return module, requires, scanner.Err()
+}
+
+func unquoteModPath(s string) string {
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// limitStrings keeps the first n entries and summarizes the rest
+func limitStrings(list []string, n int) []string {
+	if len(list) <= n {
+		return list
+	}
+	return append(append([]string(nil), list[:n]...), fmt.Sprintf("%d more", len(list)-n))
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const doctorGoMod = "module example.com/shop\n\ngo 1.21\n\nrequire " + defaultImportPath + " v0.0.0\n"
+
+const instrumentedSource = `package shop
+
+import devtrace "github.com/skulidropek/gotrace"
+
+func Checkout(id string) error {
+	devtrace.GlobalEnter(devtrace.CreateFrame("Checkout", "", "shop.go", 5, nil))
+	defer devtrace.GlobalLeave()
+	return nil
+}
+
+func Refund(id string) {
+	devtrace.GlobalEnter(devtrace.CreateFrame("Refund", "", "shop.go", 11, nil))
+	defer func() {
+		devtrace.GlobalLeaveResults()
+	}()
+}
+`
+
+// doctorProjectOf writes files into a new directory and loads it as gotrace
+// doctor does
+func doctorProjectOf(t *testing.T, files map[string]string) *doctorProject {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	project, err := loadDoctorProject(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+// assertFindings checks the level of each finding and that its message and
+// fix contain the given text
+func assertFindings(t *testing.T, got []finding, want ...finding) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d finding(s), got %+v", len(want), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.level != w.level || !strings.Contains(g.message, w.message) || !strings.Contains(g.fix, w.fix) {
+			t.Errorf("finding %d: got %s %q (fix %q), want %s containing %q (fix %q)", i, g.level.mark(), g.message, g.fix, w.level.mark(), w.message, w.fix)
+		}
+	}
+}
+
+func TestDoctorImportPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []finding
+	}{
+		{
+			name:  "no go.mod",
+			files: map[string]string{"main.go": "package main\n"},
+			want:  []finding{{level: doctorFail, message: "no go.mod found", fix: "go mod init"}},
+		},
+		{
+			name:  "nothing imports devtrace",
+			files: map[string]string{"go.mod": "module example.com/shop\n", "main.go": "package main\n"},
+			want:  []finding{{level: doctorWarn, message: "no file imports devtrace yet", fix: "go get " + defaultImportPath + ", then run gotrace-instrument"}},
+		},
+		{
+			name:  "required but not imported",
+			files: map[string]string{"go.mod": doctorGoMod, "main.go": "package main\n"},
+			want:  []finding{{level: doctorWarn, message: "no file imports devtrace yet", fix: "run gotrace-instrument -src"}},
+		},
+		{
+			name:  "imported and required",
+			files: map[string]string{"go.mod": doctorGoMod, "shop.go": instrumentedSource, "v1.go": "package shop\n\nimport _ \"" + defaultImportPath + "/v1\"\n"},
+			want:  []finding{{level: doctorOK, message: defaultImportPath + " (imported by 2 file(s), required in go.mod)"}},
+		},
+		{
+			name:  "imported but not required",
+			files: map[string]string{"go.mod": "module example.com/shop\n", "shop.go": instrumentedSource},
+			want:  []finding{{level: doctorFail, message: defaultImportPath + " is imported by shop.go but go.mod does not require it", fix: "go get " + defaultImportPath}},
+		},
+		{
+			name: "imported under another path than the fork go.mod requires",
+			files: map[string]string{
+				"go.mod":  "module example.com/shop\n\nrequire (\n\t\"example.com/fork/gotrace\" v1.0.0 // fork\n)\n",
+				"shop.go": instrumentedSource,
+			},
+			want: []finding{{level: doctorFail, message: "go.mod does not require it", fix: "re-run gotrace-instrument -module-path example.com/fork/gotrace"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFindings(t, checkImportPath(doctorProjectOf(t, tt.files)), tt.want...)
+		})
+	}
+}
+
+func TestDoctorInstrumentation(t *testing.T) {
+	unbalanced := strings.Replace(instrumentedSource, "\tdefer devtrace.GlobalLeave()\n", "", 1)
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []finding
+	}{
+		{
+			name:  "nothing instrumented",
+			files: map[string]string{"main.go": "package main\n\nfunc main() {}\n"},
+			want:  []finding{{level: doctorSkip, message: "no instrumented functions found"}},
+		},
+		{
+			name:  "every frame left",
+			files: map[string]string{"shop.go": instrumentedSource},
+			want:  []finding{{level: doctorOK, message: "2 instrumented function(s), every frame entered is left"}},
+		},
+		{
+			name:  "frame never left",
+			files: map[string]string{"shop.go": unbalanced},
+			want:  []finding{{level: doctorFail, message: "shop.go:5 Checkout enters 1 frame(s) but leaves 0", fix: "deferred GlobalLeave"}},
+		},
+		{
+			name:  "syntax error",
+			files: map[string]string{"shop.go": instrumentedSource, "broken.go": "package shop\n\nfunc {\n"},
+			want:  []finding{{level: doctorFail, message: "broken.go:3", fix: "fix the syntax error"}},
+		},
+		{
+			name:  "testdata and vendor are left out",
+			files: map[string]string{"testdata/shop.go": unbalanced, "vendor/shop.go": unbalanced},
+			want:  []finding{{level: doctorSkip, message: "no instrumented functions found"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFindings(t, checkInstrumentation(doctorProjectOf(t, tt.files)), tt.want...)
+		})
+	}
+}
+
+func TestDoctorSources(t *testing.T) {
+	project := doctorProjectOf(t, map[string]string{"go.mod": doctorGoMod})
+	assertFindings(t, checkSources(project), finding{level: doctorSkip, message: "pass -binary"})
+
+	project.binary = filepath.Join(project.dir, "go.mod")
+	assertFindings(t, checkSources(project), finding{level: doctorFail, message: "can't read build info", fix: "module-aware go build"})
+
+	if testing.Short() {
+		t.Skip("builds binaries")
+	}
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := buildBinary(t, "module example.com/plain\n\ngo 1.21\n", "package main\n\nfunc main() {}\n", "-trimpath")
+	project.binary = plain
+	assertFindings(t, checkSources(project),
+		finding{level: doctorFail, message: "was built with -trimpath", fix: "without -trimpath"},
+		finding{level: doctorWarn, message: "does not link devtrace", fix: "rebuild"},
+		finding{level: doctorWarn, message: "was built from example.com/plain, not from example.com/shop", fix: "run gotrace doctor in the directory"},
+	)
+
+	traced := buildBinary(t, doctorGoMod+"\nreplace "+defaultImportPath+" => "+filepath.ToSlash(root)+"\n",
+		"package main\n\nimport devtrace \""+defaultImportPath+"\"\n\nfunc main() { devtrace.GlobalLeave() }\n")
+	project.binary = traced
+	assertFindings(t, checkSources(project), finding{level: doctorOK, message: "keeps source paths (built with go"})
+}
+
+// buildBinary builds a main package from one source file and returns the binary
+func buildBinary(t *testing.T, goMod, source string, flags ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte(source), 0o644)
+	binary := filepath.Join(dir, "app")
+	cmd := exec.Command("go", append(append([]string{"build", "-mod=mod", "-o", binary}, flags...), ".")...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	return binary
+}
+
+func TestDoctorEnvironment(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string // "" unsets the variable
+		want []finding
+	}{
+		{
+			name: "unset",
+			env:  map[string]string{"DEVTRACE_ENABLED": "", "GO_ENV": ""},
+			want: []finding{{level: doctorWarn, message: "tracing starts disabled", fix: "export DEVTRACE_ENABLED=1"}},
+		},
+		{
+			name: "enabled",
+			env:  map[string]string{"DEVTRACE_ENABLED": "true", "GO_ENV": ""},
+			want: []finding{{level: doctorOK, message: "DEVTRACE_ENABLED is set"}},
+		},
+		{
+			name: "development",
+			env:  map[string]string{"DEVTRACE_ENABLED": "", "GO_ENV": "Development"},
+			want: []finding{{level: doctorOK, message: "enabled by GO_ENV=development"}},
+		},
+		{
+			name: "not a boolean",
+			env:  map[string]string{"DEVTRACE_ENABLED": "yes please", "GO_ENV": ""},
+			want: []finding{{level: doctorFail, message: `DEVTRACE_ENABLED="yes please" is not a boolean`, fix: "export DEVTRACE_ENABLED=1"}},
+		},
+		{
+			name: "invalid config",
+			env:  map[string]string{"DEVTRACE_ENABLED": "1", "GO_ENV": "", "DEVTRACE_STACK_LIMIT": "-1"},
+			want: []finding{
+				{level: doctorOK, message: "DEVTRACE_ENABLED is set"},
+				{level: doctorFail, message: "DEVTRACE_* variables give", fix: "correct or unset"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+				if value == "" {
+					os.Unsetenv(name)
+				}
+			}
+			got := checkEnvironment(&doctorProject{})
+			assertFindings(t, got, tt.want...)
+			if tt.name == "invalid config" && !strings.Contains(got[1].message, "StackLimit") {
+				t.Errorf("expected the invalid field to be named, got %q", got[1].message)
+			}
+		})
+	}
+}
+
+func TestDoctorCollector(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	reachable := listener.Addr().String()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		collector string
+		want      finding
+	}{
+		{collector: "", want: finding{level: doctorSkip, message: "no collector configured"}},
+		{collector: reachable, want: finding{level: doctorOK, message: reachable + " is reachable"}},
+		{collector: "http://" + reachable + "/v1/frames", want: finding{level: doctorOK, message: reachable + " is reachable"}},
+		{collector: unreachable, want: finding{level: doctorFail, message: "can't reach " + unreachable, fix: "start the collector"}},
+		{collector: "localhost", want: finding{level: doctorFail, message: `invalid collector address "localhost"`, fix: "host:port"}},
+		{collector: "tcp://localhost", want: finding{level: doctorFail, message: "has no port", fix: "host:port"}},
+	}
+	for _, tt := range tests {
+		project := &doctorProject{collector: tt.collector, dialTimeout: time.Second}
+		t.Run(tt.collector, func(t *testing.T) {
+			assertFindings(t, checkCollector(project), tt.want)
+		})
+	}
+}
+
+func TestCollectorAddress(t *testing.T) {
+	for raw, want := range map[string]string{
+		"localhost:4318":             "localhost:4318",
+		"http://collector":           "collector:80",
+		"https://collector/v1":       "collector:443",
+		"grpc://collector":           "collector:80",
+		"grpcs://collector":          "collector:443",
+		"http://collector:4318/path": "collector:4318",
+	} {
+		if got, err := collectorAddress(raw); err != nil || got != want {
+			t.Errorf("collectorAddress(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	if _, err := collectorAddress("http://:4318"); err == nil {
+		t.Error("expected an error for a URL without a host")
+	}
+}
+
+func TestDoctorCountsFailures(t *testing.T) {
+	t.Setenv("DEVTRACE_ENABLED", "1")
+	project := doctorProjectOf(t, map[string]string{"go.mod": doctorGoMod, "shop.go": instrumentedSource})
+	if out, err := runCommand(t, runDoctor, "", project.dir); err != nil {
+		t.Fatalf("expected a healthy project to pass, got %v:\n%s", err, out)
+	} else if !strings.Contains(out, "✔ instrumentation") || !strings.Contains(out, "– sources") {
+		t.Errorf("unexpected report:\n%s", out)
+	}
+
+	os.WriteFile(filepath.Join(project.dir, "go.mod"), []byte("module example.com/shop\n"), 0o644)
+	out, err := runCommand(t, runDoctor, "", "-collector", "localhost", project.dir)
+	if err == nil || err.Error() != "2 problem(s) found" {
+		t.Fatalf("expected the import and collector problems, got %v", err)
+	}
+	if !strings.Contains(out, "✘ import path") || !strings.Contains(out, "fix: go get "+defaultImportPath) {
+		t.Errorf("expected each problem with its fix, got:\n%s", out)
+	}
+}
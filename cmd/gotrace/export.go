@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "", "Write the report to this file instead of stdout")
+	format := fs.String("format", "markdown", "Output format: markdown, mermaid (sequence diagram) or dot (Graphviz call graph)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gotrace export [-format markdown|mermaid|dot] [-o report.md] <session-file>")
+		fmt.Fprintln(fs.Output(), "Renders the session as Markdown for a bug report, or its call tree as a diagram.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one session file is required")
+	}
+
+	session, err := devtrace.ReadSessionFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var report string
+	switch *format {
+	case "markdown", "md":
+		report = devtrace.ExportMarkdown(session)
+	default:
+		var b strings.Builder
+		if err := devtrace.RenderTrace(session, devtrace.TraceFormat(*format), &b); err != nil {
+			return err
+		}
+		report = b.String()
+	}
+	if *output == "" {
+		_, err = fmt.Print(report)
+		return err
+	}
+	return os.WriteFile(*output, []byte(report), 0o644)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// goldenOutput compares out with testdata/<name>, rewriting the file when
+// DEVTRACE_UPDATE_GOLDEN is set, as for devtracetest.Golden
+func goldenOutput(t *testing.T, name, out string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if os.Getenv("DEVTRACE_UPDATE_GOLDEN") != "" {
+		if err := os.WriteFile(path, []byte(out), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != string(want) {
+		t.Errorf("output differs from %s:\n%s", path, out)
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	for _, tt := range []struct{ format, golden string }{
+		{format: "markdown", golden: "checkout.md"},
+		{format: "mermaid", golden: "checkout.mermaid"},
+		{format: "dot", golden: "checkout.dot"},
+	} {
+		t.Run(tt.format, func(t *testing.T) {
+			out, err := runCommand(t, runExport, "", "-format", tt.format, checkoutSession)
+			if err != nil {
+				t.Fatal(err)
+			}
+			goldenOutput(t, tt.golden, out)
+		})
+	}
+}
+
+func TestExportWritesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.dot")
+	out, err := runCommand(t, runExport, "", "-format", "dot", "-o", path, checkoutSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "" || !strings.HasPrefix(string(written), "digraph trace {") {
+		t.Errorf("expected the graph in %s and nothing on stdout, got %q and:\n%s", path, out, written)
+	}
+}
+
+func TestExportRejectsBadInput(t *testing.T) {
+	if _, err := runCommand(t, runExport, "", "-format", "svg", checkoutSession); err == nil || !strings.Contains(err.Error(), `unknown trace format "svg"`) {
+		t.Errorf("expected an unknown format error, got %v", err)
+	}
+	if _, err := runCommand(t, runExport, "", checkoutSession, checkoutSession); err == nil {
+		t.Error("expected an error for two session files")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+func runFlamegraph(args []string) error {
+	fs := flag.NewFlagSet("flamegraph", flag.ContinueOnError)
+	output := fs.String("o", "", "Write the graph to this file instead of stdout")
+	format := fs.String("format", "", "Output format: folded or svg (default: svg for a .svg output file, folded otherwise)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gotrace flamegraph [-format folded|svg] [-o out.svg] <session-file>")
+		fmt.Fprintln(fs.Output(), "Builds a wall-clock flame graph from the session's frames, as folded stacks")
+		fmt.Fprintln(fs.Output(), "(for flamegraph.pl, speedscope or pprof) or as an SVG.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one session file is required")
+	}
+	if *format == "" {
+		*format = "folded"
+		if filepath.Ext(*output) == ".svg" {
+			*format = "svg"
+		}
+	}
+
+	var write func(io.Writer, *devtrace.FlameNode) error
+	switch *format {
+	case "folded":
+		write = devtrace.WriteFoldedStacks
+	case "svg":
+		write = devtrace.WriteFlameSVG
+	default:
+		return fmt.Errorf("unknown format %q (want folded or svg)", *format)
+	}
+
+	session, err := devtrace.ReadSessionFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	root := devtrace.SessionFlameGraph(session)
+
+	if *output == "" {
+		return write(os.Stdout, root)
+	}
+	out, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := write(out, root); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
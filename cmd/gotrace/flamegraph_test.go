@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFlamegraphFoldedStacks(t *testing.T) {
+	out, err := runCommand(t, runFlamegraph, "", checkoutSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"handleCheckout 7000",
+		"handleCheckout;chargeCard 5000",
+		"handleCheckout;chargeCard;callGateway 15000",
+		"handleCheckout;loadCart 13000",
+	}
+	if got := rows(out); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected the self time in microseconds per stack, got:\n%s", out)
+	}
+}
+
+func TestFlamegraphPicksFormatFromOutput(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name   string
+		args   []string
+		prefix string
+	}{
+		{name: "latency.svg", prefix: "<svg "},
+		{name: "latency.folded", prefix: "handleCheckout 7000\n"},
+		{name: "forced.txt", args: []string{"-format", "svg"}, prefix: "<svg "},
+	} {
+		path := filepath.Join(dir, tt.name)
+		if _, err := runCommand(t, runFlamegraph, "", append(tt.args, "-o", path, checkoutSession)...); err != nil {
+			t.Fatal(err)
+		}
+		written, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(written), tt.prefix) {
+			t.Errorf("%s: expected output starting with %q, got:\n%.200s", tt.name, tt.prefix, written)
+		}
+	}
+
+	svg, err := os.ReadFile(filepath.Join(dir, "latency.svg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"handleCheckout (2 calls, 40ms)", "chargeCard (1 call, 20ms)", "callGateway (1 call, 15ms)", "loadCart (3 calls, 13ms)"} {
+		if !strings.Contains(string(svg), "<title>"+title+"</title>") {
+			t.Errorf("expected a frame titled %q in the SVG", title)
+		}
+	}
+}
+
+func TestFlamegraphRejectsBadInput(t *testing.T) {
+	if _, err := runCommand(t, runFlamegraph, "", "-format", "png", checkoutSession); err == nil || !strings.Contains(err.Error(), `unknown format "png"`) {
+		t.Errorf("expected an unknown format error, got %v", err)
+	}
+	if _, err := runCommand(t, runFlamegraph, ""); err == nil {
+		t.Error("expected an error without a session file")
+	}
+}
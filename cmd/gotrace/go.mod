@@ -0,0 +1,7 @@
+module github.com/skulidropek/gotrace/cmd/gotrace
+
+go 1.21
+
+require github.com/skulidropek/gotrace v0.0.0
+
+replace github.com/skulidropek/gotrace => ../../
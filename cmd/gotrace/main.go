@@ -0,0 +1,54 @@
+// gotrace is the command-line companion for devtrace session files
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{name: "stats", summary: "aggregate statistics across a directory of sessions", run: runStats},
+	{name: "analyze", summary: "report where the time goes: self and cumulative time, hot call paths", run: runAnalyze},
+	{name: "annotate", summary: "add a note to a session or list its notes", run: runAnnotate},
+	{name: "export", summary: "render a session as Markdown, or as a Mermaid or Graphviz diagram", run: runExport},
+	{name: "flamegraph", summary: "build a wall-clock flame graph (folded stacks or SVG) from a session", run: runFlamegraph},
+	{name: "calls", summary: "list the calls of a recording made with StartCallRecording", run: runCalls},
+	{name: "view", summary: "browse sessions and JSONL exports as an expandable call tree", run: runView},
+	{name: "doctor", summary: "check a project's devtrace setup and suggest fixes", run: runDoctor},
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "gotrace %s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "gotrace: unknown command %q\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: gotrace <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+}
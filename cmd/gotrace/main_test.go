@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// checkoutSession is a fixture of two checkout requests: the first fails in
+// chargeCard, which waits on callGateway, the second loads its cart twice
+const checkoutSession = "testdata/checkout.jsonl"
+
+// runCommand runs a gotrace command with stdin as its standard input and
+// returns what it printed to standard output
+func runCommand(t *testing.T, run func(args []string) error, stdin string, args ...string) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	in, err := os.CreateTemp(dir, "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if _, err := io.WriteString(in, stdin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.CreateTemp(dir, "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	originalIn, originalOut := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = in, out
+	runErr := run(args)
+	os.Stdin, os.Stdout = originalIn, originalOut
+
+	printed, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(printed), runErr
+}
+
+// rows returns the lines of out, with the runs of spaces tabwriter pads
+// columns with collapsed to one
+func rows(out string) []string {
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return lines
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+// functionAggregate collects one function's calls across every session
+type functionAggregate struct {
+	name      string
+	durations []time.Duration
+	errors    int
+	perRun    []int // calls per session, in session order
+}
+
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	pattern := fs.String("pattern", "*.jsonl*", "Glob for session files inside the directory")
+	top := fs.Int("top", 20, "Number of functions to show (0 for all)")
+	sortBy := fs.String("sort", "p95", "Sort by: p95, calls, errors, total")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	sessions, err := loadSessions(dir, *pattern)
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		return fmt.Errorf("no sessions matching %q in %s", *pattern, dir)
+	}
+
+	aggregates := aggregateSessions(sessions)
+	if err := sortAggregates(aggregates, *sortBy); err != nil {
+		return err
+	}
+	if *top > 0 && len(aggregates) > *top {
+		aggregates = aggregates[:*top]
+	}
+
+	printStats(os.Stdout, sessions, aggregates)
+	return nil
+}
+
+func loadSessions(dir, pattern string) ([]*devtrace.Session, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*devtrace.Session, 0, len(paths))
+	for _, path := range paths {
+		if strings.HasSuffix(path, devtrace.AnnotationSuffix) {
+			continue
+		}
+		session, err := devtrace.ReadSessionFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %v\n", err)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Start().Before(sessions[j].Start())
+	})
+	return sessions, nil
+}
+
+func aggregateSessions(sessions []*devtrace.Session) []*functionAggregate {
+	byName := make(map[string]*functionAggregate)
+
+	for i, session := range sessions {
+		for _, frame := range session.Frames {
+			agg, ok := byName[frame.Function]
+			if !ok {
+				agg = &functionAggregate{name: frame.Function, perRun: make([]int, len(sessions))}
+				byName[frame.Function] = agg
+			}
+			agg.durations = append(agg.durations, frame.Duration)
+			agg.perRun[i]++
+			if frame.Error != "" {
+				agg.errors++
+			}
+		}
+	}
+
+	aggregates := make([]*functionAggregate, 0, len(byName))
+	for _, agg := range byName {
+		sort.Slice(agg.durations, func(i, j int) bool { return agg.durations[i] < agg.durations[j] })
+		aggregates = append(aggregates, agg)
+	}
+	return aggregates
+}
+
+func sortAggregates(aggregates []*functionAggregate, key string) error {
+	var less func(a, b *functionAggregate) bool
+	switch key {
+	case "p95":
+		less = func(a, b *functionAggregate) bool { return a.percentile(95) > b.percentile(95) }
+	case "calls":
+		less = func(a, b *functionAggregate) bool { return len(a.durations) > len(b.durations) }
+	case "errors":
+		less = func(a, b *functionAggregate) bool { return a.errorRate() > b.errorRate() }
+	case "total":
+		less = func(a, b *functionAggregate) bool { return a.total() > b.total() }
+	default:
+		return fmt.Errorf("unknown sort key %q", key)
+	}
+
+	sort.SliceStable(aggregates, func(i, j int) bool {
+		if less(aggregates[i], aggregates[j]) {
+			return true
+		}
+		if less(aggregates[j], aggregates[i]) {
+			return false
+		}
+		return aggregates[i].name < aggregates[j].name
+	})
+	return nil
+}
+
+func (a *functionAggregate) percentile(p float64) time.Duration {
+	if len(a.durations) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(a.durations))))
+	if rank < 1 {
+		rank = 1
+	}
+	return a.durations[rank-1]
+}
+
+func (a *functionAggregate) errorRate() float64 {
+	if len(a.durations) == 0 {
+		return 0
+	}
+	return float64(a.errors) / float64(len(a.durations))
+}
+
+func (a *functionAggregate) total() time.Duration {
+	var total time.Duration
+	for _, d := range a.durations {
+		total += d
+	}
+	return total
+}
+
+// trend renders calls per session as a sparkline, oldest session first
+func (a *functionAggregate) trend() string {
+	levels := []rune("▁▂▃▄▅▆▇█")
+	maxCalls := 0
+	for _, n := range a.perRun {
+		if n > maxCalls {
+			maxCalls = n
+		}
+	}
+
+	var builder strings.Builder
+	for _, n := range a.perRun {
+		if maxCalls == 0 || n == 0 {
+			builder.WriteRune(' ')
+			continue
+		}
+		builder.WriteRune(levels[(n*(len(levels)-1))/maxCalls])
+	}
+	return builder.String()
+}
+
+func printStats(w io.Writer, sessions []*devtrace.Session, aggregates []*functionAggregate) {
+	fmt.Fprintf(w, "%d sessions", len(sessions))
+	if first, last := sessions[0].Start(), sessions[len(sessions)-1].Start(); !first.IsZero() {
+		fmt.Fprintf(w, " from %s to %s", first.Format(time.RFC3339), last.Format(time.RFC3339))
+	}
+	fmt.Fprintln(w)
+	for _, session := range sessions {
+		for _, note := range session.Annotations {
+			fmt.Fprintf(w, "  %s: %q", session.Name, note.Text)
+			if note.Author != "" {
+				fmt.Fprintf(w, " — %s", note.Author)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FUNCTION\tCALLS\tP50\tP95\tP99\tERRORS\tTREND")
+	for _, agg := range aggregates {
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%v\t%v\t%.1f%%\t%s\n",
+			agg.name, len(agg.durations), agg.percentile(50), agg.percentile(95), agg.percentile(99),
+			agg.errorRate()*100, agg.trend())
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%s\n", devtrace.SessionLatencyBreakdown(sessions...))
+}
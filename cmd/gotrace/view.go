@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+// viewer browses the call trees of a session one command line at a time
+type viewer struct {
+	out      io.Writer
+	roots    []*devtrace.CallTreeNode
+	expanded map[*devtrace.CallTreeNode]bool
+	rows     []viewRow
+	limit    int
+
+	function    string
+	minDuration time.Duration
+	onlyErrors  bool
+}
+
+// viewRow is a numbered line of the rendered tree
+type viewRow struct {
+	node  *devtrace.CallTreeNode
+	depth int
+}
+
+func runView(args []string) error {
+	fs := flag.NewFlagSet("view", flag.ContinueOnError)
+	function := fs.String("fn", "", "Only show calls whose function contains this text, with their callers")
+	minDuration := fs.Duration("min", 0, "Only show calls at least this long, with their callers")
+	onlyErrors := fs.Bool("errors", false, "Only show calls that failed, with their callers")
+	limit := fs.Int("limit", 200, "Rows printed at once (0 for all)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gotrace view [-fn text] [-min 10ms] [-errors] <session-file>...")
+		fmt.Fprintln(fs.Output(), "Browses sessions and JSONL exports as an expandable call tree; type ? for commands.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("at least one session file is required")
+	}
+
+	v := &viewer{
+		out:         os.Stdout,
+		expanded:    make(map[*devtrace.CallTreeNode]bool),
+		limit:       *limit,
+		function:    *function,
+		minDuration: *minDuration,
+		onlyErrors:  *onlyErrors,
+	}
+	for _, path := range fs.Args() {
+		session, err := devtrace.ReadSessionFile(path)
+		if err != nil {
+			return err
+		}
+		v.roots = append(v.roots, devtrace.SessionCallTree(session)...)
+	}
+	sort.SliceStable(v.roots, func(i, j int) bool {
+		return v.roots[i].Frame.StartTime.Before(v.roots[j].Frame.StartTime)
+	})
+
+	v.render()
+	return v.loop(os.Stdin)
+}
+
+// loop reads commands until q or the end of input
+func (v *viewer) loop(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(v.out, "view> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(v.out)
+			return scanner.Err()
+		}
+		if quit := v.command(strings.TrimSpace(scanner.Text())); quit {
+			return nil
+		}
+	}
+}
+
+// command runs one command line and reports whether the viewer should exit
+func (v *viewer) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	if n, err := strconv.Atoi(name); err == nil {
+		name, arg = "o", strconv.Itoa(n)
+	}
+
+	switch name {
+	case "":
+		v.render()
+	case "q", "quit", "exit":
+		return true
+	case "?", "h", "help":
+		v.help()
+	case "o", "s":
+		row, ok := v.row(arg)
+		if !ok {
+			return false
+		}
+		if name == "s" {
+			v.show(row.node)
+			return false
+		}
+		v.expanded[row.node] = !v.expanded[row.node]
+		v.render()
+	case "a":
+		v.walk(func(node *devtrace.CallTreeNode) { v.expanded[node] = true })
+		v.render()
+	case "c":
+		v.expanded = make(map[*devtrace.CallTreeNode]bool)
+		v.render()
+	case "f":
+		v.function = arg
+		v.render()
+	case "m":
+		if arg == "" {
+			arg = "0"
+		}
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			fmt.Fprintf(v.out, "bad duration %q\n", arg)
+			return false
+		}
+		v.minDuration = d
+		v.render()
+	case "e":
+		v.onlyErrors = !v.onlyErrors
+		v.render()
+	default:
+		fmt.Fprintf(v.out, "unknown command %q, type ? for help\n", name)
+	}
+	return false
+}
+
+func (v *viewer) help() {
+	fmt.Fprint(v.out, `Commands:
+  <n>, o <n>   expand or collapse row n
+  s <n>        show row n: args, results, error, logs, events and source
+  a / c        expand / collapse every row
+  f <text>     filter by function name (f alone clears)
+  m <dur>      filter by minimum duration, e.g. m 10ms (m alone clears)
+  e            toggle showing only failed calls
+  <enter>      print the tree again
+  q            quit
+`)
+}
+
+func (v *viewer) row(arg string) (viewRow, bool) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(v.rows) {
+		fmt.Fprintf(v.out, "no row %q\n", arg)
+		return viewRow{}, false
+	}
+	return v.rows[n-1], true
+}
+
+func (v *viewer) walk(fn func(node *devtrace.CallTreeNode)) {
+	var visit func(nodes []*devtrace.CallTreeNode)
+	visit = func(nodes []*devtrace.CallTreeNode) {
+		for _, node := range nodes {
+			fn(node)
+			visit(node.Children)
+		}
+	}
+	visit(v.roots)
+}
+
+func (v *viewer) filtering() bool {
+	return v.function != "" || v.minDuration > 0 || v.onlyErrors
+}
+
+func (v *viewer) matches(frame *devtrace.FrameSnapshot) bool {
+	if v.function != "" && !strings.Contains(frame.Function, v.function) {
+		return false
+	}
+	if frame.Duration < v.minDuration {
+		return false
+	}
+	return !v.onlyErrors || frame.Error != ""
+}
+
+// visible marks the nodes that match the filters or lead to one that does
+func (v *viewer) visible() map[*devtrace.CallTreeNode]bool {
+	shown := make(map[*devtrace.CallTreeNode]bool)
+	var visit func(node *devtrace.CallTreeNode) bool
+	visit = func(node *devtrace.CallTreeNode) bool {
+		keep := v.matches(node.Frame)
+		for _, child := range node.Children {
+			if visit(child) {
+				keep = true
+			}
+		}
+		shown[node] = keep
+		return keep
+	}
+	for _, root := range v.roots {
+		visit(root)
+	}
+	return shown
+}
+
+// render numbers and prints the rows: every root, and the children of
+// expanded rows. While filtering, paths to matching calls are open.
+func (v *viewer) render() {
+	shown := v.visible()
+	v.rows = v.rows[:0]
+	var add func(node *devtrace.CallTreeNode, depth int)
+	add = func(node *devtrace.CallTreeNode, depth int) {
+		if !shown[node] {
+			return
+		}
+		v.rows = append(v.rows, viewRow{node: node, depth: depth})
+		if v.expanded[node] || (v.filtering() && !v.matches(node.Frame)) {
+			for _, child := range node.Children {
+				add(child, depth+1)
+			}
+		}
+	}
+	for _, root := range v.roots {
+		add(root, 0)
+	}
+
+	for i, row := range v.rows {
+		if v.limit > 0 && i == v.limit {
+			fmt.Fprintf(v.out, "… %d more rows; narrow them with f, m or e\n", len(v.rows)-v.limit)
+			break
+		}
+		frame := row.node.Frame
+		marker := " "
+		if len(row.node.Children) > 0 {
+			marker = "▸"
+			if v.expanded[row.node] {
+				marker = "▾"
+			}
+		}
+		fmt.Fprintf(v.out, "%4d %s%s %s %v", i+1, strings.Repeat("  ", row.depth), marker, frame.Function, frame.Duration.Round(time.Microsecond))
+		if frame.Unfinished {
+			fmt.Fprint(v.out, " (unfinished)")
+		}
+		if frame.Error != "" {
+			fmt.Fprintf(v.out, " ❌ %s", oneLine(frame.Error, 80))
+		}
+		fmt.Fprintln(v.out)
+	}
+	if len(v.rows) == 0 {
+		fmt.Fprintln(v.out, "no calls match the filters")
+	}
+}
+
+// show prints everything the session has on a frame
+func (v *viewer) show(node *devtrace.CallTreeNode) {
+	frame := node.Frame
+	fmt.Fprintf(v.out, "%s\n", frame.Function)
+	if frame.Signature != "" {
+		fmt.Fprintf(v.out, "  signature: %s\n", frame.Signature)
+	}
+	if frame.File != "" {
+		fmt.Fprintf(v.out, "  at:        %s:%d\n", frame.File, frame.Line)
+	}
+	if frame.Operation != "" {
+		fmt.Fprintf(v.out, "  operation: %s\n", frame.Operation)
+	}
+	fmt.Fprintf(v.out, "  started:   %s, took %v", frame.StartTime.Format(time.RFC3339Nano), frame.Duration)
+	if frame.Goroutine != 0 {
+		fmt.Fprintf(v.out, ", goroutine %d", frame.Goroutine)
+	}
+	fmt.Fprintln(v.out)
+
+	names := make([]string, 0, len(frame.Args))
+	for name := range frame.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(v.out, "  arg %s = %s\n", name, frame.Args[name])
+	}
+	for i, result := range frame.Results {
+		fmt.Fprintf(v.out, "  result %d = %s\n", i, result)
+	}
+	if frame.Error != "" {
+		fmt.Fprintf(v.out, "  error:     %s\n", frame.Error)
+	}
+	if frame.Panic != "" {
+		fmt.Fprintf(v.out, "  panic:     %s\n", frame.Panic)
+	}
+	for _, log := range frame.Logs {
+		fmt.Fprintf(v.out, "  log +%v %s %s\n", log.Time.Sub(frame.StartTime).Round(time.Microsecond), log.Level, oneLine(log.Message, 120))
+	}
+	for _, event := range frame.Events {
+		fmt.Fprintf(v.out, "  event +%v %s %v\n", event.Time.Sub(frame.StartTime).Round(time.Microsecond), event.Name, event.Attrs)
+	}
+	if snippet := devtrace.SourceSnippet(frame.File, frame.Line, 3); snippet != "" {
+		fmt.Fprintf(v.out, "  %s:\n", filepath.Base(frame.File))
+		for _, line := range strings.Split(snippet, "\n") {
+			fmt.Fprintf(v.out, "    %s\n", line)
+		}
+	}
+}
+
+func oneLine(s string, limit int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > limit {
+		s = s[:limit] + "…"
+	}
+	return s
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestViewBrowsesSessionTree(t *testing.T) {
+	out, err := runCommand(t, runView, "1\n3\ns 4\nc\nq\n", checkoutSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	screens := strings.Split(out, "view> ")
+	if len(screens) != 6 {
+		t.Fatalf("expected a screen per command, got %d:\n%s", len(screens), out)
+	}
+
+	want := [][]string{
+		// the roots, collapsed
+		{"1 ▸ handleCheckout 30ms ❌ card declined", "2 ▸ handleCheckout 10ms"},
+		// 1 expands the first request
+		{"1 ▾ handleCheckout 30ms ❌ card declined", "2 loadCart 5ms", "3 ▸ chargeCard 20ms ❌ card declined", "4 ▸ handleCheckout 10ms"},
+		// 3 expands chargeCard
+		{"1 ▾ handleCheckout 30ms ❌ card declined", "2 loadCart 5ms", "3 ▾ chargeCard 20ms ❌ card declined", "4 callGateway 15ms", "5 ▸ handleCheckout 10ms"},
+	}
+	for i, screen := range want {
+		if got := rows(screens[i]); strings.Join(got, "\n") != strings.Join(screen, "\n") {
+			t.Errorf("screen %d:\n%s\nwant:\n%s", i, strings.Join(got, "\n"), strings.Join(screen, "\n"))
+		}
+	}
+
+	// s 4 shows the frame of callGateway without changing the tree
+	if shown := screens[3]; !strings.HasPrefix(shown, "callGateway\n") || !strings.Contains(shown, "at:        gateway.go:8") || !strings.Contains(shown, "took 15ms") {
+		t.Errorf("expected the details of callGateway, got:\n%s", shown)
+	}
+	if got := rows(screens[4]); len(got) != 2 || got[0] != "1 ▸ handleCheckout 30ms ❌ card declined" {
+		t.Errorf("expected c to collapse every row, got:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestViewShowsArgsErrorsAndLogs(t *testing.T) {
+	out, err := runCommand(t, runView, "a\ns 3\n", checkoutSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"chargeCard\n",
+		"  arg amount = 42\n",
+		"  result 0 = card declined\n",
+		"  error:     card declined\n",
+		"  log +19ms WARN gateway answered 402\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestViewFilters(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		input string
+		want  []string
+	}{
+		{
+			name:  "function keeps the callers open",
+			flags: []string{"-fn", "Gateway"},
+			want:  []string{"1 ▸ handleCheckout 30ms ❌ card declined", "2 ▸ chargeCard 20ms ❌ card declined", "3 callGateway 15ms"},
+		},
+		{
+			name:  "minimum duration hides the shorter calls",
+			flags: []string{"-min", "15ms"},
+			input: "1\n",
+			want:  []string{"1 ▾ handleCheckout 30ms ❌ card declined", "2 ▸ chargeCard 20ms ❌ card declined"},
+		},
+		{
+			name:  "errors",
+			flags: []string{"-errors"},
+			want:  []string{"1 ▸ handleCheckout 30ms ❌ card declined"},
+		},
+		{
+			name:  "nothing matches",
+			flags: []string{"-fn", "refund"},
+			want:  []string{"no calls match the filters"},
+		},
+		{
+			name:  "commands replace the flags",
+			flags: []string{"-errors"},
+			input: "e\nf loadCart\n",
+			want: []string{
+				"1 ▸ handleCheckout 30ms ❌ card declined", "2 loadCart 5ms",
+				"3 ▸ handleCheckout 10ms", "4 loadCart 4ms", "5 loadCart 4ms",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCommand(t, runView, tt.input, append(tt.flags, checkoutSession)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			screens := strings.Split(out, "view> ")
+			last := screens[len(screens)-2]
+			if got := rows(last); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestViewLimitsRows(t *testing.T) {
+	out, err := runCommand(t, runView, "a\n", "-limit", "2", checkoutSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	screens := strings.Split(out, "view> ")
+	if got := rows(screens[1]); len(got) != 3 || got[2] != "… 5 more rows; narrow them with f, m or e" {
+		t.Errorf("expected two rows and a note on the rest, got:\n%s", screens[1])
+	}
+}
+
+func TestViewRejectsBadInput(t *testing.T) {
+	if _, err := runCommand(t, runView, ""); err == nil {
+		t.Error("expected an error without session files")
+	}
+	if _, err := runCommand(t, runView, "", "testdata/missing.jsonl"); err == nil {
+		t.Error("expected an error for a missing session file")
+	}
+
+	out, err := runCommand(t, runView, "9\nm soon\nz\n", checkoutSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`no row "9"`, `bad duration "soon"`, `unknown command "z", type ? for help`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package devtrace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The collector protocol ships completed frames from many processes to one
+// gotrace-collector over TCP or a Unix socket. A stream is a sequence of
+// messages, each a 4-byte big-endian length followed by that many bytes of
+// JSON: first a CollectorHello, then one ExportedFrame per completed frame.
+
+// CollectorHello opens a collector stream and names the process behind it
+type CollectorHello struct {
+	Schema  int    `json:"schema"` // JSONLSchemaVersion of the frames that follow
+	Service string `json:"service"`
+	Host    string `json:"host,omitempty"`
+	PID     int    `json:"pid,omitempty"`
+}
+
+// MaxCollectorMessage is the largest message ReadCollectorStream accepts
+const MaxCollectorMessage = 16 << 20
+
+// Defaults for CollectorClientOptions
+var (
+	DefaultCollectorBuffer      = 4096
+	DefaultCollectorDialTimeout = 2 * time.Second
+	DefaultCollectorRetry       = time.Second // wait between failed connection attempts
+)
+
+// WriteCollectorMessage writes v as one length-prefixed JSON message
+func WriteCollectorMessage(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxCollectorMessage {
+		return fmt.Errorf("collector message of %d bytes is over the %d byte limit", len(data), MaxCollectorMessage)
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// readCollectorMessage reads one message into v; io.EOF means the stream ended
+// cleanly between messages
+func readCollectorMessage(r io.Reader, v interface{}) error {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > MaxCollectorMessage {
+		return fmt.Errorf("collector message of %d bytes is over the %d byte limit", n, MaxCollectorMessage)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ReadCollectorStream reads a collector stream, calling handle with the hello
+// and each frame, until the stream ends (nil), handle fails or the stream is
+// malformed
+func ReadCollectorStream(r io.Reader, handle func(hello CollectorHello, frame ExportedFrame) error) error {
+	reader := bufio.NewReader(r)
+	var hello CollectorHello
+	if err := readCollectorMessage(reader, &hello); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return fmt.Errorf("collector hello: %v", err)
+	}
+	if hello.Schema > JSONLSchemaVersion {
+		return fmt.Errorf("collector stream schema %d is newer than this version of devtrace reads (%d)", hello.Schema, JSONLSchemaVersion)
+	}
+
+	for {
+		var frame ExportedFrame
+		if err := readCollectorMessage(reader, &frame); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := handle(hello, frame); err != nil {
+			return err
+		}
+	}
+}
+
+// CollectorClientOptions configures NewCollectorClient; zero fields use the defaults
+type CollectorClientOptions struct {
+	Service     string        // name of this process in the collector; defaults to the executable name
+	Buffer      int           // frames queued while sending; frames past it are dropped and counted
+	DialTimeout time.Duration // for each connection attempt
+	Retry       time.Duration // wait after a failed connection attempt; frames completing meanwhile are dropped
+}
+
+// CollectorClient ships every completed frame to a gotrace-collector. It
+// connects on first use and reconnects after errors, so the collector may start
+// after the process; frames are dropped while it is unreachable.
+type CollectorClient struct {
+	network, addr string
+	opts          CollectorClientOptions
+	hello         CollectorHello
+
+	frames  chan ExportedFrame
+	done    chan struct{}
+	dropped int64
+
+	mu     sync.RWMutex // guards closed against sends on the closed frames channel
+	closed bool
+}
+
+// NewCollectorClient starts shipping frames to the collector listening on addr,
+// e.g. ("tcp", "localhost:7070") or ("unix", "/tmp/gotrace.sock"), until Close
+func NewCollectorClient(network, addr string, opts *CollectorClientOptions) *CollectorClient {
+	c := &CollectorClient{network: network, addr: addr}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.Service == "" {
+		c.opts.Service = filepath.Base(os.Args[0])
+	}
+	if c.opts.Buffer <= 0 {
+		c.opts.Buffer = DefaultCollectorBuffer
+	}
+	if c.opts.DialTimeout <= 0 {
+		c.opts.DialTimeout = DefaultCollectorDialTimeout
+	}
+	if c.opts.Retry <= 0 {
+		c.opts.Retry = DefaultCollectorRetry
+	}
+	host, _ := os.Hostname()
+	c.hello = CollectorHello{Schema: JSONLSchemaVersion, Service: c.opts.Service, Host: host, PID: os.Getpid()}
+
+	c.frames = make(chan ExportedFrame, c.opts.Buffer)
+	c.done = make(chan struct{})
+	go c.run()
+	addExporter(c)
+	return c
+}
+
+// Dropped returns how many frames were not delivered: dropped because the
+// queue was full or the collector unreachable, or lost with a broken connection
+func (c *CollectorClient) Dropped() int64 {
+	return atomic.LoadInt64(&c.dropped)
+}
+
+// Close stops shipping frames and sends the queued ones
+func (c *CollectorClient) Close() error {
+	removeExporter(c)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.frames)
+	c.mu.Unlock()
+
+	<-c.done
+	return nil
+}
+
+func (c *CollectorClient) export(tc *TraceContext, line ExportedFrame) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.frames <- line:
+	default:
+		atomic.AddInt64(&c.dropped, 1)
+	}
+}
+
+// run sends queued frames, flushing whenever the queue runs empty
+func (c *CollectorClient) run() {
+	defer close(c.done)
+	var conn net.Conn
+	var w *bufio.Writer
+	var retryAt time.Time
+	defer func() {
+		if conn != nil {
+			w.Flush()
+			conn.Close()
+		}
+	}()
+
+	for frame := range c.frames {
+		if conn == nil && time.Now().After(retryAt) {
+			var err error
+			if conn, w, err = c.connect(); err != nil {
+				retryAt = time.Now().Add(c.opts.Retry)
+			}
+		}
+		if conn == nil {
+			atomic.AddInt64(&c.dropped, 1)
+			continue
+		}
+
+		err := WriteCollectorMessage(w, frame)
+		if err == nil && len(c.frames) == 0 {
+			err = w.Flush()
+		}
+		if err != nil {
+			// what the buffer held is lost with the connection
+			atomic.AddInt64(&c.dropped, 1)
+			conn.Close()
+			conn, w = nil, nil
+		}
+	}
+}
+
+func (c *CollectorClient) connect() (net.Conn, *bufio.Writer, error) {
+	conn, err := net.DialTimeout(c.network, c.addr, c.opts.DialTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	w := bufio.NewWriter(conn)
+	if err := WriteCollectorMessage(w, c.hello); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, w, nil
+}
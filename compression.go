@@ -0,0 +1,147 @@
+package devtrace
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Compression names a codec for trace files and transport.
+// The name doubles as the HTTP Content-Encoding token.
+type Compression string
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// Compressor creates writers and readers for one compression format
+type Compressor struct {
+	Magic     []byte // leading bytes used to detect the format when reading
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[Compression]Compressor{
+		CompressionGzip: {
+			Magic: []byte{0x1f, 0x8b},
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return gzip.NewWriter(w), nil
+			},
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+		},
+	}
+)
+
+// RegisterCompressor adds or replaces a codec. The core package ships gzip only, to stay
+// free of third-party dependencies; zstd can be plugged in from an external package:
+//
+//	devtrace.RegisterCompressor(devtrace.CompressionZstd, devtrace.Compressor{
+//		Magic:     []byte{0x28, 0xb5, 0x2f, 0xfd},
+//		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+//		NewReader: func(r io.Reader) (io.ReadCloser, error) { d, err := zstd.NewReader(r); return d.IOReadCloser(), err },
+//	})
+func RegisterCompressor(name Compression, compressor Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+
+	compressors[name] = compressor
+}
+
+// SupportedCompressions returns the registered codec names, sorted
+func SupportedCompressions() []Compression {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+
+	names := make([]Compression, 0, len(compressors))
+	for name := range compressors {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+func lookupCompressor(name Compression) (Compressor, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+
+	c, ok := compressors[name]
+	return c, ok
+}
+
+// NewCompressingWriter wraps w with the named codec. CompressionNone returns a
+// writer whose Close is a no-op. Close must be called to flush compressed output;
+// it does not close w.
+func NewCompressingWriter(w io.Writer, name Compression) (io.WriteCloser, error) {
+	if name == CompressionNone {
+		return nopWriteCloser{w}, nil
+	}
+
+	c, ok := lookupCompressor(name)
+	if !ok {
+		return nil, fmt.Errorf("devtrace: compression %q is not registered", name)
+	}
+	return c.NewWriter(w)
+}
+
+// NewDecompressingReader detects the codec of r from its leading bytes and returns
+// a reader for the plain content; uncompressed input is passed through unchanged
+func NewDecompressingReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(8)
+
+	compressorsMu.RLock()
+	var match *Compressor
+	for name := range compressors {
+		c := compressors[name]
+		if len(c.Magic) > 0 && bytes.HasPrefix(head, c.Magic) {
+			match = &c
+			break
+		}
+	}
+	compressorsMu.RUnlock()
+
+	if match == nil {
+		if bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+			return nil, fmt.Errorf("devtrace: zstd input but no zstd compressor registered")
+		}
+		return io.NopCloser(br), nil
+	}
+	return match.NewReader(br)
+}
+
+// NegotiateCompression picks the first registered codec accepted by an HTTP
+// Accept-Encoding header, preferring zstd over gzip, or CompressionNone
+func NegotiateCompression(acceptEncoding string) Compression {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		token := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if strings.Contains(part, "q=0") && !strings.Contains(part, "q=0.") {
+			continue
+		}
+		accepted[strings.ToLower(token)] = true
+	}
+
+	for _, name := range []Compression{CompressionZstd, CompressionGzip} {
+		if _, ok := lookupCompressor(name); ok && (accepted[string(name)] || accepted["*"]) {
+			return name
+		}
+	}
+	return CompressionNone
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,42 @@
+package devtrace
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCompressionRoundTripAndNegotiation(t *testing.T) {
+	payload := strings.Repeat(`{"function":"worker","duration":1200}`+"\n", 200)
+
+	var buf bytes.Buffer
+	w, err := NewCompressingWriter(&buf, CompressionGzip)
+	if err != nil {
+		t.Fatalf("writer: %v", err)
+	}
+	io.WriteString(w, payload)
+	w.Close()
+
+	if buf.Len() >= len(payload) {
+		t.Fatalf("gzip output not smaller: %d >= %d", buf.Len(), len(payload))
+	}
+
+	for name, input := range map[string][]byte{"gzip": buf.Bytes(), "plain": []byte(payload)} {
+		r, err := NewDecompressingReader(bytes.NewReader(input))
+		if err != nil {
+			t.Fatalf("%s reader: %v", name, err)
+		}
+		out, _ := io.ReadAll(r)
+		if string(out) != payload {
+			t.Fatalf("%s round trip mismatch", name)
+		}
+	}
+
+	if got := NegotiateCompression("br, gzip;q=0.8, zstd"); got != CompressionGzip {
+		t.Fatalf("expected gzip without registered zstd, got %q", got)
+	}
+	if got := NegotiateCompression("gzip;q=0, identity"); got != CompressionNone {
+		t.Fatalf("expected no compression, got %q", got)
+	}
+}
@@ -0,0 +1,48 @@
+package devtrace
+
+import "context"
+
+// TraceCondition decides from a frame about to be entered, its Function and
+// Args, whether a trace should start recording there
+type TraceCondition func(frame *Frame) bool
+
+const traceConditionKey contextKey = "devtrace_trace_condition"
+
+// WithTraceCondition makes trace contexts entered through ctx record nothing
+// until a frame satisfies cond; that frame and everything it calls are then
+// recorded. It works like a conditional breakpoint for tracing:
+//
+//	ctx = devtrace.WithTraceCondition(ctx, func(f *devtrace.Frame) bool {
+//		return f.Args["userID"] == 42
+//	})
+//
+// Frames entered before the match are counted in the depth only, and the stack
+// logger prints messages without a stack while nothing has matched. The
+// condition is checked by EnterContext, Trace wrappers and StartSpan;
+// GlobalEnter does not see ctx and always records.
+func WithTraceCondition(ctx context.Context, cond TraceCondition) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, traceConditionKey, cond)
+}
+
+func traceConditionFrom(ctx context.Context) TraceCondition {
+	if ctx == nil {
+		return nil
+	}
+	cond, _ := ctx.Value(traceConditionKey).(TraceCondition)
+	return cond
+}
+
+// waitingForCondition reports whether tc records nothing yet because the
+// condition of ctx has not matched any of its frames
+func waitingForCondition(ctx context.Context, tc *TraceContext) bool {
+	return tc != nil && len(tc.Frames) == 0 && tc.overflow == 0 && traceConditionFrom(ctx) != nil
+}
+
+// skippedByCondition reports whether frame must not be recorded because the
+// condition of ctx has not matched yet and does not match frame either
+func skippedByCondition(ctx context.Context, tc *TraceContext, frame *Frame) bool {
+	return waitingForCondition(ctx, tc) && !traceConditionFrom(ctx)(frame)
+}
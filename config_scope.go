@@ -0,0 +1,148 @@
+package devtrace
+
+import (
+	"context"
+	"math/rand"
+)
+
+const configOverridesKey contextKey = "devtrace_config_overrides"
+
+// ConfigOverrides holds per-context changes to the global configuration.
+// Nil fields inherit the value from the parent scope or the global Config.
+type ConfigOverrides struct {
+	Enabled     *bool
+	ShowArgs    *bool
+	ShowTiming  *bool
+	ShowSnippet *int
+	StackLimit  *int
+	DebugLevel  *int
+	SampleRate  *float64
+	LogErrors   *bool
+}
+
+// Override returns a pointer to v, for filling ConfigOverrides fields inline
+func Override[T any](v T) *T {
+	return &v
+}
+
+// WithConfig attaches config overrides to a context subtree.
+// Overrides stack: fields set here replace those inherited from ctx, unset fields are kept.
+func WithConfig(ctx context.Context, overrides ConfigOverrides) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	merged := overrides
+	if parent := overridesFromContext(ctx); parent != nil {
+		merged = parent.merge(overrides)
+	}
+
+	return context.WithValue(ctx, configOverridesKey, &merged)
+}
+
+// ConfigFromContext returns the global Config with any overrides attached to ctx applied
+func ConfigFromContext(ctx context.Context) DevTraceConfig {
+	return configFor(ctx, "")
+}
+
+// configFor resolves the config for a function running under ctx:
+// global Config, then the matching PackageOverride, then ctx overrides
+func configFor(ctx context.Context, function string) DevTraceConfig {
+	cfg := CurrentConfig()
+	if overrides := effectiveOverrides(ctx, function); overrides != nil {
+		overrides.apply(&cfg)
+	}
+	return cfg
+}
+
+// effectiveOverrides merges the package override for function with those attached to ctx
+func effectiveOverrides(ctx context.Context, function string) *ConfigOverrides {
+	scoped := overridesFromContext(ctx)
+	pkg := packageOverrideFor(function)
+	if pkg == nil {
+		return scoped
+	}
+	if scoped != nil {
+		merged := pkg.merge(*scoped)
+		return &merged
+	}
+	return pkg
+}
+
+func overridesFromContext(ctx context.Context) *ConfigOverrides {
+	if ctx == nil {
+		return nil
+	}
+	overrides, _ := ctx.Value(configOverridesKey).(*ConfigOverrides)
+	return overrides
+}
+
+// merge returns a copy of o with every field set in child taking precedence
+func (o ConfigOverrides) merge(child ConfigOverrides) ConfigOverrides {
+	if child.Enabled != nil {
+		o.Enabled = child.Enabled
+	}
+	if child.ShowArgs != nil {
+		o.ShowArgs = child.ShowArgs
+	}
+	if child.ShowTiming != nil {
+		o.ShowTiming = child.ShowTiming
+	}
+	if child.ShowSnippet != nil {
+		o.ShowSnippet = child.ShowSnippet
+	}
+	if child.StackLimit != nil {
+		o.StackLimit = child.StackLimit
+	}
+	if child.DebugLevel != nil {
+		o.DebugLevel = child.DebugLevel
+	}
+	if child.SampleRate != nil {
+		o.SampleRate = child.SampleRate
+	}
+	if child.LogErrors != nil {
+		o.LogErrors = child.LogErrors
+	}
+	return o
+}
+
+func (o *ConfigOverrides) apply(cfg *DevTraceConfig) {
+	if o.Enabled != nil {
+		cfg.Enabled = *o.Enabled
+	}
+	if o.ShowArgs != nil {
+		cfg.ShowArgs = *o.ShowArgs
+	}
+	if o.ShowTiming != nil {
+		cfg.ShowTiming = *o.ShowTiming
+	}
+	if o.ShowSnippet != nil {
+		cfg.ShowSnippet = *o.ShowSnippet
+	}
+	if o.StackLimit != nil {
+		cfg.StackLimit = *o.StackLimit
+	}
+	if o.DebugLevel != nil {
+		cfg.DebugLevel = *o.DebugLevel
+	}
+	if o.SampleRate != nil {
+		cfg.SampleRate = *o.SampleRate
+	}
+	if o.LogErrors != nil {
+		cfg.LogErrors = *o.LogErrors
+	}
+}
+
+// captureArgsFor reports whether traced calls to function under ctx should record their
+// arguments: ShowArgs of a scope or package override, or of the global Config when they leave it unset.
+func captureArgsFor(ctx context.Context, function string) bool {
+	return configFor(ctx, function).ShowArgs
+}
+
+// sampled decides whether a single call should be traced under the given config
+func sampled(cfg DevTraceConfig) bool {
+	if cfg.SampleRate <= 0 || cfg.SampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < cfg.SampleRate
+}
@@ -0,0 +1,69 @@
+package devtrace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCaptureArgsFollowsGlobalShowArgs(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+
+	const function = "github.com/acme/shop/api.Serve"
+	tests := []struct {
+		name      string
+		global    bool
+		overrides []ConfigOverrides
+		want      bool
+	}{
+		{name: "global on", global: true, want: true},
+		{name: "global off", global: false, want: false},
+		{name: "scope without ShowArgs keeps global off", global: false, overrides: []ConfigOverrides{{StackLimit: Override(3)}}, want: false},
+		{name: "scope without ShowArgs keeps global on", global: true, overrides: []ConfigOverrides{{StackLimit: Override(3)}}, want: true},
+		{name: "scope enables", global: false, overrides: []ConfigOverrides{{ShowArgs: Override(true)}}, want: true},
+		{name: "scope disables", global: true, overrides: []ConfigOverrides{{ShowArgs: Override(false)}}, want: false},
+		{name: "inner scope inherits", global: false, overrides: []ConfigOverrides{{ShowArgs: Override(true)}, {DebugLevel: Override(2)}}, want: true},
+		{name: "inner scope wins", global: true, overrides: []ConfigOverrides{{ShowArgs: Override(true)}, {ShowArgs: Override(false)}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			UpdateConfig(func(c *DevTraceConfig) { c.ShowArgs = tt.global })
+			ctx := context.Background()
+			for _, overrides := range tt.overrides {
+				ctx = WithConfig(ctx, overrides)
+			}
+			if got := captureArgsFor(ctx, function); got != tt.want {
+				t.Errorf("captureArgsFor = %v, want %v", got, tt.want)
+			}
+			if got := ConfigFromContext(ctx).ShowArgs; got != tt.want {
+				t.Errorf("ConfigFromContext(ctx).ShowArgs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTracedCallHonoursGlobalShowArgs(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) {
+		c.Enabled = true
+		c.ShowArgs = false
+	})
+
+	var args []map[string]interface{}
+	t.Cleanup(RegisterHook(Hook{OnEnter: func(frame *Frame) { args = append(args, frame.Args) }}))
+
+	double := NewTracedFunc(func(n int) int { return n * 2 }, nil)
+	double.Call(context.Background(), 21)
+	double.Call(WithConfig(context.Background(), ConfigOverrides{ShowArgs: Override(true)}), 21)
+
+	if len(args) != 2 {
+		t.Fatalf("expected two traced calls, got %d", len(args))
+	}
+	if len(args[0]) != 0 {
+		t.Errorf("expected no args with ShowArgs off globally, got %v", args[0])
+	}
+	if len(args[1]) != 1 {
+		t.Errorf("expected the scope to turn arg capture back on, got %v", args[1])
+	}
+}
@@ -0,0 +1,84 @@
+package devtrace
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ConfigError describes one invalid DevTraceConfig field and what it accepts
+type ConfigError struct {
+	Field string
+	Value interface{}
+	Hint  string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("devtrace: invalid %s %v: %s", e.Field, e.Value, e.Hint)
+}
+
+// Validate reports every field that would make devtrace misbehave later, such
+// as a negative StackLimit. The result joins one *ConfigError per problem.
+func (c DevTraceConfig) Validate() error {
+	var errs []error
+	check := func(ok bool, field string, value interface{}, hint string) {
+		if !ok {
+			errs = append(errs, &ConfigError{Field: field, Value: value, Hint: hint})
+		}
+	}
+
+	check(c.StackLimit >= 0, "StackLimit", c.StackLimit,
+		"it is the number of stack frames shown per log line; use 0 or more")
+	check(c.ShowSnippet >= 0, "ShowSnippet", c.ShowSnippet,
+		"it is the number of source lines shown around each frame; use 0 to turn snippets off")
+	check(c.DebugLevel >= 0 && c.DebugLevel <= 2, "DebugLevel", c.DebugLevel,
+		"use 0 for warnings and errors only, 1 to add info or 2 to add debug messages")
+	check(!math.IsNaN(c.SampleRate) && c.SampleRate >= 0 && c.SampleRate <= 1, "SampleRate", c.SampleRate,
+		"it is the fraction of traced calls to record; use a value between 0 and 1")
+	check(c.MaxDepth >= 0, "MaxDepth", c.MaxDepth,
+		"it is the number of frames kept per trace context; use 0 for no limit")
+	check(c.MaxValueLen >= 0, "MaxValueLen", c.MaxValueLen,
+		"it is the number of bytes kept of each captured value; use 0 for no limit")
+	check(c.MaxValueDepth >= 0, "MaxValueDepth", c.MaxValueDepth,
+		"it is the nesting of structs, slices and maps rendered per value; use 0 for no limit")
+	check(c.MaxSliceElems >= 0, "MaxSliceElems", c.MaxSliceElems,
+		"it is the number of slice elements rendered per value; use 0 for no limit")
+	check(c.MaxMapEntries >= 0, "MaxMapEntries", c.MaxMapEntries,
+		"it is the number of map entries rendered per value; use 0 for no limit")
+	check(c.SlowThreshold >= 0, "SlowThreshold", c.SlowThreshold,
+		"use a positive duration such as 250ms, or 0 to turn slow-call warnings off")
+	check(strings.TrimSpace(c.AppPattern) == c.AppPattern, "AppPattern", fmt.Sprintf("%q", c.AppPattern),
+		"surrounding spaces never match a file path; use \"/\" to detect application code from the main module")
+	for _, name := range c.CaptureEnvVars {
+		check(name != "" && !strings.ContainsAny(name, "= \t"), "CaptureEnvVars", fmt.Sprintf("%q", name),
+			"list variable names only, without values or spaces")
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate reports stack logger options that would hide every frame or none,
+// such as OnlyApp without an AppPattern. The result joins one *ConfigError per
+// problem.
+func (o StackLoggerOptions) Validate() error {
+	var errs []error
+	check := func(ok bool, field string, value interface{}, hint string) {
+		if !ok {
+			errs = append(errs, &ConfigError{Field: field, Value: value, Hint: hint})
+		}
+	}
+
+	check(o.Skip >= 0, "Skip", o.Skip,
+		"it is the number of logger frames dropped from the top of the stack; use 0 or more")
+	check(o.Limit >= 0, "Limit", o.Limit,
+		"it is the number of stack frames shown per log line; use 0 or more")
+	check(o.ShowSnippet >= 0, "ShowSnippet", o.ShowSnippet,
+		"it is the number of source lines shown around each frame; use 0 to turn snippets off")
+	check(!o.OnlyApp || o.AppPattern != "", "AppPattern", fmt.Sprintf("%q", o.AppPattern),
+		"OnlyApp keeps only frames matching it; set the import path of your code, or \"/\" to detect it from the main module")
+	check(strings.TrimSpace(o.AppPattern) == o.AppPattern, "AppPattern", fmt.Sprintf("%q", o.AppPattern),
+		"surrounding spaces never match a file path; use \"/\" to detect application code from the main module")
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,96 @@
+package devtrace
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSetConfigRejectsInvalidConfig(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+
+	bad := original
+	bad.StackLimit = -3
+	bad.SampleRate = 2
+
+	err := SetConfig(bad)
+	if err == nil {
+		t.Fatal("expected a negative StackLimit to be rejected")
+	}
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) || cfgErr.Field != "StackLimit" {
+		t.Fatalf("expected a *ConfigError for StackLimit, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "SampleRate") {
+		t.Fatalf("expected every problem to be reported, got %v", err)
+	}
+	if CurrentConfig().StackLimit != original.StackLimit {
+		t.Fatal("expected the current config to stay in place")
+	}
+	if err := builtinConfig.Validate(); err != nil {
+		t.Fatalf("expected the built-in defaults to be valid, got %v", err)
+	}
+}
+
+func TestUpdateConfigRejectsInvalidResult(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+
+	cfg, err := UpdateConfig(func(c *DevTraceConfig) {
+		c.Enabled = !original.Enabled
+		c.MaxDepth = -1
+	})
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) || cfgErr.Field != "MaxDepth" {
+		t.Fatalf("expected a *ConfigError for MaxDepth, got %v", err)
+	}
+	if cfg.Enabled != original.Enabled || CurrentConfig().Enabled != original.Enabled {
+		t.Fatal("expected none of a rejected update to be applied")
+	}
+
+	if cfg, err = UpdateConfig(func(c *DevTraceConfig) { c.MaxDepth = 7 }); err != nil || cfg.MaxDepth != 7 {
+		t.Fatalf("expected a valid update to be applied, got %+v, %v", cfg, err)
+	}
+}
+
+func TestStackLoggerOptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		opts  StackLoggerOptions
+		field string
+	}{
+		{name: "defaults", opts: DefaultStackLoggerOptions},
+		{name: "only app with a pattern", opts: StackLoggerOptions{OnlyApp: true, AppPattern: "example.com/app"}},
+		{name: "only app detecting the main module", opts: StackLoggerOptions{OnlyApp: true, AppPattern: "/"}},
+		{name: "empty pattern without only app", opts: StackLoggerOptions{PreferApp: true}},
+		{name: "only app without a pattern", opts: StackLoggerOptions{OnlyApp: true}, field: "AppPattern"},
+		{name: "negative limit", opts: StackLoggerOptions{Limit: -1, AppPattern: "/"}, field: "Limit"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.opts.Validate()
+			if tc.field == "" {
+				if err != nil {
+					t.Fatalf("expected valid options, got %v", err)
+				}
+				return
+			}
+			var cfgErr *ConfigError
+			if !errors.As(err, &cfgErr) || cfgErr.Field != tc.field {
+				t.Fatalf("expected a *ConfigError for %s, got %v", tc.field, err)
+			}
+		})
+	}
+}
+
+func TestInstallStackLoggerRejectsInvalidOptions(t *testing.T) {
+	original := GlobalEnhancedLogger
+	t.Cleanup(func() { GlobalEnhancedLogger = original })
+
+	if err := InstallStackLogger(&StackLoggerOptions{OnlyApp: true}); err == nil {
+		t.Fatal("expected OnlyApp without an AppPattern to be rejected")
+	}
+	if GlobalEnhancedLogger != original {
+		t.Fatal("expected the current stack logger to stay in place")
+	}
+}
@@ -53,17 +53,18 @@ func WithTraceContext(ctx context.Context, traceCtx *TraceContext) context.Conte
 	return context.WithValue(ctx, traceContextKey, traceCtx)
 }
 
-// FromContext extracts the trace context from the given context
+// FromContext extracts the trace context from the given context,
+// falling back to the calling goroutine's context
 func FromContext(ctx context.Context) *TraceContext {
 	if ctx == nil {
-		return GetGlobalContext()
+		return CurrentContext()
 	}
 
 	if traceCtx, ok := ctx.Value(traceContextKey).(*TraceContext); ok {
 		return traceCtx
 	}
 
-	return GetGlobalContext()
+	return CurrentContext()
 }
 
 // NewTraceContext creates a new trace context
@@ -81,38 +82,142 @@ func (tc *TraceContext) Enter(frame *Frame) {
 		return
 	}
 
-	tc.Frames = append(tc.Frames, frame)
+	if tc.enter(frame) {
+		runEnterHooks(frame)
+	}
+}
+
+// enter records the frame unless the context is past Config.MaxDepth,
+// in which case only the depth and skip counters move. It reports whether the frame was kept.
+func (tc *TraceContext) enter(frame *Frame) bool {
+	tc.mu.Lock()
 	tc.Depth++
+	tc.mu.Unlock()
+	cfg := CurrentConfig()
+	if limit := cfg.MaxDepth; limit > 0 && len(tc.Frames) >= limit {
+		if tc.Skipped == 0 && GlobalLogger != nil {
+			GlobalLogger.Warn("⚠ trace depth limit %d reached, deeper frames are not recorded", limit)
+		}
+		tc.Skipped++
+		tc.overflow++
+		return false
+	}
+
+	if len(tc.Frames) == 0 {
+		markActive(tc)
+		registerGoroutineContext(tc)
+		attachInvocation(frame)
+		tc.topChild, tc.topChildDuration = "", 0
+	}
+	if frame.Operation == "" {
+		frame.Operation = tc.operation()
+	}
+	tc.mu.Lock()
+	tc.Frames = append(tc.Frames, frame)
+	tc.mu.Unlock()
+	if cfg.PprofLabels {
+		tc.applyPprofLabels(frame)
+	}
+	return true
 }
 
 // Leave removes the most recent frame from the trace context
 func (tc *TraceContext) Leave() *Frame {
-	if tc == nil || len(tc.Frames) == 0 {
+	frame := tc.leave()
+	runExitHooks(frame)
+	return frame
+}
+
+func (tc *TraceContext) leave() *Frame {
+	if tc == nil {
 		return nil
 	}
 
+	if tc.overflow > 0 {
+		tc.overflow--
+		tc.addDepth(-1)
+		return nil
+	}
+
+	if len(tc.Frames) == 0 && tc.unmatched > 0 {
+		tc.unmatched--
+		tc.addDepth(-1)
+		return nil
+	}
+
+	if len(tc.Frames) == 0 {
+		return nil
+	}
+
+	tc.mu.Lock()
 	frame := tc.Frames[len(tc.Frames)-1]
 	tc.Frames = tc.Frames[:len(tc.Frames)-1]
 	tc.Depth--
+	tc.mu.Unlock()
+	if len(tc.Frames) == 0 {
+		markIdle(tc)
+		unregisterGoroutineContext(tc)
+	}
 
 	// Update frame end time and duration
 	frame.EndTime = time.Now()
 	if !frame.StartTime.IsZero() {
 		frame.Duration = frame.EndTime.Sub(frame.StartTime)
 	}
+	tc.restorePprofLabels(frame)
+	if len(tc.Frames) == 0 {
+		tc.labelBase = nil
+	}
+	if len(tc.Frames) == 1 && (tc.topChild == "" || frame.Duration > tc.topChildDuration) {
+		tc.topChild, tc.topChildDuration = frame.Function, frame.Duration
+	}
+
+	recordFrame(tc, frame)
+	exportFrame(tc, frame)
+	recordFunctionStats(frame)
+	recordOperationStats(tc, frame)
 
 	return frame
 }
 
+// recordChild records frame, which already ran to completion elsewhere, as a
+// child of the current frame of tc: recorder and stats see it as if it had
+// been entered and left there
+func recordChild(tc *TraceContext, frame *Frame) {
+	if tc == nil || tc.overflow > 0 {
+		return
+	}
+	if frame.Operation == "" {
+		frame.Operation = tc.operation()
+	}
+	if frame.Goroutine == 0 {
+		frame.Goroutine = tc.goroutine
+	}
+	if frame.Goroutine == 0 {
+		frame.Goroutine = goroutineID()
+	}
+	recordFrame(tc, frame)
+	exportFrame(tc, frame)
+	recordFunctionStats(frame)
+	recordOperationStats(tc, frame)
+}
+
 // Stack returns a copy of the current stack frames
 func (tc *TraceContext) Stack() []*Frame {
 	if tc == nil {
 		return []*Frame{}
 	}
 
-	// Create a copy to avoid race conditions
+	// The copy is taken under mu so other goroutines can call Stack while the
+	// owner enters and leaves frames; retaining the frames before mu is
+	// released keeps the owner from recycling any of them
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
 	stack := make([]*Frame, len(tc.Frames))
 	copy(stack, tc.Frames)
+	for _, frame := range stack {
+		frame.retain()
+	}
 	return stack
 }
 
@@ -121,67 +226,186 @@ func (tc *TraceContext) GetDepth() int {
 	if tc == nil {
 		return 0
 	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
 	return tc.Depth
 }
 
+// addDepth moves Depth for frames that are counted but not recorded
+func (tc *TraceContext) addDepth(delta int) {
+	tc.mu.Lock()
+	tc.Depth += delta
+	tc.mu.Unlock()
+}
+
+// SkippedFrames returns how many frames were dropped by the depth guard
+func (tc *TraceContext) SkippedFrames() int {
+	if tc == nil {
+		return 0
+	}
+	return tc.Skipped
+}
+
 // GetCurrentFrame returns the most recent frame without removing it
 func (tc *TraceContext) GetCurrentFrame() *Frame {
+	frame := tc.current()
+	if frame != nil {
+		frame.retain()
+	}
+	return frame
+}
+
+// current is GetCurrentFrame for callers that don't keep the frame
+func (tc *TraceContext) current() *Frame {
 	if tc == nil || len(tc.Frames) == 0 {
 		return nil
 	}
 	return tc.Frames[len(tc.Frames)-1]
 }
 
-// CreateFrame creates a new frame with the given parameters
+// CreateFrame creates a new frame with the given parameters. The doc comment shown in verbose mode and the caller are looked up only when
+// the frame is rendered (see Frame.Caller).
 func CreateFrame(functionName, signature, file string, line int, args map[string]interface{}) *Frame {
-	frame := &Frame{
+	frame := newFrame()
+	*frame = Frame{
 		Function:  functionName,
 		Signature: signature,
 		File:      file,
 		Line:      line,
 		Args:      args,
 		StartTime: time.Now(),
+		Goroutine: goroutineID(),
 	}
-
-	// Capture caller information
-	if pc, callerFile, callerLine, ok := runtime.Caller(2); ok {
-		if fn := runtime.FuncForPC(pc); fn != nil {
-			frame.CallerInfo = &runtime.Frame{
-				PC:       pc,
-				Func:     fn,
-				Function: fn.Name(),
-				File:     callerFile,
-				Line:     callerLine,
-			}
+	redactArgs(frame.Args)
+	if len(frame.Args) > 0 {
+		cfg := CurrentConfig()
+		clipArgs(frame.Args, cfg.MaxValueLen)
+		if cfg.SnapshotArgs {
+			snapshotArgs(frame.Args, cfg)
 		}
 	}
 
+	var pcs [1]uintptr
+	if runtime.Callers(3, pcs[:]) == 1 {
+		frame.callerPC = pcs[0]
+	}
+
 	return frame
 }
 
-// GlobalEnter adds a frame to the global trace context
+// Caller returns where the function of a frame made by CreateFrame was called
+// from, resolving and storing it in CallerInfo on first use
+func (f *Frame) Caller() *runtime.Frame {
+	if f.CallerInfo != nil || f.callerPC == 0 {
+		return f.CallerInfo
+	}
+	caller, _ := runtime.CallersFrames([]uintptr{f.callerPC}).Next()
+	if caller.Func == nil {
+		return nil
+	}
+	caller.File, caller.Line = originalPosition(caller.File, caller.Line)
+	f.CallerInfo = &caller
+	return f.CallerInfo
+}
+
+// GlobalEnter adds a frame to the calling goroutine's trace context. The
+// goroutine is the one CreateFrame recorded on frame, so entering does not look
+// it up again.
 func GlobalEnter(frame *Frame) {
-	InitGlobalContext()
+	if frame == nil || frame.Goroutine == 0 {
+		CurrentContext().Enter(frame)
+		return
+	}
+	goroutineContext(frame.Goroutine).Enter(frame)
+}
 
-	globalMutex.Lock()
-	defer globalMutex.Unlock()
+// GlobalLeave removes the most recent frame from the calling goroutine's trace context
+func GlobalLeave() *Frame {
+	return leaveChecked(context.Background(), CurrentContext())
+}
 
-	globalContext.Enter(frame)
+// EnterContext adds a frame to the trace context carried by ctx, or to the
+// calling goroutine's context when ctx has none. With Config.PprofLabels, the
+// pprof labels of ctx are kept beneath those of the frame.
+func EnterContext(ctx context.Context, frame *Frame) {
+	tc := FromContext(ctx)
+	if skippedByCondition(ctx, tc, frame) {
+		tc.addDepth(1)
+		tc.unmatched++
+		return
+	}
+	enterContextUnchecked(ctx, tc, frame)
 }
 
-// GlobalLeave removes a frame from the global trace context
-func GlobalLeave() *Frame {
-	if globalContext == nil {
-		return nil
+// enterContextUnchecked is EnterContext for callers that already found frame
+// not skipped by the trace condition of ctx, so it is evaluated only once
+func enterContextUnchecked(ctx context.Context, tc *TraceContext, frame *Frame) {
+	if tc != nil && len(tc.Frames) == 0 {
+		tc.labelBase = ctx
 	}
+	tc.Enter(frame)
+}
 
-	globalMutex.Lock()
-	defer globalMutex.Unlock()
+// LeaveContext removes the most recent frame entered with EnterContext(ctx, ...)
+func LeaveContext(ctx context.Context) *Frame {
+	return leaveChecked(ctx, FromContext(ctx))
+}
+
+// GlobalLeaveResults is GlobalLeave for functions with results: the values are
+// stored on the frame as its Results before it is popped
+func GlobalLeaveResults(results ...interface{}) *Frame {
+	tc := CurrentContext()
+	setCurrentResults(tc, results)
+	return leaveChecked(context.Background(), tc)
+}
+
+// LeaveContextResults is LeaveContext for functions with results
+func LeaveContextResults(ctx context.Context, results ...interface{}) *Frame {
+	tc := FromContext(ctx)
+	setCurrentResults(tc, results)
+	return leaveChecked(ctx, tc)
+}
+
+func setCurrentResults(tc *TraceContext, results []interface{}) {
+	if tc == nil || tc.overflow > 0 {
+		return // the frame being left was never recorded
+	}
+	if frame := tc.current(); frame != nil {
+		frame.setResults(results)
+	}
+}
+
+// setResults records what the frame's function returned and the error among
+// it. After a panic the results are zero values, so the frame keeps its Error.
+func (f *Frame) setResults(results []interface{}) {
+	f.Results = clipResults(results, CurrentConfig().MaxValueLen)
+	if f.Panic != nil {
+		return
+	}
+	f.Error = lastError(results)
+}
 
-	return globalContext.Leave()
+// leaveChecked pops the current frame of tc, warning first if it ran past the
+// slow threshold, and recycles it when Config.PoolFrames is set
+func leaveChecked(ctx context.Context, tc *TraceContext) *Frame {
+	cfg := ConfigFromContext(ctx)
+	if current := tc.current(); current != nil && tc.overflow == 0 {
+		if !current.StartTime.IsZero() {
+			warnIfSlow(ctx, current, time.Since(current.StartTime), 0, cfg)
+		}
+		logIfFailed(ctx, current, current.Function, nil)
+	}
+	frame := tc.Leave()
+	if tc.GetDepth() == 0 {
+		tc.panicReported = false // re-arm panic dumps once the outermost frame has left
+	}
+	if cfg.PoolFrames {
+		releaseFrame(frame)
+	}
+	return frame
 }
 
-// GlobalStack returns the current global stack
+// GlobalStack returns the current stack of the calling goroutine
 func GlobalStack() []*Frame {
-	return GetGlobalContext().Stack()
+	return CurrentContext().Stack()
 }
@@ -0,0 +1,323 @@
+package devtrace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestTraceContextDepthGuard(t *testing.T) {
+	originalConfig := Config
+	originalLogger := GlobalLogger
+	t.Cleanup(func() {
+		SetConfig(originalConfig)
+		GlobalLogger = originalLogger
+	})
+	cfg := Config
+	cfg.MaxDepth = 2
+	SetConfig(cfg)
+	GlobalLogger = &captureLogger{}
+
+	tc := NewTraceContext()
+	for i := 0; i < 5; i++ {
+		tc.Enter(&Frame{Function: "recurse"})
+	}
+
+	if len(tc.Frames) != 2 || tc.GetDepth() != 5 || tc.SkippedFrames() != 3 {
+		t.Fatalf("unexpected state: frames=%d depth=%d skipped=%d", len(tc.Frames), tc.GetDepth(), tc.SkippedFrames())
+	}
+
+	for i := 0; i < 3; i++ {
+		if frame := tc.Leave(); frame != nil {
+			t.Fatalf("leaving a skipped frame popped a recorded one")
+		}
+	}
+	if len(tc.Frames) != 2 {
+		t.Fatalf("recorded frames lost: %d", len(tc.Frames))
+	}
+
+	tc.Leave()
+	orphans := tc.Close()
+	if len(orphans) != 1 || !orphans[0].Unfinished {
+		t.Fatalf("expected one unfinished frame, got %+v", orphans)
+	}
+	if tc.GetDepth() != 0 {
+		t.Fatalf("depth not reset after close: %d", tc.GetDepth())
+	}
+}
+
+func TestGlobalLeaveResultsRecordsReturnValues(t *testing.T) {
+	t.Cleanup(DisableRecorder)
+	EnableRecorder(4)
+
+	failing := func() (n int, err error) {
+		GlobalEnter(CreateFrame("failing", "failing() (int, error)", "context_test.go", 1, nil))
+		defer func() { GlobalLeaveResults(n, err) }()
+		return 3, errors.New("boom")
+	}
+	failing()
+
+	records := RecentTraces(TraceFilter{OnlyErrors: true})
+	if len(records) != 1 {
+		t.Fatalf("expected the returned error to mark the frame, got %d records", len(records))
+	}
+	if results := records[0].Frame.Results; len(results) != 2 || results[0] != 3 {
+		t.Fatalf("unexpected results: %v", results)
+	}
+}
+
+type traceUser struct{ name string }
+
+type traceErr struct{}
+
+func (*traceErr) Error() string { return "typed nil" }
+
+func TestTraceKeepsNilAndInterfaceResults(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) {
+		c.Enabled = true
+		c.ShowTiming = false
+	})
+
+	find := Trace(func(name string) (*traceUser, map[string]int, error) {
+		if name == "" {
+			return nil, nil, nil
+		}
+		return nil, nil, errors.New("not found")
+	}, nil).(func(string) (*traceUser, map[string]int, error))
+	if user, m, err := find(""); user != nil || m != nil || err != nil {
+		t.Fatalf("nil results changed: %v %v %v", user, m, err)
+	}
+	if _, _, err := find("bob"); err == nil || err.Error() != "not found" {
+		t.Fatalf("returned error lost: %v", err)
+	}
+
+	var typedNil *traceErr
+	open := Trace(func() (fmt.Stringer, error) {
+		return nil, typedNil
+	}, nil).(func() (fmt.Stringer, error))
+	s, err := open()
+	if s != nil {
+		t.Fatalf("nil interface result changed: %v", s)
+	}
+	if err == nil {
+		t.Fatalf("a typed nil error must stay a non-nil error, as untraced")
+	}
+	if _, ok := err.(*traceErr); !ok {
+		t.Fatalf("error type changed: %T", err)
+	}
+}
+
+func createPoolTestFrame(name string) *Frame {
+	return CreateFrame(name, "", "pool.go", 1, nil)
+}
+
+func TestPooledFramesKeepRetainedFrames(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true; c.PoolFrames = true })
+
+	kept := createPoolTestFrame("kept")
+	GlobalEnter(kept)
+	stack := GlobalStack()
+	GlobalLeave()
+
+	for i := 0; i < 100; i++ {
+		frame := createPoolTestFrame("reused")
+		GlobalEnter(frame)
+		GlobalLeave()
+		if frame == kept {
+			t.Fatalf("a frame returned by GlobalStack was recycled")
+		}
+	}
+	if stack[0].Function != "kept" || stack[0].Duration == 0 {
+		t.Fatalf("retained frame was overwritten: %+v", stack[0])
+	}
+
+	caller := kept.Caller()
+	if caller == nil || caller.Function != "github.com/skulidropek/gotrace.TestPooledFramesKeepRetainedFrames" || kept.CallerInfo != caller {
+		t.Fatalf("expected the caller to be resolved on demand, got %+v", caller)
+	}
+}
+
+func TestTracedFuncFramesAreKeptWithoutPoolFrames(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true; c.PoolFrames = false })
+
+	var left *Frame
+	NewTracedFunc(func() { left = FromContext(ctx).current() }, nil).Call(ctx)
+	for i := 0; i < 100; i++ {
+		if createPoolTestFrame("reused") == left {
+			t.Fatal("expected the frame of a Trace wrapper to be kept without PoolFrames")
+		}
+	}
+}
+
+func TestSpansNestAndRecordEvents(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	outer := StartSpan(ctx, "checkout", Attrs{"order": 7})
+	inner := StartSpan(ctx, "charge-card", Attrs{"password": "hunter2"})
+	if stack := FromContext(ctx).Stack(); len(stack) != 2 || stack[1] != inner.Frame() {
+		t.Fatalf("expected the spans to nest, got %+v", stack)
+	}
+	inner.AddEvent("retry", Attrs{"attempt": 2})
+	inner.RecordError(errors.New("card declined"))
+	inner.End()
+	inner.End()
+	outer.SetAttr("items", 3)
+	outer.End()
+
+	frame := inner.Frame()
+	if frame.Args["password"] == "hunter2" || frame.Duration == 0 || FrameError(frame) == nil {
+		t.Fatalf("unexpected span frame: %+v", frame)
+	}
+	snapshot := SnapshotFrame(frame)
+	if len(snapshot.Events) != 2 || snapshot.Events[0].Name != "retry" || snapshot.Events[0].Attrs["attempt"] != "2" {
+		t.Fatalf("unexpected events: %+v", snapshot.Events)
+	}
+	if outer.Frame().Args["items"] != 3 || FromContext(ctx).GetDepth() != 0 {
+		t.Fatalf("expected the outer span to end with its attributes, got %+v", outer.Frame())
+	}
+
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = false })
+	off := StartSpan(ctx, "off", nil)
+	off.AddEvent("ignored", nil)
+	off.End()
+	if off != nil {
+		t.Fatalf("expected no span while tracing is off")
+	}
+}
+
+func TestEmitAttachesAndCountsEvents(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() {
+		SetConfig(original)
+		ResetFunctionStats()
+	})
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+	ResetFunctionStats()
+
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	Emit(ctx, "cache.miss", Attrs{"key": "user:1"}) // no frame open: only counted
+	frame := CreateFrame("pkg.Lookup", "", "lookup.go", 1, nil)
+	EnterContext(ctx, frame)
+	Emit(ctx, "cache.miss", Attrs{"key": "user:2", "token": "t-1"})
+	Emit(ctx, "cache.fill", nil)
+	LeaveContext(ctx)
+
+	events := SnapshotFrame(frame).Events
+	if len(events) != 2 || events[0].Name != "cache.miss" || events[0].Attrs["key"] != "user:2" || events[0].Attrs["token"] != RedactedValue {
+		t.Fatalf("unexpected frame events: %+v", events)
+	}
+	stats := AllEventStats()
+	if len(stats) != 2 || stats[0].Name != "cache.miss" || stats[0].Count != 2 || stats[1].Count != 1 {
+		t.Fatalf("unexpected event stats: %+v", stats)
+	}
+}
+
+func TestTraceConditionsSelectWhatIsRecorded(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	tc := NewTraceContext()
+	ctx := WithTraceCondition(WithTraceContext(context.Background(), tc), func(f *Frame) bool {
+		return f.Args["userID"] == 42
+	})
+	handle := func(userID int) (recorded []string) {
+		EnterContext(ctx, CreateFrame("middleware", "", "mw.go", 1, nil))
+		EnterContext(ctx, CreateFrame("handler", "", "h.go", 1, map[string]interface{}{"userID": userID}))
+		EnterContext(ctx, CreateFrame("query", "", "db.go", 1, nil))
+		for _, frame := range tc.Stack() {
+			recorded = append(recorded, frame.Function)
+		}
+		LeaveContext(ctx)
+		LeaveContext(ctx)
+		LeaveContext(ctx)
+		return recorded
+	}
+
+	if got := handle(7); len(got) != 0 {
+		t.Fatalf("expected nothing recorded for another user, got %v", got)
+	}
+	if got := handle(42); len(got) != 2 || got[0] != "handler" || got[1] != "query" {
+		t.Fatalf("expected the matching call and its callees, got %v", got)
+	}
+	if tc.GetDepth() != 0 || len(tc.Frames) != 0 {
+		t.Fatalf("expected a balanced context, depth %d", tc.GetDepth())
+	}
+
+	big := NewTracedFunc(func(amount int) int { return amount }, &TraceOptions{
+		Condition: func(args []interface{}) bool { return args[0].(int) > 100 },
+	})
+	traced := 0
+	t.Cleanup(RegisterHook(Hook{OnEnter: func(*Frame) { traced++ }}))
+	big.Call(context.Background(), 5)
+	big.Call(context.Background(), 500)
+	if traced != 1 {
+		t.Fatalf("expected only the call over 100 traced, got %d", traced)
+	}
+}
+
+func TestTracedCallEvaluatesContextConditionOnce(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	evaluated := 0
+	ctx := WithTraceCondition(WithTraceContext(context.Background(), NewTraceContext()), func(f *Frame) bool {
+		evaluated++
+		return true
+	})
+	double := NewTracedFunc(func(n int) int { return n * 2 }, nil)
+	if got := double.Call(ctx, 21).Results[0]; got != 42 {
+		t.Fatalf("unexpected result %v", got)
+	}
+	if evaluated != 1 {
+		t.Fatalf("expected the condition to be evaluated once per call, got %d", evaluated)
+	}
+}
+
+func TestPprofLabelsFollowFrames(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() {
+		SetConfig(original)
+		pprof.SetGoroutineLabels(context.Background())
+	})
+	UpdateConfig(func(c *DevTraceConfig) {
+		c.Enabled = true
+		c.PprofLabels = true
+	})
+
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("request", "r1"))
+	ctx = WithTraceContext(ctx, NewTraceContext())
+	outer := CreateFrame("handle", "", "handler.go", 1, nil)
+	EnterContext(ctx, outer)
+	inner := CreateFrame("query", "", "db.go", 2, nil)
+	EnterContext(ctx, inner)
+
+	if fn, _ := pprof.Label(inner.labels, PprofFunctionLabel); fn != "query" {
+		t.Fatalf("expected the inner frame's function label, got %q", fn)
+	}
+	if id, _ := pprof.Label(inner.labels, PprofTraceIDLabel); id == "" || id != FromContext(ctx).TraceID {
+		t.Fatalf("expected the trace ID label, got %q", id)
+	}
+	if req, _ := pprof.Label(inner.labels, "request"); req != "r1" {
+		t.Fatalf("labels of ctx should be kept, got %q", req)
+	}
+	LeaveContext(ctx)
+	LeaveContext(ctx)
+	if FromContext(ctx).labelBase != nil {
+		t.Fatal("the label base should be dropped once the outermost frame leaves")
+	}
+}
@@ -0,0 +1,219 @@
+package devtrace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CallContract is an expected sequence of traced calls, parsed from a spec such as
+//
+//	"Handler → Validate → Repo.Get ×1 → Cache.Set ×+"
+//
+// Steps are separated by "→" or "->". A step may carry a count suffix:
+// "×N" (or "xN") for exactly N calls, "×+" for one or more and "×*" for any number.
+// Steps without a suffix expect exactly one call.
+type CallContract struct {
+	Spec  string
+	Steps []ContractStep
+}
+
+// ContractStep is a single function in a CallContract
+type ContractStep struct {
+	Name string
+	Min  int
+	Max  int // -1 means unbounded
+}
+
+// ParseCallContract parses a call sequence spec into a CallContract
+func ParseCallContract(spec string) (*CallContract, error) {
+	normalized := strings.ReplaceAll(spec, "->", "→")
+	rawSteps := strings.Split(normalized, "→")
+
+	contract := &CallContract{Spec: spec, Steps: make([]ContractStep, 0, len(rawSteps))}
+	for i, raw := range rawSteps {
+		step, err := parseContractStep(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("call contract step %d: %v", i+1, err)
+		}
+		contract.Steps = append(contract.Steps, step)
+	}
+
+	return contract, nil
+}
+
+// MustParseCallContract is like ParseCallContract but panics on an invalid spec
+func MustParseCallContract(spec string) *CallContract {
+	contract, err := ParseCallContract(spec)
+	if err != nil {
+		panic(err)
+	}
+	return contract
+}
+
+func parseContractStep(raw string) (ContractStep, error) {
+	if raw == "" {
+		return ContractStep{}, fmt.Errorf("empty step")
+	}
+
+	fields := strings.Fields(raw)
+	step := ContractStep{Name: fields[0], Min: 1, Max: 1}
+
+	switch len(fields) {
+	case 1:
+		return step, nil
+	case 2:
+	default:
+		return ContractStep{}, fmt.Errorf("unexpected tokens in %q", raw)
+	}
+
+	count := fields[1]
+	switch {
+	case strings.HasPrefix(count, "×"):
+		count = strings.TrimPrefix(count, "×")
+	case strings.HasPrefix(count, "x"):
+		count = strings.TrimPrefix(count, "x")
+	default:
+		return ContractStep{}, fmt.Errorf("invalid count %q (want ×N, ×+ or ×*)", fields[1])
+	}
+
+	switch count {
+	case "+":
+		step.Min, step.Max = 1, -1
+	case "*":
+		step.Min, step.Max = 0, -1
+	default:
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 0 {
+			return ContractStep{}, fmt.Errorf("invalid count %q (want ×N, ×+ or ×*)", fields[1])
+		}
+		step.Min, step.Max = n, n
+	}
+
+	return step, nil
+}
+
+// String renders the step back into spec form
+func (s ContractStep) String() string {
+	switch {
+	case s.Min == 1 && s.Max == 1:
+		return s.Name
+	case s.Max < 0 && s.Min == 0:
+		return s.Name + " ×*"
+	case s.Max < 0:
+		return s.Name + " ×+"
+	default:
+		return fmt.Sprintf("%s ×%d", s.Name, s.Min)
+	}
+}
+
+// matches reports whether a traced function name refers to this step
+func (s ContractStep) matches(function string) bool {
+	return MatchFunction(s.Name, function)
+}
+
+// MatchFunction reports whether a traced function name refers to name, the
+// way call contracts match steps: "Repo.Get" matches "main.Repo.Get",
+// "main.(*Repo).Get", the method value "main.(*Repo).Get-fm" and "Repo.Get"
+func MatchFunction(name, function string) bool {
+	normalized := normalizeContractName(function)
+	return normalized == name || strings.HasSuffix(normalized, "."+name)
+}
+
+func normalizeContractName(function string) string {
+	function = strings.TrimSuffix(simplifyFunctionName(function), "-fm")
+	return strings.NewReplacer("(", "", ")", "", "*", "").Replace(function)
+}
+
+// Verify checks recorded frames, in call order, against the contract.
+// Frames for functions not named in the contract are ignored.
+func (c *CallContract) Verify(frames []*Frame) error {
+	names := make([]string, 0, len(frames))
+	for _, frame := range frames {
+		if frame != nil {
+			names = append(names, frame.Function)
+		}
+	}
+	return c.VerifyNames(names)
+}
+
+// VerifyNames checks a sequence of function names against the contract
+func (c *CallContract) VerifyNames(functions []string) error {
+	relevant := make([]string, 0, len(functions))
+	for _, fn := range functions {
+		if c.stepFor(fn) >= 0 {
+			relevant = append(relevant, fn)
+		}
+	}
+
+	pos := 0
+	for i, step := range c.Steps {
+		count := 0
+		for pos < len(relevant) && (step.Max < 0 || count < step.Max) && step.matches(relevant[pos]) {
+			count++
+			pos++
+		}
+
+		if count < step.Min {
+			got := "end of trace"
+			if pos < len(relevant) {
+				got = normalizeContractName(relevant[pos])
+			}
+			return c.mismatch(relevant, "step %d (%s): expected %d call(s), got %d before %s", i+1, step, step.Min, count, got)
+		}
+	}
+
+	if pos < len(relevant) {
+		return c.mismatch(relevant, "unexpected call %s after the last step", normalizeContractName(relevant[pos]))
+	}
+
+	return nil
+}
+
+func (c *CallContract) stepFor(function string) int {
+	for i, step := range c.Steps {
+		if step.matches(function) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *CallContract) mismatch(actual []string, format string, args ...interface{}) error {
+	expected := make([]string, len(c.Steps))
+	for i, step := range c.Steps {
+		expected[i] = step.String()
+	}
+
+	got := make([]string, len(actual))
+	for i, fn := range actual {
+		got[i] = normalizeContractName(fn)
+	}
+
+	return fmt.Errorf("call contract mismatch at %s\n  expected: %s\n  actual:   %s",
+		fmt.Sprintf(format, args...), strings.Join(expected, " → "), strings.Join(got, " → "))
+}
+
+// RecordCalls runs fn and returns every frame entered while it ran, in call order
+func RecordCalls(fn func()) []*Frame {
+	var (
+		mu     sync.Mutex
+		frames []*Frame
+	)
+
+	unregister := RegisterHook(Hook{
+		OnEnter: func(frame *Frame) {
+			mu.Lock()
+			frames = append(frames, frame)
+			mu.Unlock()
+		},
+	})
+	defer unregister()
+
+	fn()
+
+	mu.Lock()
+	defer mu.Unlock()
+	return frames
+}
@@ -0,0 +1,27 @@
+package devtrace
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCallContractVerify(t *testing.T) {
+	contract := MustParseCallContract("Handler → Validate -> Repo.Get ×2 → Cache.Set ×*")
+
+	ok := []string{"main.Handler", "main.Validate", "log.Printf", "main.(*Repo).Get", "main.(*Repo).Get"}
+	if err := contract.VerifyNames(ok); err != nil {
+		t.Fatalf("expected contract to hold: %v", err)
+	}
+
+	err := contract.VerifyNames([]string{"main.Handler", "main.Validate", "main.(*Repo).Get", "main.Cache.Set"})
+	if err == nil {
+		t.Fatalf("expected mismatch for missing Repo.Get call")
+	}
+	if !strings.Contains(err.Error(), "step 3 (Repo.Get ×2): expected 2 call(s), got 1") {
+		t.Fatalf("unreadable mismatch report: %v", err)
+	}
+
+	if _, err := ParseCallContract("Handler → → Repo"); err == nil {
+		t.Fatalf("expected parse error for empty step")
+	}
+}
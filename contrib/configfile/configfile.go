@@ -0,0 +1,200 @@
+// Package devtraceconfig loads devtrace configuration from YAML or TOML files,
+// including per-package overrides, and can reload it when the file changes.
+//
+//	# devtrace.yaml
+//	enabled: true
+//	stack_limit: 5
+//	slow_threshold: 250ms
+//	packages:
+//	  internal/payments:
+//	    stack_limit: 12
+//	    show_args: false
+//
+// The format is picked by extension: .yaml/.yml or .toml.
+package devtraceconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	devtrace "github.com/skulidropek/gotrace"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings lists the configuration keys a file may set. Keys left out keep their current value.
+type Settings struct {
+	Enabled       *bool    `yaml:"enabled" toml:"enabled"`
+	StackLimit    *int     `yaml:"stack_limit" toml:"stack_limit"`
+	ShowArgs      *bool    `yaml:"show_args" toml:"show_args"`
+	ShowTiming    *bool    `yaml:"show_timing" toml:"show_timing"`
+	ShowSnippet   *int     `yaml:"show_snippet" toml:"show_snippet"`
+	AppPattern    *string  `yaml:"app_pattern" toml:"app_pattern"`
+	DebugLevel    *int     `yaml:"debug_level" toml:"debug_level"`
+	SampleRate    *float64 `yaml:"sample_rate" toml:"sample_rate"`
+	LogErrors     *bool    `yaml:"log_errors" toml:"log_errors"`
+	MaxDepth      *int     `yaml:"max_depth" toml:"max_depth"`
+	SlowThreshold string   `yaml:"slow_threshold" toml:"slow_threshold"`
+	MaxValueLen   *int     `yaml:"max_value_len" toml:"max_value_len"`
+	MaxValueDepth *int     `yaml:"max_value_depth" toml:"max_value_depth"`
+	MaxSliceElems *int     `yaml:"max_slice_elems" toml:"max_slice_elems"`
+	MaxMapEntries *int     `yaml:"max_map_entries" toml:"max_map_entries"`
+}
+
+// File is a parsed configuration file
+type File struct {
+	Settings `yaml:",inline"`
+	Packages map[string]Settings `yaml:"packages" toml:"packages"`
+}
+
+// ParseFile reads and validates a configuration file without applying it
+func ParseFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file File
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".toml":
+		err = toml.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if err := file.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &file, nil
+}
+
+// LoadConfigFile parses path and applies it on top of the current configuration
+func LoadConfigFile(path string) error {
+	file, err := ParseFile(path)
+	if err != nil {
+		return err
+	}
+
+	base := devtrace.CurrentConfig()
+	merged := base
+	file.Settings.apply(&merged)
+	if err := merged.Validate(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	file.ApplyTo(base)
+	return nil
+}
+
+// ApplyTo installs base with the file's settings on top as the global configuration
+// and replaces the per-package overrides
+func (f *File) ApplyTo(base devtrace.DevTraceConfig) {
+	devtrace.UpdateConfig(func(c *devtrace.DevTraceConfig) {
+		*c = base
+		f.Settings.apply(c)
+	})
+
+	overrides := make([]devtrace.PackageOverride, 0, len(f.Packages))
+	for pkg, settings := range f.Packages {
+		overrides = append(overrides, devtrace.PackageOverride{Package: pkg, ConfigOverrides: settings.overrides()})
+	}
+	devtrace.SetPackageOverrides(overrides)
+}
+
+func (f *File) validate() error {
+	if err := f.Settings.validate(); err != nil {
+		return err
+	}
+	for pkg, settings := range f.Packages {
+		if err := settings.validate(); err != nil {
+			return fmt.Errorf("packages.%s: %v", pkg, err)
+		}
+		if settings.SlowThreshold != "" || settings.AppPattern != nil || settings.MaxDepth != nil ||
+			settings.MaxValueLen != nil || settings.MaxValueDepth != nil || settings.MaxSliceElems != nil || settings.MaxMapEntries != nil {
+			return fmt.Errorf("packages.%s: only enabled, stack_limit, show_args, show_timing, show_snippet, debug_level, sample_rate and log_errors can be set per package", pkg)
+		}
+	}
+	return nil
+}
+
+func (s Settings) validate() error {
+	if s.SlowThreshold != "" {
+		if _, err := time.ParseDuration(s.SlowThreshold); err != nil {
+			return fmt.Errorf("slow_threshold: %v", err)
+		}
+	}
+
+	// Check the values the file sets against the defaults
+	candidate := devtrace.DefaultConfig
+	s.apply(&candidate)
+	return candidate.Validate()
+}
+
+func (s Settings) apply(c *devtrace.DevTraceConfig) {
+	overrides := s.overrides()
+	if overrides.Enabled != nil {
+		c.Enabled = *overrides.Enabled
+	}
+	if overrides.StackLimit != nil {
+		c.StackLimit = *overrides.StackLimit
+	}
+	if overrides.ShowArgs != nil {
+		c.ShowArgs = *overrides.ShowArgs
+	}
+	if overrides.ShowTiming != nil {
+		c.ShowTiming = *overrides.ShowTiming
+	}
+	if overrides.ShowSnippet != nil {
+		c.ShowSnippet = *overrides.ShowSnippet
+	}
+	if overrides.DebugLevel != nil {
+		c.DebugLevel = *overrides.DebugLevel
+	}
+	if overrides.SampleRate != nil {
+		c.SampleRate = *overrides.SampleRate
+	}
+	if overrides.LogErrors != nil {
+		c.LogErrors = *overrides.LogErrors
+	}
+	if s.AppPattern != nil {
+		c.AppPattern = *s.AppPattern
+	}
+	if s.MaxDepth != nil {
+		c.MaxDepth = *s.MaxDepth
+	}
+	if s.SlowThreshold != "" {
+		c.SlowThreshold, _ = time.ParseDuration(s.SlowThreshold)
+	}
+	if s.MaxValueLen != nil {
+		c.MaxValueLen = *s.MaxValueLen
+	}
+	if s.MaxValueDepth != nil {
+		c.MaxValueDepth = *s.MaxValueDepth
+	}
+	if s.MaxSliceElems != nil {
+		c.MaxSliceElems = *s.MaxSliceElems
+	}
+	if s.MaxMapEntries != nil {
+		c.MaxMapEntries = *s.MaxMapEntries
+	}
+}
+
+func (s Settings) overrides() devtrace.ConfigOverrides {
+	return devtrace.ConfigOverrides{
+		Enabled:     s.Enabled,
+		ShowArgs:    s.ShowArgs,
+		ShowTiming:  s.ShowTiming,
+		ShowSnippet: s.ShowSnippet,
+		StackLimit:  s.StackLimit,
+		DebugLevel:  s.DebugLevel,
+		SampleRate:  s.SampleRate,
+		LogErrors:   s.LogErrors,
+	}
+}
@@ -0,0 +1,56 @@
+package devtraceconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+func TestLoadConfigFileFormats(t *testing.T) {
+	original := devtrace.CurrentConfig()
+	t.Cleanup(func() {
+		devtrace.SetConfig(original)
+		devtrace.SetPackageOverrides(nil)
+	})
+
+	files := map[string]string{
+		"devtrace.yaml": "stack_limit: 9\nslow_threshold: 250ms\npackages:\n  internal/payments:\n    show_args: false\n",
+		"devtrace.toml": "stack_limit = 9\nslow_threshold = \"250ms\"\n[packages.\"internal/payments\"]\nshow_args = false\n",
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			devtrace.SetConfig(original)
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := LoadConfigFile(path); err != nil {
+				t.Fatalf("LoadConfigFile: %v", err)
+			}
+
+			cfg := devtrace.CurrentConfig()
+			if cfg.StackLimit != 9 || cfg.SlowThreshold != 250*time.Millisecond {
+				t.Fatalf("settings not applied: %+v", cfg)
+			}
+			overrides := devtrace.PackageOverrides()
+			if len(overrides) != 1 || overrides[0].Package != "internal/payments" || overrides[0].ShowArgs == nil || *overrides[0].ShowArgs {
+				t.Fatalf("unexpected package overrides: %+v", overrides)
+			}
+		})
+	}
+}
+
+func TestParseFileRejectsInvalidValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devtrace.yml")
+	if err := os.WriteFile(path, []byte("sample_rate: 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFile(path); err == nil {
+		t.Fatalf("expected sample_rate out of range to be rejected")
+	}
+}
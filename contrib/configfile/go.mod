@@ -0,0 +1,14 @@
+module github.com/skulidropek/gotrace/contrib/configfile
+
+go 1.21
+
+require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/skulidropek/gotrace v0.0.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.4.0 // indirect
+
+replace github.com/skulidropek/gotrace => ../../
@@ -0,0 +1,82 @@
+package devtraceconfig
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+// reloadDelay coalesces the burst of events editors produce for a single save
+const reloadDelay = 100 * time.Millisecond
+
+// Watch loads path and reloads it whenever it changes. Each reload starts from the
+// configuration in effect when Watch was called, so deleting a key reverts it.
+// A file that fails to parse is reported to onError (if set) and the previous
+// configuration stays active. Call the returned function to stop watching.
+func Watch(path string, onError func(error)) (stop func(), err error) {
+	base := devtrace.CurrentConfig()
+
+	file, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file.ApplyTo(base)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// Watch the directory: editors often replace the file instead of writing to it
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	report := func(err error) {
+		if onError != nil {
+			onError(err)
+		} else if devtrace.GlobalLogger != nil {
+			devtrace.GlobalLogger.Warn("config reload failed: %v", err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		target := filepath.Clean(path)
+		var pending <-chan time.Time
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == target && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					pending = time.After(reloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				report(err)
+			case <-pending:
+				pending = nil
+				file, err := ParseFile(path)
+				if err != nil {
+					report(err)
+					continue
+				}
+				file.ApplyTo(base)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		watcher.Close()
+	}, nil
+}
@@ -0,0 +1,12 @@
+module github.com/skulidropek/gotrace/contrib/logrus
+
+go 1.21
+
+require (
+	github.com/sirupsen/logrus v1.9.3
+	github.com/skulidropek/gotrace v0.0.0
+)
+
+require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+
+replace github.com/skulidropek/gotrace => ../../
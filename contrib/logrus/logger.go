@@ -0,0 +1,72 @@
+// Package devtracelogrus implements devtrace.Logger on top of logrus.
+//
+//	devtrace.SetLogger(devtracelogrus.NewLogrusLogger(logrus.StandardLogger()))
+//	devtrace.InstallStackLogger(nil)
+//
+// DebugVars passed to the logger become logrus fields instead of being rendered into the message.
+package devtracelogrus
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+// Logger adapts a *logrus.Logger to devtrace.Logger and devtrace.StructuredLogger
+type Logger struct {
+	logger *logrus.Logger
+}
+
+// NewLogrusLogger wraps l so devtrace can log through it
+func NewLogrusLogger(l *logrus.Logger) *Logger {
+	return &Logger{logger: l}
+}
+
+// Log formats msg with args and writes it at the given devtrace level
+func (l *Logger) Log(level string, msg string, args ...interface{}) {
+	fields, rest := devtrace.DebugFields(args)
+	l.write(level, format(msg, rest), fields)
+}
+
+// LogFields writes msg with fields attached as logrus fields
+func (l *Logger) LogFields(level string, msg string, fields map[string]interface{}) {
+	l.write(level, msg, fields)
+}
+
+func (l *Logger) Debug(msg string, args ...interface{}) { l.Log("DEBUG", msg, args...) }
+func (l *Logger) Info(msg string, args ...interface{})  { l.Log("INFO", msg, args...) }
+func (l *Logger) Warn(msg string, args ...interface{})  { l.Log("WARN", msg, args...) }
+func (l *Logger) Error(msg string, args ...interface{}) { l.Log("ERROR", msg, args...) }
+
+func (l *Logger) write(level, msg string, fields map[string]interface{}) {
+	lvl := logrusLevel(level)
+	if !l.logger.IsLevelEnabled(lvl) {
+		return
+	}
+	l.logger.WithFields(logrus.Fields(fields)).Log(lvl, msg)
+}
+
+// logrusLevel maps devtrace level names to logrus levels; unknown names log at info
+func logrusLevel(level string) logrus.Level {
+	switch strings.ToUpper(level) {
+	case "TRACE":
+		return logrus.TraceLevel
+	case "DEBUG":
+		return logrus.DebugLevel
+	case "WARN", "WARNING":
+		return logrus.WarnLevel
+	case "ERROR":
+		return logrus.ErrorLevel
+	default:
+		return logrus.InfoLevel
+	}
+}
+
+func format(msg string, args []interface{}) string {
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
@@ -0,0 +1,90 @@
+package devtracelogrus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+func TestLogrusLoggerAttachesDebugVarsAsFields(t *testing.T) {
+	base, hook := test.NewNullLogger()
+	base.SetLevel(logrus.DebugLevel)
+	logger := NewLogrusLogger(base)
+
+	logger.Warn("charge failed for %s", "order-1", devtrace.NewDebugVars(map[string]interface{}{"amount": 42, "password": "hunter2"}))
+
+	entries := hook.AllEntries()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Level != logrus.WarnLevel || entry.Message != "charge failed for order-1" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	if entry.Data["amount"] != 42 {
+		t.Fatalf("expected amount field, got %+v", entry.Data)
+	}
+	if entry.Data["password"] == "hunter2" {
+		t.Fatalf("password should be redacted, got %+v", entry.Data)
+	}
+}
+
+func TestLogrusLoggerMapsLevels(t *testing.T) {
+	base, hook := test.NewNullLogger()
+	base.SetLevel(logrus.TraceLevel)
+	logger := NewLogrusLogger(base)
+
+	for level, want := range map[string]logrus.Level{
+		"TRACE":   logrus.TraceLevel,
+		"debug":   logrus.DebugLevel,
+		"INFO":    logrus.InfoLevel,
+		"WARN":    logrus.WarnLevel,
+		"WARNING": logrus.WarnLevel,
+		"ERROR":   logrus.ErrorLevel,
+		"NOTICE":  logrus.InfoLevel,
+	} {
+		hook.Reset()
+		logger.Log(level, "%d%%", 100)
+		entry := hook.LastEntry()
+		if entry == nil || entry.Level != want || entry.Message != "100%" {
+			t.Errorf("%s: expected a %s entry %q, got %+v", level, want, "100%", entry)
+		}
+	}
+}
+
+func TestLogrusLoggerSkipsDisabledLevels(t *testing.T) {
+	base, hook := test.NewNullLogger()
+	base.SetLevel(logrus.WarnLevel)
+	logger := NewLogrusLogger(base)
+
+	logger.Debug("hidden")
+	logger.Info("hidden")
+	logger.LogFields("ERROR", "shown", map[string]interface{}{"user": 7})
+
+	entries := hook.AllEntries()
+	if len(entries) != 1 || entries[0].Message != "shown" || entries[0].Data["user"] != 7 {
+		t.Fatalf("expected only the error entry with its fields, got %+v", entries)
+	}
+}
+
+func TestLogrusLoggerReceivesStackLogs(t *testing.T) {
+	originalConfig := devtrace.CurrentConfig()
+	t.Cleanup(func() { devtrace.SetConfig(originalConfig) })
+	devtrace.UpdateConfig(func(c *devtrace.DevTraceConfig) { c.Enabled = true })
+
+	base, hook := test.NewNullLogger()
+	stack := devtrace.NewEnhancedLogger(nil)
+	stack.SetLogger(NewLogrusLogger(base))
+	stack.Error(context.Background(), "boom", devtrace.NewDebugVars(map[string]interface{}{"user": 7}))
+
+	entries := hook.AllEntries()
+	if len(entries) != 1 || entries[0].Level != logrus.ErrorLevel {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if entries[0].Data["user"] != 7 {
+		t.Fatalf("expected user field, got %+v", entries[0].Data)
+	}
+}
@@ -0,0 +1,145 @@
+// Package devtracetemporal connects Temporal workflows and activities to devtrace,
+// so activity executions appear as child frames of their workflow's logical trace.
+//
+// Register the interceptor on the worker:
+//
+//	w := worker.New(c, "queue", worker.Options{
+//		Interceptors: []interceptor.WorkerInterceptor{devtracetemporal.NewWorkerInterceptor()},
+//	})
+//
+// Cadence and other engines can use devtrace.InjectTraceHeaders and
+// devtrace.ExtractTraceHeaders with their own header carriers in the same way.
+package devtracetemporal
+
+import (
+	"context"
+	"fmt"
+
+	devtrace "github.com/skulidropek/gotrace"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/workflow"
+)
+
+// NewWorkerInterceptor returns a worker interceptor that propagates devtrace context
+// from workflows to the activities they schedule. The workflow run ID becomes the
+// devtrace trace ID, and each activity execution runs inside its own frame.
+func NewWorkerInterceptor() interceptor.WorkerInterceptor {
+	return &workerInterceptor{}
+}
+
+type workerInterceptor struct {
+	interceptor.WorkerInterceptorBase
+}
+
+func (w *workerInterceptor) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	i := &activityInbound{}
+	i.Next = next
+	return i
+}
+
+func (w *workerInterceptor) InterceptWorkflow(ctx workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	i := &workflowInbound{}
+	i.Next = next
+	return i
+}
+
+type activityInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+}
+
+func (a *activityInbound) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	info := activity.GetInfo(ctx)
+
+	headers := readHeaders(interceptor.Header(ctx))
+	if headers[devtrace.TraceIDHeader] == "" {
+		headers[devtrace.TraceIDHeader] = info.WorkflowExecution.RunID
+	}
+	if headers[devtrace.ParentFrameHeader] == "" {
+		headers[devtrace.ParentFrameHeader] = workflowFrameName(info.WorkflowType.Name)
+	}
+	ctx = devtrace.ExtractTraceHeaders(ctx, headers)
+
+	args := make(map[string]interface{}, len(in.Args))
+	for i, arg := range in.Args {
+		args[fmt.Sprintf("arg%d", i)] = arg
+	}
+
+	// LeaveContextResults records the result and sets the frame's Error from err
+	var result interface{}
+	var err error
+	devtrace.EnterContext(ctx, devtrace.CreateFrame("activity:"+info.ActivityType.Name, "", "", 0, args))
+	defer func() { devtrace.LeaveContextResults(ctx, result, err) }()
+
+	result, err = a.Next.ExecuteActivity(ctx, in)
+	return result, err
+}
+
+type workflowInbound struct {
+	interceptor.WorkflowInboundInterceptorBase
+}
+
+func (w *workflowInbound) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	i := &workflowOutbound{}
+	i.Next = outbound
+	return w.Next.Init(i)
+}
+
+type workflowOutbound struct {
+	interceptor.WorkflowOutboundInterceptorBase
+}
+
+func (w *workflowOutbound) ExecuteActivity(ctx workflow.Context, activityType string, args ...interface{}) workflow.Future {
+	writeHeaders(ctx)
+	return w.Next.ExecuteActivity(ctx, activityType, args...)
+}
+
+func (w *workflowOutbound) ExecuteLocalActivity(ctx workflow.Context, activityType string, args ...interface{}) workflow.Future {
+	writeHeaders(ctx)
+	return w.Next.ExecuteLocalActivity(ctx, activityType, args...)
+}
+
+// writeHeaders stamps the workflow's logical trace onto the header of the activity being scheduled.
+// Values derive only from workflow info, so they are identical on replay.
+func writeHeaders(ctx workflow.Context) {
+	header := interceptor.WorkflowHeader(ctx)
+	if header == nil {
+		return
+	}
+
+	info := workflow.GetInfo(ctx)
+	values := map[string]string{
+		devtrace.TraceIDHeader:     info.WorkflowExecution.RunID,
+		devtrace.ParentFrameHeader: workflowFrameName(info.WorkflowType.Name),
+	}
+
+	dc := converter.GetDefaultDataConverter()
+	for key, value := range values {
+		if payload, err := dc.ToPayload(value); err == nil {
+			header[key] = payload
+		}
+	}
+}
+
+func readHeaders(header map[string]*commonpb.Payload) map[string]string {
+	values := make(map[string]string, 2)
+	dc := converter.GetDefaultDataConverter()
+
+	for _, key := range []string{devtrace.TraceIDHeader, devtrace.ParentFrameHeader} {
+		payload, ok := header[key]
+		if !ok {
+			continue
+		}
+		var value string
+		if err := dc.FromPayload(payload, &value); err == nil {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+func workflowFrameName(workflowType string) string {
+	return "workflow:" + workflowType
+}
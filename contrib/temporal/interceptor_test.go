@@ -0,0 +1,117 @@
+package devtracetemporal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	devtrace "github.com/skulidropek/gotrace"
+	"github.com/skulidropek/gotrace/devtracetest"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// traceOf reports the trace an activity runs in: "<trace id> <remote parent>"
+func traceOf(ctx context.Context) (string, error) {
+	tc := devtrace.FromContext(ctx)
+	return tc.TraceID + " " + tc.RemoteParent, nil
+}
+
+func chargeCard(ctx context.Context, amount int) (string, error) {
+	return "", errors.New("card declined")
+}
+
+// checkoutWorkflow returns its run ID, the trace traceOf ran in and the error
+// of chargeCard, separated by "|"
+func checkoutWorkflow(ctx workflow.Context, amount int) (string, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 1},
+	})
+
+	var trace string
+	if err := workflow.ExecuteActivity(ctx, traceOf).Get(ctx, &trace); err != nil {
+		return "", err
+	}
+	var receipt string
+	err := workflow.ExecuteActivity(ctx, chargeCard, amount).Get(ctx, &receipt)
+	return workflow.GetInfo(ctx).WorkflowExecution.RunID + "|" + trace + "|" + err.Error(), nil
+}
+
+func TestInterceptorPropagatesWorkflowTraceToActivities(t *testing.T) {
+	rec := devtracetest.NewRecorder()
+	defer rec.Stop()
+	// The exported snapshot falls back to an error among the results, so
+	// look at the frame itself to see that its Error is set
+	var frameErr error
+	defer devtrace.RegisterHook(devtrace.Hook{OnExit: func(frame *devtrace.Frame) {
+		if frame.Function == "activity:chargeCard" {
+			frameErr = frame.Error
+		}
+	}})()
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{NewWorkerInterceptor()}})
+	env.RegisterWorkflow(checkoutWorkflow)
+	env.RegisterActivity(traceOf)
+	env.RegisterActivity(chargeCard)
+
+	env.ExecuteWorkflow(checkoutWorkflow, 42)
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("workflow did not complete")
+	}
+	var result string
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(result, "|")
+	if len(parts) != 3 || !strings.Contains(parts[2], "card declined") {
+		t.Fatalf("unexpected workflow result %q", result)
+	}
+	runID := parts[0]
+	if parts[1] != runID+" workflow:checkoutWorkflow" {
+		t.Fatalf("expected traceOf to run in the workflow's trace, got %q", parts[1])
+	}
+
+	traced := rec.CallsOf("activity:traceOf")
+	if len(traced) != 1 || traced[0].TraceID != runID {
+		t.Fatalf("expected one traceOf frame in trace %s, got %+v", runID, traced)
+	}
+
+	charged := rec.CallsOf("activity:chargeCard")
+	if len(charged) != 1 || charged[0].Error != "card declined" || charged[0].Args["arg0"] != "42" {
+		t.Fatalf("expected the failed chargeCard frame with its error and args, got %+v", charged)
+	}
+	if frameErr == nil || !strings.Contains(frameErr.Error(), "card declined") {
+		t.Fatalf("expected the chargeCard frame's Error to be set, got %v", frameErr)
+	}
+}
+
+func TestActivityWithoutWorkflowHeadersUsesActivityInfo(t *testing.T) {
+	rec := devtracetest.NewRecorder()
+	defer rec.Stop()
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{NewWorkerInterceptor()}})
+	env.RegisterActivity(traceOf)
+
+	value, err := env.ExecuteActivity(traceOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trace string
+	if err := value.Get(&trace); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(trace, " workflow:") && !strings.Contains(trace, " workflow:") {
+		t.Fatalf("expected the parent frame to be named after the workflow type, got %q", trace)
+	}
+	rec.AssertCallCount(t, "activity:traceOf", 1)
+}
@@ -0,0 +1,12 @@
+module github.com/skulidropek/gotrace/contrib/zap
+
+go 1.21
+
+require (
+	github.com/skulidropek/gotrace v0.0.0
+	go.uber.org/zap v1.26.0
+)
+
+require go.uber.org/multierr v1.10.0 // indirect
+
+replace github.com/skulidropek/gotrace => ../../
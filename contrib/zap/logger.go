@@ -0,0 +1,89 @@
+// Package devtracezap implements devtrace.Logger on top of zap.
+//
+//	devtrace.SetLogger(devtracezap.NewZapLogger(zapLogger))
+//	devtrace.InstallStackLogger(nil)
+//
+// DebugVars passed to the logger become zap fields instead of being rendered into the message.
+package devtracezap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	devtrace "github.com/skulidropek/gotrace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger adapts a *zap.Logger to devtrace.Logger and devtrace.StructuredLogger
+type Logger struct {
+	logger *zap.Logger
+}
+
+// NewZapLogger wraps l so devtrace can log through it
+func NewZapLogger(l *zap.Logger) *Logger {
+	// Skip write, Log and the level method so zap reports the line that called Info/Warn/...
+	return &Logger{logger: l.WithOptions(zap.AddCallerSkip(3))}
+}
+
+// Log formats msg with args and writes it at the given devtrace level
+func (l *Logger) Log(level string, msg string, args ...interface{}) {
+	fields, rest := devtrace.DebugFields(args)
+	l.write(level, format(msg, rest), fields)
+}
+
+// LogFields writes msg with fields attached as structured zap fields
+func (l *Logger) LogFields(level string, msg string, fields map[string]interface{}) {
+	l.write(level, msg, fields)
+}
+
+func (l *Logger) Debug(msg string, args ...interface{}) { l.Log("DEBUG", msg, args...) }
+func (l *Logger) Info(msg string, args ...interface{})  { l.Log("INFO", msg, args...) }
+func (l *Logger) Warn(msg string, args ...interface{})  { l.Log("WARN", msg, args...) }
+func (l *Logger) Error(msg string, args ...interface{}) { l.Log("ERROR", msg, args...) }
+
+func (l *Logger) write(level, msg string, fields map[string]interface{}) {
+	if ce := l.logger.Check(zapLevel(level), msg); ce != nil {
+		ce.Write(zapFields(fields)...)
+	}
+}
+
+// zapLevel maps devtrace level names to zap levels; unknown names log at info
+func zapLevel(level string) zapcore.Level {
+	switch strings.ToUpper(level) {
+	case "DEBUG", "TRACE":
+		return zapcore.DebugLevel
+	case "WARN", "WARNING":
+		return zapcore.WarnLevel
+	case "ERROR":
+		return zapcore.ErrorLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+func zapFields(fields map[string]interface{}) []zap.Field {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]zap.Field, 0, len(names))
+	for _, name := range names {
+		out = append(out, zap.Any(name, fields[name]))
+	}
+	return out
+}
+
+func format(msg string, args []interface{}) string {
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
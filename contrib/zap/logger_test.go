@@ -0,0 +1,53 @@
+package devtracezap
+
+import (
+	"context"
+	"testing"
+
+	devtrace "github.com/skulidropek/gotrace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZapLoggerAttachesDebugVarsAsFields(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := NewZapLogger(zap.New(core))
+
+	logger.Warn("charge failed for %s", "order-1", devtrace.NewDebugVars(map[string]interface{}{"amount": 42, "password": "hunter2"}))
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Level != zapcore.WarnLevel || entry.Message != "charge failed for order-1" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	fields := entry.ContextMap()
+	if fields["amount"] != int64(42) {
+		t.Fatalf("expected amount field, got %+v", fields)
+	}
+	if fields["password"] == "hunter2" {
+		t.Fatalf("password should be redacted, got %+v", fields)
+	}
+}
+
+func TestZapLoggerReceivesStackLogs(t *testing.T) {
+	originalConfig := devtrace.CurrentConfig()
+	t.Cleanup(func() { devtrace.SetConfig(originalConfig) })
+	devtrace.UpdateConfig(func(c *devtrace.DevTraceConfig) { c.Enabled = true })
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	stack := devtrace.NewEnhancedLogger(nil)
+	stack.SetLogger(NewZapLogger(zap.New(core)))
+	stack.Error(context.Background(), "boom", devtrace.NewDebugVars(map[string]interface{}{"user": 7}))
+
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].Level != zapcore.ErrorLevel {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if entries[0].ContextMap()["user"] != int64(7) {
+		t.Fatalf("expected user field, got %+v", entries[0].ContextMap())
+	}
+}
@@ -0,0 +1,116 @@
+//go:build !devtrace_minimal
+
+package devtrace
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	debugSessionDirMu sync.RWMutex
+	debugSessionDir   string
+)
+
+// SetDebugSessionDir points the diff page of DebugHandler at a directory of
+// session files written with WriteSession; "" turns the page off. Only files
+// directly inside dir can be opened.
+func SetDebugSessionDir(dir string) {
+	debugSessionDirMu.Lock()
+	defer debugSessionDirMu.Unlock()
+	debugSessionDir = dir
+}
+
+func currentDebugSessionDir() string {
+	debugSessionDirMu.RLock()
+	defer debugSessionDirMu.RUnlock()
+	return debugSessionDir
+}
+
+type debugDiffView struct {
+	Dir       string         `json:"dir"`
+	Sessions  []string       `json:"sessions"`
+	Before    string         `json:"-"`
+	After     string         `json:"-"`
+	Operation string         `json:"-"`
+	Diff      *SessionDiff   `json:"diff,omitempty"`
+	Rows      []debugDiffRow `json:"-"`
+}
+
+// debugDiffRow is a CallDiff flattened for the side-by-side table
+type debugDiffRow struct {
+	*CallDiff
+	Depth   int
+	Percent string // duration change of a matched call, e.g. +12.5%
+}
+
+func debugDiff(r *http.Request) (*debugDiffView, error) {
+	view := &debugDiffView{Dir: currentDebugSessionDir()}
+	if view.Dir == "" {
+		return view, nil
+	}
+
+	sessions, err := listDebugSessions(view.Dir)
+	if err != nil {
+		return nil, err
+	}
+	view.Sessions = sessions
+
+	q := r.URL.Query()
+	view.Before, view.After, view.Operation = q.Get("before"), q.Get("after"), q.Get("op")
+	if view.Before == "" || view.After == "" {
+		return view, nil
+	}
+
+	before, err := readDebugSession(view.Dir, view.Before)
+	if err != nil {
+		return nil, err
+	}
+	after, err := readDebugSession(view.Dir, view.After)
+	if err != nil {
+		return nil, err
+	}
+	view.Diff = DiffSessions(before, after, view.Operation)
+	view.Rows = flattenCallDiffs(nil, view.Diff.Roots, 0)
+	return view, nil
+}
+
+func listDebugSessions(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), AnnotationSuffix) {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readDebugSession opens a session by file name, refusing anything outside dir
+func readDebugSession(dir, name string) (*Session, error) {
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return nil, fmt.Errorf("invalid session name %q", name)
+	}
+	return ReadSessionFile(filepath.Join(dir, name))
+}
+
+func flattenCallDiffs(rows []debugDiffRow, calls []*CallDiff, depth int) []debugDiffRow {
+	for _, call := range calls {
+		row := debugDiffRow{CallDiff: call, Depth: depth}
+		if call.Status == DiffMatched && call.Before.Duration > 0 {
+			row.Percent = fmt.Sprintf("%+.1f%%", 100*float64(call.Delta)/float64(call.Before.Duration))
+		}
+		rows = flattenCallDiffs(append(rows, row), call.Children, depth+1)
+	}
+	return rows
+}
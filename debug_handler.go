@@ -0,0 +1,487 @@
+//go:build !devtrace_minimal
+
+package devtrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DebugPathPrefix is where RegisterDebugHandlers mounts the debug pages
+const DebugPathPrefix = "/debug/gotrace/"
+
+// RegisterDebugHandlers mounts DebugHandler on mux under /debug/gotrace/
+func RegisterDebugHandlers(mux *http.ServeMux) {
+	mux.Handle(DebugPathPrefix, DebugHandler())
+}
+
+// DebugHandler serves a live view of the tracer, in the spirit of net/http/pprof:
+//
+//	/debug/gotrace/         index
+//	/debug/gotrace/stacks   open frames of every active trace context
+//	/debug/gotrace/recent   recorder contents (?fn=, ?min=100ms, ?errors=1, ?roots=1, ?limit=)
+//	/debug/gotrace/stats    per-function statistics
+//	/debug/gotrace/events   counts of the events recorded with Emit
+//	/debug/gotrace/flame    interactive flame graph / icicle view of recorded frames (?fn=, ?trace=)
+//	/debug/gotrace/startup  startup waterfall: init functions, phases, ready and first request
+//	/debug/gotrace/diff     side-by-side call tree diff of two sessions in the directory
+//	                        set with SetDebugSessionDir (?before=, ?after=, ?op=)
+//	/debug/gotrace/config   current configuration; POST enabled, show_args, sample_rate
+//	                        or debug_level (form or JSON) to change it at runtime
+//
+// Every page is HTML by default and JSON with ?format=json or Accept: application/json.
+// The config page is writable, so only expose the handler on trusted listeners.
+func DebugHandler() http.Handler {
+	return http.HandlerFunc(serveDebug)
+}
+
+type debugContextView struct {
+	TraceID   string          `json:"trace_id,omitempty"`
+	Goroutine uint64          `json:"goroutine,omitempty"`
+	StartAt   time.Time       `json:"start_at"`
+	Depth     int             `json:"depth"`
+	Frames    []FrameSnapshot `json:"frames"`
+}
+
+type debugRecordView struct {
+	TraceID string            `json:"trace_id,omitempty"`
+	Path    []string          `json:"path"`
+	Baggage map[string]string `json:"baggage,omitempty"`
+	Frame   FrameSnapshot     `json:"frame"`
+}
+
+type debugStatsView struct {
+	FunctionStats
+	Average time.Duration `json:"average"`
+}
+
+type debugOperationView struct {
+	OperationStats
+	Average   time.Duration    `json:"average"`
+	Functions []debugStatsView `json:"functions"`
+}
+
+func serveDebug(w http.ResponseWriter, r *http.Request) {
+	page := path.Base(strings.TrimSuffix(r.URL.Path, "/"))
+
+	var data interface{}
+	switch page {
+	case "stacks":
+		data = debugStacks()
+	case "recent":
+		data = debugRecent(r)
+	case "stats":
+		data = debugStats()
+	case "operations":
+		data = debugOperations()
+	case "events":
+		data = AllEventStats()
+	case "flame":
+		data = debugFlame(r)
+	case "startup":
+		data = DefaultStartupTracer.Report()
+	case "diff":
+		view, err := debugDiff(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data = view
+	case "config":
+		if r.Method == http.MethodPost {
+			cfg, err := updateConfigFromRequest(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data = cfg
+		} else {
+			data = CurrentConfig()
+		}
+	default:
+		page = "index"
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if page == "index" {
+			data = []string{"stacks", "recent", "stats", "operations", "events", "flame", "startup", "diff", "config"}
+		}
+		if err := enc.Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := debugTemplates.ExecuteTemplate(w, page, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// configUpdate lists the settings that can be flipped through the config page
+type configUpdate struct {
+	Enabled    *bool    `json:"enabled"`
+	ShowArgs   *bool    `json:"show_args"`
+	SampleRate *float64 `json:"sample_rate"`
+	DebugLevel *int     `json:"debug_level"`
+}
+
+func updateConfigFromRequest(r *http.Request) (DevTraceConfig, error) {
+	var update configUpdate
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			return DevTraceConfig{}, fmt.Errorf("invalid config update: %v", err)
+		}
+	} else if err := parseConfigForm(r, &update); err != nil {
+		return DevTraceConfig{}, err
+	}
+
+	apply := func(c *DevTraceConfig) {
+		if update.Enabled != nil {
+			c.Enabled = *update.Enabled
+		}
+		if update.ShowArgs != nil {
+			c.ShowArgs = *update.ShowArgs
+		}
+		if update.SampleRate != nil {
+			c.SampleRate = *update.SampleRate
+		}
+		if update.DebugLevel != nil {
+			c.DebugLevel = *update.DebugLevel
+		}
+	}
+	return UpdateConfig(apply)
+}
+
+func parseConfigForm(r *http.Request, update *configUpdate) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	var err error
+	if update.Enabled, err = formBool(r, "enabled"); err != nil {
+		return err
+	}
+	if update.ShowArgs, err = formBool(r, "show_args"); err != nil {
+		return err
+	}
+
+	if raw := r.PostForm.Get("sample_rate"); raw != "" {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid sample_rate: %q", raw)
+		}
+		update.SampleRate = &value
+	}
+
+	if raw := r.PostForm.Get("debug_level"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid debug_level: %q", raw)
+		}
+		update.DebugLevel = &value
+	}
+
+	return nil
+}
+
+func formBool(r *http.Request, name string) (*bool, error) {
+	raw := r.PostForm.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %q", name, raw)
+	}
+	return &value, nil
+}
+
+func wantsJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func debugStacks() []debugContextView {
+	contexts := ActiveContexts()
+	views := make([]debugContextView, 0, len(contexts))
+	for _, tc := range contexts {
+		view := debugContextView{TraceID: tc.TraceID, Goroutine: tc.Goroutine(), StartAt: tc.StartAt, Depth: tc.GetDepth()}
+		for _, frame := range tc.Stack() {
+			snapshot := SnapshotFrame(frame)
+			if !frame.StartTime.IsZero() {
+				snapshot.Duration = time.Since(frame.StartTime)
+			}
+			view.Frames = append(view.Frames, snapshot)
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+func debugRecent(r *http.Request) []debugRecordView {
+	q := r.URL.Query()
+	filter := TraceFilter{
+		Function:   q.Get("fn"),
+		Operation:  q.Get("op"),
+		OnlyErrors: q.Get("errors") == "1" || q.Get("errors") == "true",
+		OnlyRoots:  q.Get("roots") == "1" || q.Get("roots") == "true",
+		TraceID:    q.Get("trace"),
+		Limit:      100,
+	}
+	if min, err := time.ParseDuration(q.Get("min")); err == nil {
+		filter.MinDuration = min
+	}
+	if limit, err := strconv.Atoi(q.Get("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+
+	records := RecentTraces(filter)
+	views := make([]debugRecordView, 0, len(records))
+	for _, record := range records {
+		views = append(views, debugRecordView{TraceID: record.TraceID, Path: record.Path, Baggage: record.Baggage, Frame: SnapshotFrame(record.Frame)})
+	}
+	return views
+}
+
+func debugFlame(r *http.Request) *FlameNode {
+	q := r.URL.Query()
+	return BuildFlameGraph(RecentTraces(TraceFilter{Function: q.Get("fn"), Operation: q.Get("op"), TraceID: q.Get("trace")}))
+}
+
+func debugStats() []debugStatsView {
+	return statsViews(AllFunctionStats())
+}
+
+func statsViews(all []FunctionStats) []debugStatsView {
+	views := make([]debugStatsView, 0, len(all))
+	for _, stats := range all {
+		views = append(views, debugStatsView{FunctionStats: stats, Average: stats.Average()})
+	}
+	return views
+}
+
+func debugOperations() []debugOperationView {
+	all := AllOperationStats()
+	views := make([]debugOperationView, 0, len(all))
+	for _, stats := range all {
+		views = append(views, debugOperationView{OperationStats: stats, Average: stats.Average(), Functions: statsViews(stats.Functions)})
+	}
+	return views
+}
+
+var debugTemplates = template.Must(template.New("debug").Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>gotrace</title>
+<style>body{font-family:monospace;margin:1.5em}table{border-collapse:collapse}td,th{padding:2px 8px;text-align:left;border-bottom:1px solid #ddd}.err{color:#b00}.added{background:#e6ffec}.removed{background:#ffebe9}.slower{color:#b00}.faster{color:#070}</style>
+</head><body><p><a href="./">index</a> · <a href="stacks">stacks</a> · <a href="recent">recent</a> · <a href="stats">stats</a> · <a href="operations">operations</a> · <a href="events">events</a> · <a href="flame">flame</a> · <a href="startup">startup</a> · <a href="diff">diff</a> · <a href="config">config</a></p>{{end}}
+{{define "footer"}}</body></html>{{end}}
+
+{{define "index"}}{{template "header"}}
+<h1>/debug/gotrace</h1>
+<ul>
+<li><a href="stacks">stacks</a> — open frames of every active trace context</li>
+<li><a href="recent">recent</a> — recently completed frames (enable with devtrace.EnableRecorder)</li>
+<li><a href="stats">stats</a> — per-function statistics</li>
+<li><a href="operations">operations</a> — statistics per operation (devtrace.SetOperation, OperationMiddleware), broken down by function</li>
+<li><a href="events">events</a> — counts of tracepoint events (devtrace.Emit)</li>
+<li><a href="flame">flame</a> — flame graph and icicle view of recorded frames</li>
+<li><a href="startup">startup</a> — startup waterfall (devtrace.TraceInit, TraceStartupPhase, MarkReady)</li>
+<li><a href="diff">diff</a> — compare the call trees of two stored sessions (devtrace.SetDebugSessionDir)</li>
+<li><a href="config">config</a> — current configuration</li>
+</ul>
+<p>Append <code>?format=json</code> to any page for JSON.</p>
+{{template "footer"}}{{end}}
+
+{{define "frame"}}{{if .Operation}}<small>[{{.Operation}}]</small> {{end}}{{.Function}} <small>{{.File}}:{{.Line}}</small> {{.Duration}}{{if .Error}} <span class="err">{{.Error}}</span>{{end}}{{if .Doc}}<br><small><i>{{.Doc}}</i></small>{{end}}{{if .Args}}<br><small>{{range $k, $v := .Args}}{{$k}}={{$v}} {{end}}</small>{{end}}{{end}}
+
+{{define "stacks"}}{{template "header"}}
+<h1>Active trace contexts ({{len .}})</h1>
+{{range .}}<h3>trace {{.TraceID}}{{if .Goroutine}} · goroutine {{.Goroutine}}{{end}} · depth {{.Depth}} · since {{.StartAt.Format "15:04:05.000"}}</h3>
+<ol>{{range .Frames}}<li>{{template "frame" .}}</li>{{end}}</ol>
+{{else}}<p>No open frames.</p>{{end}}
+{{template "footer"}}{{end}}
+
+{{define "recent"}}{{template "header"}}
+<h1>Recent frames ({{len .}})</h1>
+<table><tr><th>frame</th><th>path</th></tr>
+{{range .}}<tr><td>{{template "frame" .Frame}}</td><td><small>{{range $i, $p := .Path}}{{if $i}} → {{end}}{{$p}}{{end}}</small></td></tr>{{end}}
+</table>
+{{template "footer"}}{{end}}
+
+{{define "startup"}}{{template "header"}}
+<h1>Startup</h1>
+<pre>{{.Waterfall}}</pre>
+<table><tr><th>span</th><th>kind</th><th>offset</th><th>duration</th></tr>
+{{range .Spans}}<tr><td>{{.Name}}</td><td>{{.Kind}}</td><td>{{.Offset}}</td><td>{{.Duration}}</td></tr>{{end}}
+</table>
+{{template "footer"}}{{end}}
+
+{{define "stats"}}{{template "header"}}
+<h1>Function statistics</h1>
+<table><tr><th>function</th><th>calls</th><th>errors</th><th>avg</th><th>min</th><th>max</th><th>total</th></tr>
+{{range .}}<tr><td>{{.Function}}</td><td>{{.Calls}}</td><td>{{.Errors}}</td><td>{{.Average}}</td><td>{{.Min}}</td><td>{{.Max}}</td><td>{{.Total}}</td></tr>{{end}}
+</table>
+{{template "footer"}}{{end}}
+
+{{define "operations"}}{{template "header"}}
+<h1>Operations</h1>
+{{range .}}<h3>{{.Operation}} · {{.Calls}} calls{{if .Errors}} · <span class="err">{{.Errors}} errors</span>{{end}} · avg {{.Average}} · max {{.Max}} · <a href="recent?op={{.Operation}}">recent</a> · <a href="flame?op={{.Operation}}">flame</a></h3>
+<table><tr><th>function</th><th>calls</th><th>errors</th><th>avg</th><th>min</th><th>max</th><th>total</th></tr>
+{{range .Functions}}<tr><td>{{.Function}}</td><td>{{.Calls}}</td><td>{{.Errors}}</td><td>{{.Average}}</td><td>{{.Min}}</td><td>{{.Max}}</td><td>{{.Total}}</td></tr>{{end}}
+</table>
+{{else}}<p>No operations recorded. Name them with devtrace.SetOperation, TraceOptions.Operation or devtrace.OperationMiddleware.</p>{{end}}
+{{template "footer"}}{{end}}
+
+{{define "events"}}{{template "header"}}
+<h1>Events</h1>
+{{if .}}<table><tr><th>event</th><th>count</th><th>last</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{.Last.Format "15:04:05.000"}}</td></tr>{{end}}
+</table>{{else}}<p>No events recorded. Record them with devtrace.Emit.</p>{{end}}
+{{template "footer"}}{{end}}
+
+{{define "flame"}}{{template "header"}}
+<h1>Flame graph</h1>
+<p>
+<label><input type="radio" name="mode" value="flame" checked> flame</label>
+<label><input type="radio" name="mode" value="icicle"> icicle</label>
+<input id="search" placeholder="search function" size="30">
+<button id="reset">reset zoom</button>
+</p>
+<div id="chart" style="position:relative;width:100%;border:1px solid #ddd"></div>
+<pre id="details"></pre>
+<style>.fg{position:absolute;height:17px;overflow:hidden;white-space:nowrap;font-size:11px;line-height:17px;padding-left:2px;box-sizing:border-box;border:1px solid #fff;cursor:pointer;background:#f4a460}.fg.err{background:#e9776b}.fg.hit{background:#c77dff}.fg.dim{opacity:.35}</style>
+<script>
+(function() {
+  var root = {{.}};
+  var focus = root, mode = "flame", rowHeight = 18;
+  var chart = document.getElementById("chart"), details = document.getElementById("details");
+
+  function depthOf(node) {
+    var d = 0;
+    (node.children || []).forEach(function(c) { d = Math.max(d, depthOf(c)); });
+    return d + 1;
+  }
+
+  function pathTo(target, node, path) {
+    path = path.concat([node]);
+    if (node === target) return path;
+    var kids = node.children || [];
+    for (var i = 0; i < kids.length; i++) {
+      var found = pathTo(target, kids[i], path);
+      if (found) return found;
+    }
+    return null;
+  }
+
+  function fmt(ns) {
+    if (ns >= 1e9) return (ns / 1e9).toFixed(2) + "s";
+    if (ns >= 1e6) return (ns / 1e6).toFixed(2) + "ms";
+    if (ns >= 1e3) return (ns / 1e3).toFixed(1) + "µs";
+    return ns + "ns";
+  }
+
+  function render() {
+    chart.innerHTML = "";
+    if (!root.value) { chart.textContent = "No recorded frames. Enable with devtrace.EnableRecorder."; return; }
+
+    var ancestors = pathTo(focus, root, []) || [root];
+    var depth = ancestors.length - 1 + depthOf(focus);
+    var width = chart.clientWidth, query = document.getElementById("search").value.toLowerCase();
+    chart.style.height = (depth * rowHeight) + "px";
+
+    function place(node, level, x, w) {
+      if (w < 1) return;
+      var el = document.createElement("div");
+      el.className = "fg" + (node.errors ? " err" : "");
+      if (query) el.className += node.name.toLowerCase().indexOf(query) >= 0 ? " hit" : " dim";
+      var row = mode === "flame" ? depth - 1 - level : level;
+      el.style.top = (row * rowHeight) + "px";
+      el.style.left = x + "px";
+      el.style.width = w + "px";
+      el.textContent = node.name;
+      el.title = node.name + " · " + fmt(node.value) + " · " + node.calls + " calls";
+      el.onclick = function() { focus = node; show(node); render(); };
+      chart.appendChild(el);
+      return el;
+    }
+
+    ancestors.slice(0, -1).forEach(function(node, level) { place(node, level, 0, width); });
+
+    (function layout(node, level, x, w) {
+      place(node, level, x, w);
+      var offset = x;
+      (node.children || []).forEach(function(child) {
+        var cw = node.value ? w * child.value / node.value : 0;
+        layout(child, level + 1, offset, cw);
+        offset += cw;
+      });
+    })(focus, ancestors.length - 1, 0, width);
+  }
+
+  function show(node) {
+    var lines = [node.name, "total " + fmt(node.value) + " · " + node.calls + " calls" + (node.errors ? " · " + node.errors + " errors" : "")];
+    var s = node.sample;
+    if (s) {
+      lines.push(s.file + ":" + s.line);
+      Object.keys(s.args || {}).sort().forEach(function(k) { lines.push("  " + k + " = " + s.args[k]); });
+      if (s.results) lines.push("  → " + s.results.join(", "));
+      if (s.error) lines.push("  error: " + s.error);
+    }
+    details.textContent = lines.join("\n");
+  }
+
+  document.querySelectorAll("input[name=mode]").forEach(function(el) {
+    el.onchange = function() { mode = el.value; render(); };
+  });
+  document.getElementById("search").oninput = render;
+  document.getElementById("reset").onclick = function() { focus = root; details.textContent = ""; render(); };
+  window.onresize = render;
+  render();
+})();
+</script>
+{{template "footer"}}{{end}}
+
+{{define "diff"}}{{template "header"}}
+<h1>Session diff</h1>
+{{if not .Dir}}<p>No session directory. Point the page at stored sessions with devtrace.SetDebugSessionDir.</p>
+{{else}}<form>
+<label>before <select name="before">{{range .Sessions}}<option{{if eq . $.Before}} selected{{end}}>{{.}}</option>{{end}}</select></label>
+<label>after <select name="after">{{range .Sessions}}<option{{if eq . $.After}} selected{{end}}>{{.}}</option>{{end}}</select></label>
+<label>operation <input name="op" size="20" value="{{.Operation}}"></label>
+<button type="submit">compare</button>
+</form>
+{{with .Diff}}<h3>{{.Before}} → {{.After}}{{if .Operation}} · {{.Operation}}{{end}} · {{.BeforeTotal}} → {{.AfterTotal}} · {{.Added}} added · {{.Removed}} removed</h3>
+<table><tr><th>before</th><th>after</th><th>delta</th></tr>
+{{range $.Rows}}<tr class="{{.Status}}">
+<td style="padding-left:{{.Depth}}em">{{with .Before}}{{.Function}} {{.Duration}}{{if .Error}} <span class="err">{{.Error}}</span>{{end}}{{end}}</td>
+<td style="padding-left:{{.Depth}}em">{{with .After}}{{.Function}} {{.Duration}}{{if .Error}} <span class="err">{{.Error}}</span>{{end}}{{end}}</td>
+<td>{{if eq .Status "matched"}}<span class="{{if gt .Delta 0}}slower{{else if lt .Delta 0}}faster{{end}}">{{.Delta}}{{if .Percent}} ({{.Percent}}){{end}}</span>{{else}}{{.Status}}{{end}}</td>
+</tr>{{end}}
+</table>
+{{else}}{{if and .Before .After}}<p>No calls to compare.</p>{{end}}{{end}}{{end}}
+{{template "footer"}}{{end}}
+
+{{define "config"}}{{template "header"}}
+<h1>Configuration</h1>
+<pre>{{printf "%+v" .}}</pre>
+<form method="post">
+<label>enabled <select name="enabled"><option value="true"{{if .Enabled}} selected{{end}}>true</option><option value="false"{{if not .Enabled}} selected{{end}}>false</option></select></label>
+<label>show_args <select name="show_args"><option value="true"{{if .ShowArgs}} selected{{end}}>true</option><option value="false"{{if not .ShowArgs}} selected{{end}}>false</option></select></label>
+<label>sample_rate <input name="sample_rate" size="5" value="{{.SampleRate}}"></label>
+<label>debug_level <input name="debug_level" size="3" value="{{.DebugLevel}}"></label>
+<button type="submit">apply</button>
+</form>
+{{template "footer"}}{{end}}
+`))
@@ -0,0 +1,170 @@
+//go:build !devtrace_minimal
+
+package devtrace
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugHandlerServesStacksAndStats(t *testing.T) {
+	tc := NewTraceContext()
+	tc.Enter(CreateFrame("stuckHandler", "", "handler.go", 10, map[string]interface{}{"id": 7}))
+	defer tc.Close()
+
+	mux := http.NewServeMux()
+	RegisterDebugHandlers(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/gotrace/stacks?format=json", nil))
+
+	var contexts []debugContextView
+	if err := json.Unmarshal(rec.Body.Bytes(), &contexts); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, rec.Body.String())
+	}
+	found := false
+	for _, c := range contexts {
+		for _, f := range c.Frames {
+			if f.Function == "stuckHandler" && f.Args["id"] == "7" {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("open frame missing from stacks: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/gotrace/stats", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Function statistics") {
+		t.Fatalf("stats page not rendered: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDebugHandlerUpdatesConfig(t *testing.T) {
+	originalConfig := CurrentConfig()
+	t.Cleanup(func() { SetConfig(originalConfig) })
+
+	UpdateConfig(func(c *DevTraceConfig) {
+		c.Enabled = false
+		c.SampleRate = 1
+	})
+
+	handler := DebugHandler()
+
+	req := httptest.NewRequest(http.MethodPost, "/debug/gotrace/config", strings.NewReader("enabled=true&sample_rate=0.25"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	cfg := CurrentConfig()
+	if !cfg.Enabled || cfg.SampleRate != 0.25 {
+		t.Fatalf("config not updated: %+v", cfg)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/debug/gotrace/config", strings.NewReader(`{"sample_rate": 2}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected out-of-range sample_rate to be rejected, got %d", rec.Code)
+	}
+	if CurrentConfig().SampleRate != 0.25 {
+		t.Fatalf("rejected update must not change config")
+	}
+}
+
+func TestDebugHandlerRendersFlameGraph(t *testing.T) {
+	EnableRecorder(16)
+	t.Cleanup(DisableRecorder)
+
+	tc := NewTraceContext()
+	tc.Enter(CreateFrame("outer", "", "flame.go", 1, nil))
+	tc.Enter(CreateFrame("inner", "", "flame.go", 2, map[string]interface{}{"id": 3}))
+	tc.Leave()
+	tc.Leave()
+
+	rec := httptest.NewRecorder()
+	DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/gotrace/flame", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name":"inner"`) {
+		t.Fatalf("flame page missing data: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDebugHandlerDiffsSessions(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { SetDebugSessionDir("") })
+	SetDebugSessionDir(dir)
+
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	frame := func(fn string, offset, duration time.Duration) FrameSnapshot {
+		return FrameSnapshot{Function: fn, Operation: "checkout", StartTime: start.Add(offset), EndTime: start.Add(offset + duration), Duration: duration}
+	}
+	write := func(name string, frames ...FrameSnapshot) {
+		var b strings.Builder
+		enc := json.NewEncoder(&b)
+		for _, f := range frames {
+			if err := enc.Encode(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(b.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("before.jsonl",
+		frame("pkg.Load", time.Millisecond, 2*time.Millisecond),
+		frame("pkg.Audit", 4*time.Millisecond, time.Millisecond),
+		frame("pkg.Handle", 0, 10*time.Millisecond))
+	write("after.jsonl",
+		frame("pkg.Load", time.Millisecond, 2*time.Millisecond),
+		frame("pkg.Cache", 4*time.Millisecond, time.Millisecond),
+		frame("pkg.Handle", 0, 15*time.Millisecond))
+
+	mux := http.NewServeMux()
+	RegisterDebugHandlers(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/gotrace/diff?format=json&before=before.jsonl&after=after.jsonl&op=checkout", nil))
+	var view debugDiffView
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil || view.Diff == nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, rec.Body.String())
+	}
+	if len(view.Sessions) != 2 || len(view.Diff.Roots) != 1 {
+		t.Fatalf("unexpected diff: %s", rec.Body.String())
+	}
+	root := view.Diff.Roots[0]
+	if root.Status != DiffMatched || root.Delta != 5*time.Millisecond || len(root.Children) != 3 {
+		t.Fatalf("unexpected root: %+v", root)
+	}
+	var statuses []string
+	for _, child := range root.Children {
+		statuses = append(statuses, child.Function+"="+string(child.Status))
+	}
+	if got := strings.Join(statuses, " "); got != "pkg.Load=matched pkg.Audit=removed pkg.Cache=added" {
+		t.Fatalf("unexpected children: %s", got)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/gotrace/diff?before=before.jsonl&after=after.jsonl", nil))
+	for _, want := range []string{`class="added"`, `class="removed"`, "5ms (&#43;50.0%)"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("expected %q in diff page:\n%s", want, rec.Body.String())
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/gotrace/diff?before=../before.jsonl&after=after.jsonl", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected a path outside the session directory to be refused, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,148 @@
+package devtrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DebugVars represents variables to be logged for debugging
+type DebugVars struct {
+	Vars map[string]interface{} `json:"vars"`
+
+	// order lists keys in insertion order for vars made by NewOrderedDebugVars
+	// or added with Set; keys missing from it render after them, sorted
+	order []string
+}
+
+// NewDebugVars creates a new DebugVars instance; its vars render sorted by key
+func NewDebugVars(vars map[string]interface{}) *DebugVars {
+	return &DebugVars{Vars: vars}
+}
+
+// NewOrderedDebugVars creates DebugVars from alternating keys and values that
+// render in the order given, e.g. NewOrderedDebugVars("user", id, "step", 2)
+func NewOrderedDebugVars(keyvals ...interface{}) *DebugVars {
+	dv := &DebugVars{Vars: make(map[string]interface{}, (len(keyvals)+1)/2), order: []string{}}
+	for i := 0; i < len(keyvals); i += 2 {
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+		var value interface{} = "<missing>"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		dv.Set(key, value)
+	}
+	return dv
+}
+
+// Set adds or replaces a var; new keys render after the ones set before them
+func (dv *DebugVars) Set(key string, value interface{}) *DebugVars {
+	if dv.Vars == nil {
+		dv.Vars = make(map[string]interface{})
+	}
+	if _, exists := dv.Vars[key]; !exists {
+		dv.order = append(dv.order, key)
+	}
+	dv.Vars[key] = value
+	return dv
+}
+
+// Keys returns the keys in the order the vars render
+func (dv *DebugVars) Keys() []string {
+	if dv == nil || len(dv.Vars) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(dv.Vars))
+	seen := make(map[string]bool, len(dv.order))
+	for _, key := range dv.order {
+		if _, ok := dv.Vars[key]; ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	rest := len(keys)
+	for key := range dv.Vars {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys[rest:])
+	return keys
+}
+
+// DebugVarsFormat selects how DebugVars.Render writes vars
+type DebugVarsFormat string
+
+const (
+	// DebugVarsText is the String form: {"user": 42, "step": 2}
+	DebugVarsText DebugVarsFormat = "text"
+	// DebugVarsJSON is a JSON object of rendered values: {"user":"42","step":"2"}
+	DebugVarsJSON DebugVarsFormat = "json"
+	// DebugVarsLogfmt is logfmt: user=42 step=2
+	DebugVarsLogfmt DebugVarsFormat = "logfmt"
+)
+
+// String returns a string representation of debug variables
+func (dv *DebugVars) String() string {
+	return dv.Render(DebugVarsText)
+}
+
+// Render writes the vars, redacted and formatted with FormatValue, in format;
+// unknown formats render as DebugVarsText
+func (dv *DebugVars) Render(format DebugVarsFormat) string {
+	keys := dv.Keys()
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = FormatValue(Redact(key, dv.Vars[key]))
+	}
+
+	switch format {
+	case DebugVarsJSON:
+		var b strings.Builder
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			name, _ := json.Marshal(key)
+			value, _ := json.Marshal(values[i])
+			b.Write(name)
+			b.WriteByte(':')
+			b.Write(value)
+		}
+		b.WriteByte('}')
+		return b.String()
+	case DebugVarsLogfmt:
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = logfmtValue(key) + "=" + logfmtValue(values[i])
+		}
+		return strings.Join(parts, " ")
+	}
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%q: %s", key, values[i])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// logfmtValue quotes s when it is empty or holds spaces, quotes, '=' or
+// control characters
+func logfmtValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
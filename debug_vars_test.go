@@ -0,0 +1,24 @@
+package devtrace
+
+import "testing"
+
+func TestDebugVarsRenderInStableOrder(t *testing.T) {
+	vars := NewDebugVars(map[string]interface{}{"zeta": 1, "alpha": "a b", "mid": true})
+	for i := 0; i < 20; i++ {
+		if got, want := vars.String(), `{"alpha": a b, "mid": true, "zeta": 1}`; got != want {
+			t.Fatalf("String = %s, want %s", got, want)
+		}
+	}
+
+	ordered := NewOrderedDebugVars("zeta", 1, "alpha", "a b")
+	ordered.Set("mid", true)
+	if got, want := ordered.String(), `{"zeta": 1, "alpha": a b, "mid": true}`; got != want {
+		t.Fatalf("ordered String = %s, want %s", got, want)
+	}
+	if got, want := ordered.Render(DebugVarsJSON), `{"zeta":"1","alpha":"a b","mid":"true"}`; got != want {
+		t.Fatalf("JSON = %s, want %s", got, want)
+	}
+	if got, want := ordered.Render(DebugVarsLogfmt), `zeta=1 alpha="a b" mid=true`; got != want {
+		t.Fatalf("logfmt = %s, want %s", got, want)
+	}
+}
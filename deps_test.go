@@ -0,0 +1,21 @@
+package devtrace
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// The core module must stay free of third-party requirements so it can be vendored
+// into constrained environments; integrations belong in contrib/ submodules.
+func TestCoreHasNoThirdPartyDependencies(t *testing.T) {
+	data, err := os.ReadFile("go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "require") {
+			t.Fatalf("go.mod of the core module must not require other modules: %q", line)
+		}
+	}
+}
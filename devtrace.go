@@ -1,10 +1,15 @@
-// Package devtrace provides enhanced development tracing and debugging capabilities for Go applications
+// Package devtrace provides enhanced development tracing and debugging capabilities for Go applications.
+//
+// The package depends only on the standard library. Build with -tags devtrace_minimal
+// to also leave out the /debug/gotrace web UI and its net/http dependency. Integrations
+// with third-party libraries live in separate modules under contrib/.
 package devtrace
 
 import (
 	"fmt"
 	"os"
-	"strings"
+	"sync"
+	"time"
 )
 
 // DevTraceConfig holds global configuration for devtrace
@@ -16,22 +21,53 @@ type DevTraceConfig struct {
 	ShowSnippet int // lines of code context
 	AppPattern  string
 	DebugLevel  int
+	SampleRate  float64 // fraction of traced calls to record; 0 or 1 records all
+	MaxDepth    int     // frames kept per trace context; deeper calls are only counted, 0 is unlimited
+	// SlowThreshold makes traced calls running longer than this log a WARN with their stack; 0 disables
+	SlowThreshold time.Duration
+	// CaptureInvocation records argv, cwd and CaptureEnvVars on the root frame of each trace context
+	CaptureInvocation bool
+	CaptureEnvVars    []string
+	// PoolFrames recycles frames once GlobalLeave, LeaveContext and Trace wrappers
+	// have popped them, unless the recorder, a hook or Stack kept them. The frame
+	// GlobalLeave and LeaveContext return is then only valid until the next traced
+	// call, as instrumented code discards it anyway.
+	PoolFrames bool
+	// PprofLabels labels the goroutine with the function and trace ID of each
+	// frame while it runs, so CPU profiles can be sliced by frame. Setting labels
+	// allocates on every enter and leave.
+	PprofLabels bool
+	// MaxValueLen cuts captured strings and byte slices, and the rendered text
+	// of any captured value, to this many bytes. MaxValueDepth, MaxSliceElems
+	// and MaxMapEntries bound how much of nested structs, slices and maps is
+	// rendered. Each cut is marked with how much was left out; 0 is unlimited.
+	MaxValueLen   int
+	MaxValueDepth int
+	MaxSliceElems int
+	MaxMapEntries int
+	// SnapshotArgs deep-copies pointer, slice, map and struct arguments when a
+	// frame is created, so the frame shows their values at call time even when
+	// the function mutates them. Copies go MaxValueDepth levels deep and share
+	// what lies beyond with the caller.
+	SnapshotArgs bool
+	// LogErrors logs an ERROR with the stack, args included, whenever a traced
+	// function returns a non-nil error. ConfigOverrides and TraceOptions can
+	// turn it on or off per package, context or function.
+	LogErrors bool
 }
 
-// DefaultConfig provides sensible defaults for devtrace
-var DefaultConfig = DevTraceConfig{
-	Enabled:     strings.ToLower(os.Getenv("DEVTRACE_ENABLED")) == "true" || strings.ToLower(os.Getenv("GO_ENV")) == "development",
-	StackLimit:  5,
-	ShowArgs:    true,
-	ShowTiming:  true,
-	ShowSnippet: 2,
-	AppPattern:  "/",
-	DebugLevel:  1,
-}
+// DefaultConfig provides sensible defaults for devtrace, adjusted by DEVTRACE_* environment variables
+var DefaultConfig = ConfigFromEnv()
 
-// Config holds the current devtrace configuration
+// Config holds the current devtrace configuration.
+// Code that may run while UpdateConfig is in progress should read it through CurrentConfig.
+//
+// Deprecated: use CurrentConfig and Update of github.com/skulidropek/gotrace/v1,
+// which never race with writers.
 var Config = DefaultConfig
 
+var configMutex sync.RWMutex
+
 // Logger interface allows custom logging implementations
 type Logger interface {
 	Log(level string, msg string, args ...interface{})
@@ -41,6 +77,37 @@ type Logger interface {
 	Error(msg string, args ...interface{})
 }
 
+// StructuredLogger is implemented by loggers that take debug variables as
+// structured fields; the stack logger then passes DebugVars through LogFields
+// instead of rendering them into the message.
+type StructuredLogger interface {
+	Logger
+	LogFields(level string, msg string, fields map[string]interface{})
+}
+
+// DebugFields separates *DebugVars from formatting args and merges them into
+// redacted fields. It returns nil fields when args contain no DebugVars.
+func DebugFields(args []interface{}) (fields map[string]interface{}, rest []interface{}) {
+	rest = make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		dv, ok := arg.(*DebugVars)
+		if !ok {
+			rest = append(rest, arg)
+			continue
+		}
+		if dv == nil {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]interface{}, len(dv.Vars))
+		}
+		for name, value := range dv.Vars {
+			fields[name] = Redact(name, value)
+		}
+	}
+	return fields, rest
+}
+
 // DefaultLogger implements the Logger interface using Go's standard log package
 type DefaultLogger struct{}
 
@@ -50,13 +117,13 @@ func (l *DefaultLogger) Log(level string, msg string, args ...interface{}) {
 }
 
 func (l *DefaultLogger) Debug(msg string, args ...interface{}) {
-	if Config.DebugLevel >= 2 {
+	if CurrentConfig().DebugLevel >= 2 {
 		l.Log("DEBUG", msg, args...)
 	}
 }
 
 func (l *DefaultLogger) Info(msg string, args ...interface{}) {
-	if Config.DebugLevel >= 1 {
+	if CurrentConfig().DebugLevel >= 1 {
 		l.Log("INFO", msg, args...)
 	}
 }
@@ -70,19 +137,66 @@ func (l *DefaultLogger) Error(msg string, args ...interface{}) {
 }
 
 // GlobalLogger is the default logger instance
+//
+// Deprecated: use SetLogger of github.com/skulidropek/gotrace/v1.
 var GlobalLogger Logger = &DefaultLogger{}
 
 // SetLogger sets a custom logger implementation
+//
+// Deprecated: use SetLogger of github.com/skulidropek/gotrace/v1, which also
+// routes the stack logger's output.
 func SetLogger(logger Logger) {
 	GlobalLogger = logger
 }
 
-// SetConfig updates the global configuration
-func SetConfig(config DevTraceConfig) {
+// SetConfig updates the global configuration. An invalid config (see
+// DevTraceConfig.Validate) is rejected and the current one stays in place.
+//
+// Deprecated: use Update of github.com/skulidropek/gotrace/v1 for the settings
+// it covers.
+func SetConfig(config DevTraceConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
 	Config = config
+	return nil
+}
+
+// UpdateConfig applies fn to a copy of the global configuration and swaps it in,
+// so concurrent readers see either the old or the new config, never a mix. The
+// copy is validated before the swap: when it is invalid (see
+// DevTraceConfig.Validate) the current config stays in place and is returned
+// with the error.
+//
+// Deprecated: use Update of github.com/skulidropek/gotrace/v1 for the settings
+// it covers.
+func UpdateConfig(fn func(c *DevTraceConfig)) (DevTraceConfig, error) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	updated := Config
+	updated.CaptureEnvVars = append([]string(nil), Config.CaptureEnvVars...)
+	fn(&updated)
+	if err := updated.Validate(); err != nil {
+		return Config, err
+	}
+	Config = updated
+	return updated, nil
+}
+
+// CurrentConfig returns a consistent snapshot of the global configuration
+func CurrentConfig() DevTraceConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return Config
 }
 
 // IsEnabled returns whether devtrace is currently enabled
+//
+// Deprecated: use Enabled of github.com/skulidropek/gotrace/v1.
 func IsEnabled() bool {
-	return Config.Enabled
+	return CurrentConfig().Enabled
 }
@@ -0,0 +1,217 @@
+package devtracetest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+// GoldenUpdateEnv names the environment variable that makes Golden write the
+// current trace to the golden file instead of comparing against it:
+//
+//	DEVTRACE_UPDATE_GOLDEN=1 go test ./...
+const GoldenUpdateEnv = "DEVTRACE_UPDATE_GOLDEN"
+
+// GoldenOptions configures GoldenWithOptions
+type GoldenOptions struct {
+	// Dir holds the golden files, <Dir>/<name>.golden; default testdata/golden
+	Dir string
+	// Lines keeps the file:line of every call, so moving code fails the comparison
+	Lines bool
+	// Results keeps the rendered return values; args and errors are always kept
+	Results bool
+}
+
+// Golden compares the call tree of trace with the golden file
+// testdata/golden/<name>.golden and fails the test with a line diff when they
+// differ. Durations, times, goroutine and span IDs, line numbers and context
+// arguments are left out and pointer addresses in values are masked, so only
+// changes in the call flow and its arguments fail. trace is a *Recorder, a
+// *devtrace.Session, []devtrace.FrameSnapshot or []devtrace.ExportedFrame.
+func Golden(t testing.TB, name string, trace interface{}) {
+	t.Helper()
+	GoldenWithOptions(t, name, trace, nil)
+}
+
+// GoldenWithOptions is Golden with options
+func GoldenWithOptions(t testing.TB, name string, trace interface{}, opts *GoldenOptions) {
+	t.Helper()
+	var o GoldenOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Dir == "" {
+		o.Dir = filepath.Join("testdata", "golden")
+	}
+
+	frames, err := goldenFrames(trace)
+	if err != nil {
+		t.Fatalf("devtracetest.Golden: %v", err)
+	}
+	got := RenderGolden(frames, &o)
+	path := filepath.Join(o.Dir, name+".golden")
+
+	if os.Getenv(GoldenUpdateEnv) != "" {
+		if err := os.MkdirAll(o.Dir, 0o755); err != nil {
+			t.Fatalf("devtracetest.Golden: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("devtracetest.Golden: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("golden file %s does not exist; create it with %s=1 go test. Current trace:\n%s", path, GoldenUpdateEnv, got)
+	}
+	if err != nil {
+		t.Fatalf("devtracetest.Golden: %v", err)
+	}
+	if string(want) != got {
+		t.Errorf("trace differs from %s (- golden, + current; %s=1 updates it):\n%s", path, GoldenUpdateEnv, lineDiff(string(want), got))
+	}
+}
+
+func goldenFrames(trace interface{}) ([]devtrace.FrameSnapshot, error) {
+	switch trace := trace.(type) {
+	case *Recorder:
+		return snapshots(trace.Frames()), nil
+	case *devtrace.Session:
+		return trace.Frames, nil
+	case []devtrace.FrameSnapshot:
+		return trace, nil
+	case []devtrace.ExportedFrame:
+		return snapshots(trace), nil
+	}
+	return nil, fmt.Errorf("unsupported trace type %T", trace)
+}
+
+func snapshots(frames []devtrace.ExportedFrame) []devtrace.FrameSnapshot {
+	out := make([]devtrace.FrameSnapshot, len(frames))
+	for i, frame := range frames {
+		out[i] = frame.FrameSnapshot
+	}
+	return out
+}
+
+// addressPattern matches pointer addresses in rendered values
+var addressPattern = regexp.MustCompile(`0x[0-9a-f]{6,}`)
+
+// RenderGolden renders frames as the normalized call tree Golden compares,
+// one call per line, children indented under their caller:
+//
+//	users.UserService.GetUser(id=42)
+//	  users.Repo.Load(id=42) error: not found
+func RenderGolden(frames []devtrace.FrameSnapshot, opts *GoldenOptions) string {
+	var o GoldenOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	var b strings.Builder
+	var render func(nodes []*devtrace.CallTreeNode, depth int)
+	render = func(nodes []*devtrace.CallTreeNode, depth int) {
+		for _, node := range nodes {
+			b.WriteString(strings.Repeat("  ", depth))
+			b.WriteString(goldenLine(node.Frame, o))
+			b.WriteByte('\n')
+			render(node.Children, depth+1)
+		}
+	}
+	render(devtrace.SessionCallTree(&devtrace.Session{Frames: frames}), 0)
+	return b.String()
+}
+
+func goldenLine(frame *devtrace.FrameSnapshot, o GoldenOptions) string {
+	name := frame.Function
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.NewReplacer("(*", "", "(", "", ")", "").Replace(strings.TrimSuffix(name, "-fm"))
+
+	keys := make([]string, 0, len(frame.Args))
+	for key, value := range frame.Args {
+		if !strings.HasPrefix(value, "context.") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	args := make([]string, len(keys))
+	for i, key := range keys {
+		args[i] = key + "=" + mask(frame.Args[key])
+	}
+
+	line := name + "(" + strings.Join(args, ", ") + ")"
+	if o.Results && len(frame.Results) > 0 {
+		results := make([]string, len(frame.Results))
+		for i, result := range frame.Results {
+			results[i] = mask(result)
+		}
+		line += " → " + strings.Join(results, ", ")
+	}
+	switch {
+	case frame.Panic != "":
+		line += " panic: " + mask(frame.Panic)
+	case frame.Error != "":
+		line += " error: " + mask(frame.Error)
+	}
+	if o.Lines && frame.File != "" {
+		line += fmt.Sprintf(" [%s:%d]", filepath.Base(frame.File), frame.Line)
+	}
+	return line
+}
+
+// mask hides pointer addresses and keeps a value on one line
+func mask(value string) string {
+	value = addressPattern.ReplaceAllString(value, "0x?")
+	return strings.NewReplacer("\n", `\n`, "\r", `\r`).Replace(value)
+}
+
+// lineDiff lists want and got line by line along their longest common
+// subsequence, marking lines only in want with "-" and only in got with "+"
+func lineDiff(want, got string) string {
+	a := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		default:
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,65 @@
+package devtracetest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+func TestGoldenComparesNormalizedCallTree(t *testing.T) {
+	rec := NewRecorder()
+	defer rec.Stop()
+
+	svc := &userService{load: devtrace.TraceTyped(repo{}.Load, nil)}
+	getUser := devtrace.TraceTyped(svc.GetUser, nil)
+	ctx := devtrace.WithTraceContext(context.Background(), devtrace.NewTraceContext())
+	getUser(ctx, 42)
+	getUser(ctx, 0)
+
+	opts := &GoldenOptions{Dir: t.TempDir(), Results: true}
+	t.Setenv(GoldenUpdateEnv, "1")
+	GoldenWithOptions(t, "get_user", rec, opts)
+
+	golden, err := os.ReadFile(filepath.Join(opts.Dir, "get_user.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "devtracetest.userService.GetUser(arg1=42) → user-42, <nil>\n" +
+		"  devtracetest.repo.Load(arg1=42) → user-42, <nil>\n" +
+		"devtracetest.userService.GetUser(arg1=0) → , not found error: not found\n" +
+		"  devtracetest.repo.Load(arg1=0) → , not found error: not found\n"
+	if string(golden) != want {
+		t.Fatalf("golden file:\n%s\nwant:\n%s", golden, want)
+	}
+
+	t.Setenv(GoldenUpdateEnv, "")
+	GoldenWithOptions(t, "get_user", rec, opts)
+
+	rec.Reset()
+	svc.load = func(ctx context.Context, id int) (string, error) { return "cached", nil }
+	getUser(ctx, 42)
+	getUser(ctx, 0)
+	fake := &fakeT{TB: t}
+	GoldenWithOptions(fake, "get_user", rec, opts)
+	if len(fake.failures) != 1 || !strings.Contains(fake.failures[0], "-   devtracetest.repo.Load(arg1=42) → user-42, <nil>\n") ||
+		!strings.Contains(fake.failures[0], "+ devtracetest.userService.GetUser(arg1=42) → cached, <nil>\n") {
+		t.Fatalf("unexpected failures: %q", fake.failures)
+	}
+}
+
+func TestRenderGoldenMasksAddresses(t *testing.T) {
+	frames := []devtrace.FrameSnapshot{{
+		Function: "github.com/acme/app/users.(*Cache).Get-fm",
+		File:     "/src/users/cache.go",
+		Line:     12,
+		Args:     map[string]string{"key": "k1", "entry": "&{Next:0xc000123456}"},
+	}}
+	got := RenderGolden(frames, &GoldenOptions{Lines: true})
+	if want := "users.Cache.Get(entry=&{Next:0x?}, key=k1) [cache.go:12]\n"; got != want {
+		t.Fatalf("RenderGolden = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,286 @@
+// Package devtracetest records what devtrace traces and logs while a test runs
+// and asserts on it, instead of matching captured log strings:
+//
+//	rec := devtracetest.NewRecorder()
+//	defer rec.Stop()
+//
+//	svc.GetUser(ctx, 42)
+//
+//	rec.AssertCalled(t, "UserService.GetUser")
+//	rec.AssertOrder(t, "UserService.GetUser", "Repo.Load")
+//	rec.AssertMaxDuration(t, "Repo.Load", 50*time.Millisecond)
+//
+// Function names match the way call contracts do: "Repo.Load" matches
+// "pkg.Repo.Load", "pkg.(*Repo).Load" and the method value "pkg.(*Repo).Load-fm".
+// The recorder swaps process-wide state, so tests using it must not run in
+// parallel with other traced tests.
+package devtracetest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+// LogLine is a message written through devtrace's loggers while recording
+type LogLine struct {
+	Level   string
+	Message string
+}
+
+// Recorder captures completed frames and log lines until Stop is called
+type Recorder struct {
+	mu     sync.Mutex
+	frames []devtrace.ExportedFrame // in completion order
+	logs   []LogLine
+
+	restore []func()
+}
+
+// NewRecorder enables tracing and starts capturing every completed frame and
+// every message written through devtrace.GlobalLogger and
+// devtrace.GlobalEnhancedLogger. Stop restores the previous configuration
+// and loggers.
+func NewRecorder() *Recorder {
+	r := &Recorder{}
+
+	config := devtrace.CurrentConfig()
+	devtrace.UpdateConfig(func(c *devtrace.DevTraceConfig) { c.Enabled = true })
+	r.restore = append(r.restore, func() { devtrace.SetConfig(config) })
+
+	unregister := devtrace.RegisterExporter(func(frame devtrace.ExportedFrame) {
+		r.mu.Lock()
+		r.frames = append(r.frames, frame)
+		r.mu.Unlock()
+	})
+	r.restore = append(r.restore, unregister)
+
+	logger := &captureLogger{recorder: r}
+	globalLogger := devtrace.GlobalLogger
+	devtrace.SetLogger(logger)
+	r.restore = append(r.restore, func() { devtrace.SetLogger(globalLogger) })
+
+	enhancedLogger := devtrace.GlobalEnhancedLogger
+	if enhancedLogger != nil {
+		capturing := *enhancedLogger
+		capturing.SetLogger(logger)
+		devtrace.GlobalEnhancedLogger = &capturing
+	}
+	r.restore = append(r.restore, func() { devtrace.GlobalEnhancedLogger = enhancedLogger })
+
+	return r
+}
+
+// Stop stops recording and restores what NewRecorder replaced. What was
+// recorded stays available; calling Stop again does nothing.
+func (r *Recorder) Stop() {
+	r.mu.Lock()
+	restore := r.restore
+	r.restore = nil
+	r.mu.Unlock()
+
+	for i := len(restore) - 1; i >= 0; i-- {
+		restore[i]()
+	}
+}
+
+// Reset drops the frames and log lines recorded so far
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frames = nil
+	r.logs = nil
+}
+
+// Frames returns the recorded frames in call order: by start time, callers
+// before the calls they make
+func (r *Recorder) Frames() []devtrace.ExportedFrame {
+	r.mu.Lock()
+	frames := append([]devtrace.ExportedFrame(nil), r.frames...)
+	r.mu.Unlock()
+
+	sort.SliceStable(frames, func(i, j int) bool {
+		if !frames[i].StartTime.Equal(frames[j].StartTime) {
+			return frames[i].StartTime.Before(frames[j].StartTime)
+		}
+		return len(frames[i].Path) < len(frames[j].Path)
+	})
+	return frames
+}
+
+// Calls returns the function names of the recorded frames in call order
+func (r *Recorder) Calls() []string {
+	frames := r.Frames()
+	names := make([]string, len(frames))
+	for i, frame := range frames {
+		names[i] = frame.Function
+	}
+	return names
+}
+
+// CallsOf returns the recorded frames of function in call order
+func (r *Recorder) CallsOf(function string) []devtrace.ExportedFrame {
+	var matching []devtrace.ExportedFrame
+	for _, frame := range r.Frames() {
+		if devtrace.MatchFunction(function, frame.Function) {
+			matching = append(matching, frame)
+		}
+	}
+	return matching
+}
+
+// Logs returns the recorded log lines in the order they were written
+func (r *Recorder) Logs() []LogLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]LogLine(nil), r.logs...)
+}
+
+// AssertCalled fails the test unless function was called at least once
+func (r *Recorder) AssertCalled(t testing.TB, function string) bool {
+	t.Helper()
+	if len(r.CallsOf(function)) == 0 {
+		t.Errorf("expected a call of %s, traced calls: %s", function, r.callList())
+		return false
+	}
+	return true
+}
+
+// AssertNotCalled fails the test if function was called
+func (r *Recorder) AssertNotCalled(t testing.TB, function string) bool {
+	t.Helper()
+	if calls := r.CallsOf(function); len(calls) > 0 {
+		t.Errorf("expected no call of %s, got %d", function, len(calls))
+		return false
+	}
+	return true
+}
+
+// AssertCallCount fails the test unless function was called exactly n times
+func (r *Recorder) AssertCallCount(t testing.TB, function string, n int) bool {
+	t.Helper()
+	if calls := r.CallsOf(function); len(calls) != n {
+		t.Errorf("expected %d call(s) of %s, got %d", n, function, len(calls))
+		return false
+	}
+	return true
+}
+
+// AssertOrder fails the test unless the functions were called in the order
+// given. Other calls in between are ignored, so AssertOrder(t, "A", "C")
+// holds for A → B → C.
+func (r *Recorder) AssertOrder(t testing.TB, functions ...string) bool {
+	t.Helper()
+	calls := r.Calls()
+	next := 0
+	for _, call := range calls {
+		if next < len(functions) && devtrace.MatchFunction(functions[next], call) {
+			next++
+		}
+	}
+	if next < len(functions) {
+		t.Errorf("expected calls in order %s, missing %s from position %d; traced calls: %s",
+			strings.Join(functions, " → "), functions[next], next+1, r.callList())
+		return false
+	}
+	return true
+}
+
+// AssertContract fails the test unless the recorded calls satisfy a call
+// contract spec such as "Handler → Validate → Repo.Get ×+"; see
+// devtrace.ParseCallContract
+func (r *Recorder) AssertContract(t testing.TB, spec string) bool {
+	t.Helper()
+	contract, err := devtrace.ParseCallContract(spec)
+	if err != nil {
+		t.Errorf("invalid call contract: %v", err)
+		return false
+	}
+	if err := contract.VerifyNames(r.Calls()); err != nil {
+		t.Error(err)
+		return false
+	}
+	return true
+}
+
+// AssertMaxDuration fails the test unless function was called and every call
+// of it took at most max
+func (r *Recorder) AssertMaxDuration(t testing.TB, function string, max time.Duration) bool {
+	t.Helper()
+	calls := r.CallsOf(function)
+	if len(calls) == 0 {
+		t.Errorf("expected a call of %s, traced calls: %s", function, r.callList())
+		return false
+	}
+	ok := true
+	for i, call := range calls {
+		if call.Duration > max {
+			t.Errorf("call %d of %s took %v, more than %v", i+1, function, call.Duration, max)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// AssertNoErrors fails the test if any traced call returned an error or panicked
+func (r *Recorder) AssertNoErrors(t testing.TB) bool {
+	t.Helper()
+	ok := true
+	for _, frame := range r.Frames() {
+		switch {
+		case frame.Panic != "":
+			t.Errorf("%s panicked: %s", frame.Function, frame.Panic)
+			ok = false
+		case frame.Error != "":
+			t.Errorf("%s returned error: %s", frame.Function, frame.Error)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// AssertLogged fails the test unless a recorded log line contains substr
+func (r *Recorder) AssertLogged(t testing.TB, substr string) bool {
+	t.Helper()
+	logs := r.Logs()
+	for _, line := range logs {
+		if strings.Contains(line.Message, substr) {
+			return true
+		}
+	}
+	t.Errorf("expected a log line containing %q, got %d line(s)", substr, len(logs))
+	return false
+}
+
+// callList renders the recorded calls for failure messages
+func (r *Recorder) callList() string {
+	calls := r.Calls()
+	if len(calls) == 0 {
+		return "none"
+	}
+	return strings.Join(calls, " → ")
+}
+
+// captureLogger records messages into the recorder
+type captureLogger struct {
+	recorder *Recorder
+}
+
+func (c *captureLogger) Log(level string, msg string, args ...interface{}) {
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	c.recorder.mu.Lock()
+	c.recorder.logs = append(c.recorder.logs, LogLine{Level: level, Message: msg})
+	c.recorder.mu.Unlock()
+}
+
+func (c *captureLogger) Debug(msg string, args ...interface{}) { c.Log("DEBUG", msg, args...) }
+func (c *captureLogger) Info(msg string, args ...interface{})  { c.Log("INFO", msg, args...) }
+func (c *captureLogger) Warn(msg string, args ...interface{})  { c.Log("WARN", msg, args...) }
+func (c *captureLogger) Error(msg string, args ...interface{}) { c.Log("ERROR", msg, args...) }
@@ -0,0 +1,92 @@
+package devtracetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	devtrace "github.com/skulidropek/gotrace"
+)
+
+type repo struct{}
+
+func (repo) Load(ctx context.Context, id int) (string, error) {
+	if id == 0 {
+		return "", errors.New("not found")
+	}
+	return fmt.Sprintf("user-%d", id), nil
+}
+
+type userService struct {
+	load func(ctx context.Context, id int) (string, error)
+}
+
+func (s *userService) GetUser(ctx context.Context, id int) (string, error) {
+	devtrace.GlobalEnhancedLogger.Info(ctx, "loading user", devtrace.NewDebugVars(map[string]interface{}{"id": id}))
+	return s.load(ctx, id)
+}
+
+// fakeT collects the failures an assertion reports instead of failing the test
+type fakeT struct {
+	testing.TB
+	failures []string
+}
+
+func (f *fakeT) Helper()                   {}
+func (f *fakeT) Error(args ...interface{}) { f.failures = append(f.failures, fmt.Sprint(args...)) }
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func TestRecorderAssertions(t *testing.T) {
+	rec := NewRecorder()
+	defer rec.Stop()
+
+	svc := &userService{load: devtrace.TraceTyped(repo{}.Load, nil)}
+	getUser := devtrace.TraceTyped(svc.GetUser, nil)
+	ctx := devtrace.WithTraceContext(context.Background(), devtrace.NewTraceContext())
+	if _, err := getUser(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+
+	rec.AssertCalled(t, "userService.GetUser")
+	rec.AssertCallCount(t, "repo.Load", 1)
+	rec.AssertOrder(t, "userService.GetUser", "repo.Load")
+	rec.AssertContract(t, "userService.GetUser → repo.Load")
+	rec.AssertMaxDuration(t, "repo.Load", time.Second)
+	rec.AssertNoErrors(t)
+	rec.AssertLogged(t, "loading user")
+
+	fake := &fakeT{TB: t}
+	rec.AssertOrder(fake, "repo.Load", "userService.GetUser")
+	rec.AssertNotCalled(fake, "repo.Load")
+	rec.AssertMaxDuration(fake, "repo.Save", time.Second)
+	if len(fake.failures) != 3 || !strings.Contains(fake.failures[0], "missing userService.GetUser from position 2") {
+		t.Fatalf("unexpected failures: %q", fake.failures)
+	}
+
+	rec.Reset()
+	getUser(ctx, 0)
+	rec.AssertNoErrors(fake)
+	if !strings.Contains(fake.failures[len(fake.failures)-1], "returned error: not found") {
+		t.Fatalf("error not reported: %q", fake.failures)
+	}
+}
+
+func TestRecorderStopRestoresLoggersAndConfig(t *testing.T) {
+	logger, enabled := devtrace.GlobalLogger, devtrace.IsEnabled()
+
+	rec := NewRecorder()
+	if !devtrace.IsEnabled() || devtrace.GlobalLogger == logger {
+		t.Fatal("recorder did not take over tracing and logging")
+	}
+	rec.Stop()
+	rec.Stop()
+
+	if devtrace.GlobalLogger != logger || devtrace.IsEnabled() != enabled {
+		t.Fatal("Stop did not restore the logger and config")
+	}
+}
@@ -0,0 +1,191 @@
+package devtrace
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// encryptedMagic starts every stream written by NewEncryptingWriter
+const encryptedMagic = "DTENC1\n"
+
+// maxEncryptedRecord bounds a single sealed record to protect readers from corrupt input
+const maxEncryptedRecord = 64 << 20
+
+// ErrNoEncryptionKey is returned when encryption is requested but no key is configured
+var ErrNoEncryptionKey = errors.New("devtrace: no encryption key configured")
+
+// KeyProvider returns the AES key (16, 24 or 32 bytes) used for trace files at rest.
+// Implementations can fetch the key from a KMS; it is called once per writer or reader.
+type KeyProvider func() ([]byte, error)
+
+var (
+	keyProviderMu sync.RWMutex
+	keyProvider   = EnvKeyProvider("DEVTRACE_ENCRYPTION_KEY")
+)
+
+// SetKeyProvider replaces the key source for trace file encryption
+func SetKeyProvider(provider KeyProvider) {
+	keyProviderMu.Lock()
+	defer keyProviderMu.Unlock()
+
+	keyProvider = provider
+}
+
+// EnvKeyProvider reads a hex or base64 encoded key from the named environment variable
+func EnvKeyProvider(name string) KeyProvider {
+	return func() ([]byte, error) {
+		raw := strings.TrimSpace(os.Getenv(name))
+		if raw == "" {
+			return nil, ErrNoEncryptionKey
+		}
+		if key, err := hex.DecodeString(raw); err == nil {
+			return key, nil
+		}
+		if key, err := base64.StdEncoding.DecodeString(raw); err == nil {
+			return key, nil
+		}
+		return nil, fmt.Errorf("devtrace: %s is neither hex nor base64", name)
+	}
+}
+
+func currentAEAD() (cipher.AEAD, error) {
+	keyProviderMu.RLock()
+	provider := keyProvider
+	keyProviderMu.RUnlock()
+
+	return providerAEAD(provider)
+}
+
+func providerAEAD(provider KeyProvider) (cipher.AEAD, error) {
+	if provider == nil {
+		return nil, ErrNoEncryptionKey
+	}
+
+	key, err := provider()
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("devtrace: invalid encryption key: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptingWriter seals each Write as an independent AES-GCM record:
+// 4-byte big-endian length, nonce, ciphertext.
+type encryptingWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+}
+
+// NewEncryptingWriter wraps w so everything written is encrypted with AES-GCM
+// using the configured KeyProvider. Closing the writer does not close w.
+func NewEncryptingWriter(w io.Writer) (io.WriteCloser, error) {
+	aead, err := currentAEAD()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, encryptedMagic); err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{w: w, aead: aead}, nil
+}
+
+func (ew *encryptingWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	nonce := make([]byte, ew.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, err
+	}
+
+	sealed := ew.aead.Seal(nonce, nonce, p, nil)
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(sealed)))
+
+	if _, err := ew.w.Write(header[:]); err != nil {
+		return 0, err
+	}
+	if _, err := ew.w.Write(sealed); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (ew *encryptingWriter) Close() error {
+	return nil
+}
+
+type decryptingReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	pending []byte
+}
+
+// NewDecryptingReader reads a stream produced by NewEncryptingWriter
+func NewDecryptingReader(r io.Reader) (io.Reader, error) {
+	aead, err := currentAEAD()
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(encryptedMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != encryptedMagic {
+		return nil, errors.New("devtrace: not an encrypted trace stream")
+	}
+	return &decryptingReader{r: br, aead: aead}, nil
+}
+
+func (dr *decryptingReader) Read(p []byte) (int, error) {
+	for len(dr.pending) == 0 {
+		var header [4]byte
+		if _, err := io.ReadFull(dr.r, header[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return 0, errors.New("devtrace: truncated encrypted record")
+			}
+			return 0, err
+		}
+
+		size := binary.BigEndian.Uint32(header[:])
+		if size > maxEncryptedRecord || int(size) < dr.aead.NonceSize() {
+			return 0, fmt.Errorf("devtrace: invalid encrypted record size %d", size)
+		}
+
+		sealed := make([]byte, size)
+		if _, err := io.ReadFull(dr.r, sealed); err != nil {
+			return 0, errors.New("devtrace: truncated encrypted record")
+		}
+
+		nonce, ciphertext := sealed[:dr.aead.NonceSize()], sealed[dr.aead.NonceSize():]
+		plain, err := dr.aead.Open(nil, nonce, ciphertext, nil)
+		if err != nil {
+			return 0, fmt.Errorf("devtrace: decrypting record: %v", err)
+		}
+		dr.pending = plain
+	}
+
+	n := copy(p, dr.pending)
+	dr.pending = dr.pending[n:]
+	return n, nil
+}
+
+// IsEncryptedStream reports whether data starts like a stream from NewEncryptingWriter
+func IsEncryptedStream(data []byte) bool {
+	return strings.HasPrefix(string(data), encryptedMagic)
+}
@@ -0,0 +1,88 @@
+package devtrace
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	SetKeyProvider(func() ([]byte, error) { return key, nil })
+	t.Cleanup(func() { SetKeyProvider(EnvKeyProvider("DEVTRACE_ENCRYPTION_KEY")) })
+
+	var buf bytes.Buffer
+	w, err := NewEncryptingWriter(&buf)
+	if err != nil {
+		t.Fatalf("writer: %v", err)
+	}
+	io.WriteString(w, `{"function":"login","args":{"user":"bob"}}`+"\n")
+	io.WriteString(w, `{"function":"logout"}`+"\n")
+	w.Close()
+
+	if strings.Contains(buf.String(), "login") || !IsEncryptedStream(buf.Bytes()) {
+		t.Fatalf("stream is not encrypted")
+	}
+
+	r, err := NewDecryptingReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("reader: %v", err)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if !strings.Contains(string(plain), `"function":"logout"`) {
+		t.Fatalf("unexpected plaintext: %s", plain)
+	}
+
+	tampered := append([]byte(nil), buf.Bytes()...)
+	tampered[len(tampered)-1] ^= 0xff
+	r, _ = NewDecryptingReader(bytes.NewReader(tampered))
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatalf("expected tampered stream to fail authentication")
+	}
+}
+
+func TestExportSecuritySignsAndEncryptsFields(t *testing.T) {
+	key := bytes.Repeat([]byte{9}, 32)
+	security := &ExportSecurity{
+		SigningKey:    []byte("collector secret"),
+		EncryptFields: []string{"email", ExportFieldResults, ExportFieldError},
+		Keys:          func() ([]byte, error) { return key, nil },
+	}
+
+	frame := CreateFrame("pkg.Signup", "", "signup.go", 12, map[string]interface{}{"email": "ana@example.com", "plan": "pro"})
+	frame.StartTime = time.Now()
+	frame.EndTime = frame.StartTime.Add(time.Millisecond)
+	frame.Results = []interface{}{errors.New("ana@example.com already registered")}
+
+	var buf bytes.Buffer
+	if err := WriteProtectedSession(&buf, []*Frame{frame}, security); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "ana@example.com") || !strings.Contains(buf.String(), `"plan":"pro"`) {
+		t.Fatalf("expected only the designated fields to be encrypted:\n%s", buf.String())
+	}
+
+	frames, err := ReadSession(&buf)
+	if err != nil || len(frames) != 1 {
+		t.Fatalf("unexpected session: %v %v", frames, err)
+	}
+	revealed, err := security.Reveal(frames[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revealed.Args["email"] != "ana@example.com" || revealed.Error != "ana@example.com already registered" || revealed.Results[0] != revealed.Error {
+		t.Fatalf("unexpected revealed frame: %+v", revealed)
+	}
+
+	tampered := frames[0]
+	tampered.Args = map[string]string{"email": tampered.Args["email"], "plan": "free"}
+	if err := security.Verify(tampered); !errors.Is(err, ErrInvalidMAC) {
+		t.Fatalf("expected a tampered frame to fail verification, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package devtrace
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// builtinConfig is the configuration before any environment variables are applied
+var builtinConfig = DevTraceConfig{
+	StackLimit:  5,
+	ShowArgs:    true,
+	ShowTiming:  true,
+	ShowSnippet: 2,
+	AppPattern:  "/",
+	DebugLevel:  1,
+	SampleRate:  1,
+	MaxDepth:    1000,
+
+	MaxValueLen:   4096,
+	MaxValueDepth: 4,
+	MaxSliceElems: 100,
+	MaxMapEntries: 100,
+}
+
+// ConfigFromEnv returns the built-in defaults with these variables applied:
+//
+//	DEVTRACE_ENABLED            bool (also enabled by GO_ENV=development)
+//	DEVTRACE_STACK_LIMIT        int
+//	DEVTRACE_SHOW_ARGS          bool
+//	DEVTRACE_SHOW_TIMING        bool
+//	DEVTRACE_SHOW_SNIPPET       int
+//	DEVTRACE_APP_PATTERN        string
+//	DEVTRACE_DEBUG_LEVEL        int
+//	DEVTRACE_SAMPLE_RATE        float in [0, 1]
+//	DEVTRACE_MAX_DEPTH          int
+//	DEVTRACE_SLOW_THRESHOLD     duration, e.g. 250ms
+//	DEVTRACE_CAPTURE_INVOCATION bool
+//	DEVTRACE_CAPTURE_ENV        comma-separated variable names
+//	DEVTRACE_POOL_FRAMES        bool
+//	DEVTRACE_PPROF_LABELS       bool
+//	DEVTRACE_MAX_VALUE_LEN      int
+//	DEVTRACE_MAX_VALUE_DEPTH    int
+//	DEVTRACE_MAX_SLICE_ELEMS    int
+//	DEVTRACE_MAX_MAP_ENTRIES    int
+//	DEVTRACE_SNAPSHOT_ARGS      bool
+//	DEVTRACE_LOG_ERRORS         bool
+//
+// Invalid values are reported through GlobalLogger and leave the default in place.
+func ConfigFromEnv() DevTraceConfig {
+	cfg := builtinConfig
+
+	enabled, _ := strconv.ParseBool(os.Getenv("DEVTRACE_ENABLED"))
+	cfg.Enabled = enabled || strings.ToLower(os.Getenv("GO_ENV")) == "development"
+
+	envInt("DEVTRACE_STACK_LIMIT", &cfg.StackLimit)
+	envBool("DEVTRACE_SHOW_ARGS", &cfg.ShowArgs)
+	envBool("DEVTRACE_SHOW_TIMING", &cfg.ShowTiming)
+	envInt("DEVTRACE_SHOW_SNIPPET", &cfg.ShowSnippet)
+	if value, ok := os.LookupEnv("DEVTRACE_APP_PATTERN"); ok {
+		cfg.AppPattern = value
+	}
+	envInt("DEVTRACE_DEBUG_LEVEL", &cfg.DebugLevel)
+	envInt("DEVTRACE_MAX_DEPTH", &cfg.MaxDepth)
+	envBool("DEVTRACE_CAPTURE_INVOCATION", &cfg.CaptureInvocation)
+	envBool("DEVTRACE_POOL_FRAMES", &cfg.PoolFrames)
+	envBool("DEVTRACE_PPROF_LABELS", &cfg.PprofLabels)
+	envInt("DEVTRACE_MAX_VALUE_LEN", &cfg.MaxValueLen)
+	envInt("DEVTRACE_MAX_VALUE_DEPTH", &cfg.MaxValueDepth)
+	envInt("DEVTRACE_MAX_SLICE_ELEMS", &cfg.MaxSliceElems)
+	envInt("DEVTRACE_MAX_MAP_ENTRIES", &cfg.MaxMapEntries)
+	envBool("DEVTRACE_SNAPSHOT_ARGS", &cfg.SnapshotArgs)
+	envBool("DEVTRACE_LOG_ERRORS", &cfg.LogErrors)
+
+	if raw, ok := lookupEnv("DEVTRACE_SAMPLE_RATE"); ok {
+		if rate, err := strconv.ParseFloat(raw, 64); err == nil && rate >= 0 && rate <= 1 {
+			cfg.SampleRate = rate
+		} else {
+			warnInvalidEnv("DEVTRACE_SAMPLE_RATE", raw)
+		}
+	}
+
+	if raw, ok := lookupEnv("DEVTRACE_SLOW_THRESHOLD"); ok {
+		if threshold, err := time.ParseDuration(raw); err == nil {
+			cfg.SlowThreshold = threshold
+		} else {
+			warnInvalidEnv("DEVTRACE_SLOW_THRESHOLD", raw)
+		}
+	}
+
+	if raw, ok := lookupEnv("DEVTRACE_CAPTURE_ENV"); ok {
+		for _, name := range strings.Split(raw, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.CaptureEnvVars = append(cfg.CaptureEnvVars, name)
+			}
+		}
+	}
+
+	return cfg
+}
+
+// lookupEnv returns the trimmed value of a variable that is set and non-empty
+func lookupEnv(name string) (string, bool) {
+	value := strings.TrimSpace(os.Getenv(name))
+	return value, value != ""
+}
+
+func envBool(name string, target *bool) {
+	raw, ok := lookupEnv(name)
+	if !ok {
+		return
+	}
+	if value, err := strconv.ParseBool(raw); err == nil {
+		*target = value
+	} else {
+		warnInvalidEnv(name, raw)
+	}
+}
+
+func envInt(name string, target *int) {
+	raw, ok := lookupEnv(name)
+	if !ok {
+		return
+	}
+	if value, err := strconv.Atoi(raw); err == nil {
+		*target = value
+	} else {
+		warnInvalidEnv(name, raw)
+	}
+}
+
+func warnInvalidEnv(name, value string) {
+	if GlobalLogger != nil {
+		GlobalLogger.Warn("ignoring invalid %s=%q", name, value)
+	}
+}
@@ -0,0 +1,39 @@
+package devtrace
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	originalLogger := GlobalLogger
+	t.Cleanup(func() { GlobalLogger = originalLogger })
+	logger := &captureLogger{}
+	GlobalLogger = logger
+
+	t.Setenv("DEVTRACE_ENABLED", "1")
+	t.Setenv("DEVTRACE_STACK_LIMIT", "12")
+	t.Setenv("DEVTRACE_SHOW_ARGS", "false")
+	t.Setenv("DEVTRACE_APP_PATTERN", "github.com/acme/")
+	t.Setenv("DEVTRACE_SAMPLE_RATE", "0.1")
+	t.Setenv("DEVTRACE_SLOW_THRESHOLD", "250ms")
+	t.Setenv("DEVTRACE_CAPTURE_ENV", "HOSTNAME, REGION")
+	t.Setenv("DEVTRACE_DEBUG_LEVEL", "loud")
+
+	cfg := ConfigFromEnv()
+	if !cfg.Enabled || cfg.StackLimit != 12 || cfg.ShowArgs || cfg.AppPattern != "github.com/acme/" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if cfg.SampleRate != 0.1 || cfg.SlowThreshold != 250*time.Millisecond {
+		t.Fatalf("unexpected sampling/slow settings: %+v", cfg)
+	}
+	if len(cfg.CaptureEnvVars) != 2 || cfg.CaptureEnvVars[1] != "REGION" {
+		t.Fatalf("unexpected CaptureEnvVars: %v", cfg.CaptureEnvVars)
+	}
+	if cfg.DebugLevel != builtinConfig.DebugLevel {
+		t.Fatalf("invalid DEVTRACE_DEBUG_LEVEL should keep the default, got %d", cfg.DebugLevel)
+	}
+	if len(logger.messages) == 0 {
+		t.Fatalf("expected a warning for the invalid value")
+	}
+}
@@ -0,0 +1,130 @@
+package devtrace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// TracedError is an error that carries the traced call stack, args included,
+// of the place it was created. Frames are snapshotted then, so they stay valid
+// after the calls return. Format it with %+v, or log it with
+// EnhancedLogger.Error, to see the stack.
+type TracedError struct {
+	msg string
+	err error
+
+	// TraceID is the trace the error was created in
+	TraceID string
+	// Frames are the traced calls open when the error was created, outermost
+	// first; empty when an error it wraps already carries them
+	Frames []FrameSnapshot
+}
+
+// Wrap annotates err with msg and the current goroutine's traced call stack.
+// It returns nil when err is nil.
+func Wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return newTracedError(CurrentContext(), msg, err)
+}
+
+// WrapContext is Wrap for the trace context carried by ctx
+func WrapContext(ctx context.Context, err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return newTracedError(FromContext(ctx), msg, err)
+}
+
+// Errorf formats an error like fmt.Errorf, %w included, and attaches the
+// current goroutine's traced call stack
+func Errorf(format string, args ...interface{}) error {
+	return newTracedError(CurrentContext(), "", fmt.Errorf(format, args...))
+}
+
+func newTracedError(tc *TraceContext, msg string, err error) *TracedError {
+	e := &TracedError{msg: msg, err: err}
+	if !IsEnabled() || tc == nil || len(ErrorFrames(err)) > 0 {
+		return e
+	}
+
+	e.TraceID = tc.TraceID
+	e.Frames = make([]FrameSnapshot, 0, len(tc.Frames))
+	for _, frame := range tc.Frames {
+		e.Frames = append(e.Frames, SnapshotFrame(frame))
+	}
+	return e
+}
+
+func (e *TracedError) Error() string {
+	if e.msg == "" {
+		return e.err.Error()
+	}
+	return e.msg + ": " + e.err.Error()
+}
+
+// Unwrap returns the wrapped error, so errors.Is and errors.As see through it
+func (e *TracedError) Unwrap() error {
+	return e.err
+}
+
+// Format writes the message for %s and %v, and adds the captured stack for %+v
+func (e *TracedError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		io.WriteString(s, e.Error())
+		if s.Flag('+') {
+			if stack := ErrorStack(e); stack != "" {
+				io.WriteString(s, "\n"+stack)
+			}
+		}
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// ErrorFrames returns the frames captured by the innermost TracedError in the
+// chain of err that has any, or nil
+func ErrorFrames(err error) []FrameSnapshot {
+	var frames []FrameSnapshot
+	for err != nil {
+		var traced *TracedError
+		if !errors.As(err, &traced) {
+			break
+		}
+		if len(traced.Frames) > 0 {
+			frames = traced.Frames
+		}
+		err = traced.err
+	}
+	return frames
+}
+
+// ErrorStack renders the frames ErrorFrames finds in err as the stack logger
+// prints frames, or "" when there are none
+func ErrorStack(err error) string {
+	frames := ErrorFrames(err)
+	lines := make([]string, 0, 2*len(frames))
+	for i, frame := range frames {
+		name := frame.Signature
+		if name == "" {
+			name = frame.Function
+		}
+		lines = append(lines, fmt.Sprintf("  %d. %s:%d → %s", i+1, filepath.Base(frame.File), frame.Line, name))
+		if len(frame.Args) > 0 {
+			vars := NewDebugVars(make(map[string]interface{}, len(frame.Args)))
+			for name, value := range frame.Args {
+				vars.Vars[name] = value
+			}
+			lines = append(lines, "     Vars: "+vars.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,57 @@
+package devtrace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWrapCapturesFramesAndUnwraps(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	tc := NewTraceContext()
+	ctx := WithTraceContext(context.Background(), tc)
+	EnterContext(ctx, CreateFrame("pkg.Handle", "", "handle.go", 3, map[string]interface{}{"user": 42}))
+	EnterContext(ctx, CreateFrame("pkg.Load", "", "load.go", 9, map[string]interface{}{"path": "/tmp/x"}))
+	err := WrapContext(ctx, io.ErrUnexpectedEOF, "loading profile")
+	LeaveContext(ctx)
+	LeaveContext(ctx)
+
+	wrapped := fmt.Errorf("request failed: %w", err)
+	if !errors.Is(wrapped, io.ErrUnexpectedEOF) {
+		t.Fatal("expected errors.Is to see the wrapped error")
+	}
+	var traced *TracedError
+	if !errors.As(wrapped, &traced) || len(traced.Frames) != 2 || traced.Frames[1].Function != "pkg.Load" {
+		t.Fatalf("expected both frames on the error, got %+v", traced)
+	}
+
+	if got := fmt.Sprintf("%v", err); got != "loading profile: unexpected EOF" {
+		t.Fatalf("%%v = %q", got)
+	}
+	detailed := fmt.Sprintf("%+v", err)
+	if !strings.Contains(detailed, "2. load.go:9 → pkg.Load") || !strings.Contains(detailed, `"path": /tmp/x`) {
+		t.Fatalf("%%+v lacks the captured stack: %s", detailed)
+	}
+
+	outer := Wrap(err, "outer")
+	if got := ErrorFrames(outer); len(got) != 2 || got[0].Function != "pkg.Handle" {
+		t.Fatalf("expected the innermost frames to be kept, got %+v", got)
+	}
+	logger := &captureLogger{}
+	stackLogger := NewEnhancedLogger(&StackLoggerOptions{})
+	stackLogger.SetLogger(logger)
+	stackLogger.Error(ctx, "profile: %v", outer)
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "Error stack:\n  1. handle.go:3 → pkg.Handle") {
+		t.Fatalf("expected the logger to print the error's stack, got %q", logger.messages)
+	}
+
+	if Wrap(nil, "nothing") != nil {
+		t.Fatal("expected Wrap(nil) to be nil")
+	}
+}
@@ -0,0 +1,60 @@
+package devtrace
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// EventStats counts the tracepoint events emitted under one name
+type EventStats struct {
+	Name  string    `json:"name"`
+	Count int64     `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// Emit records that something named name happened, for things worth tracing
+// that are not function calls:
+//
+//	devtrace.Emit(ctx, "cache.miss", devtrace.Attrs{"key": key})
+//
+// The event is attached to the current frame of ctx, where stack output,
+// sessions and exporters show it like a span event, and counted in
+// AllEventStats even when no frame is open. vars are redacted like args.
+func Emit(ctx context.Context, name string, vars Attrs) {
+	if !ConfigFromContext(ctx).Enabled {
+		return
+	}
+
+	if frame := FromContext(ctx).current(); frame != nil {
+		addFrameEvent(frame, name, vars)
+	}
+
+	functionStatsMu.Lock()
+	defer functionStatsMu.Unlock()
+	stats, ok := eventStats[name]
+	if !ok {
+		stats = &EventStats{Name: name}
+		eventStats[name] = stats
+	}
+	stats.Count++
+	stats.Last = time.Now()
+}
+
+// AllEventStats returns the counts of every event name emitted, most frequent first
+func AllEventStats() []EventStats {
+	functionStatsMu.Lock()
+	all := make([]EventStats, 0, len(eventStats))
+	for _, stats := range eventStats {
+		all = append(all, *stats)
+	}
+	functionStatsMu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Count == all[j].Count {
+			return all[i].Name < all[j].Name
+		}
+		return all[i].Count > all[j].Count
+	})
+	return all
+}
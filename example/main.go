@@ -96,7 +96,7 @@ func main() {
 	fmt.Println("===================================")
 
 	// Initialize devtrace with development settings
-	devtrace.SetConfig(devtrace.DevTraceConfig{
+	if err := devtrace.SetConfig(devtrace.DevTraceConfig{
 		Enabled:     true,
 		StackLimit:  10,
 		ShowArgs:    true,
@@ -104,10 +104,12 @@ func main() {
 		ShowSnippet: 3,
 		AppPattern:  "gotrace/example",
 		DebugLevel:  2,
-	})
+	}); err != nil {
+		log.Fatal(err)
+	}
 
 	// Install enhanced stack logger
-	devtrace.InstallStackLogger(&devtrace.StackLoggerOptions{
+	if err := devtrace.InstallStackLogger(&devtrace.StackLoggerOptions{
 		Prefix:      "📞 CALL STACK",
 		Skip:        2,
 		Limit:       8,
@@ -117,7 +119,9 @@ func main() {
 		AppPattern:  "gotrace/example",
 		ShowMeta:    true,
 		Ascending:   true,
-	})
+	}); err != nil {
+		log.Fatal(err)
+	}
 	devtrace.RedirectStandardLogger()
 
 	fmt.Println("\n1. Testing Manual Function Tracing")
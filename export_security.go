@@ -0,0 +1,234 @@
+package devtrace
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// encryptedFieldPrefix marks a field value sealed by ExportSecurity
+const encryptedFieldPrefix = "enc:"
+
+// Field names that designate a frame's results and error in EncryptFields
+const (
+	ExportFieldResults = "results"
+	ExportFieldError   = "error"
+)
+
+// ErrInvalidMAC is returned by Verify when a frame's signature doesn't match
+var ErrInvalidMAC = errors.New("devtrace: frame signature mismatch")
+
+// ExportSecurity protects frames a sink ships to shared infrastructure such as
+// a collector other teams can read. Each sink has its own settings, so a local
+// session file can stay plain while the network export is signed and encrypted:
+//
+//	security := &devtrace.ExportSecurity{
+//		SigningKey:    key,
+//		EncryptFields: []string{"email", "*address*", devtrace.ExportFieldResults},
+//	}
+//	devtrace.WriteProtectedSession(conn, frames, security)
+//
+// Fields are encrypted first and the frame is signed last, so the signature
+// also covers the ciphertexts.
+type ExportSecurity struct {
+	SigningKey    []byte      // HMAC-SHA256 key; frames carry no MAC when empty
+	EncryptFields []string    // case-insensitive globs of arg names, plus ExportFieldResults and ExportFieldError
+	Keys          KeyProvider // AES key for EncryptFields; nil uses the key set with SetKeyProvider
+}
+
+// Protect returns a copy of snapshot with the designated fields encrypted and a
+// MAC over the result
+func (s *ExportSecurity) Protect(snapshot FrameSnapshot) (FrameSnapshot, error) {
+	if s == nil {
+		return snapshot, nil
+	}
+	snapshot.MAC = ""
+
+	if len(s.EncryptFields) > 0 {
+		seal, err := s.fieldCipher(snapshot.Function, true)
+		if err != nil {
+			return snapshot, err
+		}
+		if err := s.transformFields(&snapshot, seal); err != nil {
+			return snapshot, err
+		}
+	}
+
+	if len(s.SigningKey) > 0 {
+		mac, err := s.mac(snapshot)
+		if err != nil {
+			return snapshot, err
+		}
+		snapshot.MAC = mac
+	}
+	return snapshot, nil
+}
+
+// Verify checks the MAC Protect put on snapshot
+func (s *ExportSecurity) Verify(snapshot FrameSnapshot) error {
+	if s == nil || len(s.SigningKey) == 0 {
+		return nil
+	}
+	got, err := hex.DecodeString(snapshot.MAC)
+	if err != nil || snapshot.MAC == "" {
+		return ErrInvalidMAC
+	}
+	want, err := s.mac(snapshot)
+	if err != nil {
+		return err
+	}
+	wantBytes, _ := hex.DecodeString(want)
+	if !hmac.Equal(got, wantBytes) {
+		return ErrInvalidMAC
+	}
+	return nil
+}
+
+// Reveal verifies snapshot and returns it with its encrypted fields decrypted
+func (s *ExportSecurity) Reveal(snapshot FrameSnapshot) (FrameSnapshot, error) {
+	if s == nil {
+		return snapshot, nil
+	}
+	if err := s.Verify(snapshot); err != nil {
+		return snapshot, err
+	}
+	snapshot.MAC = ""
+	if len(s.EncryptFields) == 0 {
+		return snapshot, nil
+	}
+
+	open, err := s.fieldCipher(snapshot.Function, false)
+	if err != nil {
+		return snapshot, err
+	}
+	err = s.transformFields(&snapshot, func(field, value string) (string, error) {
+		if !strings.HasPrefix(value, encryptedFieldPrefix) {
+			return value, nil
+		}
+		return open(field, value)
+	})
+	return snapshot, err
+}
+
+// WriteProtectedSession is WriteSession with every frame passed through
+// security.Protect
+func WriteProtectedSession(w io.Writer, frames []*Frame, security *ExportSecurity) error {
+	enc := json.NewEncoder(w)
+	for _, frame := range frames {
+		if frame == nil {
+			continue
+		}
+		protected, err := security.Protect(SnapshotFrame(frame))
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(protected); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ExportSecurity) mac(snapshot FrameSnapshot) (string, error) {
+	snapshot.MAC = ""
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", err
+	}
+	h := hmac.New(sha256.New, s.SigningKey)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// transformFields applies fn to every designated field of snapshot, copying
+// the args and results so the caller's snapshot is left alone
+func (s *ExportSecurity) transformFields(snapshot *FrameSnapshot, fn func(field, value string) (string, error)) error {
+	if len(snapshot.Args) > 0 {
+		args := make(map[string]string, len(snapshot.Args))
+		for name, value := range snapshot.Args {
+			if s.designates(name) {
+				var err error
+				if value, err = fn(name, value); err != nil {
+					return err
+				}
+			}
+			args[name] = value
+		}
+		snapshot.Args = args
+	}
+
+	if len(snapshot.Results) > 0 && s.designates(ExportFieldResults) {
+		results := make([]string, len(snapshot.Results))
+		for i, value := range snapshot.Results {
+			var err error
+			if results[i], err = fn(ExportFieldResults, value); err != nil {
+				return err
+			}
+		}
+		snapshot.Results = results
+	}
+
+	if snapshot.Error != "" && s.designates(ExportFieldError) {
+		var err error
+		if snapshot.Error, err = fn(ExportFieldError, snapshot.Error); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ExportSecurity) designates(name string) bool {
+	lower := strings.ToLower(name)
+	for _, pattern := range s.EncryptFields {
+		if ok, _ := path.Match(strings.ToLower(pattern), lower); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldCipher returns a function sealing (or opening) one field value with
+// AES-GCM. The function and field names are authenticated with it, so a
+// ciphertext can't be moved to another field or frame unnoticed.
+func (s *ExportSecurity) fieldCipher(function string, seal bool) (func(field, value string) (string, error), error) {
+	provider := s.Keys
+	if provider == nil {
+		keyProviderMu.RLock()
+		provider = keyProvider
+		keyProviderMu.RUnlock()
+	}
+	aead, err := providerAEAD(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	if seal {
+		return func(field, value string) (string, error) {
+			nonce := make([]byte, aead.NonceSize())
+			if _, err := rand.Read(nonce); err != nil {
+				return "", err
+			}
+			sealed := aead.Seal(nonce, nonce, []byte(value), []byte(function+"\x00"+field))
+			return encryptedFieldPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+		}, nil
+	}
+	return func(field, value string) (string, error) {
+		sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedFieldPrefix))
+		if err != nil || len(sealed) < aead.NonceSize() {
+			return "", fmt.Errorf("devtrace: invalid encrypted field %s", field)
+		}
+		plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(function+"\x00"+field))
+		if err != nil {
+			return "", fmt.Errorf("devtrace: decrypting field %s: %v", field, err)
+		}
+		return string(plain), nil
+	}, nil
+}
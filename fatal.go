@@ -0,0 +1,98 @@
+package devtrace
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// exit ends the process after a fatal log; tests replace it
+var exit = os.Exit
+
+// PrintWithStack is log.Print with a stack trace: the operands are formatted
+// as fmt.Sprint does and logged at INFO through GlobalEnhancedLogger.
+// gotrace-instrument rewrites log.Print calls into it.
+func PrintWithStack(ctx context.Context, v ...interface{}) {
+	logWithStack(ctx, "INFO", fmt.Sprint(v...))
+}
+
+// PrintfWithStack is log.Printf with a stack trace
+func PrintfWithStack(ctx context.Context, format string, v ...interface{}) {
+	logWithStack(ctx, "INFO", fmt.Sprintf(format, v...))
+}
+
+// PrintlnWithStack is log.Println with a stack trace
+func PrintlnWithStack(ctx context.Context, v ...interface{}) {
+	logWithStack(ctx, "INFO", sprintln(v))
+}
+
+// FatalWithStack is log.Fatal with a stack trace: the operands are formatted
+// as fmt.Sprint does, logged at ERROR through GlobalEnhancedLogger, and the
+// process exits with status 1. gotrace-instrument rewrites log.Fatal calls
+// into it.
+func FatalWithStack(ctx context.Context, v ...interface{}) {
+	logWithStack(ctx, "ERROR", fmt.Sprint(v...))
+	exit(1)
+}
+
+// FatalfWithStack is log.Fatalf with a stack trace
+func FatalfWithStack(ctx context.Context, format string, v ...interface{}) {
+	logWithStack(ctx, "ERROR", fmt.Sprintf(format, v...))
+	exit(1)
+}
+
+// FatallnWithStack is log.Fatalln with a stack trace
+func FatallnWithStack(ctx context.Context, v ...interface{}) {
+	logWithStack(ctx, "ERROR", sprintln(v))
+	exit(1)
+}
+
+// PanicWithStack is log.Panic with a stack trace: the message is logged at
+// ERROR and then panicked with, so recover sees the same value log.Panic
+// would give it
+func PanicWithStack(ctx context.Context, v ...interface{}) {
+	s := fmt.Sprint(v...)
+	logWithStack(ctx, "ERROR", s)
+	panic(s)
+}
+
+// PanicfWithStack is log.Panicf with a stack trace
+func PanicfWithStack(ctx context.Context, format string, v ...interface{}) {
+	s := fmt.Sprintf(format, v...)
+	logWithStack(ctx, "ERROR", s)
+	panic(s)
+}
+
+// PaniclnWithStack is log.Panicln with a stack trace
+func PaniclnWithStack(ctx context.Context, v ...interface{}) {
+	s := fmt.Sprintln(v...)
+	logWithStack(ctx, "ERROR", s[:len(s)-1])
+	panic(s)
+}
+
+// sprintln formats like fmt.Sprintln without the newline, which log drops too
+func sprintln(v []interface{}) string {
+	s := fmt.Sprintln(v...)
+	return s[:len(s)-1]
+}
+
+// logWithStack logs message as given, skipping the helpers' frames in runtime
+// stacks. Without an enhanced logger the message still reaches GlobalLogger
+// or stderr, as it would have with the log package.
+func logWithStack(ctx context.Context, level, message string) {
+	if GlobalEnhancedLogger == nil {
+		switch {
+		case GlobalLogger == nil:
+			fmt.Fprintln(os.Stderr, message)
+		case level == "ERROR":
+			GlobalLogger.Error("%s", message)
+		default:
+			GlobalLogger.Info("%s", message)
+		}
+		return
+	}
+
+	el := *GlobalEnhancedLogger
+	el.options.Skip += 2
+	el.LogWithStack(ctx, level, "%s", message)
+}
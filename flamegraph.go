@@ -0,0 +1,238 @@
+package devtrace
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FlameNode is one call path in a flame graph. Value is the total time spent in
+// frames with exactly this path, children included.
+type FlameNode struct {
+	Name     string         `json:"name"`
+	Value    time.Duration  `json:"value"`
+	Calls    int            `json:"calls"`
+	Errors   int            `json:"errors,omitempty"`
+	Sample   *FrameSnapshot `json:"sample,omitempty"` // the most recent frame on this path
+	Children []*FlameNode   `json:"children,omitempty"`
+}
+
+// BuildFlameGraph merges recorded frames into a call tree keyed by their paths.
+// Frames serving an operation are grouped under a top-level node named after
+// it. Records are expected newest first, as returned by RecentTraces.
+func BuildFlameGraph(records []RecordedFrame) *FlameNode {
+	root := &FlameNode{Name: "all"}
+	index := make(map[*FlameNode]map[string]*FlameNode)
+
+	for _, record := range records {
+		if record.Frame == nil || len(record.Path) == 0 {
+			continue
+		}
+
+		path := record.Path
+		if op := record.Frame.Operation; op != "" {
+			path = append([]string{op}, path...)
+		}
+
+		node := root
+		for _, name := range path {
+			children := index[node]
+			if children == nil {
+				children = make(map[string]*FlameNode)
+				index[node] = children
+			}
+			child, ok := children[name]
+			if !ok {
+				child = &FlameNode{Name: name}
+				children[name] = child
+				node.Children = append(node.Children, child)
+			}
+			node = child
+		}
+
+		node.Value += record.Frame.Duration
+		node.Calls++
+		if FrameError(record.Frame) != nil {
+			node.Errors++
+		}
+		if node.Sample == nil {
+			snapshot := SnapshotFrame(record.Frame)
+			node.Sample = &snapshot
+		}
+	}
+
+	root.settle()
+	return root
+}
+
+// settle makes every node at least as wide as its children, since ancestors
+// may have been evicted from the recorder or still be running, and sorts
+// children widest first
+func (n *FlameNode) settle() time.Duration {
+	var childTotal time.Duration
+	for _, child := range n.Children {
+		childTotal += child.settle()
+	}
+	if n.Value < childTotal {
+		n.Value = childTotal
+	}
+	sort.SliceStable(n.Children, func(i, j int) bool { return n.Children[i].Value > n.Children[j].Value })
+	return n.Value
+}
+
+// SessionFlameGraph is BuildFlameGraph for a stored session: frames are nested
+// by time as in ExportMarkdown, since sessions don't record paths
+func SessionFlameGraph(session *Session) *FlameNode {
+	root := &FlameNode{Name: "all"}
+	if session == nil {
+		return root
+	}
+	index := make(map[*FlameNode]map[string]*FlameNode)
+	child := func(parent *FlameNode, name string) *FlameNode {
+		children := index[parent]
+		if children == nil {
+			children = make(map[string]*FlameNode)
+			index[parent] = children
+		}
+		node, ok := children[name]
+		if !ok {
+			node = &FlameNode{Name: name}
+			children[name] = node
+			parent.Children = append(parent.Children, node)
+		}
+		return node
+	}
+
+	var add func(parent *FlameNode, call *callNode)
+	add = func(parent *FlameNode, call *callNode) {
+		node := child(parent, call.frame.Function)
+		node.Value += call.frame.Duration
+		node.Calls++
+		if call.frame.Error != "" {
+			node.Errors++
+		}
+		if node.Sample == nil || call.frame.StartTime.After(node.Sample.StartTime) {
+			node.Sample = call.frame
+		}
+		for _, c := range call.children {
+			add(node, c)
+		}
+	}
+	for _, call := range buildCallTree(session.Frames) {
+		parent := root
+		if op := call.frame.Operation; op != "" {
+			parent = child(root, op)
+		}
+		add(parent, call)
+	}
+
+	root.settle()
+	return root
+}
+
+// WriteFoldedStacks writes a flame graph in the folded format of flamegraph.pl
+// and speedscope: one "outer;inner microseconds" line per path, counting only
+// the time spent in the innermost frame itself
+func WriteFoldedStacks(w io.Writer, root *FlameNode) error {
+	var b strings.Builder
+	var walk func(node *FlameNode, path string)
+	walk = func(node *FlameNode, path string) {
+		self := node.Value
+		for _, child := range node.Children {
+			self -= child.Value
+		}
+		if path != "" && self > 0 {
+			fmt.Fprintf(&b, "%s %d\n", path, self.Microseconds())
+		}
+		for _, child := range node.Children {
+			name := strings.ReplaceAll(child.Name, ";", ":")
+			if path != "" {
+				name = path + ";" + name
+			}
+			walk(child, name)
+		}
+	}
+	if root != nil {
+		walk(root, "")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Layout of WriteFlameSVG
+const (
+	flameSVGWidth     = 1200
+	flameSVGRowHeight = 18
+	flameSVGMinWidth  = 0.5 // narrower frames are left out
+	flameSVGCharWidth = 7   // approximate width of a label character
+)
+
+// WriteFlameSVG renders a flame graph as a static SVG, widest frames at the
+// bottom; hovering a frame shows its calls and total time. Frames with errors
+// are drawn in red.
+func WriteFlameSVG(w io.Writer, root *FlameNode) error {
+	depth := flameDepth(root)
+	height := (depth+1)*flameSVGRowHeight + 10
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="monospace" font-size="12">`+"\n", flameSVGWidth, height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#fafafa"/>`+"\n")
+	if root != nil && root.Value > 0 {
+		scale := float64(flameSVGWidth-20) / float64(root.Value)
+		var draw func(node *FlameNode, x float64, level int)
+		draw = func(node *FlameNode, x float64, level int) {
+			width := float64(node.Value) * scale
+			if width < flameSVGMinWidth {
+				return
+			}
+			y := height - (level+1)*flameSVGRowHeight - 5
+			fmt.Fprintf(&b, `<g><title>%s (%s, %s)</title><rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s" rx="2"/>`,
+				html.EscapeString(node.Name), callCount(node.Calls), node.Value.Round(time.Microsecond), x, y, width, flameSVGRowHeight-1, flameColor(node))
+			if chars := int(width / flameSVGCharWidth); chars > 2 {
+				label := []rune(node.Name)
+				if len(label) > chars {
+					label = append(label[:chars-2], '.', '.')
+				}
+				fmt.Fprintf(&b, `<text x="%.1f" y="%d">%s</text>`, x+3, y+flameSVGRowHeight-5, html.EscapeString(string(label)))
+			}
+			b.WriteString("</g>\n")
+			for _, child := range node.Children {
+				draw(child, x, level+1)
+				x += float64(child.Value) * scale
+			}
+		}
+		draw(root, 10, 0)
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func flameDepth(node *FlameNode) int {
+	if node == nil {
+		return 0
+	}
+	deepest := 0
+	for _, child := range node.Children {
+		if d := flameDepth(child) + 1; d > deepest {
+			deepest = d
+		}
+	}
+	return deepest
+}
+
+// flameColor picks a warm color from the node's name so the same function has
+// the same color across graphs
+func flameColor(node *FlameNode) string {
+	if node.Errors > 0 {
+		return "rgb(230,70,60)"
+	}
+	h := fnv.New32a()
+	h.Write([]byte(node.Name))
+	sum := h.Sum32()
+	return fmt.Sprintf("rgb(%d,%d,%d)", 205+sum%50, 100+(sum>>8)%130, 40+(sum>>16)%50)
+}
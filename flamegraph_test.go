@@ -0,0 +1,78 @@
+package devtrace
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildFlameGraph(t *testing.T) {
+	records := []RecordedFrame{
+		{Frame: &Frame{Function: "handler", Duration: 12 * time.Millisecond}, Path: []string{"handler"}},
+		{Frame: &Frame{Function: "query", Duration: 6 * time.Millisecond, Results: []interface{}{errors.New("timeout")}}, Path: []string{"handler", "query"}},
+		{Frame: &Frame{Function: "render", Duration: 3 * time.Millisecond}, Path: []string{"handler", "render"}},
+		{Frame: &Frame{Function: "query", Duration: 2 * time.Millisecond}, Path: []string{"handler", "query"}},
+		// parent evicted from the recorder: the node must still cover its child
+		{Frame: &Frame{Function: "flush", Duration: 4 * time.Millisecond}, Path: []string{"worker", "flush"}},
+	}
+
+	root := BuildFlameGraph(records)
+	if root.Value != 16*time.Millisecond || len(root.Children) != 2 {
+		t.Fatalf("unexpected root: %+v", root)
+	}
+
+	handler := root.Children[0]
+	if handler.Name != "handler" || handler.Children[0].Name != "query" {
+		t.Fatalf("children should be sorted widest first: %+v", handler)
+	}
+	query := handler.Children[0]
+	if query.Calls != 2 || query.Errors != 1 || query.Value != 8*time.Millisecond {
+		t.Fatalf("unexpected query node: %+v", query)
+	}
+	if query.Sample == nil || query.Sample.Error != "timeout" {
+		t.Fatalf("sample should be the first (newest) record: %+v", query.Sample)
+	}
+
+	worker := root.Children[1]
+	if worker.Value != 4*time.Millisecond || worker.Calls != 0 {
+		t.Fatalf("evicted parent should be widened to its children: %+v", worker)
+	}
+}
+
+func TestSessionFlameGraphFoldedAndSVG(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	session := &Session{Frames: []FrameSnapshot{
+		{Function: "query", StartTime: start.Add(time.Millisecond), EndTime: start.Add(7 * time.Millisecond), Duration: 6 * time.Millisecond, Error: "timeout"},
+		{Function: "render", StartTime: start.Add(8 * time.Millisecond), EndTime: start.Add(11 * time.Millisecond), Duration: 3 * time.Millisecond},
+		{Function: "handler", StartTime: start, EndTime: start.Add(12 * time.Millisecond), Duration: 12 * time.Millisecond, Operation: "GET /a;b"},
+	}}
+
+	root := SessionFlameGraph(session)
+	if root.Value != 12*time.Millisecond || root.Children[0].Name != "GET /a;b" {
+		t.Fatalf("unexpected root: %+v", root)
+	}
+	handler := root.Children[0].Children[0]
+	if handler.Name != "handler" || len(handler.Children) != 2 || handler.Children[0].Errors != 1 {
+		t.Fatalf("unexpected handler node: %+v", handler)
+	}
+
+	var folded strings.Builder
+	if err := WriteFoldedStacks(&folded, root); err != nil {
+		t.Fatal(err)
+	}
+	want := "GET /a:b;handler 3000\nGET /a:b;handler;query 6000\nGET /a:b;handler;render 3000\n"
+	if folded.String() != want {
+		t.Fatalf("unexpected folded stacks:\n%s", folded.String())
+	}
+
+	var svg strings.Builder
+	if err := WriteFlameSVG(&svg, root); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<svg ", "<title>query (1 call, 6ms)</title>", "rgb(230,70,60)", "</svg>\n"} {
+		if !strings.Contains(svg.String(), want) {
+			t.Fatalf("expected %q in SVG:\n%s", want, svg.String())
+		}
+	}
+}
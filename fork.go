@@ -0,0 +1,88 @@
+package devtrace
+
+import (
+	"context"
+	"time"
+)
+
+// TraceFork carries a goroutine's trace into a goroutine it starts
+type TraceFork struct {
+	traceID   string
+	parent    string
+	operation string
+	baggage   map[string]string
+}
+
+// Fork captures the calling goroutine's trace. Call it before the go statement
+// and Adopt inside the new goroutine:
+//
+//	fork := devtrace.Fork()
+//	go func() {
+//		defer fork.Adopt()()
+//		work()
+//	}()
+//
+// gotrace-instrument generates this for go statements automatically.
+func Fork() *TraceFork {
+	return forkFrom(CurrentContext())
+}
+
+// ForkContext is Fork for the trace ctx carries, as set by WithTraceContext or
+// ExtractTraceHeaders, falling back to the calling goroutine's like FromContext.
+// gotrace-instrument uses it for go statements in functions that take a ctx.
+func ForkContext(ctx context.Context) *TraceFork {
+	return forkFrom(FromContext(ctx))
+}
+
+func forkFrom(tc *TraceContext) *TraceFork {
+	fork := &TraceFork{parent: tc.RemoteParent, operation: tc.operation(), baggage: tc.baggage}
+	if frame := tc.current(); frame != nil {
+		fork.traceID = tc.EnsureTraceID()
+		fork.parent = frame.Function
+	} else {
+		fork.traceID = tc.TraceID
+	}
+	return fork
+}
+
+// Adopt makes a child of the forked trace the calling goroutine's context. Frames
+// entered in this goroutine then share the parent's TraceID and show the spawning
+// frame at the start of their route. Call the returned function when the goroutine
+// finishes to restore its previous context.
+func (f *TraceFork) Adopt() func() {
+	if f == nil {
+		return func() {}
+	}
+
+	tc := &TraceContext{
+		Frames:       make([]*Frame, 0),
+		StartAt:      time.Now(),
+		TraceID:      f.traceID,
+		RemoteParent: f.parent,
+		Operation:    f.operation,
+		baggage:      f.baggage,
+		goroutine:    goroutineID(),
+		pinned:       true,
+	}
+	if tc.goroutine == 0 {
+		return func() {}
+	}
+
+	goroutineMu.Lock()
+	previous := goroutineContexts[tc.goroutine]
+	goroutineContexts[tc.goroutine] = tc
+	goroutineMu.Unlock()
+
+	return func() {
+		goroutineMu.Lock()
+		defer goroutineMu.Unlock()
+		if goroutineContexts[tc.goroutine] != tc {
+			return
+		}
+		if previous != nil {
+			goroutineContexts[tc.goroutine] = previous
+		} else {
+			delete(goroutineContexts, tc.goroutine)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package devtrace
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = make(map[reflect.Type]func(interface{}) string)
+)
+
+// RegisterFormatter renders every captured value of type T with format, at any
+// depth, instead of the default rendering
+func RegisterFormatter[T any](format func(T) string) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+
+	formatters[typ] = func(v interface{}) string {
+		return format(v.(T))
+	}
+}
+
+// ClearFormatters removes every formatter registered with RegisterFormatter
+func ClearFormatters() {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+
+	formatters = make(map[reflect.Type]func(interface{}) string)
+}
+
+// FormatValue renders a captured value the way frames and debug vars show it.
+// Registered formatters come first, then error, fmt.Stringer and
+// encoding.TextMarshaler; anything else is rendered like %+v within the
+// MaxValue* limits of Config.
+func FormatValue(value interface{}) string {
+	cfg := CurrentConfig()
+	formattersMu.RLock()
+	f := valueFormatter{
+		formatters: formatters,
+		maxLen:     cfg.MaxValueLen,
+		maxDepth:   cfg.MaxValueDepth,
+		maxElems:   cfg.MaxSliceElems,
+		maxEntries: cfg.MaxMapEntries,
+	}
+	formattersMu.RUnlock()
+
+	var b strings.Builder
+	f.write(&b, reflect.ValueOf(value), 0)
+	return f.truncate(b.String())
+}
+
+type valueFormatter struct {
+	formatters map[reflect.Type]func(interface{}) string
+	maxLen     int
+	maxDepth   int
+	maxElems   int
+	maxEntries int
+	stopped    bool // set once rendering stopped early at maxLen
+}
+
+// full reports whether enough has been written to be cut anyway
+func (f *valueFormatter) full(b *strings.Builder) bool {
+	if f.maxLen > 0 && b.Len() > f.maxLen {
+		f.stopped = true
+	}
+	return f.stopped
+}
+
+// truncate cuts the rendered text of a value whose rendering stopped early;
+// what was never rendered is unknown, so the marker gives the cut instead
+func (f *valueFormatter) truncate(s string) string {
+	if !f.stopped || len(s) <= f.maxLen {
+		return s
+	}
+	return clipString(s, f.maxLen) + fmt.Sprintf("...(cut at %d bytes)", f.maxLen)
+}
+
+// writeText writes s, cut at maxLen
+func (f *valueFormatter) writeText(b *strings.Builder, s string) {
+	if f.maxLen > 0 && len(s) > f.maxLen {
+		clipped := clipString(s, f.maxLen)
+		b.WriteString(clipped + cutMarker(len(s)-len(clipped), "bytes"))
+		return
+	}
+	b.WriteString(s)
+}
+
+// clipString returns at most n bytes of s without splitting a rune
+func clipString(s string, n int) string {
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// cutMarker says how many units were left out after an ellipsis
+func cutMarker(n int, unit string) string {
+	return fmt.Sprintf("...(+%d %s)", n, unit)
+}
+
+func (f *valueFormatter) write(b *strings.Builder, rv reflect.Value, depth int) {
+	if !rv.IsValid() {
+		b.WriteString("<nil>")
+		return
+	}
+	if (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && rv.IsNil() {
+		b.WriteString("<nil>")
+		return
+	}
+	if rv.Type() == clippedType && rv.CanInterface() {
+		c := rv.Interface().(clipped)
+		f.write(b, reflect.ValueOf(c.value), depth)
+		b.WriteString(cutMarker(c.cut, "bytes"))
+		return
+	}
+	if s, ok := f.custom(rv); ok {
+		f.writeText(b, s)
+		return
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		f.write(b, rv.Elem(), depth)
+	case reflect.Ptr:
+		switch rv.Elem().Kind() {
+		case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+			b.WriteByte('&')
+			f.write(b, rv.Elem(), depth)
+		default:
+			fmt.Fprintf(b, "%v", rv)
+		}
+	case reflect.String:
+		f.writeText(b, rv.String())
+	case reflect.Struct:
+		if f.maxDepth > 0 && depth >= f.maxDepth {
+			b.WriteString("{...}")
+			return
+		}
+		b.WriteByte('{')
+		for i := 0; i < rv.NumField() && !f.full(b); i++ {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(rv.Type().Field(i).Name)
+			b.WriteByte(':')
+			f.write(b, rv.Field(i), depth+1)
+		}
+		b.WriteByte('}')
+	case reflect.Slice, reflect.Array:
+		if f.maxDepth > 0 && depth >= f.maxDepth {
+			b.WriteString("[...]")
+			return
+		}
+		b.WriteByte('[')
+		n := rv.Len()
+		if f.maxElems > 0 && n > f.maxElems {
+			n = f.maxElems
+		}
+		i := 0
+		for ; i < n && !f.full(b); i++ {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			f.write(b, rv.Index(i), depth+1)
+		}
+		if i < rv.Len() {
+			b.WriteString(" " + cutMarker(rv.Len()-i, "elems"))
+		}
+		b.WriteByte(']')
+	case reflect.Map:
+		if f.maxDepth > 0 && depth >= f.maxDepth {
+			b.WriteString("map[...]")
+			return
+		}
+		b.WriteString("map[")
+		entries := f.mapEntries(rv, depth)
+		i := 0
+		for ; i < len(entries) && !f.full(b); i++ {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(entries[i][0])
+			b.WriteByte(':')
+			b.WriteString(entries[i][1])
+		}
+		if i < rv.Len() {
+			b.WriteString(" " + cutMarker(rv.Len()-i, "entries"))
+		}
+		b.WriteByte(']')
+	default:
+		fmt.Fprintf(b, "%+v", rv)
+	}
+}
+
+// mapEntries renders the keys and values of a map, sorted by key as fmt does,
+// up to MaxMapEntries of them
+func (f *valueFormatter) mapEntries(rv reflect.Value, depth int) [][2]string {
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	all := make([]entry, 0, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		var key strings.Builder
+		f.write(&key, iter.Key(), depth+1)
+		all = append(all, entry{key.String(), iter.Value()})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].key < all[j].key })
+	if f.maxEntries > 0 && len(all) > f.maxEntries {
+		all = all[:f.maxEntries]
+	}
+
+	entries := make([][2]string, len(all))
+	for i, e := range all {
+		var value strings.Builder
+		f.write(&value, e.value, depth+1)
+		entries[i] = [2]string{e.key, value.String()}
+	}
+	return entries
+}
+
+// custom renders values with a registered formatter or a method of their own;
+// a panicking formatter or method is reported in place of the value
+func (f *valueFormatter) custom(rv reflect.Value) (s string, ok bool) {
+	if !rv.CanInterface() {
+		return "", false
+	}
+	v := rv.Interface()
+	defer func() {
+		if r := recover(); r != nil {
+			s, ok = fmt.Sprintf("<panic formatting %T: %v>", v, r), true
+		}
+	}()
+
+	if format, found := f.formatters[rv.Type()]; found {
+		return format(v), true
+	}
+	switch v := v.(type) {
+	case *TracedError:
+		return v.Error(), true // its stack would repeat the frames around it
+	case error:
+		return fmt.Sprintf("%+v", v), true
+	case fmt.Stringer:
+		return v.String(), true
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		if err != nil {
+			return fmt.Sprintf("<%T: %v>", v, err), true
+		}
+		return string(text), true
+	}
+	return "", false
+}
+
+// clipped stands in for a captured string or byte slice longer than
+// Config.MaxValueLen, holding a copy of its start
+type clipped struct {
+	value interface{}
+	cut   int // bytes left out
+}
+
+var clippedType = reflect.TypeOf(clipped{})
+
+// MarshalJSON encodes the kept start with its marker, as FormatValue renders it
+func (c clipped) MarshalJSON() ([]byte, error) {
+	return json.Marshal(FormatValue(c))
+}
+
+// clipCaptured cuts a string or byte slice longer than maxLen, copying what is
+// kept so the frame does not hold the original alive
+func clipCaptured(value interface{}, maxLen int) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		if len(v) > maxLen {
+			kept := strings.Clone(clipString(v, maxLen))
+			return clipped{value: kept, cut: len(v) - len(kept)}, true
+		}
+	case []byte:
+		if len(v) > maxLen {
+			return clipped{value: append([]byte(nil), v[:maxLen]...), cut: len(v) - maxLen}, true
+		}
+	}
+	return value, false
+}
+
+// clipArgs applies clipCaptured to every entry of an args map in place
+func clipArgs(args map[string]interface{}, maxLen int) {
+	if maxLen <= 0 {
+		return
+	}
+	for name, value := range args {
+		if clipped, changed := clipCaptured(value, maxLen); changed {
+			args[name] = clipped
+		}
+	}
+}
+
+// clipResults returns results with clipCaptured applied, copying the slice
+// only when something was cut since callers may still use it
+func clipResults(results []interface{}, maxLen int) []interface{} {
+	if maxLen <= 0 {
+		return results
+	}
+	var copied []interface{}
+	for i, value := range results {
+		if clipped, changed := clipCaptured(value, maxLen); changed {
+			if copied == nil {
+				copied = append([]interface{}(nil), results...)
+			}
+			copied[i] = clipped
+		}
+	}
+	if copied == nil {
+		return results
+	}
+	return copied
+}
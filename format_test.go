@@ -0,0 +1,84 @@
+package devtrace
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+type testOrder struct {
+	ID    int
+	Owner *testUser
+	Items []string
+	Addr  net.IP
+}
+
+type testUser struct {
+	Name  string
+	Email string
+}
+
+func TestFormatValueUsesFormattersAndMethods(t *testing.T) {
+	t.Cleanup(ClearFormatters)
+
+	order := testOrder{ID: 7, Owner: &testUser{Name: "ann", Email: "ann@example.com"}, Items: []string{"a", "b"}, Addr: net.IPv4(10, 0, 0, 1)}
+	if got, want := FormatValue(order), "{ID:7 Owner:&{Name:ann Email:ann@example.com} Items:[a b] Addr:10.0.0.1}"; got != want {
+		t.Fatalf("FormatValue = %q, want %q", got, want)
+	}
+
+	RegisterFormatter(func(u *testUser) string { return "user(" + u.Name + ")" })
+	if got, want := FormatValue(order), "{ID:7 Owner:user(ann) Items:[a b] Addr:10.0.0.1}"; got != want {
+		t.Fatalf("FormatValue with a formatter = %q, want %q", got, want)
+	}
+
+	if got := FormatValue(map[string]int{"b": 2, "a": 1}); got != "map[a:1 b:2]" {
+		t.Fatalf("map keys not sorted: %q", got)
+	}
+	var nilUser *testUser
+	if got := FormatValue(nilUser); got != "<nil>" {
+		t.Fatalf("nil pointer rendered as %q", got)
+	}
+}
+
+func TestFormatValueLimitsDepthAndLength(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) {
+		c.MaxValueLen, c.MaxValueDepth, c.MaxSliceElems, c.MaxMapEntries = 64, 3, 3, 2
+	})
+
+	type node struct {
+		Next *node
+	}
+	deep := &node{&node{&node{&node{&node{}}}}}
+	if got, want := FormatValue(deep), "&{Next:&{Next:&{Next:&{...}}}}"; got != want {
+		t.Fatalf("FormatValue = %q, want %q", got, want)
+	}
+	if got, want := FormatValue([]int{1, 2, 3, 4, 5}), "[1 2 3 ...(+2 elems)]"; got != want {
+		t.Fatalf("FormatValue = %q, want %q", got, want)
+	}
+	if got, want := FormatValue(map[string]int{"a": 1, "b": 2, "c": 3}), "map[a:1 b:2 ...(+1 entries)]"; got != want {
+		t.Fatalf("FormatValue = %q, want %q", got, want)
+	}
+	if got, want := FormatValue(strings.Repeat("x", 100)), strings.Repeat("x", 64)+"...(+36 bytes)"; got != want {
+		t.Fatalf("FormatValue = %q, want %q", got, want)
+	}
+}
+
+func TestCapturedValuesAreClipped(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled, c.MaxValueLen = true, 8 })
+
+	frame := CreateFrame("pkg.Upload", "", "upload.go", 1, map[string]interface{}{
+		"body": make([]byte, 10<<20),
+		"name": "report-2026.csv",
+	})
+	if got, want := FormatValue(frame.Args["name"]), "report-2...(+7 bytes)"; got != want {
+		t.Fatalf("string arg = %q, want %q", got, want)
+	}
+	body, ok := frame.Args["body"].(clipped)
+	if !ok || len(body.value.([]byte)) != 8 || body.cut != 10<<20-8 {
+		t.Fatalf("byte slice arg not clipped: %s", FormatValue(frame.Args["body"]))
+	}
+}
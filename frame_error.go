@@ -0,0 +1,21 @@
+package devtrace
+
+import "context"
+
+// logIfFailed logs an ERROR with the current stack when a frame returned an
+// error and Config.LogErrors for function, or override when set, asks for it.
+// It must be called while the frame is still on the stack so it appears in the output.
+func logIfFailed(ctx context.Context, frame *Frame, function string, override *bool) {
+	if frame.Error == nil || GlobalEnhancedLogger == nil {
+		return
+	}
+	enabled := configFor(ctx, function).LogErrors
+	if override != nil {
+		enabled = *override
+	}
+	if !enabled {
+		return
+	}
+
+	GlobalEnhancedLogger.Error(ctx, "✖ %s returned error: %v", frame.Function, frame.Error)
+}
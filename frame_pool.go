@@ -0,0 +1,29 @@
+package devtrace
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// framePool recycles frames that were left and that nothing else kept, since a
+// traced call otherwise allocates one frame per call
+var framePool = sync.Pool{New: func() interface{} { return new(Frame) }}
+
+// newFrame returns a frame from the pool; the caller overwrites all of it
+func newFrame() *Frame {
+	return framePool.Get().(*Frame)
+}
+
+// retain marks a frame as referenced beyond its call, by the recorder, a hook,
+// or a caller of Stack or GetCurrentFrame, so it is never recycled
+func (f *Frame) retain() {
+	atomic.StoreUint32(&f.retained, 1)
+}
+
+// releaseFrame hands a left frame back to the pool unless something retained it
+func releaseFrame(frame *Frame) {
+	if frame == nil || atomic.LoadUint32(&frame.retained) != 0 {
+		return
+	}
+	framePool.Put(frame)
+}
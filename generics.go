@@ -0,0 +1,133 @@
+package devtrace
+
+import (
+	"go/ast"
+	"go/parser"
+	"reflect"
+	"strings"
+)
+
+// genericSuffix is what the runtime puts in place of the type arguments in the
+// name of an instantiated generic function
+const genericSuffix = "[...]"
+
+// TraceTyped is Trace for callers who know the function type, which is always
+// the case for an instantiated generic function. The result needs no type
+// assertion:
+//
+//	mapUsers := devtrace.TraceTyped(Map[User, string], nil)
+//	names := mapUsers(users, User.Name)
+//
+// The frame is named Map[main.User,string] and its signature lists the type
+// arguments, inferred from the source declaration.
+func TraceTyped[F any](fn F, options *TraceOptions) F {
+	return Trace(fn, options).(F)
+}
+
+// instantiate names an instantiated generic function after its type arguments:
+// pkg.Map[...] becomes pkg.Map[int,string] and the signature Map(s []T, ...)
+// becomes Map[T=int, U=string](s []T, ...). The arguments are inferred by
+// matching the parameter and result types of the source declaration against
+// fnType; ok is false when some type parameter can't be inferred that way.
+func instantiate(name string, fnSig *functionSignature, fnType reflect.Type) (instName, signature string, ok bool) {
+	if !strings.HasSuffix(name, genericSuffix) || len(fnSig.typeParams) == 0 {
+		return "", "", false
+	}
+
+	params := fnSig.signature
+	if !strings.HasPrefix(params, fnSig.name+"(") {
+		return "", "", false
+	}
+	expr, err := parser.ParseExpr("func" + strings.TrimPrefix(params, fnSig.name))
+	if err != nil {
+		return "", "", false
+	}
+	declType, isFunc := expr.(*ast.FuncType)
+	if !isFunc {
+		return "", "", false
+	}
+
+	u := typeUnifier{params: make(map[string]reflect.Type)}
+	for _, name := range fnSig.typeParams {
+		u.params[name] = nil
+	}
+	u.fields(declType.Params, fnType.NumIn(), fnType.In)
+	u.fields(declType.Results, fnType.NumOut(), fnType.Out)
+
+	args := make([]string, len(fnSig.typeParams))
+	bound := make([]string, len(fnSig.typeParams))
+	for i, param := range fnSig.typeParams {
+		t := u.params[param]
+		if t == nil {
+			return "", "", false
+		}
+		args[i] = t.String()
+		bound[i] = param + "=" + t.String()
+	}
+
+	instName = strings.TrimSuffix(name, genericSuffix) + "[" + strings.Join(args, ",") + "]"
+	signature = fnSig.name + "[" + strings.Join(bound, ", ") + "]" + strings.TrimPrefix(params, fnSig.name)
+	return instName, signature, true
+}
+
+// typeUnifier binds type parameter names by walking a declared type expression
+// alongside the concrete reflect type it was instantiated to
+type typeUnifier struct {
+	params map[string]reflect.Type // type parameter name to its argument, nil until bound
+}
+
+// fields unifies a parameter or result list with the n types returned by at
+func (u *typeUnifier) fields(list *ast.FieldList, n int, at func(int) reflect.Type) {
+	if list == nil {
+		return
+	}
+	i := 0
+	for _, field := range list.List {
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for ; count > 0 && i < n; count-- {
+			u.unify(field.Type, at(i))
+			i++
+		}
+	}
+}
+
+func (u *typeUnifier) unify(expr ast.Expr, t reflect.Type) {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if bound, isParam := u.params[e.Name]; isParam && bound == nil {
+			u.params[e.Name] = t
+		}
+	case *ast.ParenExpr:
+		u.unify(e.X, t)
+	case *ast.StarExpr:
+		if t.Kind() == reflect.Ptr {
+			u.unify(e.X, t.Elem())
+		}
+	case *ast.Ellipsis:
+		// the variadic parameter ...T is a []T
+		if t.Kind() == reflect.Slice {
+			u.unify(e.Elt, t.Elem())
+		}
+	case *ast.ArrayType:
+		if e.Len == nil && t.Kind() == reflect.Slice || e.Len != nil && t.Kind() == reflect.Array {
+			u.unify(e.Elt, t.Elem())
+		}
+	case *ast.MapType:
+		if t.Kind() == reflect.Map {
+			u.unify(e.Key, t.Key())
+			u.unify(e.Value, t.Elem())
+		}
+	case *ast.ChanType:
+		if t.Kind() == reflect.Chan {
+			u.unify(e.Value, t.Elem())
+		}
+	case *ast.FuncType:
+		if t.Kind() == reflect.Func {
+			u.fields(e.Params, t.NumIn(), t.In)
+			u.fields(e.Results, t.NumOut(), t.Out)
+		}
+	}
+}
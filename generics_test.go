@@ -0,0 +1,67 @@
+package devtrace
+
+import (
+	"strconv"
+	"testing"
+)
+
+func traceTestMap[T, U any](s []T, f func(T) U) []U {
+	out := make([]U, 0, len(s))
+	for _, v := range s {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+func traceTestKeys[K comparable, V any](m map[K]V, extra ...K) []K {
+	keys := append([]K(nil), extra...)
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestTraceTypedRecordsInstantiation(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	var frame *Frame
+	unregister := RegisterHook(Hook{OnExit: func(f *Frame) { frame = f }})
+	t.Cleanup(unregister)
+
+	mapInts := TraceTyped(traceTestMap[int, string], nil)
+	if got := mapInts([]int{1, 2}, strconv.Itoa); len(got) != 2 || got[1] != "2" {
+		t.Fatalf("unexpected result %v", got)
+	}
+
+	if frame == nil {
+		t.Fatal("expected a frame for the traced call")
+	}
+	if frame.Function != "github.com/skulidropek/gotrace.traceTestMap[int,string]" {
+		t.Fatalf("unexpected frame name %q", frame.Function)
+	}
+	if frame.Signature != "traceTestMap[T=int, U=string](s []T, f func(T) U) []U" {
+		t.Fatalf("unexpected signature %q", frame.Signature)
+	}
+	if _, ok := frame.Args["s"]; !ok {
+		t.Fatalf("expected args named from the source, got %v", frame.Args)
+	}
+}
+
+func TestTracedFuncInfersVariadicAndMapTypes(t *testing.T) {
+	tf := NewTracedFunc(traceTestKeys[string, bool], nil)
+	if tf.Name != "github.com/skulidropek/gotrace.traceTestKeys[string,bool]" {
+		t.Fatalf("unexpected name %q", tf.Name)
+	}
+	if tf.Signature != "traceTestKeys[K=string, V=bool](m map[K]V, extra ...K) []K" {
+		t.Fatalf("unexpected signature %q", tf.Signature)
+	}
+}
+
+func TestTraceTypedPassesVariadicArgs(t *testing.T) {
+	keys := TraceTyped(traceTestKeys[string, bool], nil)
+	if got := keys(nil, "a", "b"); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("variadic args lost through the wrapper: %q", got)
+	}
+}
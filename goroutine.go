@@ -0,0 +1,102 @@
+package devtrace
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// goroutineContexts holds the trace context of each goroutine that has open frames.
+// Entries are added by the first Enter and removed when the stack empties again,
+// so goroutines that exit with balanced frames leave nothing behind.
+var (
+	goroutineMu       sync.RWMutex
+	goroutineContexts = make(map[uint64]*TraceContext)
+)
+
+var goroutinePrefix = []byte("goroutine ")
+
+// goroutineID returns the runtime id of the calling goroutine, or 0 if it cannot be parsed
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, goroutinePrefix)
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// CurrentContext returns the trace context of the calling goroutine. It is what
+// FromContext falls back to when ctx carries no trace context, and what
+// GlobalEnter and GlobalLeave operate on.
+func CurrentContext() *TraceContext {
+	return goroutineContext(goroutineID())
+}
+
+// goroutineContext is CurrentContext for callers that already know the id of
+// the calling goroutine; goroutineID parses runtime.Stack and is not free
+func goroutineContext(id uint64) *TraceContext {
+	goroutineMu.RLock()
+	tc := goroutineContexts[id]
+	goroutineMu.RUnlock()
+	if tc != nil {
+		return tc
+	}
+
+	// Registered on first Enter, so lookups that never trace don't leak entries
+	return &TraceContext{
+		Frames:    make([]*Frame, 0),
+		StartAt:   time.Now(),
+		goroutine: id,
+	}
+}
+
+// Goroutine returns the id of the goroutine a context returned by CurrentContext
+// belongs to, or 0 for contexts that were created explicitly
+func (tc *TraceContext) Goroutine() uint64 {
+	if tc == nil {
+		return 0
+	}
+	return tc.goroutine
+}
+
+func registerGoroutineContext(tc *TraceContext) {
+	if tc.goroutine == 0 {
+		return
+	}
+	goroutineMu.Lock()
+	if _, exists := goroutineContexts[tc.goroutine]; !exists {
+		goroutineContexts[tc.goroutine] = tc
+	}
+	goroutineMu.Unlock()
+}
+
+func unregisterGoroutineContext(tc *TraceContext) {
+	if tc.goroutine == 0 || tc.pinned {
+		return
+	}
+	goroutineMu.Lock()
+	if goroutineContexts[tc.goroutine] == tc {
+		delete(goroutineContexts, tc.goroutine)
+	}
+	goroutineMu.Unlock()
+}
+
+// goroutineContextList returns every registered goroutine context
+func goroutineContextList() []*TraceContext {
+	goroutineMu.RLock()
+	defer goroutineMu.RUnlock()
+
+	contexts := make([]*TraceContext, 0, len(goroutineContexts))
+	for _, tc := range goroutineContexts {
+		contexts = append(contexts, tc)
+	}
+	return contexts
+}
@@ -0,0 +1,236 @@
+package devtrace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGoroutineContextsDoNotInterleave(t *testing.T) {
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outer := fmt.Sprintf("worker%d", i)
+			GlobalEnter(CreateFrame(outer, "", "worker.go", 1, nil))
+			GlobalEnter(CreateFrame(outer+".step", "", "worker.go", 2, nil))
+
+			stack := GlobalStack()
+			if len(stack) != 2 || stack[0].Function != outer || stack[1].Function != outer+".step" {
+				errs <- fmt.Errorf("%s saw foreign frames: %v", outer, stack)
+			}
+			if stack[0].Goroutine == 0 || stack[0].Goroutine != CurrentContext().Goroutine() {
+				errs <- fmt.Errorf("%s: frame goroutine %d does not match context", outer, stack[0].Goroutine)
+			}
+
+			GlobalLeave()
+			GlobalLeave()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := len(goroutineContextList()); n != 0 {
+		t.Fatalf("expected registry to be empty after balanced frames, got %d entries", n)
+	}
+}
+
+func TestForkCarriesTraceIntoGoroutine(t *testing.T) {
+	GlobalEnter(CreateFrame("spawner", "", "spawn.go", 1, nil))
+	parent := CurrentContext()
+	fork := Fork()
+
+	done := make(chan *TraceContext)
+	go func() {
+		defer fork.Adopt()()
+		GlobalEnter(CreateFrame("child", "", "spawn.go", 5, nil))
+		tc := CurrentContext()
+		GlobalLeave()
+		done <- tc
+	}()
+	child := <-done
+	GlobalLeave()
+
+	if child == parent {
+		t.Fatalf("child goroutine must get its own context")
+	}
+	if child.TraceID == "" || child.TraceID != parent.TraceID {
+		t.Fatalf("expected shared trace ID, got parent %q child %q", parent.TraceID, child.TraceID)
+	}
+	if child.RemoteParent != "spawner" {
+		t.Fatalf("expected spawner as parent, got %q", child.RemoteParent)
+	}
+}
+
+func TestForkContextCarriesTraceFromCtx(t *testing.T) {
+	tc := NewTraceContext()
+	ctx := WithTraceContext(context.Background(), tc)
+	EnterContext(ctx, CreateFrame("handler", "", "spawn.go", 1, nil))
+	defer LeaveContext(ctx)
+
+	done := make(chan *TraceContext)
+	fork := ForkContext(ctx)
+	go func() {
+		defer fork.Adopt()()
+		done <- CurrentContext()
+	}()
+	child := <-done
+
+	if child.TraceID == "" || child.TraceID != tc.TraceID {
+		t.Fatalf("expected the trace ID of ctx, got %q for %q", child.TraceID, tc.TraceID)
+	}
+	if child.RemoteParent != "handler" {
+		t.Fatalf("expected handler as parent, got %q", child.RemoteParent)
+	}
+	// The goroutine's own context never saw the frame entered on ctx
+	if parent := Fork().parent; parent == "handler" {
+		t.Fatalf("expected Fork to ignore the trace on ctx, got parent %q", parent)
+	}
+}
+
+func TestTracedMutexRecordsContention(t *testing.T) {
+	original := CurrentConfig()
+	originalEnhanced := GlobalEnhancedLogger
+	t.Cleanup(func() {
+		SetConfig(original)
+		GlobalEnhancedLogger = originalEnhanced
+		DisableRecorder()
+	})
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+	EnableRecorder(10)
+	logger := &captureLogger{}
+	GlobalEnhancedLogger = NewEnhancedLogger(&StackLoggerOptions{})
+	GlobalEnhancedLogger.SetLogger(logger)
+
+	mu := TracedMutex{Name: "cache", Threshold: 5 * time.Millisecond}
+	GlobalEnter(CreateFrame("refreshCache", "", "cache.go", 1, nil))
+	mu.Lock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mu.Lock()
+		mu.Unlock()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	mu.Unlock()
+	GlobalLeave()
+	<-done
+
+	wait := RecentTraces(TraceFilter{Function: "lock wait cache"})
+	hold := RecentTraces(TraceFilter{Function: "lock hold cache"})
+	if len(wait) != 1 || len(hold) != 1 || wait[0].Frame.Duration < 5*time.Millisecond {
+		t.Fatalf("expected a wait and a hold frame, got %+v and %+v", wait, hold)
+	}
+	holder, _ := wait[0].Frame.Args["holder"].(string)
+	if !strings.Contains(holder, "TestTracedMutexRecordsContention") || !strings.Contains(holder, "route: refreshCache") {
+		t.Fatalf("expected the holder's location and route, got %q", holder)
+	}
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "lock contention: waited") {
+		t.Fatalf("expected a contention warning, got %q", logger.messages)
+	}
+}
+
+func TestSlowProfilerWritesProfileAndMetadata(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	tc := NewTraceContext()
+	tc.EnsureTraceID()
+	tc.Enter(CreateFrame("handleReport", "", "report.go", 10, nil))
+	tc.Enter(CreateFrame("buildReport", "", "report.go", 20, nil))
+	defer func() {
+		tc.Leave()
+		tc.Leave()
+	}()
+
+	found, path, profiled := nextSlowFrame(time.Now().Add(time.Second), 500*time.Millisecond, nil)
+	if found != tc || len(path) != 2 || len(profiled) != 2 {
+		t.Fatalf("expected the innermost slow frame of the context, got %d frames", len(path))
+	}
+	if again, _, _ := nextSlowFrame(time.Now().Add(time.Second), 500*time.Millisecond, profiled); again == tc {
+		t.Fatal("a profiled frame should not be profiled again")
+	}
+
+	opts := SlowProfileOptions{Duration: 20 * time.Millisecond, Interval: 5 * time.Millisecond, Dir: t.TempDir()}
+	if err := captureSlowProfile(tc, path, opts, nil); err != nil {
+		t.Fatal(err)
+	}
+	metas, _ := filepath.Glob(filepath.Join(opts.Dir, "*-buildReport.json"))
+	if len(metas) != 1 {
+		t.Fatalf("expected one metadata file, got %v", metas)
+	}
+	data, _ := os.ReadFile(metas[0])
+	var meta SlowProfile
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Function != "buildReport" || meta.TraceID != tc.TraceID || len(meta.Path) != 2 || meta.Path[0] != "handleReport" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+	if info, err := os.Stat(filepath.Join(opts.Dir, meta.Profile)); err != nil || info.Size() == 0 {
+		t.Fatalf("expected a non-empty %s profile: %v", meta.Kind, err)
+	}
+}
+
+func TestActiveContextsReadableWhileOwnerRuns(t *testing.T) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			GlobalEnter(CreateFrame("owner", "", "owner.go", 1, map[string]interface{}{"i": i}))
+			GlobalEnter(CreateFrame("owner.step", "", "owner.go", 2, nil))
+			setOpenArg(GlobalStack()[1], "step", i)
+			GlobalLeave()
+			GlobalLeave()
+		}
+	}()
+
+	deadline := time.Now().Add(50 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		_ = DumpActiveContexts()
+		scanStuckFrames(time.Now(), time.Hour, nil)
+	}
+	close(stop)
+	<-done
+}
+
+func BenchmarkGoroutineID(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		goroutineID()
+	}
+}
+
+// BenchmarkGlobalEnterLeave runs what an instrumented function without results does per call
+func BenchmarkGlobalEnterLeave(b *testing.B) {
+	original := CurrentConfig()
+	b.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true; c.PoolFrames = true })
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		GlobalEnter(CreateFrame("bench", "bench()", "bench.go", 1, nil))
+		RecordPanic(nil)
+		GlobalLeave()
+	}
+}
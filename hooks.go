@@ -0,0 +1,109 @@
+package devtrace
+
+import "sync"
+
+// Hook receives callbacks for every traced frame.
+// Any callback may be nil. Callbacks run synchronously on the traced goroutine,
+// so they should be quick and must not block.
+type Hook struct {
+	// OnEnter is called after a frame has been pushed onto a trace context
+	OnEnter func(frame *Frame)
+	// OnExit is called after a frame has been popped; Duration and Results are populated
+	OnExit func(frame *Frame)
+	// OnPanic is called when a traced call panics, before the frame is left
+	OnPanic func(frame *Frame, recovered interface{})
+}
+
+type hookEntry struct {
+	id   int
+	hook Hook
+}
+
+var (
+	hooksMu    sync.RWMutex
+	hooks      []hookEntry
+	nextHookID int
+)
+
+// RegisterHook adds a hook to the global registry and returns a function that removes it
+func RegisterHook(hook Hook) func() {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	nextHookID++
+	id := nextHookID
+	hooks = append(hooks, hookEntry{id: id, hook: hook})
+
+	return func() {
+		hooksMu.Lock()
+		defer hooksMu.Unlock()
+
+		for i, entry := range hooks {
+			if entry.id == id {
+				hooks = append(hooks[:i:i], hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// ClearHooks removes every registered hook
+func ClearHooks() {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	hooks = nil
+}
+
+// registeredHooks returns a snapshot of the registry so callbacks run without holding the lock
+func registeredHooks() []hookEntry {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+
+	return hooks
+}
+
+func runEnterHooks(frame *Frame) {
+	if frame == nil {
+		return
+	}
+	entries := registeredHooks()
+	if len(entries) > 0 {
+		frame.retain()
+	}
+	for _, entry := range entries {
+		if entry.hook.OnEnter != nil {
+			entry.hook.OnEnter(frame)
+		}
+	}
+}
+
+func runExitHooks(frame *Frame) {
+	if frame == nil {
+		return
+	}
+	entries := registeredHooks()
+	if len(entries) > 0 {
+		frame.retain()
+	}
+	for _, entry := range entries {
+		if entry.hook.OnExit != nil {
+			entry.hook.OnExit(frame)
+		}
+	}
+}
+
+func runPanicHooks(frame *Frame, recovered interface{}) {
+	if frame == nil {
+		return
+	}
+	entries := registeredHooks()
+	if len(entries) > 0 {
+		frame.retain()
+	}
+	for _, entry := range entries {
+		if entry.hook.OnPanic != nil {
+			entry.hook.OnPanic(frame, recovered)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package devtrace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHooksObserveEnterAndExit(t *testing.T) {
+	originalConfig := Config
+	t.Cleanup(func() { SetConfig(originalConfig) })
+	cfg := Config
+	cfg.Enabled = true
+	cfg.ShowTiming = false
+	SetConfig(cfg)
+
+	var entered, exited []string
+	var results []interface{}
+	unregister := RegisterHook(Hook{
+		OnEnter: func(frame *Frame) { entered = append(entered, frame.Function) },
+		OnExit: func(frame *Frame) {
+			exited = append(exited, frame.Function)
+			results = frame.Results
+		},
+	})
+	defer unregister()
+
+	double := TraceFunc(func(ctx context.Context, n int) int { return n * 2 }, "double").(func(context.Context, int) int)
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	if got := double(ctx, 21); got != 42 {
+		t.Fatalf("unexpected result: %d", got)
+	}
+
+	if len(entered) != 1 || entered[0] != "double" {
+		t.Fatalf("enter hook not called: %v", entered)
+	}
+	if len(exited) != 1 || exited[0] != "double" {
+		t.Fatalf("exit hook not called: %v", exited)
+	}
+	if len(results) != 1 || results[0] != 42 {
+		t.Fatalf("results not exposed to hook: %v", results)
+	}
+
+	unregister()
+	double(ctx, 1)
+	if len(entered) != 1 {
+		t.Fatalf("hook still called after unregister: %v", entered)
+	}
+}
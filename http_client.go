@@ -0,0 +1,241 @@
+//go:build !devtrace_minimal
+
+package devtrace
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WrapTransport wraps an outbound http.RoundTripper (http.DefaultTransport when
+// nil) so each request runs in a frame named "HTTP GET host/path" and carries
+// the trace headers of InjectTraceHeaders. Where the time went shows up as
+// child frames of the request: DNS lookup, TCP connect, TLS handshake and the
+// wait for the first response byte.
+//
+// The frame records the method, URL without its query, status and retry count:
+// attempts the transport itself made on a fresh connection, plus earlier failed
+// attempts at the same method and URL from the same frame, as a retrying client
+// makes. Failed requests and 5xx responses are logged through
+// GlobalEnhancedLogger with the stack that sent them; slow ones are, like any
+// frame, when Config.SlowThreshold is set.
+//
+//	client := &http.Client{Transport: devtrace.WrapTransport(nil)}
+//	resp, err := client.Do(req.WithContext(ctx))
+func WrapTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &tracingTransport{next: next}
+}
+
+// Transport is WrapTransport
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return WrapTransport(next)
+}
+
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !IsEnabled() {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	tc := FromContext(ctx)
+	url := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path // the query may carry secrets
+	attempt := tc.nextOutbound(req.Method + " " + url)
+	frame := CreateFrame("HTTP "+req.Method+" "+req.URL.Host+req.URL.Path, "", "", 0, map[string]interface{}{
+		"method": req.Method,
+		"url":    url,
+	})
+	EnterContext(ctx, frame)
+
+	phases := &clientPhases{host: req.URL.Hostname()}
+	outbound := req.Clone(httptrace.WithClientTrace(ctx, phases.trace()))
+	for key, value := range InjectTraceHeaders(ctx) {
+		outbound.Header.Set(key, value)
+	}
+
+	resp, err := t.next.RoundTrip(outbound)
+	for _, child := range phases.frames() {
+		recordChild(tc, child)
+	}
+	attempt.failed = err != nil || resp.StatusCode >= 500
+	tc.lastOutbound = attempt
+	retries := attempt.retries
+	if phases.conns > 1 {
+		retries += phases.conns - 1
+	}
+	if retries > 0 {
+		setOpenArg(frame, "retry", retries)
+	}
+
+	if err != nil {
+		if GlobalEnhancedLogger != nil {
+			GlobalEnhancedLogger.Error(ctx, "🌐 %s failed%s: %v", frame.Function, retrySuffix(retries), err)
+		}
+		LeaveContextResults(ctx, err)
+		return nil, err
+	}
+	setOpenArg(frame, "status", resp.StatusCode)
+	if phases.reused {
+		setOpenArg(frame, "reused_conn", true)
+	}
+	if resp.StatusCode >= 500 && GlobalEnhancedLogger != nil {
+		GlobalEnhancedLogger.Warn(ctx, "🌐 %s returned %s%s", frame.Function, resp.Status, retrySuffix(retries))
+	}
+	LeaveContext(ctx)
+	return resp, nil
+}
+
+func retrySuffix(retries int) string {
+	if retries == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (retry %d)", retries)
+}
+
+// nextOutbound describes a request to key from the current frame of tc, with
+// retries counting the attempts at key that failed in a row just before it
+func (tc *TraceContext) nextOutbound(key string) outboundAttempt {
+	parent := tc.current()
+	if parent == nil {
+		return outboundAttempt{key: key}
+	}
+	attempt := outboundAttempt{parent: parent, started: parent.StartTime, key: key}
+	if last := tc.lastOutbound; last.failed && last.parent == parent && last.started.Equal(parent.StartTime) && last.key == key {
+		attempt.retries = last.retries + 1
+	}
+	return attempt
+}
+
+// clientPhases collects the timings httptrace reports for one request. The
+// hooks run on the transport's goroutines, so the frames are built afterwards
+// on the caller's.
+type clientPhases struct {
+	mu       sync.Mutex
+	host     string
+	dns      clientPhase
+	connects []clientPhase
+	tls      clientPhase
+	wait     clientPhase
+	reused   bool
+	conns    int // connections the transport got; more than one means it retried
+}
+
+// clientPhase is one step of a request; a zero end means it never finished
+type clientPhase struct {
+	name       string
+	start, end time.Time
+	args       map[string]interface{}
+	err        error
+}
+
+func (p *clientPhases) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.dns = clientPhase{name: "HTTP DNS lookup", start: time.Now(), args: map[string]interface{}{"host": info.Host}}
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			addrs := make([]string, len(info.Addrs))
+			for i, addr := range info.Addrs {
+				addrs[i] = addr.String()
+			}
+			p.dns.args["addrs"] = strings.Join(addrs, ", ")
+			p.dns.finish(info.Err)
+		},
+		ConnectStart: func(network, addr string) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.connects = append(p.connects, clientPhase{name: "HTTP connect", start: time.Now(), args: map[string]interface{}{"network": network, "addr": addr}})
+		},
+		ConnectDone: func(network, addr string, err error) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			for i := range p.connects {
+				if c := &p.connects[i]; c.end.IsZero() && c.args["addr"] == addr {
+					c.finish(err)
+					break
+				}
+			}
+		},
+		TLSHandshakeStart: func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.tls = clientPhase{name: "HTTP TLS handshake", start: time.Now(), args: map[string]interface{}{"server_name": p.host}}
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if err == nil {
+				p.tls.args["version"] = tls.VersionName(state.Version)
+				p.tls.args["resumed"] = state.DidResume
+			}
+			p.tls.finish(err)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.reused = info.Reused
+			p.conns++
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.wait = clientPhase{name: "HTTP time to first byte", start: time.Now()}
+		},
+		GotFirstResponseByte: func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.wait.finish(nil)
+		},
+	}
+}
+
+func (c *clientPhase) finish(err error) {
+	if c.start.IsZero() {
+		return
+	}
+	c.end = time.Now()
+	c.err = err
+}
+
+// frames returns a frame per finished phase, in the order they started
+func (p *clientPhases) frames() []*Frame {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	phases := append([]clientPhase{p.dns}, p.connects...)
+	phases = append(phases, p.tls, p.wait)
+
+	var frames []*Frame
+	for _, phase := range phases {
+		if phase.end.IsZero() {
+			continue
+		}
+		frame := &Frame{
+			Function:  phase.name,
+			Args:      phase.args,
+			StartTime: phase.start,
+			EndTime:   phase.end,
+			Duration:  phase.end.Sub(phase.start),
+		}
+		if phase.err != nil {
+			frame.Results = []interface{}{phase.err}
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
@@ -0,0 +1,56 @@
+package devtrace
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// Invocation describes how the process was started, for CLI tools whose
+// trace files are shared outside the machine that produced them
+type Invocation struct {
+	Argv []string          `json:"argv"`
+	Cwd  string            `json:"cwd,omitempty"`
+	Env  map[string]string `json:"env,omitempty"`
+}
+
+var (
+	invocationOnce sync.Once
+	invocation     *Invocation
+)
+
+// CurrentInvocation returns argv, the working directory and the env vars listed in
+// Config.CaptureEnvVars (redacted), captured once per process
+func CurrentInvocation() *Invocation {
+	invocationOnce.Do(func() {
+		inv := &Invocation{Argv: append([]string(nil), os.Args...)}
+		if cwd, err := os.Getwd(); err == nil {
+			inv.Cwd = cwd
+		}
+
+		for _, name := range CurrentConfig().CaptureEnvVars {
+			name = strings.TrimSpace(name)
+			value, ok := os.LookupEnv(name)
+			if name == "" || !ok {
+				continue
+			}
+			if inv.Env == nil {
+				inv.Env = make(map[string]string)
+			}
+			if redacted, isString := Redact(name, value).(string); isString {
+				value = redacted
+			}
+			inv.Env[name] = value
+		}
+
+		invocation = inv
+	})
+	return invocation
+}
+
+// attachInvocation stores the invocation on a root frame when capture is enabled
+func attachInvocation(frame *Frame) {
+	if frame != nil && CurrentConfig().CaptureInvocation {
+		frame.Invocation = CurrentInvocation()
+	}
+}
@@ -0,0 +1,98 @@
+package devtrace
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// resetInvocation forgets the invocation captured by an earlier test, which
+// CurrentInvocation otherwise keeps for the life of the process
+func resetInvocation(t *testing.T) {
+	invocationOnce, invocation = sync.Once{}, nil
+	t.Cleanup(func() { invocationOnce, invocation = sync.Once{}, nil })
+}
+
+func TestInvocationCapturedOnRootFrame(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	resetInvocation(t)
+	t.Setenv("DEPLOY_REGION", "eu-west-1")
+	t.Setenv("DEPLOY_TOKEN", "hunter2")
+	UpdateConfig(func(c *DevTraceConfig) {
+		c.Enabled = true
+		c.CaptureInvocation = true
+		c.CaptureEnvVars = []string{"DEPLOY_REGION", "DEPLOY_TOKEN", "DEPLOY_UNSET"}
+	})
+
+	tc := NewTraceContext()
+	root := CreateFrame("main", "", "main.go", 1, nil)
+	child := CreateFrame("run", "", "main.go", 5, nil)
+	tc.Enter(root)
+	tc.Enter(child)
+	tc.Leave()
+	tc.Leave()
+
+	inv := root.Invocation
+	if inv == nil {
+		t.Fatal("expected the invocation on the root frame")
+	}
+	cwd, _ := os.Getwd()
+	if !reflect.DeepEqual(inv.Argv, os.Args) || inv.Cwd != cwd {
+		t.Errorf("expected argv %q in %s, got %q in %s", os.Args, cwd, inv.Argv, inv.Cwd)
+	}
+	want := map[string]string{"DEPLOY_REGION": "eu-west-1", "DEPLOY_TOKEN": RedactedValue}
+	if !reflect.DeepEqual(inv.Env, want) {
+		t.Errorf("expected the listed, set env vars with secrets redacted, got %v", inv.Env)
+	}
+	if child.Invocation != nil {
+		t.Errorf("only the root frame should carry the invocation, the nested one has %+v", child.Invocation)
+	}
+}
+
+func TestInvocationResetBetweenRootFrames(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	resetInvocation(t)
+	UpdateConfig(func(c *DevTraceConfig) {
+		c.Enabled = true
+		c.CaptureInvocation = true
+		c.PoolFrames = true
+	})
+
+	// Each time the context empties, the next frame is a new root
+	tc := NewTraceContext()
+	var roots []*Frame
+	for i := 0; i < 2; i++ {
+		root := CreateFrame("handle", "", "main.go", 1, nil)
+		root.retain()
+		tc.Enter(root)
+		tc.Leave()
+		roots = append(roots, root)
+	}
+	if roots[0].Invocation == nil || roots[1].Invocation != roots[0].Invocation {
+		t.Fatalf("expected every root frame to share the process invocation, got %+v and %+v", roots[0].Invocation, roots[1].Invocation)
+	}
+
+	// GlobalLeave recycles the root frame; whichever frame the pool hands out
+	// next must not keep its invocation
+	GlobalEnter(CreateFrame("main", "", "main.go", 1, nil))
+	GlobalEnter(CreateFrame("run", "", "main.go", 2, nil))
+	GlobalLeave()
+	GlobalLeave()
+	for i := 0; i < 10; i++ {
+		if frame := CreateFrame("run", "", "main.go", 2, nil); frame.Invocation != nil {
+			t.Fatalf("a new frame carries the invocation of a recycled root: %+v", frame.Invocation)
+		}
+	}
+
+	UpdateConfig(func(c *DevTraceConfig) { c.CaptureInvocation = false })
+	off := CreateFrame("handle", "", "main.go", 1, nil)
+	off.retain()
+	tc.Enter(off)
+	tc.Leave()
+	if off.Invocation != nil {
+		t.Fatalf("expected no invocation once CaptureInvocation is off, got %+v", off.Invocation)
+	}
+}
@@ -0,0 +1,325 @@
+package devtrace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JSONLSchemaVersion is the schema field of every line a JSONLExporter writes.
+// It changes only when a field changes meaning or goes away; ReadSession
+// refuses files of a newer schema.
+const JSONLSchemaVersion = 1
+
+// Defaults for JSONLExporterOptions
+var (
+	DefaultJSONLMaxSize       int64 = 64 << 20
+	DefaultJSONLMaxFiles            = 5
+	DefaultJSONLBuffer              = 4096
+	DefaultJSONLFlushInterval       = time.Second
+	DefaultJSONLMaxTraceSize        = 10000 // frames buffered per trace with Traces
+)
+
+// ExportedFrame is a line written by a JSONLExporter for a completed frame. The
+// snapshot fields are inlined, so ReadSession reads these files as sessions.
+type ExportedFrame struct {
+	Schema  int      `json:"schema"`
+	TraceID string   `json:"trace_id,omitempty"`
+	Path    []string `json:"path,omitempty"` // function names from the root frame down to this one
+	FrameSnapshot
+}
+
+// ExportedTrace is a line written by a JSONLExporter with Traces set: every
+// frame of a trace, in completion order, written once its root frame completes
+type ExportedTrace struct {
+	Schema  int             `json:"schema"`
+	TraceID string          `json:"trace_id,omitempty"`
+	Frames  []ExportedFrame `json:"frames"`
+}
+
+// JSONLExporterOptions configures NewJSONLExporter; zero fields use the defaults
+type JSONLExporterOptions struct {
+	MaxSize       int64         // bytes per file before it is rotated to path.1, path.2, ...
+	MaxFiles      int           // rotated files kept besides the current one
+	Buffer        int           // lines queued for the writer; lines past it are dropped and counted
+	FlushInterval time.Duration // how often buffered lines reach the file
+	Traces        bool          // write one ExportedTrace per completed trace instead of one ExportedFrame per frame
+}
+
+// JSONLExporter writes completed frames as JSON lines to a file that is rotated
+// by size. Frames are snapshotted on the traced goroutine and written by a
+// background goroutine, so a slow disk never blocks traced code; when the
+// queue is full, lines are dropped instead.
+type JSONLExporter struct {
+	path string
+	opts JSONLExporterOptions
+
+	lines   chan interface{}
+	done    chan struct{}
+	dropped int64
+
+	mu     sync.RWMutex // guards closed against sends on the closed lines channel
+	closed bool
+	err    error // first write error, reported by Close
+
+	pendingMu sync.Mutex
+	pending   map[*TraceContext][]ExportedFrame // with Traces, frames waiting for their root
+
+	file *os.File
+	w    *bufio.Writer
+	size int64
+}
+
+// frameExporter receives every completed frame once registered with addExporter
+type frameExporter interface {
+	export(tc *TraceContext, line ExportedFrame)
+}
+
+var (
+	exportersMu sync.RWMutex
+	exporters   []frameExporter
+)
+
+func addExporter(e frameExporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	exporters = append(exporters, e)
+}
+
+func removeExporter(e frameExporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	for i, exporter := range exporters {
+		if exporter == e {
+			exporters = append(exporters[:i:i], exporters[i+1:]...)
+			return
+		}
+	}
+}
+
+// funcExporter hands exported frames to a function registered with RegisterExporter
+type funcExporter struct {
+	fn func(ExportedFrame)
+}
+
+func (e *funcExporter) export(_ *TraceContext, line ExportedFrame) {
+	e.fn(line)
+}
+
+// RegisterExporter calls fn with every completed frame, snapshotted like the
+// lines of a JSONLExporter, and returns a function that unregisters it. fn runs
+// synchronously on the traced goroutine, so it should be quick.
+func RegisterExporter(fn func(ExportedFrame)) func() {
+	e := &funcExporter{fn: fn}
+	addExporter(e)
+	return func() { removeExporter(e) }
+}
+
+// NewJSONLExporter opens path for appending and exports every completed frame
+// to it until Close is called
+func NewJSONLExporter(path string, opts *JSONLExporterOptions) (*JSONLExporter, error) {
+	e := &JSONLExporter{path: path, pending: make(map[*TraceContext][]ExportedFrame)}
+	if opts != nil {
+		e.opts = *opts
+	}
+	if e.opts.MaxSize <= 0 {
+		e.opts.MaxSize = DefaultJSONLMaxSize
+	}
+	if e.opts.MaxFiles <= 0 {
+		e.opts.MaxFiles = DefaultJSONLMaxFiles
+	}
+	if e.opts.Buffer <= 0 {
+		e.opts.Buffer = DefaultJSONLBuffer
+	}
+	if e.opts.FlushInterval <= 0 {
+		e.opts.FlushInterval = DefaultJSONLFlushInterval
+	}
+	if err := e.open(); err != nil {
+		return nil, err
+	}
+
+	e.lines = make(chan interface{}, e.opts.Buffer)
+	e.done = make(chan struct{})
+	go e.run()
+
+	addExporter(e)
+	return e, nil
+}
+
+// Dropped returns how many lines were dropped because the queue was full
+func (e *JSONLExporter) Dropped() int64 {
+	return atomic.LoadInt64(&e.dropped)
+}
+
+// Close stops exporting, writes the queued lines and closes the file. Traces
+// still running are not written.
+func (e *JSONLExporter) Close() error {
+	removeExporter(e)
+
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	close(e.lines)
+	e.mu.Unlock()
+
+	<-e.done
+	if err := e.w.Flush(); err != nil && e.err == nil {
+		e.err = err
+	}
+	if err := e.file.Close(); err != nil && e.err == nil {
+		e.err = err
+	}
+	return e.err
+}
+
+// exportFrame hands frame, which just left tc, to every registered exporter
+func exportFrame(tc *TraceContext, frame *Frame) {
+	exportersMu.RLock()
+	current := exporters
+	exportersMu.RUnlock()
+	if len(current) == 0 || frame == nil {
+		return
+	}
+
+	path := make([]string, 0, len(tc.Frames)+1)
+	for _, parent := range tc.Frames {
+		path = append(path, parent.Function)
+	}
+	path = append(path, frame.Function)
+	line := ExportedFrame{Schema: JSONLSchemaVersion, TraceID: tc.TraceID, Path: path, FrameSnapshot: SnapshotFrame(frame)}
+
+	for _, e := range current {
+		e.export(tc, line)
+	}
+}
+
+func (e *JSONLExporter) export(tc *TraceContext, line ExportedFrame) {
+	if !e.opts.Traces {
+		e.send(line)
+		return
+	}
+
+	e.pendingMu.Lock()
+	frames := e.pending[tc]
+	if len(frames) < DefaultJSONLMaxTraceSize || len(line.Path) == 1 {
+		frames = append(frames, line)
+	}
+	if len(line.Path) > 1 {
+		e.pending[tc] = frames
+		e.pendingMu.Unlock()
+		return
+	}
+	delete(e.pending, tc)
+	e.pendingMu.Unlock()
+
+	e.send(ExportedTrace{Schema: JSONLSchemaVersion, TraceID: line.TraceID, Frames: frames})
+}
+
+// send queues a line without blocking
+func (e *JSONLExporter) send(line interface{}) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.lines <- line:
+	default:
+		atomic.AddInt64(&e.dropped, 1)
+	}
+}
+
+// run writes queued lines until the queue is closed
+func (e *JSONLExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				return
+			}
+			e.write(line)
+		case <-ticker.C:
+			if err := e.w.Flush(); err != nil {
+				e.fail(err)
+			}
+		}
+	}
+}
+
+func (e *JSONLExporter) write(line interface{}) {
+	data, err := json.Marshal(line)
+	if err != nil {
+		e.fail(err)
+		return
+	}
+	data = append(data, '\n')
+
+	if e.size > 0 && e.size+int64(len(data)) > e.opts.MaxSize {
+		if err := e.rotate(); err != nil {
+			e.fail(err)
+			return
+		}
+	}
+	n, err := e.w.Write(data)
+	e.size += int64(n)
+	if err != nil {
+		e.fail(err)
+	}
+}
+
+// fail keeps the first error for Close and logs it once
+func (e *JSONLExporter) fail(err error) {
+	e.mu.Lock()
+	first := e.err == nil
+	if first {
+		e.err = err
+	}
+	e.mu.Unlock()
+	if first && GlobalLogger != nil {
+		GlobalLogger.Warn("⚠ JSONL export to %s: %v", e.path, err)
+	}
+}
+
+func (e *JSONLExporter) open() error {
+	file, err := os.OpenFile(e.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	e.file, e.w, e.size = file, bufio.NewWriter(file), info.Size()
+	return nil
+}
+
+// rotate shifts path.N-1 to path.N, ..., path to path.1, dropping the oldest,
+// and starts a new file at path
+func (e *JSONLExporter) rotate() error {
+	if err := e.w.Flush(); err != nil {
+		return err
+	}
+	if err := e.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", e.path, e.opts.MaxFiles))
+	for i := e.opts.MaxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", e.path, i), fmt.Sprintf("%s.%d", e.path, i+1))
+	}
+	if err := os.Rename(e.path, e.path+".1"); err != nil {
+		return err
+	}
+	return e.open()
+}
@@ -0,0 +1,230 @@
+//go:build !devtrace_minimal
+
+package devtrace
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LiveEvent is a message streamed by the live UI: "enter" when a frame is
+// pushed, "exit" with the completed frame when it is popped
+type LiveEvent struct {
+	Type  string         `json:"type"`
+	Time  time.Time      `json:"time"`
+	Frame *ExportedFrame `json:"frame"`
+}
+
+// liveClientBuffer is how many events may queue for a slow browser before
+// further events are dropped for it
+const liveClientBuffer = 512
+
+// liveHub fans events out to the connected browsers. It hooks into tracing
+// only while at least one is connected.
+type liveHub struct {
+	mu         sync.Mutex
+	clients    map[chan []byte]struct{}
+	unregister func()
+}
+
+var live = &liveHub{clients: make(map[chan []byte]struct{})}
+
+// ServeUI serves the live UI on addr until the listener fails: a page at /
+// showing the open call stacks of every goroutine, completed calls with their
+// timings and the log entries made inside them as they happen, fed by
+// /ws. The debug pages are mounted under /debug/gotrace/ as well.
+//
+//	go devtrace.ServeUI("localhost:8123")
+//
+// Like DebugHandler it exposes args and can change the configuration, so only
+// listen on trusted interfaces. The stream only accepts pages served from the
+// UI's own host; see AllowWebSocketOrigins to embed it elsewhere.
+func ServeUI(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/", LiveUIHandler())
+	RegisterDebugHandlers(mux)
+	return http.ListenAndServe(addr, mux)
+}
+
+// LiveUIHandler serves the live UI page and, at /ws below wherever it is
+// mounted, the WebSocket stream of LiveEvents
+func LiveUIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path := r.URL.Path; len(path) >= 3 && path[len(path)-3:] == "/ws" {
+			serveLiveEvents(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(liveUIPage))
+	})
+}
+
+func serveLiveEvents(w http.ResponseWriter, r *http.Request) {
+	conn, err := acceptWebSocket(w, r)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errCrossOrigin) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer conn.Close()
+
+	events := live.join()
+	defer live.leave(events)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			opcode, _, err := conn.readFrame()
+			if err != nil || opcode == wsClose {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event := <-events:
+			if err := conn.writeFrame(wsText, event); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// join adds a browser, hooking into tracing for the first one
+func (h *liveHub) join() chan []byte {
+	events := make(chan []byte, liveClientBuffer)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[events] = struct{}{}
+	if h.unregister == nil {
+		addExporter(h)
+		unhook := RegisterHook(Hook{OnEnter: h.enter})
+		h.unregister = func() {
+			unhook()
+			removeExporter(h)
+		}
+	}
+	return events
+}
+
+// leave removes a browser, unhooking from tracing after the last one
+func (h *liveHub) leave(events chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, events)
+	if len(h.clients) == 0 && h.unregister != nil {
+		h.unregister()
+		h.unregister = nil
+	}
+}
+
+func (h *liveHub) enter(frame *Frame) {
+	h.broadcast(LiveEvent{Type: "enter", Time: time.Now(), Frame: &ExportedFrame{
+		Schema: JSONLSchemaVersion,
+		FrameSnapshot: FrameSnapshot{
+			Function:  frame.Function,
+			File:      frame.File,
+			Line:      frame.Line,
+			StartTime: frame.StartTime,
+			Goroutine: frame.Goroutine,
+			Operation: frame.Operation,
+		},
+	}})
+}
+
+func (h *liveHub) export(tc *TraceContext, line ExportedFrame) {
+	h.broadcast(LiveEvent{Type: "exit", Time: time.Now(), Frame: &line})
+}
+
+// broadcast sends event to every browser that has room for it
+func (h *liveHub) broadcast(event LiveEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		select {
+		case client <- data:
+		default:
+		}
+	}
+}
+
+const liveUIPage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>gotrace live</title>
+<style>
+body{font:13px/1.4 ui-monospace,monospace;margin:0;background:#fafafa;color:#222}
+header{padding:8px 12px;background:#222;color:#eee;display:flex;gap:16px;align-items:center}
+header input{font:inherit;padding:2px 6px}
+main{display:grid;grid-template-columns:1fr 1fr;gap:12px;padding:12px}
+section{background:#fff;border:1px solid #ddd;border-radius:4px;overflow:auto;max-height:80vh}
+h2{font-size:13px;margin:0;padding:6px 10px;background:#eee;position:sticky;top:0}
+.row{padding:2px 10px;white-space:nowrap;border-bottom:1px solid #f2f2f2}
+.err{color:#c0392b}.dim{color:#888}.slow{background:#fff3cd}
+#logs{grid-column:1/3;max-height:30vh}
+</style></head><body>
+<header><b>gotrace live</b><span id="status" class="dim">connecting…</span>
+<label>filter <input id="filter" placeholder="function"></label>
+<label><input type="checkbox" id="pause"> pause</label></header>
+<main>
+<section><h2>Open call stacks</h2><div id="stacks"></div></section>
+<section><h2>Completed calls</h2><div id="calls"></div></section>
+<section id="logs"><h2>Log entries</h2><div id="logrows"></div></section>
+</main>
+<script>
+const stacks = new Map(), maxRows = 300;
+const $ = id => document.getElementById(id);
+const esc = s => String(s).replace(/[&<>"]/g, c => ({'&':'&amp;','<':'&lt;','>':'&gt;','"':'&quot;'}[c]));
+const ms = ns => (ns / 1e6).toFixed(ns < 1e7 ? 3 : 1) + 'ms';
+const shown = f => !$('filter').value || f.function.includes($('filter').value);
+function prepend(id, html) {
+  const box = $(id);
+  box.insertAdjacentHTML('afterbegin', html);
+  while (box.children.length > maxRows) box.lastChild.remove();
+}
+function renderStacks() {
+  const now = Date.now(), rows = [];
+  for (const [g, frames] of stacks) {
+    if (!frames.length) continue;
+    rows.push('<div class="row dim">goroutine ' + g + '</div>');
+    frames.forEach((f, i) => {
+      const open = now - Date.parse(f.start_time);
+      rows.push('<div class="row' + (open > 1000 ? ' slow' : '') + '">' + '&nbsp;&nbsp;'.repeat(i + 1) +
+        esc(f.function) + ' <span class="dim">' + open + 'ms</span></div>');
+    });
+  }
+  $('stacks').innerHTML = rows.join('') || '<div class="row dim">no open frames</div>';
+}
+function connect() {
+  const ws = new WebSocket((location.protocol === 'https:' ? 'wss://' : 'ws://') + location.host + location.pathname.replace(/\/?$/, '/ws'));
+  ws.onopen = () => $('status').textContent = 'live';
+  ws.onclose = () => { $('status').textContent = 'disconnected, retrying…'; setTimeout(connect, 1000); };
+  ws.onmessage = msg => {
+    const ev = JSON.parse(msg.data), f = ev.frame, g = f.goroutine || 0;
+    const stack = stacks.get(g) || [];
+    if (ev.type === 'enter') { stack.push(f); stacks.set(g, stack); return; }
+    const i = stack.map(s => s.function).lastIndexOf(f.function);
+    if (i >= 0) stack.splice(i);
+    if ($('pause').checked || !shown(f)) return;
+    prepend('calls', '<div class="row' + (f.error ? ' err' : '') + '" title="' + esc(JSON.stringify(f.args || {})) + '">' +
+      esc((f.path || [f.function]).join(' → ')) + ' <b>' + ms(f.duration) + '</b>' + (f.error ? ' ' + esc(f.error) : '') + '</div>');
+    (f.logs || []).forEach(l => prepend('logrows', '<div class="row' + (l.level === 'ERROR' ? ' err' : '') + '">' +
+      esc(l.time.slice(11, 23)) + ' ' + esc(l.level) + ' <span class="dim">' + esc(f.function) + '</span> ' + esc(l.message) + '</div>'));
+  };
+}
+setInterval(renderStacks, 250);
+connect();
+</script></body></html>
+`
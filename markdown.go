@@ -0,0 +1,258 @@
+package devtrace
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Limits for ExportMarkdown so a report stays pasteable
+const (
+	markdownMaxArgs      = 4
+	markdownMaxArgLength = 60
+	markdownSnippetLines = 3
+)
+
+// callNode is a session frame placed in its call tree
+type callNode struct {
+	frame    *FrameSnapshot
+	children []*callNode
+}
+
+// ExportMarkdown renders a session as Markdown for an issue or incident doc: the
+// call tree with durations, key args and errors, the session's notes, and the
+// source around each call site in collapsible sections
+func ExportMarkdown(session *Session) string {
+	if session == nil {
+		return ""
+	}
+	var b strings.Builder
+
+	name := session.Name
+	if name == "" {
+		name = "session"
+	}
+	fmt.Fprintf(&b, "## Trace `%s`\n\n", name)
+
+	roots := buildCallTree(session.Frames)
+	errCount := 0
+	for i := range session.Frames {
+		if session.Frames[i].Error != "" {
+			errCount++
+		}
+	}
+	if start := session.Start(); !start.IsZero() {
+		fmt.Fprintf(&b, "Started %s · %d frames · %d errors\n\n", start.Format(time.RFC3339), len(session.Frames), errCount)
+	} else {
+		fmt.Fprintf(&b, "%d frames · %d errors\n\n", len(session.Frames), errCount)
+	}
+
+	if len(session.Annotations) > 0 {
+		b.WriteString("### Notes\n\n")
+		for _, note := range session.Annotations {
+			fmt.Fprintf(&b, "- %s", note.Time.Format(time.RFC3339))
+			if note.Author != "" {
+				fmt.Fprintf(&b, " **%s**", note.Author)
+			}
+			fmt.Fprintf(&b, ": %s\n", note.Text)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("### Call tree\n\n")
+	writeMarkdownRoots(&b, roots)
+
+	writeMarkdownSnippets(&b, session.Frames)
+	return b.String()
+}
+
+// writeMarkdownRoots writes the call trees, grouped under one heading per
+// operation when the session's frames have operations
+func writeMarkdownRoots(b *strings.Builder, roots []*callNode) {
+	var operations []string
+	groups := make(map[string][]*callNode)
+	for _, root := range roots {
+		op := root.frame.Operation
+		if _, seen := groups[op]; !seen {
+			operations = append(operations, op)
+		}
+		groups[op] = append(groups[op], root)
+	}
+	if len(operations) == 1 && operations[0] == "" {
+		for _, root := range roots {
+			writeMarkdownNode(b, root, 0)
+		}
+		return
+	}
+
+	for _, op := range operations {
+		if op == "" {
+			b.WriteString("#### Other calls\n\n")
+		} else {
+			fmt.Fprintf(b, "#### `%s`\n\n", markdownInline(op, 0))
+		}
+		for _, root := range groups[op] {
+			writeMarkdownNode(b, root, 0)
+		}
+		b.WriteString("\n")
+	}
+}
+
+func writeMarkdownNode(b *strings.Builder, node *callNode, depth int) {
+	frame := node.frame
+	fmt.Fprintf(b, "%s- `%s` %s", strings.Repeat("  ", depth), frame.Function, frame.Duration.Round(time.Microsecond))
+	if frame.Unfinished {
+		b.WriteString(" (unfinished)")
+	}
+	if args := markdownArgs(frame.Args); args != "" {
+		fmt.Fprintf(b, " — %s", args)
+	}
+	if frame.File != "" {
+		fmt.Fprintf(b, " · %s:%d", filepath.Base(frame.File), frame.Line)
+	}
+	if frame.Error != "" {
+		fmt.Fprintf(b, " · ❌ **%s**", markdownInline(frame.Error, 0))
+	}
+	b.WriteString("\n")
+
+	for _, child := range node.children {
+		writeMarkdownNode(b, child, depth+1)
+	}
+}
+
+// markdownArgs renders the first few args by name as inline code
+func markdownArgs(args map[string]string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, markdownMaxArgs+1)
+	for i, name := range names {
+		if i == markdownMaxArgs {
+			parts = append(parts, fmt.Sprintf("+%d more", len(names)-markdownMaxArgs))
+			break
+		}
+		parts = append(parts, "`"+name+"="+markdownInline(args[name], markdownMaxArgLength)+"`")
+	}
+	return strings.Join(parts, " ")
+}
+
+// markdownInline keeps a value on one line and out of the way of Markdown syntax
+func markdownInline(s string, limit int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.ReplaceAll(s, "`", "'")
+	if limit > 0 && len(s) > limit {
+		s = s[:limit] + "…"
+	}
+	return s
+}
+
+// writeMarkdownSnippets adds one collapsible section per distinct call site
+func writeMarkdownSnippets(b *strings.Builder, frames []FrameSnapshot) {
+	seen := make(map[string]bool)
+	wroteHeader := false
+	for i := range frames {
+		frame := &frames[i]
+		if frame.File == "" || frame.Line <= 0 {
+			continue
+		}
+		location := fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		if seen[location] {
+			continue
+		}
+		seen[location] = true
+
+		snippet := markdownSnippet(frame.File, frame.Line)
+		if snippet == "" {
+			continue
+		}
+		if !wroteHeader {
+			b.WriteString("\n### Code\n")
+			wroteHeader = true
+		}
+		fmt.Fprintf(b, "\n<details><summary><code>%s</code> %s:%d</summary>\n\n```go\n%s\n```\n\n</details>\n",
+			frame.Function, filepath.Base(frame.File), frame.Line, snippet)
+	}
+}
+
+func markdownSnippet(file string, line int) string {
+	return strings.ReplaceAll(SourceSnippet(file, line, markdownSnippetLines), "```", "'''")
+}
+
+// CallTreeNode is a session frame placed in its call tree by SessionCallTree
+type CallTreeNode struct {
+	Frame    *FrameSnapshot
+	Children []*CallTreeNode
+}
+
+// SessionCallTree nests the frames of a session into call trees, as
+// ExportMarkdown does, and returns their roots in start order
+func SessionCallTree(session *Session) []*CallTreeNode {
+	if session == nil {
+		return nil
+	}
+	var convert func(nodes []*callNode) []*CallTreeNode
+	convert = func(nodes []*callNode) []*CallTreeNode {
+		out := make([]*CallTreeNode, len(nodes))
+		for i, node := range nodes {
+			out[i] = &CallTreeNode{Frame: node.frame, Children: convert(node.children)}
+		}
+		return out
+	}
+	return convert(buildCallTree(session.Frames))
+}
+
+// buildCallTree nests session frames by time: a frame is a child of the innermost
+// frame on the same goroutine that was running when it started and ended after it
+func buildCallTree(frames []FrameSnapshot) []*callNode {
+	ordered := make([]*callNode, len(frames))
+	for i := range frames {
+		ordered[i] = &callNode{frame: &frames[i]}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i].frame, ordered[j].frame
+		if !a.StartTime.Equal(b.StartTime) {
+			return a.StartTime.Before(b.StartTime)
+		}
+		return a.Duration > b.Duration
+	})
+
+	var roots []*callNode
+	open := make(map[uint64][]*callNode)
+	for _, node := range ordered {
+		stack := open[node.frame.Goroutine]
+		for len(stack) > 0 && !containsFrame(stack[len(stack)-1].frame, node.frame) {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, node)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+		}
+		open[node.frame.Goroutine] = append(stack, node)
+	}
+	return roots
+}
+
+// containsFrame reports whether inner ran within outer
+func containsFrame(outer, inner *FrameSnapshot) bool {
+	if outer.StartTime.IsZero() || inner.StartTime.IsZero() || inner.StartTime.Before(outer.StartTime) {
+		return false
+	}
+	if outer.Unfinished || outer.EndTime.IsZero() {
+		return true
+	}
+	end := inner.EndTime
+	if inner.Unfinished || end.IsZero() {
+		return false
+	}
+	return !end.After(outer.EndTime)
+}
@@ -0,0 +1,92 @@
+package devtrace
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportMarkdownBuildsCallTree(t *testing.T) {
+	_, file, line, _ := runtime.Caller(0)
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	at := func(offset, duration time.Duration) (time.Time, time.Time) {
+		return start.Add(offset), start.Add(offset + duration)
+	}
+
+	handleStart, handleEnd := at(0, 10*time.Millisecond)
+	loadStart, loadEnd := at(time.Millisecond, 3*time.Millisecond)
+	saveStart, saveEnd := at(5*time.Millisecond, 4*time.Millisecond)
+	session := &Session{
+		Name: "run.jsonl",
+		Frames: []FrameSnapshot{
+			// Completion order: children before their parent
+			{Function: "pkg.Load", StartTime: loadStart, EndTime: loadEnd, Duration: 3 * time.Millisecond, Args: map[string]string{"id": "42"}},
+			{Function: "pkg.Save", File: file, Line: line, StartTime: saveStart, EndTime: saveEnd, Duration: 4 * time.Millisecond, Error: "disk full"},
+			{Function: "pkg.Handle", StartTime: handleStart, EndTime: handleEnd, Duration: 10 * time.Millisecond},
+		},
+		Annotations: []Annotation{{Time: start, Author: "ana", Text: "before fix"}},
+	}
+
+	out := ExportMarkdown(session)
+	for _, want := range []string{
+		"## Trace `run.jsonl`",
+		"- `pkg.Handle` 10ms\n",
+		"  - `pkg.Load` 3ms — `id=42`\n",
+		"  - `pkg.Save` 4ms · markdown_test.go:",
+		"❌ **disk full**",
+		"**ana**: before fix",
+		"<details><summary><code>pkg.Save</code> markdown_test.go:",
+		"runtime.Caller(0)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in export:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderTraceDrawsCallFlow(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	session := &Session{Frames: []FrameSnapshot{
+		{Function: "pkg.Load", StartTime: start.Add(time.Millisecond), EndTime: start.Add(3 * time.Millisecond), Duration: 2 * time.Millisecond, Args: map[string]string{"id": "42"}},
+		{Function: "pkg.Load", StartTime: start.Add(4 * time.Millisecond), EndTime: start.Add(5 * time.Millisecond), Duration: time.Millisecond, Error: "not found; retry"},
+		{Function: "pkg.Handle", StartTime: start, EndTime: start.Add(10 * time.Millisecond), Duration: 10 * time.Millisecond, Operation: "GET /users"},
+	}}
+
+	var mermaid strings.Builder
+	if err := RenderTrace(session, TraceMermaid, &mermaid); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"sequenceDiagram\n",
+		"actor c1 as GET /users\n",
+		"participant f1 as pkg.Handle\n",
+		"c1->>f1: pkg.Handle\n",
+		"f1->>f2: pkg.Load (id=42)\n",
+		"f2-->>f1: 2ms\n",
+		"f2--xf1: 1ms error: not found#59; retry\n",
+		"f1-->>c1: 10ms\n",
+	} {
+		if !strings.Contains(mermaid.String(), want) {
+			t.Fatalf("expected %q in diagram:\n%s", want, mermaid.String())
+		}
+	}
+
+	var dot strings.Builder
+	if err := RenderTrace(session, TraceDOT, &dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`n1 [label="pkg.Handle\n1 call · 10ms"];`,
+		`n2 [label="pkg.Load\n2 calls · 3ms\n1 failed", color=red];`,
+		`n1 -> n2 [label="2 calls · 3ms"];`,
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Fatalf("expected %q in graph:\n%s", want, dot.String())
+		}
+	}
+
+	if err := RenderTrace(session, "svg", &dot); err == nil {
+		t.Fatal("expected an unknown format to be rejected")
+	}
+}
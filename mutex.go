@@ -0,0 +1,279 @@
+package devtrace
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLockWaitThreshold is how long a TracedMutex or TracedRWMutex may keep
+// a goroutine waiting before the wait is logged, and how long it may be held
+// before the hold is recorded as a frame, unless the lock sets its own
+var DefaultLockWaitThreshold = 10 * time.Millisecond
+
+// TracedMutex is a sync.Mutex that, while devtrace is enabled, records waits for
+// it as "lock wait" frames of the waiting goroutine, records holds longer than
+// the threshold as "lock hold" frames, and logs a WARN with both the waiter's
+// stack and the holder's when a wait passes the threshold. Uncontended locks
+// record nothing, but still note the holder, which costs about a microsecond.
+//
+//	var mu = devtrace.TracedMutex{Name: "cache"}
+//
+// The zero value is an unlocked mutex named after the function that locks it.
+type TracedMutex struct {
+	Name      string
+	Threshold time.Duration // 0 uses DefaultLockWaitThreshold
+
+	mu     sync.Mutex
+	holder lockHolder
+}
+
+// Lock locks m, waiting for the holder to unlock it
+func (m *TracedMutex) Lock() {
+	if !IsEnabled() {
+		m.mu.Lock()
+		return
+	}
+	if !m.mu.TryLock() {
+		wait := m.holder.waitFor(m.mu.Lock)
+		m.holder.report("lock wait", m.Name, m.Threshold, wait)
+	}
+	m.holder.acquire()
+}
+
+// TryLock tries to lock m and reports whether it succeeded
+func (m *TracedMutex) TryLock() bool {
+	if !m.mu.TryLock() {
+		return false
+	}
+	if IsEnabled() {
+		m.holder.acquire()
+	}
+	return true
+}
+
+// Unlock unlocks m
+func (m *TracedMutex) Unlock() {
+	m.holder.release(m.Name, m.Threshold)
+	m.mu.Unlock()
+}
+
+// TracedRWMutex is a sync.RWMutex traced like TracedMutex. Only writers are
+// tracked as holders, so a writer kept waiting by readers reports none.
+type TracedRWMutex struct {
+	Name      string
+	Threshold time.Duration // 0 uses DefaultLockWaitThreshold
+
+	mu     sync.RWMutex
+	holder lockHolder
+}
+
+// Lock locks rw for writing
+func (rw *TracedRWMutex) Lock() {
+	if !IsEnabled() {
+		rw.mu.Lock()
+		return
+	}
+	if !rw.mu.TryLock() {
+		wait := rw.holder.waitFor(rw.mu.Lock)
+		rw.holder.report("lock wait", rw.Name, rw.Threshold, wait)
+	}
+	rw.holder.acquire()
+}
+
+// TryLock tries to lock rw for writing and reports whether it succeeded
+func (rw *TracedRWMutex) TryLock() bool {
+	if !rw.mu.TryLock() {
+		return false
+	}
+	if IsEnabled() {
+		rw.holder.acquire()
+	}
+	return true
+}
+
+// Unlock unlocks rw for writing
+func (rw *TracedRWMutex) Unlock() {
+	rw.holder.release(rw.Name, rw.Threshold)
+	rw.mu.Unlock()
+}
+
+// RLock locks rw for reading, waiting for a writer to unlock it
+func (rw *TracedRWMutex) RLock() {
+	if !IsEnabled() || rw.mu.TryRLock() {
+		return
+	}
+	wait := rw.holder.waitFor(rw.mu.RLock)
+	rw.holder.report("rlock wait", rw.Name, rw.Threshold, wait)
+}
+
+// TryRLock tries to lock rw for reading and reports whether it succeeded
+func (rw *TracedRWMutex) TryRLock() bool {
+	return rw.mu.TryRLock()
+}
+
+// RUnlock undoes a single RLock call
+func (rw *TracedRWMutex) RUnlock() {
+	rw.mu.RUnlock()
+}
+
+// RLocker returns a Locker that calls rw.RLock and rw.RUnlock
+func (rw *TracedRWMutex) RLocker() sync.Locker {
+	return rlocker{rw}
+}
+
+type rlocker struct{ rw *TracedRWMutex }
+
+func (r rlocker) Lock()   { r.rw.RLock() }
+func (r rlocker) Unlock() { r.rw.RUnlock() }
+
+// lockHolder tracks the goroutine holding a traced lock, as far as it is known
+type lockHolder struct {
+	mu      sync.Mutex
+	current lockOwner
+}
+
+// lockOwner is a goroutine that locked a traced lock; zero when unknown
+type lockOwner struct {
+	goroutine uint64
+	pc        uintptr // where Lock was called
+	since     time.Time
+}
+
+// acquire notes the calling goroutine as the holder; the caller of the traced
+// Lock method is three frames up
+func (h *lockHolder) acquire() {
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	id := goroutineID()
+
+	h.mu.Lock()
+	h.current = lockOwner{goroutine: id, pc: pcs[0], since: time.Now()}
+	h.mu.Unlock()
+}
+
+// release records the hold as a frame when it ran past threshold
+func (h *lockHolder) release(name string, threshold time.Duration) {
+	h.mu.Lock()
+	owner := h.current
+	h.current = lockOwner{}
+	h.mu.Unlock()
+
+	if owner.since.IsZero() {
+		return // locked while devtrace was disabled
+	}
+	if held := time.Since(owner.since); held > lockThreshold(threshold) {
+		recordLockFrame("lock hold", lockName(name, owner.pc), owner.since, held, nil)
+	}
+}
+
+// waitFor blocks in lock and returns the holder it found when it started
+// waiting, with the route the holder was on then
+func (h *lockHolder) waitFor(lock func()) lockWait {
+	h.mu.Lock()
+	holder := h.current
+	h.mu.Unlock()
+
+	wait := lockWait{holder: holder, route: goroutineRoute(holder.goroutine), start: time.Now()}
+	lock()
+	wait.duration = time.Since(wait.start)
+	return wait
+}
+
+// lockWait is one wait for a traced lock
+type lockWait struct {
+	holder   lockOwner
+	route    string
+	start    time.Time
+	duration time.Duration
+}
+
+// report records a wait as a frame of the waiting goroutine and warns when it
+// ran past threshold
+func (h *lockHolder) report(kind, name string, threshold time.Duration, wait lockWait) {
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	name = lockName(name, pcs[0])
+
+	holder := describeLockHolder(wait)
+	recordLockFrame(kind, name, wait.start, wait.duration, map[string]interface{}{"holder": holder})
+
+	if limit := lockThreshold(threshold); wait.duration > limit && GlobalEnhancedLogger != nil {
+		GlobalEnhancedLogger.Warn(context.Background(), "🔒 lock contention: waited %v for %s (threshold %v), held by %s",
+			wait.duration.Round(time.Microsecond), name, limit, holder)
+	}
+}
+
+func recordLockFrame(kind, name string, start time.Time, duration time.Duration, args map[string]interface{}) {
+	end := start.Add(duration)
+	recordChild(CurrentContext(), &Frame{
+		Function:  kind + " " + name,
+		Args:      args,
+		StartTime: start,
+		EndTime:   end,
+		Duration:  duration,
+	})
+}
+
+func lockThreshold(threshold time.Duration) time.Duration {
+	if threshold > 0 {
+		return threshold
+	}
+	return DefaultLockWaitThreshold
+}
+
+// lockName is the name of a traced lock, or the function at pc when it has none
+func lockName(name string, pc uintptr) string {
+	if name != "" {
+		return name
+	}
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		return shortFunctionName(fn.Name())
+	}
+	return "mutex"
+}
+
+// describeLockHolder renders the holder a wait found: its goroutine, where it
+// locked, how long it had held the lock once the wait ended, and its route
+func describeLockHolder(wait lockWait) string {
+	holder := wait.holder
+	if holder.goroutine == 0 {
+		return "unknown goroutine"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "goroutine %d", holder.goroutine)
+	if frame, _ := runtime.CallersFrames([]uintptr{holder.pc}).Next(); frame.Function != "" {
+		file, line := originalPosition(frame.File, frame.Line)
+		fmt.Fprintf(&b, " at %s (%s:%d)", shortFunctionName(frame.Function), filepath.Base(file), line)
+	}
+	if !holder.since.IsZero() {
+		fmt.Fprintf(&b, " for %v", wait.start.Add(wait.duration).Sub(holder.since).Round(time.Microsecond))
+	}
+	if wait.route != "" {
+		fmt.Fprintf(&b, ", route: %s", wait.route)
+	}
+	return b.String()
+}
+
+// goroutineRoute renders the open frames of a goroutine's trace context. They
+// are read without synchronization, like ActiveContexts.
+func goroutineRoute(id uint64) string {
+	if id == 0 {
+		return ""
+	}
+	goroutineMu.RLock()
+	tc := goroutineContexts[id]
+	goroutineMu.RUnlock()
+
+	frames := tc.Stack()
+	names := make([]string, len(frames))
+	for i, frame := range frames {
+		names[i] = shortFunctionName(frame.Function)
+	}
+	return strings.Join(names, " → ")
+}
@@ -0,0 +1,39 @@
+package devtrace
+
+import "context"
+
+// SetOperation names what the trace in ctx is serving, e.g. "GET /users/:id" or
+// "ConsumeOrderCreated", as opposed to which function is running. Frames
+// entered from now on inherit it, and stats, exports and the debug UI group by
+// it first.
+//
+// The name goes on the current frame of ctx's trace context, or on the context
+// itself while no frame is open. Without either, as in an untraced goroutine,
+// there is nothing to name and the call does nothing.
+func SetOperation(ctx context.Context, name string) {
+	tc := FromContext(ctx)
+	if frame := tc.current(); frame != nil {
+		frame.Operation = name
+		return
+	}
+	if tc.goroutine == 0 {
+		tc.Operation = name
+	}
+}
+
+// CurrentOperation returns the operation the trace in ctx is serving, "" if none
+func CurrentOperation(ctx context.Context) string {
+	return FromContext(ctx).operation()
+}
+
+// operation is what a frame entered now inherits: the operation of the current
+// frame, or of the context itself
+func (tc *TraceContext) operation() string {
+	if tc == nil {
+		return ""
+	}
+	if frame := tc.current(); frame != nil {
+		return frame.Operation
+	}
+	return tc.Operation
+}
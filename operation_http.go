@@ -0,0 +1,55 @@
+//go:build !devtrace_minimal
+
+package devtrace
+
+import (
+	"net/http"
+	"time"
+)
+
+// OperationMiddleware serves each request inside a root frame named after its
+// operation, so every frame the handler enters on the request's goroutine
+// serves that operation. name maps a request to its operation and should use
+// the route pattern rather than the raw path, e.g. "GET /users/:id"; nil uses
+// the method and path. A request carrying W3C traceparent and baggage headers
+// continues that trace with that baggage.
+func OperationMiddleware(name func(r *http.Request) string, next http.Handler) http.Handler {
+	return operationHandler(name, next, nil)
+}
+
+// operationHandler serves OperationMiddleware, writing to access when it isn't nil
+func operationHandler(name func(r *http.Request) string, next http.Handler, access *AccessLog) http.Handler {
+	if name == nil {
+		name = func(r *http.Request) string { return r.Method + " " + r.URL.Path }
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := name(r)
+		var tc *TraceContext
+		if access != nil {
+			start := time.Now()
+			lw := &accessLogWriter{ResponseWriter: w}
+			w = lw
+			defer func() { access.log(lw, r, start, op, tc) }()
+		}
+		if !IsEnabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		frame := CreateFrame(op, "", "", 0, nil)
+		frame.Operation = op
+		traceCtx := CurrentContext()
+		if traceCtx.current() == nil {
+			continueTraceparent(traceCtx, r.Header.Get(TraceparentHeader))
+			traceCtx.baggage = decodeBaggage(r.Header.Get(BaggageHeader))
+		}
+		traceCtx.Enter(frame)
+		defer GlobalLeave()
+
+		if access != nil {
+			tc = traceCtx
+			tc.EnsureTraceID()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,400 @@
+//go:build !devtrace_minimal
+
+package devtrace
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOperationMiddlewareGroupsFrames(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() {
+		SetConfig(original)
+		DisableRecorder()
+		ResetFunctionStats()
+	})
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+	EnableRecorder(10)
+	ResetFunctionStats()
+
+	var seen string
+	handler := OperationMiddleware(func(r *http.Request) string { return "GET /users/:id" },
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			EnterContext(r.Context(), CreateFrame("loadUser", "", "users.go", 12, nil))
+			seen = CurrentOperation(r.Context())
+			LeaveContext(r.Context())
+		}))
+	for _, path := range []string{"/users/1", "/users/2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if seen != "GET /users/:id" {
+		t.Fatalf("handler frames should inherit the operation, got %q", seen)
+	}
+	ops := AllOperationStats()
+	if len(ops) != 1 || ops[0].Operation != "GET /users/:id" || ops[0].Calls != 2 {
+		t.Fatalf("unexpected operation stats: %+v", ops)
+	}
+	if fns := ops[0].Functions; len(fns) != 2 || fns[0].Calls != 2 || fns[1].Calls != 2 {
+		t.Fatalf("expected the request frame and loadUser under the operation, got %+v", fns)
+	}
+	if recent := RecentTraces(TraceFilter{Operation: "GET /users/:id", Function: "loadUser"}); len(recent) != 2 {
+		t.Fatalf("expected 2 recorded loadUser frames for the operation, got %d", len(recent))
+	}
+}
+
+func TestTraceOptionsOperationIsInherited(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() {
+		SetConfig(original)
+		ResetFunctionStats()
+	})
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	var inner string
+	consume := TraceTyped(func(ctx context.Context) {
+		EnterContext(ctx, CreateFrame("decodeOrder", "", "orders.go", 30, nil))
+		inner = CurrentOperation(ctx)
+		LeaveContext(ctx)
+	}, &TraceOptions{SkipFrames: 2, Operation: "ConsumeOrderCreated"})
+
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	consume(ctx)
+	if inner != "ConsumeOrderCreated" {
+		t.Fatalf("nested frame should serve the traced function's operation, got %q", inner)
+	}
+
+	tc := NewTraceContext()
+	ctx = WithTraceContext(context.Background(), tc)
+	SetOperation(ctx, "nightly-report")
+	tc.Enter(CreateFrame("buildReport", "", "report.go", 5, nil))
+	frame := tc.Leave()
+	if frame.Operation != "nightly-report" {
+		t.Fatalf("root frame should inherit the context's operation, got %q", frame.Operation)
+	}
+}
+
+func TestTransportRecordsConnectionPhases(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() {
+		SetConfig(original)
+		DisableRecorder()
+	})
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+	EnableRecorder(10)
+
+	var parent string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parent = r.Header.Get(ParentFrameHeader)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: Transport(server.Client().Transport)}
+
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/users?token=secret", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	frames := RecentTraces(TraceFilter{})
+	byName := make(map[string]RecordedFrame)
+	for _, f := range frames {
+		byName[f.Frame.Function] = f
+	}
+	host := strings.TrimPrefix(server.URL, "https://")
+	request, ok := byName["HTTP GET "+host+"/users"]
+	if !ok {
+		t.Fatalf("no request frame among %d frames", len(frames))
+	}
+	if request.Frame.Args["status"] != http.StatusTeapot || strings.Contains(request.Frame.Args["url"].(string), "secret") {
+		t.Fatalf("unexpected request args: %v", request.Frame.Args)
+	}
+	if parent != request.Frame.Function {
+		t.Fatalf("server saw parent frame %q", parent)
+	}
+	for _, phase := range []string{"HTTP connect", "HTTP TLS handshake", "HTTP time to first byte"} {
+		child, ok := byName[phase]
+		if !ok {
+			t.Fatalf("missing %s frame", phase)
+		}
+		if len(child.Path) != 2 || child.Path[0] != request.Frame.Function {
+			t.Fatalf("%s should be a child of the request: %v", phase, child.Path)
+		}
+	}
+}
+
+func TestAccessLogCarriesTraceIDAndTopFrame(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	var out bytes.Buffer
+	access := &AccessLog{Out: &out, Format: AccessLogJSON}
+	handler := access.Middleware(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		EnterContext(r.Context(), CreateFrame("fast", "", "", 0, nil))
+		LeaveContext(r.Context())
+		EnterContext(r.Context(), CreateFrame("slow", "", "", 0, nil))
+		time.Sleep(2 * time.Millisecond)
+		LeaveContext(r.Context())
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders?id=1", nil))
+
+	var entry AccessLogEntry
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+	if entry.Status != http.StatusCreated || entry.Bytes != 4 || entry.URI != "/orders?id=1" || entry.Operation != "POST /orders" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	if len(entry.TraceID) != 32 || !strings.HasPrefix(entry.TopFrame, "slow ") {
+		t.Fatalf("expected trace ID and slow top frame: %+v", entry)
+	}
+
+	out.Reset()
+	access.Format = AccessLogCombined
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	if line := out.String(); !strings.Contains(line, `"GET /health HTTP/1.1" 201 4 "-" "-" trace_id="`) {
+		t.Fatalf("unexpected combined line: %s", line)
+	}
+}
+
+func TestTraceparentPropagatesAcrossServices(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var traceID, remoteSpan, outgoing string
+	handler := OperationMiddleware(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc := CurrentContext()
+		traceID, remoteSpan = tc.TraceID, tc.RemoteSpanID
+		header := http.Header{}
+		InjectTraceparent(r.Context(), header)
+		outgoing = header.Get(TraceparentHeader)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/charge", nil)
+	req.Header.Set(TraceparentHeader, incoming)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || remoteSpan != "00f067aa0ba902b7" {
+		t.Fatalf("expected the incoming trace to continue, got trace %q parent %q", traceID, remoteSpan)
+	}
+	gotTrace, parent, sampled, err := ParseTraceparent(outgoing)
+	if err != nil || gotTrace != traceID || parent == remoteSpan || !sampled {
+		t.Fatalf("expected a child traceparent, got %q (%v)", outgoing, err)
+	}
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, outgoing)
+	ctx := ExtractTraceparent(context.Background(), header)
+	if tc := FromContext(ctx); tc.TraceID != traceID || tc.RemoteSpanID != parent {
+		t.Fatalf("unexpected extracted context: %+v", tc)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, _, _, err := ParseTraceparent(invalid); err == nil {
+			t.Fatalf("expected %q to be rejected", invalid)
+		}
+	}
+	if _, _, _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); err != nil {
+		t.Fatalf("expected future versions to parse: %v", err)
+	}
+}
+
+func TestWrapTransportCountsRetriesAndLogsFailures(t *testing.T) {
+	original := CurrentConfig()
+	originalEnhanced := GlobalEnhancedLogger
+	t.Cleanup(func() {
+		SetConfig(original)
+		GlobalEnhancedLogger = originalEnhanced
+	})
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+	logger := &captureLogger{}
+	GlobalEnhancedLogger = NewEnhancedLogger(&StackLoggerOptions{})
+	GlobalEnhancedLogger.SetLogger(logger)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: WrapTransport(nil)}
+
+	var retries []interface{}
+	unregister := RegisterHook(Hook{OnExit: func(f *Frame) {
+		if strings.HasPrefix(f.Function, "HTTP GET") {
+			retries = append(retries, f.Args["retry"])
+		}
+	}})
+	defer unregister()
+
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	EnterContext(ctx, CreateFrame("syncUsers", "", "sync.go", 1, nil))
+	for attempt := 0; attempt < 3; attempt++ {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/users", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	LeaveContext(ctx)
+
+	if len(retries) != 3 || retries[0] != nil || retries[1] != 1 || retries[2] != 2 {
+		t.Fatalf("unexpected retry counts: %v", retries)
+	}
+	if len(logger.messages) != 2 || !strings.Contains(logger.messages[1], "returned 503 Service Unavailable (retry 1)") {
+		t.Fatalf("expected the 5xx responses to be logged, got %q", logger.messages)
+	}
+}
+
+func TestLiveUIStreamsFramesOverWebSocket(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	server := httptest.NewServer(LiveUIHandler())
+	defer server.Close()
+
+	raw, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	raw.SetDeadline(time.Now().Add(5 * time.Second))
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Write(raw)
+
+	rw := bufio.NewReadWriter(bufio.NewReader(raw), bufio.NewWriter(raw))
+	resp, err := http.ReadResponse(rw.Reader, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected handshake response: %v %v", resp.Status, resp.Header)
+	}
+
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(time.Millisecond) {
+		live.mu.Lock()
+		joined := len(live.clients) > 0
+		live.mu.Unlock()
+		if joined {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the browser never joined the live hub")
+		}
+	}
+	tc := NewTraceContext()
+	tc.Enter(CreateFrame("checkout", "", "shop.go", 7, nil))
+	tc.Leave()
+
+	client := &wsConn{conn: raw, rw: rw}
+	var events []LiveEvent
+	for len(events) < 2 {
+		opcode, payload, err := client.readFrame()
+		if err != nil || opcode != wsText {
+			t.Fatalf("unexpected frame %d: %v", opcode, err)
+		}
+		var event LiveEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	if events[0].Type != "enter" || events[1].Type != "exit" || events[1].Frame.Function != "checkout" || len(events[1].Frame.Path) != 1 {
+		t.Fatalf("unexpected events: %+v %+v", events[0], events[1])
+	}
+
+	page := httptest.NewRecorder()
+	LiveUIHandler().ServeHTTP(page, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(page.Body.String(), "new WebSocket(") {
+		t.Fatal("expected the UI page")
+	}
+}
+
+func TestLiveUIRejectsCrossOriginWebSocket(t *testing.T) {
+	t.Cleanup(func() { AllowWebSocketOrigins() })
+	server := httptest.NewServer(LiveUIHandler())
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	handshake := func(origin string) int {
+		t.Helper()
+		raw, err := net.Dial("tcp", host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer raw.Close()
+		raw.SetDeadline(time.Now().Add(5 * time.Second))
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		req.Write(raw)
+		resp, err := http.ReadResponse(bufio.NewReader(raw), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	for _, tc := range []struct {
+		origin string
+		want   int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{server.URL, http.StatusSwitchingProtocols},
+		{"https://evil.example", http.StatusForbidden},
+		{"http://" + host + ".evil.example", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	} {
+		if got := handshake(tc.origin); got != tc.want {
+			t.Errorf("origin %q: got status %d, want %d", tc.origin, got, tc.want)
+		}
+	}
+
+	AllowWebSocketOrigins("https://Dashboard.example/")
+	if got := handshake("https://dashboard.example"); got != http.StatusSwitchingProtocols {
+		t.Errorf("expected an allowed origin to connect, got status %d", got)
+	}
+	if got := handshake("https://evil.example"); got != http.StatusForbidden {
+		t.Errorf("expected other origins to stay rejected, got status %d", got)
+	}
+	AllowWebSocketOrigins("*")
+	if got := handshake("https://evil.example"); got != http.StatusSwitchingProtocols {
+		t.Errorf("expected * to allow any origin, got status %d", got)
+	}
+}
@@ -0,0 +1,79 @@
+package devtrace
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// orphanedFrames counts frames that were entered but never left, across all contexts
+var orphanedFrames int64
+
+// OrphanedFrameCount returns the number of frames closed as unfinished since process start
+func OrphanedFrameCount() int64 {
+	return atomic.LoadInt64(&orphanedFrames)
+}
+
+// Close ends the trace session. Frames that were entered but never left
+// (missed defer, panic path, leaked goroutine) are popped, marked Unfinished
+// with their open duration, reported to GlobalLogger and returned outermost first.
+func (tc *TraceContext) Close() []*Frame {
+	if tc == nil {
+		return nil
+	}
+
+	tc.overflow = 0
+	tc.unmatched = 0
+	tc.mu.Lock()
+	tc.Depth = 0
+	orphans := tc.Frames
+	if len(orphans) > 0 {
+		tc.Frames = make([]*Frame, 0)
+	}
+	tc.mu.Unlock()
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	markIdle(tc)
+	unregisterGoroutineContext(tc)
+
+	closeOrphans(orphans)
+	return orphans
+}
+
+// CloseGlobalContext closes the global trace context and every goroutine context
+// (see CurrentContext), and returns their unfinished frames. Call it at shutdown:
+// goroutines still running may race with it.
+func CloseGlobalContext() []*Frame {
+	var orphans []*Frame
+	for _, tc := range goroutineContextList() {
+		orphans = append(orphans, tc.Close()...)
+	}
+
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+
+	if globalContext != nil {
+		orphans = append(orphans, globalContext.Close()...)
+	}
+	return orphans
+}
+
+func closeOrphans(orphans []*Frame) {
+	now := time.Now()
+	for _, frame := range orphans {
+		frame.retain()
+		frame.Unfinished = true
+		frame.EndTime = now
+		if !frame.StartTime.IsZero() {
+			frame.Duration = now.Sub(frame.StartTime)
+		}
+
+		if GlobalLogger != nil {
+			GlobalLogger.Warn("⚠ unfinished frame: %s (%s:%d) open for %v",
+				frame.Function, frame.File, frame.Line, frame.Duration)
+		}
+	}
+
+	atomic.AddInt64(&orphanedFrames, int64(len(orphans)))
+}
@@ -0,0 +1,145 @@
+package devtrace
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// overheadBatch is the number of operations timed together per sample, since a
+// single enter/leave is too short to time on its own
+const overheadBatch = 100
+
+// OverheadCase is the measured cost of one devtrace feature
+type OverheadCase struct {
+	Name        string  `json:"name"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	OverheadNs  float64 `json:"overhead_ns"` // NsPerOp minus that of the untraced baseline
+	AllocsPerOp uint64  `json:"allocs_per_op"`
+	BytesPerOp  uint64  `json:"bytes_per_op"`
+}
+
+// OverheadReport is what MeasureOverhead found on this machine
+type OverheadReport struct {
+	Baseline    OverheadCase          `json:"baseline"`
+	Cases       []OverheadCase        `json:"cases"`
+	Environment *BenchmarkEnvironment `json:"environment,omitempty"`
+}
+
+// MeasureOverhead runs the standard set of devtrace micro-benchmarks for about
+// 100ms each, so a team can see what each feature costs on its own hardware
+// before enabling it
+func MeasureOverhead() *OverheadReport {
+	return MeasureOverheadWithOptions(BenchmarkOptions{Duration: 100 * time.Millisecond, Warmup: 100})
+}
+
+// MeasureOverheadWithOptions is MeasureOverhead with the warmup and length of
+// each benchmark given by opts; Name and GoBenchOutput are ignored. The cases
+// run with tracing enabled and logging silenced, whatever the configuration;
+// both are restored afterwards. Frames they create show up in function stats
+// and the recorder as devtrace.MeasureOverhead.
+func MeasureOverheadWithOptions(opts BenchmarkOptions) *OverheadReport {
+	if opts.Iterations <= 0 && opts.Duration <= 0 {
+		opts.Duration = 100 * time.Millisecond
+	}
+
+	original := CurrentConfig()
+	originalLogger := GlobalLogger
+	defer func() {
+		SetConfig(original)
+		SetLogger(originalLogger)
+	}()
+	SetLogger(discardLogger{})
+	UpdateConfig(func(c *DevTraceConfig) {
+		c.Enabled = true
+		c.SampleRate = 1
+		c.SlowThreshold = 0
+		c.ShowTiming = false
+	})
+
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	_, file, line, _ := runtime.Caller(0)
+	const name = "devtrace.MeasureOverhead"
+
+	var sink int
+	work := func(ctx context.Context, n int) int { return n*31 + 7 }
+	traced := Trace(work, &TraceOptions{}).(func(context.Context, int) int)
+	noArgs := WithConfig(ctx, ConfigOverrides{ShowArgs: Override(false)})
+
+	frame := &Frame{Function: name, File: file, Line: line, Args: map[string]interface{}{"id": 42, "name": "gopher"}}
+	plain := NewEnhancedLogger(&StackLoggerOptions{})
+	withSnippet := NewEnhancedLogger(&StackLoggerOptions{ShowSnippet: 3})
+
+	cases := []struct {
+		name string
+		op   func(i int)
+	}{
+		{"enter/leave", func(i int) {
+			EnterContext(ctx, CreateFrame(name, "", file, line, nil))
+			LeaveContext(ctx)
+		}},
+		{"enter/leave with args", func(i int) {
+			EnterContext(ctx, CreateFrame(name, "", file, line, map[string]interface{}{"id": i, "name": "gopher"}))
+			LeaveContext(ctx)
+		}},
+		{"Trace wrapper", func(i int) { sink = traced(noArgs, i) }},
+		{"Trace wrapper with args", func(i int) { sink = traced(ctx, i) }},
+		{"format frame", func(i int) { _ = plain.formatFrame(frame, 0) }},
+		{"format frame with snippet", func(i int) { _ = withSnippet.formatFrame(frame, 0) }},
+	}
+
+	report := &OverheadReport{
+		Baseline:    measureOverheadCase("untraced call", func(i int) { sink = work(ctx, i) }, opts),
+		Environment: CaptureBenchmarkEnvironment(),
+	}
+	for _, c := range cases {
+		measured := measureOverheadCase(c.name, c.op, opts)
+		measured.OverheadNs = measured.NsPerOp - report.Baseline.NsPerOp
+		report.Cases = append(report.Cases, measured)
+	}
+	_ = sink
+	return report
+}
+
+// measureOverheadCase times op in batches of overheadBatch and reports per op
+func measureOverheadCase(name string, op func(i int), opts BenchmarkOptions) OverheadCase {
+	result := measureFunc(func() {
+		for i := 0; i < overheadBatch; i++ {
+			op(i)
+		}
+	}, opts)
+	return OverheadCase{
+		Name:        name,
+		NsPerOp:     result.nsPerOp() / overheadBatch,
+		AllocsPerOp: result.AllocsPerOp / overheadBatch,
+		BytesPerOp:  result.BytesPerOp / overheadBatch,
+	}
+}
+
+// String renders the report as a table
+func (r *OverheadReport) String() string {
+	var b strings.Builder
+	if r.Environment != nil {
+		fmt.Fprintf(&b, "%s\n", r.Environment)
+	}
+	fmt.Fprintf(&b, "%-28s %12s %12s %10s %10s\n", "case", "ns/op", "overhead", "allocs/op", "B/op")
+	row := func(c OverheadCase, overhead string) {
+		fmt.Fprintf(&b, "%-28s %12.1f %12s %10d %10d\n", c.Name, c.NsPerOp, overhead, c.AllocsPerOp, c.BytesPerOp)
+	}
+	row(r.Baseline, "-")
+	for _, c := range r.Cases {
+		row(c, fmt.Sprintf("+%.1f", c.OverheadNs))
+	}
+	return b.String()
+}
+
+// discardLogger drops everything logged while overhead is measured
+type discardLogger struct{}
+
+func (discardLogger) Log(level string, msg string, args ...interface{}) {}
+func (discardLogger) Debug(msg string, args ...interface{})             {}
+func (discardLogger) Info(msg string, args ...interface{})              {}
+func (discardLogger) Warn(msg string, args ...interface{})              {}
+func (discardLogger) Error(msg string, args ...interface{})             {}
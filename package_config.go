@@ -0,0 +1,74 @@
+package devtrace
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// selfPackage is this package's import path, for telling its own frames apart
+var selfPackage = reflect.TypeOf(PackageOverride{}).PkgPath()
+
+// PackageOverride applies config overrides to functions in a package subtree.
+// Package is an import path or a path suffix such as "internal/payments";
+// it matches that package and everything below it.
+type PackageOverride struct {
+	Package string
+	ConfigOverrides
+}
+
+var packageOverrides []PackageOverride
+
+// SetPackageOverrides replaces the per-package overrides. When several entries
+// match a function the longest Package wins; WithConfig scopes still take precedence.
+func SetPackageOverrides(overrides []PackageOverride) {
+	sorted := make([]PackageOverride, 0, len(overrides))
+	for _, override := range overrides {
+		override.Package = strings.Trim(override.Package, "/")
+		if override.Package != "" {
+			sorted = append(sorted, override)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].Package) > len(sorted[j].Package) })
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	packageOverrides = sorted
+}
+
+// PackageOverrides returns the current per-package overrides, longest package first
+func PackageOverrides() []PackageOverride {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return append([]PackageOverride(nil), packageOverrides...)
+}
+
+// packageOverrideFor returns the most specific override for a fully qualified function name
+func packageOverrideFor(function string) *ConfigOverrides {
+	if function == "" {
+		return nil
+	}
+
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	if len(packageOverrides) == 0 {
+		return nil
+	}
+
+	pkg := functionPackage(function)
+	for i := range packageOverrides {
+		if packageMatches(pkg, packageOverrides[i].Package) {
+			overrides := packageOverrides[i].ConfigOverrides
+			return &overrides
+		}
+	}
+	return nil
+}
+
+func packageMatches(pkg, pattern string) bool {
+	if hasPathPrefix(pkg, pattern) {
+		return true
+	}
+	// suffix patterns: "internal/payments" matches "github.com/acme/app/internal/payments/..."
+	return strings.HasSuffix(pkg, "/"+pattern) || strings.Contains(pkg, "/"+pattern+"/")
+}
@@ -0,0 +1,43 @@
+package devtrace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPackageOverridesResolveLongestMatch(t *testing.T) {
+	originalConfig := CurrentConfig()
+	t.Cleanup(func() {
+		SetConfig(originalConfig)
+		SetPackageOverrides(nil)
+	})
+
+	cfg := originalConfig
+	cfg.StackLimit = 5
+	cfg.ShowArgs = true
+	SetConfig(cfg)
+
+	SetPackageOverrides([]PackageOverride{
+		{Package: "internal", ConfigOverrides: ConfigOverrides{StackLimit: Override(8)}},
+		{Package: "internal/payments", ConfigOverrides: ConfigOverrides{StackLimit: Override(20), ShowArgs: Override(false)}},
+	})
+
+	ctx := context.Background()
+	if got := configFor(ctx, "github.com/acme/shop/internal/payments/stripe.Charge").StackLimit; got != 20 {
+		t.Fatalf("expected payments override, got StackLimit=%d", got)
+	}
+	if got := configFor(ctx, "github.com/acme/shop/internal/orders.Place").StackLimit; got != 8 {
+		t.Fatalf("expected internal override, got StackLimit=%d", got)
+	}
+	if got := configFor(ctx, "github.com/acme/shop/api.Serve").StackLimit; got != 5 {
+		t.Fatalf("expected global StackLimit, got %d", got)
+	}
+	if captureArgsFor(ctx, "github.com/acme/shop/internal/payments.Refund") {
+		t.Fatalf("payments override should disable argument capture")
+	}
+
+	scoped := WithConfig(ctx, ConfigOverrides{StackLimit: Override(2)})
+	if got := configFor(scoped, "github.com/acme/shop/internal/payments.Refund").StackLimit; got != 2 {
+		t.Fatalf("context scope should win over package override, got %d", got)
+	}
+}
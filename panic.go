@@ -0,0 +1,95 @@
+package devtrace
+
+import (
+	"context"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// panicHandlerInstalled enables panic dumps from traced functions
+var panicHandlerInstalled int32
+
+// InstallPanicHandler makes traced functions dump the devtrace frame stack
+// (with args and snippets) together with the runtime stack when a panic passes
+// through them. The panic is then re-raised unchanged, so the process still
+// crashes (or is recovered upstream) as it would without devtrace.
+func InstallPanicHandler() {
+	atomic.StoreInt32(&panicHandlerInstalled, 1)
+}
+
+// UninstallPanicHandler disables the dumps enabled by InstallPanicHandler
+func UninstallPanicHandler() {
+	atomic.StoreInt32(&panicHandlerInstalled, 0)
+}
+
+// RecoverWithTrace recovers a panic and logs it with the devtrace stack of ctx
+// and the runtime stack. It must be deferred directly:
+//
+//	defer devtrace.RecoverWithTrace(ctx)
+func RecoverWithTrace(ctx context.Context) {
+	if r := recover(); r != nil {
+		dumpPanic(ctx, r, debug.Stack())
+	}
+}
+
+// RecordPanic marks the calling goroutine's current frame as panicked and
+// re-raises the panic, so it propagates exactly as before. recover only works
+// when called by the deferred function itself, so the value is passed in:
+//
+//	defer func() { devtrace.RecordPanic(recover()) }()
+func RecordPanic(recovered interface{}) {
+	if recovered == nil {
+		return // the usual case; skips looking up the goroutine
+	}
+	recordPanic(context.Background(), CurrentContext(), recovered)
+}
+
+// RecordPanicContext is RecordPanic for frames entered with EnterContext(ctx, ...)
+func RecordPanicContext(ctx context.Context, recovered interface{}) {
+	recordPanic(ctx, FromContext(ctx), recovered)
+}
+
+func recordPanic(ctx context.Context, tc *TraceContext, recovered interface{}) {
+	if recovered == nil {
+		return
+	}
+
+	if tc != nil && tc.overflow == 0 {
+		if frame := tc.current(); frame != nil {
+			frame.Panic = recovered
+			runPanicHooks(frame, recovered)
+		}
+	}
+	reportPanic(WithTraceContext(ctx, tc), recovered)
+
+	panic(recovered)
+}
+
+// reportPanic dumps a panic seen by a traced function once per trace context
+func reportPanic(ctx context.Context, recovered interface{}) {
+	if atomic.LoadInt32(&panicHandlerInstalled) == 0 {
+		return
+	}
+
+	traceCtx := FromContext(ctx)
+	if traceCtx.panicReported {
+		return
+	}
+	traceCtx.panicReported = true
+
+	dumpPanic(ctx, recovered, debug.Stack())
+}
+
+// clearPanicReport re-arms panic dumps once the outermost frame has left
+func clearPanicReport(ctx context.Context) {
+	if traceCtx := FromContext(ctx); traceCtx.GetDepth() == 0 {
+		traceCtx.panicReported = false
+	}
+}
+
+func dumpPanic(ctx context.Context, recovered interface{}, runtimeStack []byte) {
+	if GlobalEnhancedLogger == nil {
+		return
+	}
+	GlobalEnhancedLogger.Error(ctx, "💥 panic: %v\n\nRuntime stack:\n%s", recovered, runtimeStack)
+}
@@ -0,0 +1,148 @@
+package devtrace
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTracedPanicIsDumpedAndReraised(t *testing.T) {
+	originalConfig := Config
+	originalLogger := GlobalLogger
+	originalEnhanced := GlobalEnhancedLogger
+	t.Cleanup(func() {
+		SetConfig(originalConfig)
+		GlobalLogger = originalLogger
+		GlobalEnhancedLogger = originalEnhanced
+		UninstallPanicHandler()
+	})
+
+	cfg := Config
+	cfg.Enabled = true
+	cfg.ShowTiming = false
+	SetConfig(cfg)
+	logger := &captureLogger{}
+	GlobalLogger = logger
+	InstallStackLogger(&StackLoggerOptions{Prefix: "STACK", Limit: 5, Ascending: true})
+	InstallPanicHandler()
+
+	explode := TraceFunc(func(ctx context.Context) { panic("boom") }, "explode").(func(context.Context))
+	outer := TraceFunc(func(ctx context.Context) { explode(ctx) }, "outer").(func(context.Context))
+
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected original panic value, got %v", r)
+			}
+		}()
+		outer(ctx)
+	}()
+
+	dumps := 0
+	for _, msg := range logger.messages {
+		if strings.Contains(msg, "💥 panic: boom") {
+			dumps++
+			if !strings.Contains(msg, "Route: outer → explode") || !strings.Contains(msg, "Runtime stack:") {
+				t.Fatalf("panic dump missing stacks: %s", msg)
+			}
+		}
+	}
+	if dumps != 1 {
+		t.Fatalf("expected a single panic dump, got %d", dumps)
+	}
+	if FromContext(ctx).GetDepth() != 0 {
+		t.Fatalf("frames left open after panic")
+	}
+}
+
+func TestRecordPanicMarksFrameAndRepanics(t *testing.T) {
+	t.Cleanup(DisableRecorder)
+	EnableRecorder(4)
+
+	instrumented := func() {
+		GlobalEnter(CreateFrame("instrumented", "instrumented()", "panic_test.go", 1, nil))
+		defer GlobalLeave()
+		defer func() { RecordPanic(recover()) }()
+		panic("boom")
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected the panic to propagate unchanged, got %v", r)
+			}
+		}()
+		instrumented()
+	}()
+
+	records := RecentTraces(TraceFilter{})
+	if len(records) != 1 || records[0].Frame.Panic != "boom" {
+		t.Fatalf("expected the frame to record the panic, got %+v", records)
+	}
+	if depth := CurrentContext().GetDepth(); depth != 0 {
+		t.Fatalf("expected the frame to be left, depth %d", depth)
+	}
+}
+
+func TestFatalAndPanicWithStackKeepLogSemantics(t *testing.T) {
+	originalConfig := Config
+	originalLogger := GlobalLogger
+	originalEnhanced := GlobalEnhancedLogger
+	t.Cleanup(func() {
+		SetConfig(originalConfig)
+		GlobalLogger = originalLogger
+		GlobalEnhancedLogger = originalEnhanced
+		exit = os.Exit
+	})
+
+	cfg := Config
+	cfg.Enabled = true
+	SetConfig(cfg)
+	logger := &captureLogger{}
+	GlobalLogger = logger
+	InstallStackLogger(&StackLoggerOptions{Prefix: "STACK", Limit: 5, Ascending: true})
+
+	code := -1
+	exit = func(c int) { code = c }
+	FatalfWithStack(context.Background(), "config %s: %d%% missing", "app.yaml", 50)
+	if code != 1 {
+		t.Fatalf("expected exit status 1, got %d", code)
+	}
+	if last := logger.messages[len(logger.messages)-1]; !strings.Contains(last, "STACK") || !strings.Contains(last, "config app.yaml: 50% missing") {
+		t.Fatalf("expected the message with a stack, got %q", last)
+	}
+
+	defer func() {
+		if r := recover(); r != "lost 3 jobs" {
+			t.Fatalf("expected log.Panic's value, got %#v", r)
+		}
+	}()
+	PanicWithStack(context.Background(), "lost ", 3, " jobs")
+}
+
+func TestPrintWithStackFormatsLikeLog(t *testing.T) {
+	originalLogger := GlobalLogger
+	originalEnhanced := GlobalEnhancedLogger
+	t.Cleanup(func() {
+		GlobalLogger = originalLogger
+		GlobalEnhancedLogger = originalEnhanced
+		exit = os.Exit
+	})
+	logger := &captureLogger{}
+	GlobalLogger = logger
+	GlobalEnhancedLogger = nil
+	exit = func(int) {}
+
+	ctx := context.Background()
+	PrintWithStack(ctx, "user", 42, "loaded")
+	PrintfWithStack(ctx, "user %d: %s", 42, "loaded")
+	PrintlnWithStack(ctx, "user", 42, "loaded")
+	FatallnWithStack(ctx, "lost", 3, "jobs")
+
+	want := []string{"user42loaded", "user 42: loaded", "user 42 loaded", "lost 3 jobs"}
+	if strings.Join(logger.messages, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %q, got %q", want, logger.messages)
+	}
+}
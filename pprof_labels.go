@@ -0,0 +1,43 @@
+package devtrace
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// Goroutine profile labels set while a frame runs with Config.PprofLabels, so
+// CPU profiles can be sliced by gotrace frame, e.g. go tool pprof -tagfocus
+const (
+	PprofFunctionLabel = "devtrace.function"
+	PprofTraceIDLabel  = "devtrace.trace_id"
+)
+
+// applyPprofLabels labels the calling goroutine with the function of frame, just
+// entered on tc, and the trace ID, on top of the labels of the frame below it
+func (tc *TraceContext) applyPprofLabels(frame *Frame) {
+	parent := tc.labelBase
+	if n := len(tc.Frames); n > 1 {
+		parent = tc.Frames[n-2].labels
+	}
+	if parent == nil {
+		parent = context.Background()
+	}
+	frame.labels = pprof.WithLabels(parent, pprof.Labels(PprofFunctionLabel, frame.Function, PprofTraceIDLabel, tc.EnsureTraceID()))
+	pprof.SetGoroutineLabels(frame.labels)
+}
+
+// restorePprofLabels puts back the labels of the frame below frame, which was
+// just left, or those the goroutine had before the outermost frame
+func (tc *TraceContext) restorePprofLabels(frame *Frame) {
+	if frame.labels == nil {
+		return
+	}
+	labels := tc.labelBase
+	if current := tc.current(); current != nil && current.labels != nil {
+		labels = current.labels
+	}
+	if labels == nil {
+		labels = context.Background()
+	}
+	pprof.SetGoroutineLabels(labels)
+}
@@ -0,0 +1,92 @@
+package devtrace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Header keys used to carry a logical trace across process or engine boundaries
+const (
+	TraceIDHeader      = "devtrace-trace-id"
+	ParentFrameHeader  = "devtrace-parent-frame"
+	remoteParentMarker = "⇢ "
+)
+
+// newTraceID returns a random 128-bit hex identifier
+func newTraceID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// EnsureTraceID returns the trace ID of tc, generating one on first use
+func (tc *TraceContext) EnsureTraceID() string {
+	if tc == nil {
+		return ""
+	}
+	if tc.TraceID == "" {
+		tc.TraceID = newTraceID()
+	}
+	return tc.TraceID
+}
+
+// InjectTraceHeaders returns the headers that let another process or a workflow
+// engine continue the logical trace of ctx. The map can be carried in any
+// string-keyed metadata: HTTP headers, message attributes, Temporal/Cadence headers.
+// It includes a W3C traceparent header whenever the trace ID allows one.
+func InjectTraceHeaders(ctx context.Context) map[string]string {
+	traceCtx := FromContext(ctx)
+
+	headers := map[string]string{TraceIDHeader: traceCtx.EnsureTraceID()}
+	if traceparent := Traceparent(ctx); traceparent != "" {
+		headers[TraceparentHeader] = traceparent
+	}
+	if len(traceCtx.baggage) > 0 {
+		headers[BaggageHeader] = encodeBaggage(traceCtx.baggage)
+	}
+	if frame := traceCtx.current(); frame != nil {
+		headers[ParentFrameHeader] = frame.Function
+	} else if traceCtx.RemoteParent != "" {
+		headers[ParentFrameHeader] = traceCtx.RemoteParent
+	}
+	return headers
+}
+
+// ExtractTraceHeaders starts a new trace context that continues the logical trace
+// described by headers. Frames entered on the returned context are children of the
+// remote parent frame, which is shown at the start of the route line. A
+// traceparent header alone, as sent by other tracing systems, is enough.
+func ExtractTraceHeaders(ctx context.Context, headers map[string]string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	traceCtx := NewTraceContext()
+	traceCtx.TraceID = headers[TraceIDHeader]
+	traceCtx.RemoteParent = headers[ParentFrameHeader]
+	continueTraceparent(traceCtx, headers[TraceparentHeader])
+	traceCtx.baggage = decodeBaggage(headers[BaggageHeader])
+
+	return WithTraceContext(ctx, traceCtx)
+}
+
+// continueTraceparent makes tc a child of the span named by a traceparent
+// header value, ignoring values that don't parse
+func continueTraceparent(tc *TraceContext, value string) {
+	if value == "" {
+		return
+	}
+	traceID, parentID, _, err := ParseTraceparent(value)
+	if err != nil {
+		return
+	}
+	if tc.TraceID == "" {
+		tc.TraceID = traceID
+	}
+	if tc.TraceID == traceID {
+		tc.RemoteSpanID = parentID
+	}
+}
@@ -0,0 +1,185 @@
+package devtrace
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordedFrame is a completed frame kept by the recorder
+type RecordedFrame struct {
+	Frame   *Frame
+	TraceID string
+	Path    []string          // function names from the root frame down to this one
+	Baggage map[string]string // baggage of the trace context when the frame left; don't modify
+}
+
+// IsRoot reports whether the frame was the outermost frame of its trace context
+func (rf RecordedFrame) IsRoot() bool {
+	return len(rf.Path) <= 1
+}
+
+// TraceFilter selects recorded frames in RecentTraces. Zero fields match everything.
+type TraceFilter struct {
+	Function    string        // substring of the frame's function name
+	Operation   string        // frames serving exactly this operation
+	MinDuration time.Duration // frames at least this long
+	OnlyErrors  bool          // frames that returned a non-nil error
+	OnlyRoots   bool          // outermost frames only, i.e. whole traces
+	TraceID     string
+	Limit       int // maximum number of results, newest first
+}
+
+// frameRecorder is a fixed-size ring buffer of completed frames
+type frameRecorder struct {
+	mu      sync.RWMutex
+	entries []RecordedFrame
+	next    int
+	full    bool
+}
+
+var recorder frameRecorder
+
+// EnableRecorder keeps the last capacity completed frames in memory for RecentTraces.
+// Calling it again resizes the buffer and drops what was recorded so far.
+func EnableRecorder(capacity int) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	if capacity < 0 {
+		capacity = 0
+	}
+	recorder.entries = make([]RecordedFrame, capacity)
+	recorder.next = 0
+	recorder.full = false
+}
+
+// DisableRecorder stops recording and frees the buffer
+func DisableRecorder() {
+	EnableRecorder(0)
+}
+
+// ClearRecorder drops every recorded frame but keeps recording
+func ClearRecorder() {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	for i := range recorder.entries {
+		recorder.entries[i] = RecordedFrame{}
+	}
+	recorder.next = 0
+	recorder.full = false
+}
+
+// recordFrame stores a frame that just left tc; tc.Frames holds its ancestors
+func recordFrame(tc *TraceContext, frame *Frame) {
+	if frame == nil || !recorderEnabled() {
+		return
+	}
+	frame.retain()
+
+	path := make([]string, 0, len(tc.Frames)+1)
+	for _, parent := range tc.Frames {
+		path = append(path, parent.Function)
+	}
+	path = append(path, frame.Function)
+	entry := RecordedFrame{Frame: frame, TraceID: tc.TraceID, Path: path, Baggage: tc.baggage}
+
+	entries, sampling := tailSample(tc, entry)
+	if !sampling {
+		entries = []RecordedFrame{entry}
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	if len(recorder.entries) == 0 {
+		return
+	}
+	for _, e := range entries {
+		recorder.entries[recorder.next] = e
+		recorder.next = (recorder.next + 1) % len(recorder.entries)
+		if recorder.next == 0 {
+			recorder.full = true
+		}
+	}
+}
+
+func recorderEnabled() bool {
+	recorder.mu.RLock()
+	defer recorder.mu.RUnlock()
+	return len(recorder.entries) > 0
+}
+
+// RecentTraces returns recorded frames matching filter, newest first
+func RecentTraces(filter TraceFilter) []RecordedFrame {
+	recorder.mu.RLock()
+	defer recorder.mu.RUnlock()
+
+	size := recorder.next
+	if recorder.full {
+		size = len(recorder.entries)
+	}
+
+	results := make([]RecordedFrame, 0)
+	for i := 0; i < size; i++ {
+		idx := (recorder.next - 1 - i + len(recorder.entries)) % len(recorder.entries)
+		entry := recorder.entries[idx]
+		if !filter.matches(entry) {
+			continue
+		}
+		results = append(results, entry)
+		if filter.Limit > 0 && len(results) >= filter.Limit {
+			break
+		}
+	}
+	return results
+}
+
+func (f TraceFilter) matches(entry RecordedFrame) bool {
+	frame := entry.Frame
+	if frame == nil {
+		return false
+	}
+	if f.Function != "" && !strings.Contains(frame.Function, f.Function) {
+		return false
+	}
+	if f.Operation != "" && frame.Operation != f.Operation {
+		return false
+	}
+	if f.MinDuration > 0 && frame.Duration < f.MinDuration {
+		return false
+	}
+	if f.OnlyErrors && FrameError(frame) == nil {
+		return false
+	}
+	if f.OnlyRoots && !entry.IsRoot() {
+		return false
+	}
+	if f.TraceID != "" && entry.TraceID != f.TraceID {
+		return false
+	}
+	return true
+}
+
+// FrameError returns the error a frame finished with: Frame.Error, or else the
+// last non-nil error among its results, or nil
+func FrameError(frame *Frame) error {
+	if frame == nil {
+		return nil
+	}
+	if frame.Error != nil {
+		return frame.Error
+	}
+	return lastError(frame.Results)
+}
+
+// lastError returns the last non-nil error among results
+func lastError(results []interface{}) error {
+	for i := len(results) - 1; i >= 0; i-- {
+		if err, ok := results[i].(error); ok && err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package devtrace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"text/template"
+)
+
+func TestRecorderKeepsRecentFrames(t *testing.T) {
+	originalConfig := Config
+	t.Cleanup(func() {
+		SetConfig(originalConfig)
+		DisableRecorder()
+	})
+	cfg := Config
+	cfg.Enabled = true
+	cfg.ShowTiming = false
+	SetConfig(cfg)
+	EnableRecorder(3)
+
+	fail := TraceFunc(func(ctx context.Context, n int) error {
+		if n%2 == 0 {
+			return errors.New("even")
+		}
+		return nil
+	}, "check").(func(context.Context, int) error)
+
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	for i := 1; i <= 5; i++ {
+		fail(ctx, i)
+	}
+
+	all := RecentTraces(TraceFilter{})
+	if len(all) != 3 {
+		t.Fatalf("expected ring buffer of 3, got %d", len(all))
+	}
+	if all[0].Frame.Args["arg1"] != 5 && all[0].Frame.Args["n"] != 5 {
+		t.Fatalf("newest frame should come first: %+v", all[0].Frame.Args)
+	}
+
+	errs := RecentTraces(TraceFilter{OnlyErrors: true, Function: "check"})
+	if len(errs) != 1 || FrameError(errs[0].Frame) == nil {
+		t.Fatalf("expected one errored frame, got %d", len(errs))
+	}
+	if !errs[0].IsRoot() {
+		t.Fatalf("top-level call should be a root frame: %v", errs[0].Path)
+	}
+}
+
+func TestSerializationHelpersRecordFrames(t *testing.T) {
+	originalConfig := Config
+	t.Cleanup(func() {
+		SetConfig(originalConfig)
+		DisableRecorder()
+	})
+	cfg := Config
+	cfg.Enabled = true
+	SetConfig(cfg)
+	EnableRecorder(10)
+
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	data, err := MarshalJSON(ctx, map[string]int{"a": 1})
+	if err != nil || string(data) != `{"a":1}` {
+		t.Fatalf("MarshalJSON = %s, %v", data, err)
+	}
+	var out map[string]int
+	if err := UnmarshalJSON(ctx, []byte("{"), &out); err == nil {
+		t.Fatal("expected a syntax error")
+	}
+	tmpl := template.Must(template.New("page").Parse("hello {{.}}"))
+	var buf bytes.Buffer
+	if err := ExecuteTemplate(ctx, tmpl, &buf, "world"); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := RecentTraces(TraceFilter{})
+	if len(frames) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(frames))
+	}
+	render, unmarshal, marshal := frames[0].Frame, frames[1].Frame, frames[2].Frame
+	if marshal.Function != "json.Marshal" || marshal.Args["bytes"] != 7 || marshal.Args["type"] != "map[string]int" {
+		t.Fatalf("unexpected marshal frame: %s %v", marshal.Function, marshal.Args)
+	}
+	if unmarshal.Function != "json.Unmarshal" || len(unmarshal.Results) != 1 {
+		t.Fatalf("unmarshal frame should carry the error: %s %v", unmarshal.Function, unmarshal.Results)
+	}
+	if render.Function != "template.Execute" || render.Args["template"] != "page" || render.Args["bytes"] != 11 {
+		t.Fatalf("unexpected template frame: %s %v", render.Function, render.Args)
+	}
+	if filepath.Base(marshal.File) != "recorder_test.go" {
+		t.Fatalf("frame should point at the caller, got %s", marshal.File)
+	}
+}
@@ -0,0 +1,304 @@
+package devtrace
+
+import (
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// RedactedValue replaces sensitive values in captured args and debug vars
+const RedactedValue = "[REDACTED]"
+
+// redactTag marks struct fields that must never be captured: `devtrace:"redact"`
+const redactTag = "redact"
+
+// RedactionRules configures scrubbing of sensitive data before it reaches frames or log output
+type RedactionRules struct {
+	Enabled     bool
+	Patterns    []string // case-insensitive glob patterns matched against arg, field and map key names
+	Replacement string   // value substituted for redacted strings (defaults to RedactedValue)
+
+	// Secrets are masked inside every captured string, whatever its name, as a
+	// safety net for credentials passed under innocuous names; matches are
+	// counted in CurrentSecretStats
+	Secrets []SecretPattern
+}
+
+// DefaultRedactionRules covers common credential names
+var DefaultRedactionRules = RedactionRules{
+	Enabled: true,
+	Patterns: []string{
+		"*password*",
+		"*passwd*",
+		"*token*",
+		"*secret*",
+		"*api_key*",
+		"*apikey*",
+		"authorization",
+		"cookie",
+	},
+	Replacement: RedactedValue,
+	Secrets:     DefaultSecretPatterns,
+}
+
+var (
+	redactionMu    sync.RWMutex
+	redactionRules = DefaultRedactionRules
+	typeRedactors  = make(map[reflect.Type]func(interface{}) interface{})
+)
+
+// SetRedactionRules replaces the active redaction rules
+func SetRedactionRules(rules RedactionRules) {
+	redactionMu.Lock()
+	defer redactionMu.Unlock()
+
+	redactionRules = rules
+}
+
+// GetRedactionRules returns the active redaction rules
+func GetRedactionRules() RedactionRules {
+	redactionMu.RLock()
+	defer redactionMu.RUnlock()
+
+	return redactionRules
+}
+
+// RedactType registers a redactor applied to every captured value of type T,
+// regardless of the name it was captured under.
+func RedactType[T any](redactor func(T) interface{}) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+
+	redactionMu.Lock()
+	defer redactionMu.Unlock()
+
+	typeRedactors[typ] = func(v interface{}) interface{} {
+		return redactor(v.(T))
+	}
+}
+
+// ClearTypeRedactors removes every redactor registered with RedactType
+func ClearTypeRedactors() {
+	redactionMu.Lock()
+	defer redactionMu.Unlock()
+
+	typeRedactors = make(map[reflect.Type]func(interface{}) interface{})
+}
+
+// Redact returns value with the active redaction rules applied, as if it was captured under name
+func Redact(name string, value interface{}) interface{} {
+	r := newRedactor()
+	if r == nil {
+		return value
+	}
+	redacted, _ := r.value(name, value, 0)
+	return redacted
+}
+
+// redactArgs applies the active redaction rules to every entry of an args map in place
+func redactArgs(args map[string]interface{}) {
+	if len(args) == 0 {
+		return
+	}
+
+	r := newRedactor()
+	if r == nil {
+		return
+	}
+
+	for name, value := range args {
+		if redacted, changed := r.value(name, value, 0); changed {
+			args[name] = redacted
+		}
+	}
+}
+
+// maxRedactDepth bounds recursion into nested structs and maps
+const maxRedactDepth = 8
+
+type redactor struct {
+	rules RedactionRules
+	types map[reflect.Type]func(interface{}) interface{}
+}
+
+func newRedactor() *redactor {
+	redactionMu.RLock()
+	defer redactionMu.RUnlock()
+
+	if !redactionRules.Enabled {
+		return nil
+	}
+
+	rules := redactionRules
+	if rules.Replacement == "" {
+		rules.Replacement = RedactedValue
+	}
+
+	return &redactor{rules: rules, types: typeRedactors}
+}
+
+func (r *redactor) matchesName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	lower := strings.ToLower(name)
+	for _, pattern := range r.rules.Patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), lower); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the redacted form of value and whether anything was replaced
+func (r *redactor) value(name string, value interface{}, depth int) (interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+
+	if r.matchesName(name) {
+		return r.rules.Replacement, true
+	}
+
+	if fn, ok := r.types[reflect.TypeOf(value)]; ok {
+		return fn(value), true
+	}
+
+	if s, ok := value.(string); ok {
+		return maskSecrets(s, r.rules.Secrets, r.rules.Replacement)
+	}
+
+	if depth >= maxRedactDepth {
+		return value, false
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Struct:
+		if redacted, changed := r.structValue(rv, depth); changed {
+			return redacted.Interface(), true
+		}
+	case reflect.Ptr:
+		if rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+			return value, false
+		}
+		if redacted, changed := r.structValue(rv.Elem(), depth); changed {
+			ptr := reflect.New(rv.Elem().Type())
+			ptr.Elem().Set(redacted)
+			return ptr.Interface(), true
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return value, false
+		}
+		if redacted, changed := r.mapValue(rv, depth); changed {
+			return redacted.Interface(), true
+		}
+	}
+
+	return value, false
+}
+
+// structValue returns a copy of a struct with sensitive exported fields replaced.
+// The original value is never modified.
+func (r *redactor) structValue(rv reflect.Value, depth int) (reflect.Value, bool) {
+	typ := rv.Type()
+	var copied reflect.Value
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldValue := rv.Field(i)
+		var replacement reflect.Value
+
+		if hasRedactTag(field) || r.matchesName(field.Name) || r.matchesName(jsonFieldName(field)) {
+			replacement = r.replacementFor(field.Type)
+		} else if fieldValue.CanInterface() {
+			if redacted, changed := r.value("", fieldValue.Interface(), depth+1); changed {
+				replacement = reflect.ValueOf(redacted)
+			}
+		}
+
+		if !replacement.IsValid() {
+			continue
+		}
+		if !replacement.Type().AssignableTo(field.Type) {
+			replacement = reflect.Zero(field.Type)
+		}
+
+		if !copied.IsValid() {
+			copied = reflect.New(typ).Elem()
+			copied.Set(rv)
+		}
+		copied.Field(i).Set(replacement)
+	}
+
+	if !copied.IsValid() {
+		return rv, false
+	}
+	return copied, true
+}
+
+func (r *redactor) mapValue(rv reflect.Value, depth int) (reflect.Value, bool) {
+	if rv.IsNil() {
+		return rv, false
+	}
+
+	changed := false
+	copied := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+	elemType := rv.Type().Elem()
+
+	iter := rv.MapRange()
+	for iter.Next() {
+		key, val := iter.Key(), iter.Value()
+		redacted, redactedChanged := r.value(key.String(), val.Interface(), depth+1)
+
+		newVal := val
+		if redactedChanged {
+			changed = true
+			newVal = reflect.ValueOf(redacted)
+			if !newVal.IsValid() || !newVal.Type().AssignableTo(elemType) {
+				newVal = r.replacementFor(elemType)
+			}
+		}
+		copied.SetMapIndex(key, newVal)
+	}
+
+	if !changed {
+		return rv, false
+	}
+	return copied, true
+}
+
+// replacementFor returns the redaction marker for string-like types and the zero value otherwise
+func (r *redactor) replacementFor(typ reflect.Type) reflect.Value {
+	marker := reflect.ValueOf(r.rules.Replacement)
+	if marker.Type().AssignableTo(typ) {
+		return marker
+	}
+	if marker.Type().ConvertibleTo(typ) && typ.Kind() == reflect.String {
+		return marker.Convert(typ)
+	}
+	return reflect.Zero(typ)
+}
+
+func hasRedactTag(field reflect.StructField) bool {
+	for _, opt := range strings.Split(field.Tag.Get("devtrace"), ",") {
+		if strings.TrimSpace(opt) == redactTag {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,146 @@
+package devtrace
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SecretPattern recognizes one kind of secret inside captured strings,
+// whatever name the value was captured under
+type SecretPattern struct {
+	Name   string
+	Regexp *regexp.Regexp
+	Valid  func(match string) bool // optional check of each match, e.g. a checksum
+}
+
+// DefaultSecretPatterns catch credentials that commonly end up in request
+// bodies, headers and URLs
+var DefaultSecretPatterns = []SecretPattern{
+	{Name: "jwt", Regexp: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{8,}`)},
+	{Name: "aws_access_key", Regexp: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Name: "private_key", Regexp: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`)},
+	{Name: "card_number", Regexp: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), Valid: luhnValid},
+}
+
+// minSecretLength skips strings too short to hold any default secret
+const minSecretLength = 13
+
+var secretStats = struct {
+	sync.Mutex
+	counts map[string]int64
+}{counts: make(map[string]int64)}
+
+// SecretStats counts the secrets masked in captured values, by pattern name
+type SecretStats map[string]int64
+
+// Total returns the number of secrets masked across every pattern
+func (s SecretStats) Total() int64 {
+	var total int64
+	for _, n := range s {
+		total += n
+	}
+	return total
+}
+
+// String lists the counts by pattern name, e.g. "jwt=2 card_number=1"
+func (s SecretStats) String() string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+strconv.FormatInt(s[name], 10))
+	}
+	return strings.Join(parts, " ")
+}
+
+// CurrentSecretStats reports how many secrets the scanner has masked
+func CurrentSecretStats() SecretStats {
+	secretStats.Lock()
+	defer secretStats.Unlock()
+
+	stats := make(SecretStats, len(secretStats.counts))
+	for name, n := range secretStats.counts {
+		stats[name] = n
+	}
+	return stats
+}
+
+// ResetSecretStats zeroes the counts of CurrentSecretStats
+func ResetSecretStats() {
+	secretStats.Lock()
+	defer secretStats.Unlock()
+
+	secretStats.counts = make(map[string]int64)
+}
+
+// maskSecrets replaces every match of patterns in s with replacement and
+// reports whether anything was masked
+func maskSecrets(s string, patterns []SecretPattern, replacement string) (string, bool) {
+	if len(s) < minSecretLength || len(patterns) == 0 {
+		return s, false
+	}
+
+	var found map[string]int64
+	for _, pattern := range patterns {
+		if pattern.Regexp == nil {
+			continue
+		}
+		s = pattern.Regexp.ReplaceAllStringFunc(s, func(match string) string {
+			if pattern.Valid != nil && !pattern.Valid(match) {
+				return match
+			}
+			if found == nil {
+				found = make(map[string]int64)
+			}
+			found[pattern.Name]++
+			return replacement
+		})
+	}
+	if found == nil {
+		return s, false
+	}
+
+	secretStats.Lock()
+	for name, n := range found {
+		secretStats.counts[name] += n
+	}
+	secretStats.Unlock()
+	return s, true
+}
+
+// scrubSecrets masks secrets in rendered text with the active redaction rules
+func scrubSecrets(s string) string {
+	r := newRedactor()
+	if r == nil {
+		return s
+	}
+	masked, _ := maskSecrets(s, r.rules.Secrets, r.rules.Replacement)
+	return masked
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum card numbers carry
+func luhnValid(s string) bool {
+	sum, digits := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && digits <= 19 && sum%10 == 0
+}
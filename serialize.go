@@ -0,0 +1,109 @@
+package devtrace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// TemplateExecutor is the part of *html/template.Template and
+// *text/template.Template that ExecuteTemplate needs
+type TemplateExecutor interface {
+	Name() string
+	Execute(w io.Writer, data interface{}) error
+}
+
+// ExecuteTemplate is tmpl.Execute(w, data) inside a frame named
+// template.Execute, whose args record the template name and the bytes written.
+// Rendering rarely shows up in call traces otherwise, though it is often where
+// a handler spends its time.
+func ExecuteTemplate(ctx context.Context, tmpl TemplateExecutor, w io.Writer, data interface{}) error {
+	return traceSerialization(ctx, "template.Execute", map[string]interface{}{"template": tmpl.Name()}, func() (int, error) {
+		cw := &countingWriter{w: w}
+		err := tmpl.Execute(cw, data)
+		return cw.n, err
+	})
+}
+
+// MarshalJSON is json.Marshal inside a frame recording the value's type and the
+// size of the encoding
+func MarshalJSON(ctx context.Context, v interface{}) ([]byte, error) {
+	var data []byte
+	err := traceSerialization(ctx, "json.Marshal", map[string]interface{}{"type": fmt.Sprintf("%T", v)}, func() (int, error) {
+		var err error
+		data, err = json.Marshal(v)
+		return len(data), err
+	})
+	return data, err
+}
+
+// UnmarshalJSON is json.Unmarshal inside a frame recording the target type and
+// the size of the payload
+func UnmarshalJSON(ctx context.Context, data []byte, v interface{}) error {
+	return traceSerialization(ctx, "json.Unmarshal", map[string]interface{}{"type": fmt.Sprintf("%T", v)}, func() (int, error) {
+		return len(data), json.Unmarshal(data, v)
+	})
+}
+
+// TraceMarshal runs marshal(v) inside a frame named codec.Marshal, for codecs
+// the core doesn't depend on. With protobuf:
+//
+//	data, err := devtrace.TraceMarshal(ctx, "proto", proto.Marshal, msg)
+func TraceMarshal[T any](ctx context.Context, codec string, marshal func(T) ([]byte, error), v T) ([]byte, error) {
+	var data []byte
+	err := traceSerialization(ctx, codec+".Marshal", map[string]interface{}{"type": fmt.Sprintf("%T", v)}, func() (int, error) {
+		var err error
+		data, err = marshal(v)
+		return len(data), err
+	})
+	return data, err
+}
+
+// TraceUnmarshal runs unmarshal(data, v) inside a frame named codec.Unmarshal:
+//
+//	err := devtrace.TraceUnmarshal(ctx, "proto", proto.Unmarshal, data, msg)
+func TraceUnmarshal[T any](ctx context.Context, codec string, unmarshal func([]byte, T) error, data []byte, v T) error {
+	return traceSerialization(ctx, codec+".Unmarshal", map[string]interface{}{"type": fmt.Sprintf("%T", v)}, func() (int, error) {
+		return len(data), unmarshal(data, v)
+	})
+}
+
+// traceSerialization runs fn in a frame placed at the helper's caller, adding
+// the payload size fn reports to args as "bytes". The duration comes from the
+// frame itself and an error becomes its result.
+func traceSerialization(ctx context.Context, name string, args map[string]interface{}, fn func() (int, error)) error {
+	if !IsEnabled() {
+		_, err := fn()
+		return err
+	}
+
+	file, line := "", 0
+	if _, callerFile, callerLine, ok := runtime.Caller(2); ok {
+		file, line = originalPosition(callerFile, callerLine)
+	}
+	frame := CreateFrame(name, "", file, line, args)
+	EnterContext(ctx, frame)
+
+	n, err := fn()
+	setOpenArg(frame, "bytes", n)
+	if err != nil {
+		LeaveContextResults(ctx, err)
+	} else {
+		LeaveContext(ctx)
+	}
+	return err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
@@ -0,0 +1,118 @@
+package devtrace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Session is a stored set of completed frames, one JSON-encoded FrameSnapshot per line
+type Session struct {
+	Name        string
+	Frames      []FrameSnapshot
+	Annotations []Annotation
+}
+
+// Start returns the earliest frame start time in the session
+func (s *Session) Start() time.Time {
+	var start time.Time
+	for _, frame := range s.Frames {
+		if !frame.StartTime.IsZero() && (start.IsZero() || frame.StartTime.Before(start)) {
+			start = frame.StartTime
+		}
+	}
+	return start
+}
+
+// WriteSession writes frames in the session format (JSON lines of FrameSnapshot)
+func WriteSession(w io.Writer, frames []*Frame) error {
+	enc := json.NewEncoder(w)
+	for _, frame := range frames {
+		if frame == nil {
+			continue
+		}
+		if err := enc.Encode(SnapshotFrame(frame)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionLine is a line of a session: a FrameSnapshot, or a line written by a
+// JSONLExporter, which is an ExportedFrame or an ExportedTrace
+type sessionLine struct {
+	Schema int             `json:"schema"`
+	Frames []FrameSnapshot `json:"frames"`
+	FrameSnapshot
+}
+
+// ReadSession parses a session stream; blank lines are skipped. Files written
+// by a JSONLExporter are sessions too, with traces flattened into their frames.
+func ReadSession(r io.Reader) ([]FrameSnapshot, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+
+	frames := make([]FrameSnapshot, 0)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry sessionLine
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		if entry.Schema > JSONLSchemaVersion {
+			return nil, fmt.Errorf("line %d: schema %d is newer than this version of devtrace reads (%d)", lineNo, entry.Schema, JSONLSchemaVersion)
+		}
+		if entry.Frames != nil {
+			frames = append(frames, entry.Frames...)
+		} else {
+			frames = append(frames, entry.FrameSnapshot)
+		}
+	}
+
+	return frames, scanner.Err()
+}
+
+// ReadSessionFile loads a session file and its annotations, transparently handling
+// files written through NewEncryptingWriter and/or a registered compressor
+func ReadSessionFile(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader = bytes.NewReader(data)
+	if IsEncryptedStream(data) {
+		if reader, err = NewDecryptingReader(reader); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	plain, err := NewDecompressingReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	defer plain.Close()
+
+	frames, err := ReadSession(plain)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	notes, err := SessionAnnotations(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{Name: filepath.Base(path), Frames: frames, Annotations: notes}, nil
+}
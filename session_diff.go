@@ -0,0 +1,138 @@
+package devtrace
+
+import "time"
+
+// DiffStatus says how a call changed from one session to the other
+type DiffStatus string
+
+const (
+	DiffMatched DiffStatus = "matched" // in both sessions
+	DiffAdded   DiffStatus = "added"   // only in the after session
+	DiffRemoved DiffStatus = "removed" // only in the before session
+)
+
+// CallDiff is one call of a SessionDiff tree. Before and After are the frames of
+// the call in each session; one of them is nil for added and removed calls.
+type CallDiff struct {
+	Function string         `json:"function"`
+	Status   DiffStatus     `json:"status"`
+	Before   *FrameSnapshot `json:"before,omitempty"`
+	After    *FrameSnapshot `json:"after,omitempty"`
+	Delta    time.Duration  `json:"delta"` // after minus before duration, for matched calls
+	Children []*CallDiff    `json:"children,omitempty"`
+}
+
+// SessionDiff compares the call trees of two sessions of the same operation
+type SessionDiff struct {
+	Before      string        `json:"before"`
+	After       string        `json:"after"`
+	Operation   string        `json:"operation,omitempty"`
+	BeforeTotal time.Duration `json:"before_total"`
+	AfterTotal  time.Duration `json:"after_total"`
+	Added       int           `json:"added"`
+	Removed     int           `json:"removed"`
+	Roots       []*CallDiff   `json:"roots"`
+}
+
+// DiffSessions lines up the call trees of a before and an after session. Calls
+// are matched by function name among their siblings, keeping the order they ran
+// in, so a call inserted in the middle shows as added rather than shifting
+// every later sibling. With an operation only the roots of that operation are
+// compared.
+func DiffSessions(before, after *Session, operation string) *SessionDiff {
+	diff := &SessionDiff{Operation: operation}
+	var beforeRoots, afterRoots []*callNode
+	if before != nil {
+		diff.Before = before.Name
+		beforeRoots = operationRoots(buildCallTree(before.Frames), operation)
+	}
+	if after != nil {
+		diff.After = after.Name
+		afterRoots = operationRoots(buildCallTree(after.Frames), operation)
+	}
+	for _, root := range beforeRoots {
+		diff.BeforeTotal += root.frame.Duration
+	}
+	for _, root := range afterRoots {
+		diff.AfterTotal += root.frame.Duration
+	}
+
+	diff.Roots = diff.calls(beforeRoots, afterRoots)
+	return diff
+}
+
+func operationRoots(roots []*callNode, operation string) []*callNode {
+	if operation == "" {
+		return roots
+	}
+	var selected []*callNode
+	for _, root := range roots {
+		if root.frame.Operation == operation {
+			selected = append(selected, root)
+		}
+	}
+	return selected
+}
+
+// calls diffs two sibling lists, pairing them along their longest common
+// subsequence of function names
+func (d *SessionDiff) calls(before, after []*callNode) []*CallDiff {
+	// lcs[i][j] is the length of the common subsequence of before[i:] and after[j:]
+	lcs := make([][]int, len(before)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			switch {
+			case before[i].frame.Function == after[j].frame.Function:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out []*CallDiff
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i].frame.Function == after[j].frame.Function:
+			out = append(out, &CallDiff{
+				Function: before[i].frame.Function,
+				Status:   DiffMatched,
+				Before:   before[i].frame,
+				After:    after[j].frame,
+				Delta:    after[j].frame.Duration - before[i].frame.Duration,
+				Children: d.calls(before[i].children, after[j].children),
+			})
+			i++
+			j++
+		case j == len(after) || i < len(before) && lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, d.unmatched(before[i], DiffRemoved))
+			i++
+		default:
+			out = append(out, d.unmatched(after[j], DiffAdded))
+			j++
+		}
+	}
+	return out
+}
+
+// unmatched turns the subtree of a call found in one session only
+func (d *SessionDiff) unmatched(node *callNode, status DiffStatus) *CallDiff {
+	call := &CallDiff{Function: node.frame.Function, Status: status}
+	if status == DiffAdded {
+		call.After = node.frame
+		d.Added++
+	} else {
+		call.Before = node.frame
+		d.Removed++
+	}
+	for _, child := range node.children {
+		call.Children = append(call.Children, d.unmatched(child, status))
+	}
+	return call
+}
@@ -0,0 +1,150 @@
+package devtrace
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	frames := []*Frame{
+		{Function: "pkg.Load", StartTime: start.Add(time.Second), Duration: 5 * time.Millisecond},
+		{Function: "pkg.Save", StartTime: start, Duration: time.Millisecond, Results: []interface{}{errors.New("boom")}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSession(&buf, frames); err != nil {
+		t.Fatalf("WriteSession: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := ReadSessionFile(path)
+	if err != nil {
+		t.Fatalf("ReadSessionFile: %v", err)
+	}
+	if len(session.Frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(session.Frames))
+	}
+	if session.Frames[1].Error != "boom" {
+		t.Fatalf("expected error to survive round trip, got %q", session.Frames[1].Error)
+	}
+	if !session.Start().Equal(start) {
+		t.Fatalf("expected session start %v, got %v", start, session.Start())
+	}
+}
+
+func TestJSONLExporterRotatesAndReadsAsSession(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	dir := t.TempDir()
+	frames := filepath.Join(dir, "frames.jsonl")
+	exporter, err := NewJSONLExporter(frames, &JSONLExporterOptions{MaxSize: 600, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	traces, err := NewJSONLExporter(filepath.Join(dir, "traces.jsonl"), &JSONLExporterOptions{Traces: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := NewTraceContext()
+	tc.EnsureTraceID()
+	for i := 0; i < 5; i++ {
+		tc.Enter(CreateFrame("pkg.Handle", "", "handle.go", 1, nil))
+		tc.Enter(CreateFrame("pkg.Load", "", "load.go", 2, map[string]interface{}{"id": i}))
+		tc.Leave()
+		tc.Leave()
+	}
+	if err := exporter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := traces.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(frames + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected at most 2 rotated files: %v", err)
+	}
+	total := 0
+	for _, path := range []string{frames + ".2", frames + ".1", frames} {
+		session, err := ReadSessionFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += len(session.Frames)
+	}
+	if total == 0 || total >= 10 || exporter.Dropped() != 0 {
+		t.Fatalf("expected the oldest frames to be rotated away, got %d frames", total)
+	}
+
+	session, err := ReadSessionFile(filepath.Join(dir, "traces.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Frames) != 10 || session.Frames[0].Function != "pkg.Load" || session.Frames[0].Args["id"] != "0" {
+		t.Fatalf("expected 5 traces of 2 frames, got %+v", session.Frames)
+	}
+
+	if _, err := ReadSession(bytes.NewBufferString(`{"schema":99,"function":"x"}`)); err == nil {
+		t.Fatal("expected a newer schema to be rejected")
+	}
+}
+
+func TestCollectorClientShipsFrames(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	type received struct {
+		hello CollectorHello
+		frame ExportedFrame
+	}
+	frames := make(chan received, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		ReadCollectorStream(conn, func(hello CollectorHello, frame ExportedFrame) error {
+			frames <- received{hello, frame}
+			return nil
+		})
+	}()
+
+	client := NewCollectorClient("tcp", listener.Addr().String(), &CollectorClientOptions{Service: "worker"})
+	tc := NewTraceContext()
+	tc.EnsureTraceID()
+	tc.Enter(CreateFrame("pkg.Job", "", "job.go", 3, map[string]interface{}{"id": 7}))
+	tc.Leave()
+	client.Close()
+
+	select {
+	case got := <-frames:
+		if got.hello.Service != "worker" || got.hello.PID != os.Getpid() || got.frame.Function != "pkg.Job" ||
+			got.frame.TraceID != tc.TraceID || got.frame.Args["id"] != "7" {
+			t.Fatalf("unexpected message: %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the collector received no frame")
+	}
+	if client.Dropped() != 0 {
+		t.Fatalf("expected no dropped frames, got %d", client.Dropped())
+	}
+}
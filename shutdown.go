@@ -0,0 +1,214 @@
+package devtrace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownHookDeadline bounds each shutdown hook of a tracer that doesn't
+// set HookDeadline
+var DefaultShutdownHookDeadline = 10 * time.Second
+
+// maxShutdownStackDump caps the goroutine dump taken when a hook hangs
+const maxShutdownStackDump = 1 << 20
+
+// ShutdownHookResult is how one shutdown hook went
+type ShutdownHookResult struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Err      error         `json:"-"`
+	TimedOut bool          `json:"timed_out,omitempty"` // the hook missed its deadline and was left running
+}
+
+// ShutdownReport is the outcome of ShutdownTracer.Shutdown, hooks in the order they ran
+type ShutdownReport struct {
+	Hooks    []ShutdownHookResult `json:"hooks"`
+	Duration time.Duration        `json:"duration"`
+	Dump     string               `json:"dump,omitempty"` // goroutines at the first missed deadline
+}
+
+// Err joins the errors of every hook that failed or timed out
+func (r ShutdownReport) Err() error {
+	var errs []error
+	for _, hook := range r.Hooks {
+		if hook.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hook.Name, hook.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// String renders the report with one line per hook
+func (r ShutdownReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🛑 shutdown: %d hook(s) in %v", len(r.Hooks), r.Duration.Round(time.Microsecond))
+	for _, hook := range r.Hooks {
+		status := "ok"
+		if hook.TimedOut {
+			status = "⏰ timed out"
+		} else if hook.Err != nil {
+			status = "❌ " + hook.Err.Error()
+		}
+		fmt.Fprintf(&b, "\n  %s %v %s", hook.Name, hook.Duration.Round(time.Microsecond), status)
+	}
+	return b.String()
+}
+
+type shutdownHook struct {
+	name string
+	file string // where the hook was registered
+	line int
+	fn   func(ctx context.Context) error
+}
+
+// ShutdownTracer runs shutdown hooks and closers in reverse registration order,
+// each in its own frame and with a deadline. When a hook misses its deadline the
+// open trace frames and the stacks of all goroutines are logged, which shows what
+// is holding up exit, and shutdown moves on to the next hook. The zero value is
+// ready to use; the package-level functions use DefaultShutdownTracer.
+type ShutdownTracer struct {
+	HookDeadline time.Duration // per hook; DefaultShutdownHookDeadline if zero
+
+	mu    sync.Mutex
+	hooks []shutdownHook
+}
+
+// DefaultShutdownTracer is used by OnShutdown, OnShutdownClose and Shutdown
+var DefaultShutdownTracer = &ShutdownTracer{}
+
+// Register adds a shutdown hook; fn should return once ctx is done
+func (st *ShutdownTracer) Register(name string, fn func(ctx context.Context) error) {
+	st.register(name, fn)
+}
+
+// RegisterCloser adds c.Close as a shutdown hook
+func (st *ShutdownTracer) RegisterCloser(name string, c io.Closer) {
+	st.register(name, func(context.Context) error { return c.Close() })
+}
+
+func (st *ShutdownTracer) register(name string, fn func(ctx context.Context) error) {
+	hook := shutdownHook{name: name, fn: fn}
+	if _, file, line, ok := runtime.Caller(2); ok {
+		hook.file, hook.line = originalPosition(file, line)
+	}
+
+	st.mu.Lock()
+	st.hooks = append(st.hooks, hook)
+	st.mu.Unlock()
+}
+
+// Shutdown runs the registered hooks, last registered first. Hooks left once ctx
+// is done are not started and count as timed out.
+func (st *ShutdownTracer) Shutdown(ctx context.Context) ShutdownReport {
+	st.mu.Lock()
+	hooks := append([]shutdownHook(nil), st.hooks...)
+	deadline := st.HookDeadline
+	st.mu.Unlock()
+	if deadline <= 0 {
+		deadline = DefaultShutdownHookDeadline
+	}
+
+	start := time.Now()
+	report := ShutdownReport{Hooks: make([]ShutdownHookResult, 0, len(hooks))}
+	for i := len(hooks) - 1; i >= 0; i-- {
+		started := ctx.Err() == nil
+		result := runShutdownHook(ctx, hooks[i], deadline)
+		if result.TimedOut && started {
+			dump := dumpShutdownHang(hooks[i], result.Duration)
+			if report.Dump == "" {
+				report.Dump = dump
+			}
+		}
+		report.Hooks = append(report.Hooks, result)
+	}
+	report.Duration = time.Since(start)
+
+	if GlobalLogger != nil {
+		GlobalLogger.Info("%s", report.String())
+	}
+	return report
+}
+
+func runShutdownHook(ctx context.Context, hook shutdownHook, deadline time.Duration) ShutdownHookResult {
+	result := ShutdownHookResult{Name: hook.name}
+	if err := ctx.Err(); err != nil {
+		result.Err, result.TimedOut = err, true
+		return result
+	}
+
+	hookCtx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+
+	start := time.Now()
+	entered := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		frame := CreateFrame("shutdown "+hook.name, "", hook.file, hook.line, nil)
+		GlobalEnter(frame)
+		defer GlobalLeave()
+		defer func() { RecordPanic(recover()) }()
+		close(entered)
+
+		done <- hook.fn(hookCtx)
+	}()
+	<-entered // the hang dump reads the hook's frame
+
+	select {
+	case err := <-done:
+		result.Err = err
+	case <-hookCtx.Done():
+		result.Err, result.TimedOut = hookCtx.Err(), true
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+// dumpShutdownHang logs what every goroutine is doing while a hook is stuck
+func dumpShutdownHang(hook shutdownHook, running time.Duration) string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxShutdownStackDump {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	dump := fmt.Sprintf("⏰ shutdown hook %q (registered at %s) still running after %v\n\n%s\n\nGoroutines:\n%s",
+		hook.name, shutdownHookLocation(hook), running.Round(time.Millisecond), DumpActiveContexts(), buf)
+	if GlobalLogger != nil {
+		GlobalLogger.Warn("%s", dump)
+	}
+	return dump
+}
+
+// OnShutdown registers a hook on DefaultShutdownTracer
+func OnShutdown(name string, fn func(ctx context.Context) error) {
+	DefaultShutdownTracer.register(name, fn)
+}
+
+// OnShutdownClose registers c.Close on DefaultShutdownTracer
+func OnShutdownClose(name string, c io.Closer) {
+	DefaultShutdownTracer.register(name, func(context.Context) error { return c.Close() })
+}
+
+// Shutdown runs the hooks of DefaultShutdownTracer
+func Shutdown(ctx context.Context) ShutdownReport {
+	return DefaultShutdownTracer.Shutdown(ctx)
+}
+
+// shutdownHookLocation is where a hook was registered, for the dump header
+func shutdownHookLocation(hook shutdownHook) string {
+	if hook.file == "" {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(hook.file), hook.line)
+}
@@ -0,0 +1,57 @@
+package devtrace
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownTracerDumpsHungHook(t *testing.T) {
+	originalLogger := GlobalLogger
+	t.Cleanup(func() { GlobalLogger = originalLogger })
+	GlobalLogger = nil
+
+	st := &ShutdownTracer{HookDeadline: 20 * time.Millisecond}
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	ran := func(name string) {
+		mu.Lock()
+		order = append(order, name)
+		mu.Unlock()
+	}
+	st.Register("db", func(context.Context) error {
+		ran("db")
+		return errors.New("close failed")
+	})
+
+	release := make(chan struct{})
+	defer close(release)
+	st.Register("worker", func(context.Context) error {
+		ran("worker")
+		<-release // ignores ctx, like a stuck worker pool
+		return nil
+	})
+
+	report := st.Shutdown(context.Background())
+	mu.Lock()
+	defer mu.Unlock()
+
+	if strings.Join(order, ",") != "worker,db" {
+		t.Fatalf("expected hooks to run last registered first, got %v", order)
+	}
+	if len(report.Hooks) != 2 || !report.Hooks[0].TimedOut || report.Hooks[1].TimedOut {
+		t.Fatalf("expected only the worker to time out, got %+v", report.Hooks)
+	}
+	if !strings.Contains(report.Dump, `"worker"`) || !strings.Contains(report.Dump, "shutdown worker") ||
+		!strings.Contains(report.Dump, "goroutine ") {
+		t.Fatalf("expected the dump to show the open hook frame and goroutine stacks, got:\n%s", report.Dump)
+	}
+	if err := report.Err(); err == nil || !strings.Contains(err.Error(), "db: close failed") {
+		t.Fatalf("expected the joined hook errors, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+//go:build !windows
+
+package devtrace
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	signalDumpMu   sync.Mutex
+	signalDumpStop chan struct{}
+)
+
+// EnableSignalDump logs every active trace context (see DumpActiveContexts)
+// whenever the process receives SIGUSR1, or one of the given signals.
+// Calling it again replaces the previous registration.
+func EnableSignalDump(signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGUSR1}
+	}
+
+	signalDumpMu.Lock()
+	defer signalDumpMu.Unlock()
+
+	stopSignalDumpLocked()
+
+	ch := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	signal.Notify(ch, signals...)
+	signalDumpStop = stop
+
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ch:
+				LogActiveContexts()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// DisableSignalDump stops the handler installed by EnableSignalDump
+func DisableSignalDump() {
+	signalDumpMu.Lock()
+	defer signalDumpMu.Unlock()
+
+	stopSignalDumpLocked()
+}
+
+func stopSignalDumpLocked() {
+	if signalDumpStop != nil {
+		close(signalDumpStop)
+		signalDumpStop = nil
+	}
+}
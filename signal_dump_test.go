@@ -0,0 +1,52 @@
+//go:build !windows
+
+package devtrace
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// warnLogger hands WARN messages to a channel, for logs written off the test goroutine
+type warnLogger chan string
+
+func (w warnLogger) Log(level string, msg string, args ...interface{}) {
+	if level == "WARN" {
+		w <- fmt.Sprintf(msg, args...)
+	}
+}
+
+func (w warnLogger) Debug(msg string, args ...interface{}) { w.Log("DEBUG", msg, args...) }
+func (w warnLogger) Info(msg string, args ...interface{})  { w.Log("INFO", msg, args...) }
+func (w warnLogger) Warn(msg string, args ...interface{})  { w.Log("WARN", msg, args...) }
+func (w warnLogger) Error(msg string, args ...interface{}) { w.Log("ERROR", msg, args...) }
+
+func TestSignalDumpLogsActiveContexts(t *testing.T) {
+	originalLogger := GlobalLogger
+	t.Cleanup(func() { GlobalLogger = originalLogger })
+	logged := make(warnLogger, 1)
+	GlobalLogger = logged
+
+	tc := NewTraceContext()
+	tc.Enter(CreateFrame("rebuildIndex", "", "index.go", 40, map[string]interface{}{"shard": 3}))
+	defer tc.Leave()
+
+	EnableSignalDump(syscall.SIGUSR1)
+	defer DisableSignalDump()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case dump := <-logged:
+		if !strings.Contains(dump, "active trace context(s)") || !strings.Contains(dump, "index.go:40 → rebuildIndex") ||
+			!strings.Contains(dump, "shard") {
+			t.Fatalf("expected the open frame in the dump, got:\n%s", dump)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no dump logged after SIGUSR1")
+	}
+}
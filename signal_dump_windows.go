@@ -0,0 +1,12 @@
+//go:build windows
+
+package devtrace
+
+import "os"
+
+// EnableSignalDump is a no-op on Windows, which has no SIGUSR1.
+// Call LogActiveContexts directly instead.
+func EnableSignalDump(signals ...os.Signal) {}
+
+// DisableSignalDump is a no-op on Windows
+func DisableSignalDump() {}
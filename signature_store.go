@@ -0,0 +1,155 @@
+package devtrace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// signatureIndexVersion is part of every store key so a change to the stored
+// format never loads stale entries
+const signatureIndexVersion = "sig3"
+
+// SignatureStore persists parsed signature indexes between runs so large apps
+// don't re-parse their sources on the first log calls after a restart. Keys are
+// derived from a hash of the file contents, so an edited file is simply a miss.
+type SignatureStore interface {
+	Load(key string) ([]byte, bool)
+	Save(key string, data []byte) error
+}
+
+var (
+	signatureStoreMu sync.RWMutex
+	signatureStore   SignatureStore
+)
+
+// SetSignatureStore installs the persistent store used behind the in-memory
+// signature cache; nil disables persistence
+func SetSignatureStore(store SignatureStore) {
+	signatureStoreMu.Lock()
+	defer signatureStoreMu.Unlock()
+	signatureStore = store
+}
+
+func currentSignatureStore() SignatureStore {
+	signatureStoreMu.RLock()
+	defer signatureStoreMu.RUnlock()
+	return signatureStore
+}
+
+// DirSignatureStore keeps one JSON file per source file version in a directory
+type DirSignatureStore struct {
+	Dir string
+}
+
+// NewDirSignatureStore returns a store under dir, or under the user cache
+// directory (e.g. ~/.cache/gotrace/signatures) when dir is empty
+func NewDirSignatureStore(dir string) (*DirSignatureStore, error) {
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(base, "gotrace", "signatures")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirSignatureStore{Dir: dir}, nil
+}
+
+// Load reads the entry for key
+func (s *DirSignatureStore) Load(key string) ([]byte, bool) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, key+".json"))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Save writes the entry for key atomically, so concurrent processes sharing the
+// directory never read a partial file
+func (s *DirSignatureStore) Save(key string, data []byte) error {
+	tmp, err := os.CreateTemp(s.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.Dir, key+".json"))
+}
+
+// storedSignature is the persisted form of functionSignature
+type storedSignature struct {
+	Name       string   `json:"name"`
+	StartLine  int      `json:"start"`
+	EndLine    int      `json:"end"`
+	Signature  string   `json:"signature"`
+	Params     []string `json:"params,omitempty"`
+	TypeParams []string `json:"type_params,omitempty"`
+	Doc        string   `json:"doc,omitempty"`
+}
+
+func signatureStoreKey(data []byte) string {
+	sum := sha256.Sum256(data)
+	return signatureIndexVersion + "-" + hex.EncodeToString(sum[:])
+}
+
+// loadStoredSignatures returns the index for data from store, if present and readable
+func loadStoredSignatures(store SignatureStore, key string) *fileSignature {
+	raw, ok := store.Load(key)
+	if !ok {
+		return nil
+	}
+
+	var stored []storedSignature
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return nil
+	}
+
+	info := &fileSignature{functions: make([]functionSignature, 0, len(stored))}
+	for _, fn := range stored {
+		info.functions = append(info.functions, functionSignature{
+			name:       fn.Name,
+			startLine:  fn.StartLine,
+			endLine:    fn.EndLine,
+			signature:  sanitizeSourceText(fn.Signature),
+			params:     fn.Params,
+			typeParams: fn.TypeParams,
+			doc:        sanitizeSourceText(fn.Doc),
+		})
+	}
+	return info
+}
+
+func saveStoredSignatures(store SignatureStore, key string, info *fileSignature) {
+	stored := make([]storedSignature, 0, len(info.functions))
+	for _, fn := range info.functions {
+		stored = append(stored, storedSignature{
+			Name:       fn.name,
+			StartLine:  fn.startLine,
+			EndLine:    fn.endLine,
+			Signature:  fn.signature,
+			Params:     fn.params,
+			TypeParams: fn.typeParams,
+			Doc:        fn.doc,
+		})
+	}
+
+	raw, err := json.Marshal(stored)
+	if err != nil {
+		return
+	}
+	// A failed write only costs a re-parse next time
+	_ = store.Save(key, raw)
+}
@@ -0,0 +1,56 @@
+package devtrace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSignatureStorePersistsIndex(t *testing.T) {
+	t.Cleanup(func() { SetSignatureStore(nil) })
+
+	store, err := NewDirSignatureStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetSignatureStore(store)
+
+	src := filepath.Join(t.TempDir(), "svc.go")
+	code := "package svc\n\nfunc Load(id int, name string) error {\n\treturn nil\n}\n"
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	info := parseFileSignatures(src)
+	if info == nil || len(info.functions) != 1 {
+		t.Fatalf("expected one parsed function, got %+v", info)
+	}
+
+	key := signatureStoreKey([]byte(code))
+	raw, ok := store.Load(key)
+	if !ok {
+		t.Fatalf("expected the index to be saved under %s", key)
+	}
+
+	// A second run must come from the store, not from parsing
+	tampered := strings.Replace(string(raw), "Load(", "Stored(", 1)
+	if err := store.Save(key, []byte(tampered)); err != nil {
+		t.Fatal(err)
+	}
+	info = parseFileSignatures(src)
+	if info == nil || !strings.Contains(info.functions[0].signature, "Stored(") {
+		t.Fatalf("expected the stored index to be used, got %+v", info)
+	}
+	if got := strings.Join(info.functions[0].params, ","); got != "id,name" {
+		t.Fatalf("expected params to survive the store, got %q", got)
+	}
+
+	// Editing the file changes its key, so the stale entry is never used
+	if err := os.WriteFile(src, []byte(code+"\nfunc Save() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if info = parseFileSignatures(src); info == nil || len(info.functions) != 2 {
+		t.Fatalf("expected edited file to be re-parsed, got %+v", info)
+	}
+}
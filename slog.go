@@ -0,0 +1,205 @@
+package devtrace
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogRecord is a log line attached to the frame that was running when it was emitted
+type LogRecord struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// DefaultSlogTraceKeys are the attribute keys (compared case-insensitively, after
+// the last group) that dependencies commonly use to log a trace ID
+var DefaultSlogTraceKeys = []string{"trace_id", "traceid", "trace-id", TraceIDHeader}
+
+// maxFrameLogs caps the records kept per frame so a chatty dependency can't grow
+// a long-running frame without bound
+const maxFrameLogs = 200
+
+// frameLogsMu guards Frame.Logs, which handlers on other goroutines append to
+var frameLogsMu sync.Mutex
+
+// SlogOptions configures NewSlogHandler
+type SlogOptions struct {
+	// TraceKeys are the attribute keys holding a trace ID; DefaultSlogTraceKeys if empty
+	TraceKeys []string
+}
+
+// slogHandler attaches slog records to the open frame of the matching trace and
+// passes them on unchanged
+type slogHandler struct {
+	next      slog.Handler
+	traceKeys map[string]bool
+	attrs     []slog.Attr // from WithAttrs, keys already qualified by their groups
+	groups    []string
+}
+
+// NewSlogHandler wraps next (which may be nil) so records logged through slog by
+// code that knows nothing about devtrace show up in the trace view. A record goes
+// to the innermost open frame of the trace whose TraceID appears in one of its
+// trace-ID attributes, or else of the trace context carried by the record's ctx.
+func NewSlogHandler(next slog.Handler, opts *SlogOptions) slog.Handler {
+	keys := DefaultSlogTraceKeys
+	if opts != nil && len(opts.TraceKeys) > 0 {
+		keys = opts.TraceKeys
+	}
+
+	h := &slogHandler{next: next, traceKeys: make(map[string]bool, len(keys))}
+	for _, key := range keys {
+		h.traceKeys[strings.ToLower(key)] = true
+	}
+	return h
+}
+
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.next == nil {
+		return true
+	}
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if CurrentConfig().Enabled {
+		h.attach(ctx, r)
+	}
+	if h.next == nil {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(append([]slog.Attr(nil), h.attrs...), h.qualify(attrs)...)
+	if h.next != nil {
+		clone.next = h.next.WithAttrs(attrs)
+	}
+	return &clone
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.groups = append(append([]string(nil), h.groups...), name)
+	if h.next != nil {
+		clone.next = h.next.WithGroup(name)
+	}
+	return &clone
+}
+
+// qualify nests attrs under the handler's open groups
+func (h *slogHandler) qualify(attrs []slog.Attr) []slog.Attr {
+	if len(h.groups) == 0 {
+		return attrs
+	}
+	qualified := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		qualified[i] = slog.Attr{Key: strings.Join(h.groups, ".") + "." + attr.Key, Value: attr.Value}
+	}
+	return qualified
+}
+
+func (h *slogHandler) attach(ctx context.Context, r slog.Record) {
+	attrs := make(map[string]string)
+	traceID := ""
+	collect := func(attr slog.Attr) {
+		flattenSlogAttr("", attr, func(key string, value slog.Value) {
+			text := FormatValue(Redact(key, value.Any()))
+			attrs[key] = text
+			if traceID == "" && h.traceKeys[strings.ToLower(lastKeyElement(key))] {
+				traceID = text
+			}
+		})
+	}
+
+	for _, attr := range h.attrs {
+		collect(attr)
+	}
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		recordAttrs = append(recordAttrs, attr)
+		return true
+	})
+	for _, attr := range h.qualify(recordAttrs) {
+		collect(attr)
+	}
+
+	frame := openFrameFor(ctx, traceID)
+	if frame == nil {
+		return
+	}
+
+	record := LogRecord{Time: r.Time, Level: r.Level.String(), Message: r.Message}
+	if len(attrs) > 0 {
+		record.Attrs = attrs
+	}
+
+	frameLogsMu.Lock()
+	if len(frame.Logs) < maxFrameLogs {
+		frame.Logs = append(frame.Logs, record)
+	}
+	frameLogsMu.Unlock()
+}
+
+// openFrameFor finds the innermost open frame of the trace with traceID, falling
+// back to the trace context carried by ctx
+func openFrameFor(ctx context.Context, traceID string) *Frame {
+	if traceID != "" {
+		for _, tc := range ActiveContexts() {
+			if tc.TraceID == traceID {
+				if frame := tc.GetCurrentFrame(); frame != nil {
+					return frame
+				}
+			}
+		}
+	}
+	return FromContext(ctx).GetCurrentFrame()
+}
+
+// flattenSlogAttr reports every leaf of attr with its dotted group path
+func flattenSlogAttr(prefix string, attr slog.Attr, emit func(string, slog.Value)) {
+	value := attr.Value.Resolve()
+	key := attr.Key
+	if prefix != "" && key != "" {
+		key = prefix + "." + key
+	} else if key == "" {
+		key = prefix
+	}
+
+	if value.Kind() == slog.KindGroup {
+		for _, child := range value.Group() {
+			flattenSlogAttr(key, child, emit)
+		}
+		return
+	}
+	if key != "" {
+		emit(key, value)
+	}
+}
+
+func lastKeyElement(key string) string {
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		return key[i+1:]
+	}
+	return key
+}
+
+// frameLogs returns a copy of the records attached to frame
+func frameLogs(frame *Frame) []LogRecord {
+	frameLogsMu.Lock()
+	defer frameLogsMu.Unlock()
+	if len(frame.Logs) == 0 {
+		return nil
+	}
+	return append([]LogRecord(nil), frame.Logs...)
+}
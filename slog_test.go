@@ -0,0 +1,60 @@
+package devtrace
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandlerAttachesDependencyLogs(t *testing.T) {
+	originalConfig := Config
+	t.Cleanup(func() {
+		SetConfig(originalConfig)
+		DisableRecorder()
+	})
+	cfg := Config
+	cfg.Enabled = true
+	cfg.ShowTiming = false
+	SetConfig(cfg)
+	EnableRecorder(10)
+
+	var out bytes.Buffer
+	logger := slog.New(NewSlogHandler(slog.NewTextHandler(&out, nil), nil))
+
+	// A dependency that only knows the trace ID, logging from another goroutine
+	dependency := func(traceID string) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			logger.WithGroup("http").Info("upstream call", slog.String("trace_id", traceID), slog.Int("status", 502))
+		}()
+		<-done
+	}
+
+	handle := TraceFunc(func(ctx context.Context) {
+		dependency(FromContext(ctx).EnsureTraceID())
+		logger.InfoContext(ctx, "handler done")
+	}, "handle").(func(context.Context))
+
+	handle(WithTraceContext(context.Background(), NewTraceContext()))
+
+	records := RecentTraces(TraceFilter{Function: "handle"})
+	if len(records) != 1 {
+		t.Fatalf("expected one recorded frame, got %d", len(records))
+	}
+	logs := SnapshotFrame(records[0].Frame).Logs
+	if len(logs) != 2 {
+		t.Fatalf("expected both records on the frame, got %+v", logs)
+	}
+	if logs[0].Message != "upstream call" || logs[0].Attrs["http.status"] != "502" {
+		t.Fatalf("unexpected dependency record: %+v", logs[0])
+	}
+	if logs[1].Message != "handler done" || logs[1].Level != "INFO" {
+		t.Fatalf("unexpected ctx record: %+v", logs[1])
+	}
+	if !strings.Contains(out.String(), "upstream call") {
+		t.Fatalf("records should still reach the wrapped handler: %q", out.String())
+	}
+}
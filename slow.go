@@ -0,0 +1,20 @@
+package devtrace
+
+import (
+	"context"
+	"time"
+)
+
+// warnIfSlow logs a WARN with the current stack when a frame ran past its threshold.
+// threshold takes precedence over cfg.SlowThreshold when non-zero.
+// It must be called while the frame is still on the stack so it appears in the output.
+func warnIfSlow(ctx context.Context, frame *Frame, elapsed, threshold time.Duration, cfg DevTraceConfig) {
+	if threshold <= 0 {
+		threshold = cfg.SlowThreshold
+	}
+	if threshold <= 0 || elapsed <= threshold || GlobalEnhancedLogger == nil {
+		return
+	}
+
+	GlobalEnhancedLogger.Warn(ctx, "🐢 slow call: %s took %v (threshold %v)", frame.Function, elapsed, threshold)
+}
@@ -0,0 +1,232 @@
+package devtrace
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SlowProfileOptions configures StartSlowProfiler
+type SlowProfileOptions struct {
+	Threshold time.Duration // frames open longer than this are profiled
+	Duration  time.Duration // longest a profile runs (defaults to 1s); it stops early once the frame leaves
+	Dir       string        // where profiles are written (defaults to os.TempDir())
+	Interval  time.Duration // how often active contexts are scanned (defaults to Threshold/5)
+	Max       int           // profiles written before the profiler stops capturing; 0 is unlimited
+}
+
+// SlowProfile is the metadata written next to each profile as <name>.json
+type SlowProfile struct {
+	Function  string        `json:"function"`
+	File      string        `json:"file"`
+	Line      int           `json:"line"`
+	Operation string        `json:"operation,omitempty"`
+	TraceID   string        `json:"trace_id,omitempty"`
+	Goroutine uint64        `json:"goroutine,omitempty"`
+	Path      []string      `json:"path"`       // open frames from the outermost down to Function
+	StartTime time.Time     `json:"start_time"` // when the frame was entered
+	OpenFor   time.Duration `json:"open_for"`   // how long it had run when the profile started
+	Profiled  time.Duration `json:"profiled"`   // how long the profile ran
+	Kind      string        `json:"kind"`       // "cpu", or "goroutine" when another CPU profile was running
+	Profile   string        `json:"profile"`    // file name of the profile in the same directory
+}
+
+// StartSlowProfiler starts a goroutine that periodically scans active trace
+// contexts and, for a frame open longer than opts.Threshold, captures a CPU
+// profile while the frame keeps running, then writes it to opts.Dir as
+// <time>-<function>.pprof with a SlowProfile in <time>-<function>.json.
+//
+// CPU profiles cover the whole process; with Config.PprofLabels the samples of
+// the slow call can be picked out with -tagfocus devtrace.trace_id=<trace ID>.
+// Only one CPU profile can run at a time, so while another one is running (a
+// profiling endpoint, or go test -cpuprofile) a goroutine dump is written
+// instead. Each context gets one profile per slow frame, for the innermost one.
+// Call the returned function to stop it.
+func StartSlowProfiler(opts SlowProfileOptions) (stop func()) {
+	if opts.Threshold <= 0 {
+		return func() {}
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = time.Second
+	}
+	if opts.Dir == "" {
+		opts.Dir = os.TempDir()
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = opts.Threshold / 5
+	}
+
+	done := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+
+		profiled := make(map[*Frame]struct{})
+		written := 0
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				var tc *TraceContext
+				var path []*Frame
+				tc, path, profiled = nextSlowFrame(now, opts.Threshold, profiled)
+				if tc == nil {
+					continue
+				}
+				if err := captureSlowProfile(tc, path, opts, done); err != nil {
+					if GlobalLogger != nil {
+						GlobalLogger.Warn("⚠ slow call profile of %s: %v", path[len(path)-1].Function, err)
+					}
+					continue
+				}
+				if written++; opts.Max > 0 && written >= opts.Max {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// nextSlowFrame finds a context whose innermost frame past threshold hasn't been
+// profiled and returns it with the path down to that frame. Like
+// scanStuckFrames, it returns the profiled frames that are still open, now
+// including the path of the frame found.
+func nextSlowFrame(now time.Time, threshold time.Duration, profiled map[*Frame]struct{}) (*TraceContext, []*Frame, map[*Frame]struct{}) {
+	stillOpen := make(map[*Frame]struct{}, len(profiled))
+	var found *TraceContext
+	var path []*Frame
+
+	for _, tc := range ActiveContexts() {
+		frames := tc.Stack()
+		slow := -1
+		for i, frame := range frames {
+			if frame == nil {
+				continue
+			}
+			if _, seen := profiled[frame]; seen {
+				stillOpen[frame] = struct{}{}
+			} else if !frame.StartTime.IsZero() && now.Sub(frame.StartTime) > threshold {
+				slow = i
+			}
+		}
+		if slow >= 0 && found == nil {
+			found, path = tc, frames[:slow+1]
+			for _, frame := range path {
+				stillOpen[frame] = struct{}{}
+			}
+		}
+	}
+
+	return found, path, stillOpen
+}
+
+// captureSlowProfile profiles until the last frame of path leaves tc,
+// opts.Duration passes or done is closed, then writes the profile and its
+// metadata
+func captureSlowProfile(tc *TraceContext, path []*Frame, opts SlowProfileOptions, done <-chan struct{}) error {
+	frame := path[len(path)-1]
+	start := time.Now()
+	meta := SlowProfile{
+		Function:  frame.Function,
+		File:      frame.File,
+		Line:      frame.Line,
+		Operation: frame.Operation,
+		TraceID:   tc.TraceID,
+		Goroutine: frame.Goroutine,
+		Path:      make([]string, len(path)),
+		StartTime: frame.StartTime,
+		OpenFor:   start.Sub(frame.StartTime),
+		Kind:      "cpu",
+	}
+	for i, f := range path {
+		meta.Path[i] = f.Function
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return err
+	}
+	name := start.Format("20060102-150405.000") + "-" + profileFileName(frame.Function)
+	meta.Profile = name + ".pprof"
+	out, err := os.Create(filepath.Join(opts.Dir, meta.Profile))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := pprof.StartCPUProfile(out); err != nil {
+		// another CPU profile is running: fall back to what each goroutine is doing
+		meta.Kind = "goroutine"
+		if err := pprof.Lookup("goroutine").WriteTo(out, 0); err != nil {
+			return err
+		}
+	} else {
+		waitForFrame(tc, frame, opts, done)
+		pprof.StopCPUProfile()
+	}
+	meta.Profiled = time.Since(start)
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(opts.Dir, name+".json"), append(data, '\n'), 0o644)
+}
+
+// waitForFrame returns once frame is no longer open in tc, opts.Duration has
+// passed or done is closed. The stack is read without synchronization, like
+// ActiveContexts.
+func waitForFrame(tc *TraceContext, frame *Frame, opts SlowProfileOptions, done <-chan struct{}) {
+	deadline := time.NewTimer(opts.Duration)
+	defer deadline.Stop()
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+			if !frameOpen(tc, frame) {
+				return
+			}
+		}
+	}
+}
+
+func frameOpen(tc *TraceContext, frame *Frame) bool {
+	for _, open := range tc.Stack() {
+		if open == frame {
+			return true
+		}
+	}
+	return false
+}
+
+// profileFileName turns a function name into something safe in a file name
+func profileFileName(function string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, shortFunctionName(function))
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	if name == "" {
+		name = "frame"
+	}
+	return name
+}
@@ -0,0 +1,91 @@
+package devtrace
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWarnIfSlowThreshold(t *testing.T) {
+	originalEnhanced := GlobalEnhancedLogger
+	t.Cleanup(func() { GlobalEnhancedLogger = originalEnhanced })
+	logger := &captureLogger{}
+	GlobalEnhancedLogger = NewEnhancedLogger(&StackLoggerOptions{})
+	GlobalEnhancedLogger.SetLogger(logger)
+
+	tests := []struct {
+		name      string
+		elapsed   time.Duration
+		threshold time.Duration // the per-function override
+		global    time.Duration
+		want      string // threshold named in the warning, "" for none
+	}{
+		{name: "below the global threshold", elapsed: 99 * time.Millisecond, global: 100 * time.Millisecond},
+		{name: "at the global threshold", elapsed: 100 * time.Millisecond, global: 100 * time.Millisecond},
+		{name: "past the global threshold", elapsed: 100*time.Millisecond + time.Nanosecond, global: 100 * time.Millisecond, want: "threshold 100ms"},
+		{name: "disabled", elapsed: time.Hour},
+		{name: "override below the global", elapsed: 20 * time.Millisecond, threshold: 10 * time.Millisecond, global: 100 * time.Millisecond, want: "threshold 10ms"},
+		{name: "override above the global", elapsed: 200 * time.Millisecond, threshold: time.Second, global: 100 * time.Millisecond},
+		{name: "at the override", elapsed: time.Second, threshold: time.Second, global: 100 * time.Millisecond},
+		{name: "override without a global", elapsed: 20 * time.Millisecond, threshold: 10 * time.Millisecond, want: "threshold 10ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger.messages = nil
+			frame := &Frame{Function: "chargeCard"}
+			warnIfSlow(context.Background(), frame, tt.elapsed, tt.threshold, DevTraceConfig{SlowThreshold: tt.global})
+
+			if tt.want == "" {
+				if len(logger.messages) != 0 {
+					t.Fatalf("expected no warning, got %q", logger.messages)
+				}
+				return
+			}
+			if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "slow call: chargeCard took") || !strings.Contains(logger.messages[0], tt.want) {
+				t.Fatalf("expected a slow call warning with %s, got %q", tt.want, logger.messages)
+			}
+		})
+	}
+}
+
+func TestTracedCallWarnsPastItsSlowThreshold(t *testing.T) {
+	original := CurrentConfig()
+	originalEnhanced := GlobalEnhancedLogger
+	t.Cleanup(func() {
+		SetConfig(original)
+		GlobalEnhancedLogger = originalEnhanced
+	})
+	UpdateConfig(func(c *DevTraceConfig) {
+		c.Enabled = true
+		c.SlowThreshold = time.Hour
+	})
+	logger := &captureLogger{}
+	GlobalEnhancedLogger = NewEnhancedLogger(&StackLoggerOptions{})
+	GlobalEnhancedLogger.SetLogger(logger)
+
+	sleep := func(d time.Duration) { time.Sleep(d) }
+	NewTracedFunc(sleep, nil).Call(context.Background(), 5*time.Millisecond)
+	if len(logger.messages) != 0 {
+		t.Fatalf("expected the global threshold of an hour to keep quiet, got %q", logger.messages)
+	}
+
+	NewTracedFunc(sleep, &TraceOptions{SlowThreshold: time.Millisecond}).Call(context.Background(), 5*time.Millisecond)
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "threshold 1ms") {
+		t.Fatalf("expected the function's own threshold to trigger a warning, got %q", logger.messages)
+	}
+	if !strings.Contains(logger.messages[0], `"arg0": 5ms`) {
+		t.Fatalf("expected the warning to show the stack with the call's args, got %q", logger.messages[0])
+	}
+
+	// Frames left through a context use the global threshold
+	UpdateConfig(func(c *DevTraceConfig) { c.SlowThreshold = time.Millisecond })
+	logger.messages = nil
+	ctx := WithTraceContext(context.Background(), NewTraceContext())
+	EnterContext(ctx, CreateFrame("loadReport", "", "report.go", 1, nil))
+	time.Sleep(5 * time.Millisecond)
+	LeaveContext(ctx)
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "slow call: loadReport") {
+		t.Fatalf("expected LeaveContext to warn about the slow frame, got %q", logger.messages)
+	}
+}
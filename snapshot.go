@@ -0,0 +1,97 @@
+package devtrace
+
+import (
+	"fmt"
+	"time"
+)
+
+// FrameSnapshot is a JSON-safe copy of a Frame: args and results are rendered to
+// strings (after redaction) so values such as channels or funcs never break encoding
+type FrameSnapshot struct {
+	Function   string            `json:"function"`
+	Signature  string            `json:"signature,omitempty"`
+	File       string            `json:"file"`
+	Line       int               `json:"line"`
+	Class      FrameClass        `json:"class,omitempty"`
+	Args       map[string]string `json:"args,omitempty"`
+	Results    []string          `json:"results,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	StartTime  time.Time         `json:"start_time,omitempty"`
+	EndTime    time.Time         `json:"end_time,omitempty"`
+	Duration   time.Duration     `json:"duration"`
+	Unfinished bool              `json:"unfinished,omitempty"`
+	Allocs     uint64            `json:"allocs,omitempty"`
+	AllocBytes uint64            `json:"alloc_bytes,omitempty"`
+	Goroutine  uint64            `json:"goroutine,omitempty"`
+	SpanID     string            `json:"span_id,omitempty"`
+	Logs       []LogRecord       `json:"logs,omitempty"`
+	Events     []SpanEvent       `json:"events,omitempty"` // attribute values rendered like Args
+	Panic      string            `json:"panic,omitempty"`
+	Operation  string            `json:"operation,omitempty"`
+	Doc        string            `json:"doc,omitempty"`
+	MAC        string            `json:"mac,omitempty"` // set by ExportSecurity signing
+}
+
+// SnapshotFrame converts a frame into its JSON-safe form
+func SnapshotFrame(frame *Frame) FrameSnapshot {
+	if frame == nil {
+		return FrameSnapshot{}
+	}
+
+	snapshot := FrameSnapshot{
+		Function:   frame.Function,
+		Signature:  frame.Signature,
+		File:       frame.File,
+		Line:       frame.Line,
+		Class:      ClassifyFrame(frame),
+		StartTime:  frame.StartTime,
+		EndTime:    frame.EndTime,
+		Duration:   frame.Duration,
+		Unfinished: frame.Unfinished,
+		Allocs:     frame.Allocs,
+		AllocBytes: frame.AllocBytes,
+		Goroutine:  frame.Goroutine,
+		SpanID:     frame.SpanID,
+		Logs:       frameLogs(frame),
+		Events:     snapshotEvents(frame),
+		Operation:  frame.Operation,
+		Doc:        lookupFrameDoc(frame),
+	}
+
+	if len(frame.Args) > 0 {
+		snapshot.Args = make(map[string]string, len(frame.Args))
+		for name, value := range frame.Args {
+			// Secrets inside values rendered from non-string types only show up here
+			snapshot.Args[name] = scrubSecrets(FormatValue(Redact(name, value)))
+		}
+	}
+
+	for _, result := range frame.Results {
+		snapshot.Results = append(snapshot.Results, scrubSecrets(FormatValue(result)))
+	}
+
+	if err := FrameError(frame); err != nil {
+		snapshot.Error = err.Error()
+	}
+	if frame.Panic != nil {
+		snapshot.Panic = fmt.Sprintf("%v", frame.Panic)
+	}
+
+	return snapshot
+}
+
+// snapshotEvents copies the events of frame with their attributes rendered
+func snapshotEvents(frame *Frame) []SpanEvent {
+	events := frameEvents(frame)
+	for i, event := range events {
+		if len(event.Attrs) == 0 {
+			continue
+		}
+		attrs := make(Attrs, len(event.Attrs))
+		for name, value := range event.Attrs {
+			attrs[name] = scrubSecrets(FormatValue(Redact(name, value)))
+		}
+		events[i].Attrs = attrs
+	}
+	return events
+}
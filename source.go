@@ -0,0 +1,188 @@
+package devtrace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Source files are located through runtime paths and may be anything on disk:
+// generated megabyte-long files, binaries, FIFOs, or text with terminal escapes.
+// These limits keep snippet and signature lookups cheap and their output printable.
+const (
+	maxSourceFileSize     = 8 << 20
+	maxSnippetLineLength  = 512
+	sourceParseTimeout    = 2 * time.Second
+	truncatedLineEllipsis = " …"
+)
+
+var errNotSourceFile = errors.New("not a regular source file")
+
+// statSourceFile checks that name is a regular file within maxSourceFileSize
+func statSourceFile(name string) (os.FileInfo, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, errNotSourceFile
+	}
+	if info.Size() > maxSourceFileSize {
+		return nil, fmt.Errorf("%s: %d bytes exceeds source size limit", name, info.Size())
+	}
+	return info, nil
+}
+
+// readSourceFile returns the contents of a regular source file within
+// maxSourceFileSize, from the source cache while the file is unchanged. The
+// returned slice is shared and must not be modified.
+func readSourceFile(name string) ([]byte, error) {
+	info, err := statSourceFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if data, ok := sources.get(name, info.ModTime(), info.Size()); ok {
+		return data, nil
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// The file may grow between Stat and Read
+	data, err := io.ReadAll(io.LimitReader(file, maxSourceFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSourceFileSize {
+		return nil, fmt.Errorf("%s: exceeds source size limit", name)
+	}
+	sources.put(name, info.ModTime(), info.Size(), data)
+	return data, nil
+}
+
+// SourceSnippet returns contextLines lines of file on each side of line, with
+// line numbers and line itself marked with ">", or "" when the file can't be read
+func SourceSnippet(file string, line, contextLines int) string {
+	snippet, err := getCodeSnippet(file, line, contextLines)
+	if err != nil || snippet == "" {
+		return ""
+	}
+	// readSnippet indents for log output
+	lines := strings.Split(snippet, "\n")
+	for i, text := range lines {
+		lines[i] = strings.TrimPrefix(text, "      ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// readSnippet renders contextLines lines around line from r. It stops reading once
+// the window is complete and truncates lines longer than maxSnippetLineLength.
+func readSnippet(r io.Reader, line int, contextLines int) (string, error) {
+	if line <= 0 {
+		return "", fmt.Errorf("line %d out of range", line)
+	}
+
+	start := max(1, line-contextLines)
+	end := line + contextLines
+
+	reader := bufio.NewReader(r)
+	window := make([]string, 0, end-start+1)
+	for current := 1; current <= end; current++ {
+		text, err := readLimitedLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if current >= start {
+			window = append(window, text)
+		}
+	}
+
+	if start+len(window)-1 < line {
+		return "", fmt.Errorf("line %d out of range", line)
+	}
+
+	snippet := strings.Builder{}
+	for i, text := range window {
+		lineNum := start + i
+		marker := " "
+		if lineNum == line {
+			marker = ">"
+		}
+		snippet.WriteString(fmt.Sprintf("      %s %d %s\n", marker, lineNum, text))
+	}
+
+	return strings.TrimRight(snippet.String(), "\n"), nil
+}
+
+// readLimitedLine reads one line, keeping at most maxSnippetLineLength bytes of it
+func readLimitedLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	truncated := false
+	sawData := false
+
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			if err == io.EOF && sawData {
+				break
+			}
+			return "", err
+		}
+		sawData = true
+
+		if room := maxSnippetLineLength - len(line); room > 0 {
+			if len(chunk) > room {
+				chunk, truncated = chunk[:room], true
+			}
+			line = append(line, chunk...)
+		} else if len(chunk) > 0 {
+			truncated = true
+		}
+
+		if !isPrefix {
+			break
+		}
+	}
+
+	text := sanitizeSourceText(string(line))
+	if truncated {
+		text += truncatedLineEllipsis
+	}
+	return text, nil
+}
+
+// sanitizeSourceText replaces invalid UTF-8 and control characters (other than tab)
+// so file contents can't corrupt the terminal or log output
+func sanitizeSourceText(s string) string {
+	clean := true
+	for _, r := range s {
+		if r == utf8.RuneError || (unicode.IsControl(r) && r != '\t') {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return s
+	}
+
+	var builder strings.Builder
+	for _, r := range strings.ToValidUTF8(s, "�") {
+		if unicode.IsControl(r) && r != '\t' {
+			r = '�'
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
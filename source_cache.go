@@ -0,0 +1,89 @@
+package devtrace
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultSourceCacheSize is the byte budget of the source file cache
+const DefaultSourceCacheSize = 16 << 20
+
+// sourceCache keeps recently read source files so rendering snippets and
+// parsing signatures on hot paths doesn't re-read them. Entries are keyed by
+// path and dropped when the file's modification time or size changes; the
+// least recently used go first once the contents exceed the budget.
+type sourceCache struct {
+	mu      sync.Mutex
+	budget  int
+	used    int
+	entries map[string]*list.Element
+	lru     list.List // of *sourceCacheEntry, most recently used first
+}
+
+type sourceCacheEntry struct {
+	path    string
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+var sources = sourceCache{budget: DefaultSourceCacheSize, entries: make(map[string]*list.Element)}
+
+// SetSourceCacheSize sets the byte budget of the cache shared by code snippets
+// and signature parsing, evicting what no longer fits; 0 disables caching
+func SetSourceCacheSize(bytes int) {
+	sources.mu.Lock()
+	defer sources.mu.Unlock()
+
+	if bytes < 0 {
+		bytes = 0
+	}
+	sources.budget = bytes
+	sources.evict()
+}
+
+// get returns the cached contents of path if it hasn't changed since
+func (c *sourceCache) get(path string, modTime time.Time, size int64) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[path]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*sourceCacheEntry)
+	if !entry.modTime.Equal(modTime) || entry.size != size {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.data, true
+}
+
+func (c *sourceCache) put(path string, modTime time.Time, size int64, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(data) > c.budget {
+		return
+	}
+	if elem, ok := c.entries[path]; ok {
+		c.remove(elem)
+	}
+	c.entries[path] = c.lru.PushFront(&sourceCacheEntry{path: path, modTime: modTime, size: size, data: data})
+	c.used += len(data)
+	c.evict()
+}
+
+func (c *sourceCache) evict() {
+	for c.used > c.budget {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *sourceCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*sourceCacheEntry)
+	delete(c.entries, entry.path)
+	c.used -= len(entry.data)
+}
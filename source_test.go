@@ -0,0 +1,152 @@
+package devtrace
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+func FuzzParseSignatures(f *testing.F) {
+	f.Add([]byte("package p\n\nfunc F(a int, b ...string) (n int, err error) { return }\n"))
+	f.Add([]byte("package p\nfunc (r *T[K]) M(fn func(chan<- [3]int) map[K]struct{}) {}\n"))
+	f.Add([]byte("package p\nfunc \xff(\x1b[2J) {}"))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		info := parseSignatures("fuzz.go", data)
+		if info == nil {
+			return
+		}
+		for _, fn := range info.functions {
+			if fn.startLine > fn.endLine {
+				t.Fatalf("invalid range %d-%d for %s", fn.startLine, fn.endLine, fn.name)
+			}
+			assertPrintable(t, fn.signature)
+		}
+	})
+}
+
+func FuzzReadSnippet(f *testing.F) {
+	f.Add([]byte("a\nb\nc\nd\n"), 2, 1)
+	f.Add([]byte(strings.Repeat("x", 70000)+"\n\x1b]0;pwned\x07\n"), 2, 2)
+	f.Add([]byte("\xff\xfe\r\n"), 1, 5)
+
+	f.Fuzz(func(t *testing.T, data []byte, line int, contextLines int) {
+		if contextLines < 0 || contextLines > 50 {
+			return
+		}
+		snippet, err := readSnippet(bytes.NewReader(data), line, contextLines)
+		if err != nil {
+			return
+		}
+		for _, text := range strings.Split(snippet, "\n") {
+			if len(text) > maxSnippetLineLength+64 {
+				t.Fatalf("line not truncated: %d bytes", len(text))
+			}
+			assertPrintable(t, text)
+		}
+	})
+}
+
+func assertPrintable(t *testing.T, s string) {
+	t.Helper()
+	if !utf8.ValidString(s) {
+		t.Fatalf("invalid UTF-8 in %q", s)
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) && r != '\t' {
+			t.Fatalf("control character %U in %q", r, s)
+		}
+	}
+}
+
+func TestSourceReadersRejectNonRegularAndOversizedFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := getCodeSnippet(dir, 1, 2); err == nil {
+		t.Fatalf("expected a directory to be rejected")
+	}
+
+	big := filepath.Join(dir, "big.go")
+	if err := os.WriteFile(big, bytes.Repeat([]byte("//\n"), maxSourceFileSize/3+1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if parseFileSignatures(big) != nil {
+		t.Fatalf("expected oversized file to be skipped")
+	}
+}
+
+func TestSourceMapTranslatesRuntimePositions(t *testing.T) {
+	t.Cleanup(func() { SetSourceMap(nil) })
+
+	path := filepath.Join(t.TempDir(), "map.json")
+	data := `{"files":[{"file":"/build/svc.go","source":"/src/svc.go","lines":[1,2,0,0,3,4]}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadSourceMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetSourceMap(m)
+
+	for _, tc := range []struct {
+		file       string
+		line, want int
+		wantFile   string
+	}{
+		{"/build/svc.go", 5, 3, "/src/svc.go"},
+		{"/build/svc.go", 4, 2, "/src/svc.go"}, // added by instrumentation: the line above
+		{"/build/svc.go", 9, 9, "/src/svc.go"},
+		{"/other.go", 5, 5, "/other.go"},
+	} {
+		if file, line := originalPosition(tc.file, tc.line); file != tc.wantFile || line != tc.want {
+			t.Fatalf("%s:%d translated to %s:%d, want %s:%d", tc.file, tc.line, file, line, tc.wantFile, tc.want)
+		}
+	}
+}
+
+func TestSourceCacheInvalidatesAndEvicts(t *testing.T) {
+	t.Cleanup(func() { SetSourceCacheSize(DefaultSourceCacheSize) })
+	SetSourceCacheSize(64)
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(a, []byte("package a\n\nvar x = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if snippet, _ := getCodeSnippet(a, 3, 1); !strings.Contains(snippet, "x = 1") {
+		t.Fatalf("unexpected snippet %q", snippet)
+	}
+
+	// A rewrite shows up even when the size stays the same
+	if err := os.WriteFile(a, []byte("package a\n\nvar y = 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(a, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if snippet, _ := getCodeSnippet(a, 3, 1); !strings.Contains(snippet, "y = 2") {
+		t.Fatalf("stale snippet %q", snippet)
+	}
+
+	b := filepath.Join(dir, "b.go")
+	if err := os.WriteFile(b, bytes.Repeat([]byte("//\n"), 15), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readSourceFile(b); err != nil {
+		t.Fatal(err)
+	}
+	sources.mu.Lock()
+	_, cachedA := sources.entries[a]
+	used := sources.used
+	sources.mu.Unlock()
+	if cachedA || used > 64 {
+		t.Fatalf("expected a.go evicted within budget, cached=%v used=%d", cachedA, used)
+	}
+}
@@ -0,0 +1,116 @@
+package devtrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// SourceMap translates positions in instrumented files back to the original
+// sources. gotrace-instrument -source-map writes one for -overlay and -out
+// builds, whose binaries otherwise report the lines of the instrumented copies.
+type SourceMap struct {
+	Files []SourceMapFile `json:"files"`
+
+	byFile map[string]*SourceMapFile
+}
+
+// SourceMapFile maps the lines of one instrumented file
+type SourceMapFile struct {
+	File   string `json:"file"`   // instrumented file, as the runtime reports it
+	Source string `json:"source"` // original file
+	Lines  []int  `json:"lines"`  // original line of each line of File; 0 for lines instrumentation added
+}
+
+var (
+	sourceMapMu   sync.RWMutex
+	sourceMap     *SourceMap
+	sourceMapOnce sync.Once
+)
+
+// LoadSourceMap reads a source map written by gotrace-instrument -source-map
+func LoadSourceMap(path string) (*SourceMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &SourceMap{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+// SetSourceMap installs the source map applied to positions taken from the
+// runtime; nil removes it. Without a call, the file named by the
+// DEVTRACE_SOURCE_MAP environment variable is loaded on first use.
+func SetSourceMap(m *SourceMap) {
+	sourceMapOnce.Do(func() {}) // an explicit map wins over the environment
+	if m != nil {
+		m.index()
+	}
+
+	sourceMapMu.Lock()
+	defer sourceMapMu.Unlock()
+	sourceMap = m
+}
+
+func currentSourceMap() *SourceMap {
+	sourceMapOnce.Do(func() {
+		path, ok := lookupEnv("DEVTRACE_SOURCE_MAP")
+		if !ok {
+			return
+		}
+		m, err := LoadSourceMap(path)
+		if err != nil {
+			if GlobalLogger != nil {
+				GlobalLogger.Warn("ignoring DEVTRACE_SOURCE_MAP: %v", err)
+			}
+			return
+		}
+		m.index()
+
+		sourceMapMu.Lock()
+		sourceMap = m
+		sourceMapMu.Unlock()
+	})
+
+	sourceMapMu.RLock()
+	defer sourceMapMu.RUnlock()
+	return sourceMap
+}
+
+func (m *SourceMap) index() {
+	m.byFile = make(map[string]*SourceMapFile, len(m.Files))
+	for i := range m.Files {
+		m.byFile[m.Files[i].File] = &m.Files[i]
+	}
+}
+
+// Translate returns the original position of line in file. Lines added by
+// instrumentation map to the nearest original line above them; positions in
+// files the map doesn't know are returned unchanged.
+func (m *SourceMap) Translate(file string, line int) (string, int) {
+	if m == nil || m.byFile == nil {
+		return file, line
+	}
+	mapped, ok := m.byFile[file]
+	if !ok || line <= 0 {
+		return file, line
+	}
+	if line > len(mapped.Lines) {
+		return mapped.Source, line
+	}
+	for i := line - 1; i >= 0; i-- {
+		if original := mapped.Lines[i]; original > 0 {
+			return mapped.Source, original
+		}
+	}
+	return mapped.Source, 1
+}
+
+// originalPosition applies the installed source map to a runtime position
+func originalPosition(file string, line int) (string, int) {
+	return currentSourceMap().Translate(file, line)
+}
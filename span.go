@@ -0,0 +1,137 @@
+package devtrace
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Attrs are the attributes of a span or of one of its events
+type Attrs map[string]interface{}
+
+// SpanEvent is something that happened during a span, added with Span.AddEvent
+type SpanEvent struct {
+	Time  time.Time `json:"time"`
+	Name  string    `json:"name"`
+	Attrs Attrs     `json:"attrs,omitempty"`
+}
+
+// maxFrameEvents caps the events kept per frame, as maxFrameLogs does for logs
+const maxFrameEvents = 200
+
+// frameEventsMu guards Frame.Events, which a span may get from other goroutines
+var frameEventsMu sync.Mutex
+
+// Span traces a region of code that isn't a whole function: it is a frame of
+// its own, entered into the trace context of ctx, so it nests with traced
+// functions and shows up in stacks, the recorder and stats like one.
+//
+//	span := devtrace.StartSpan(ctx, "charge-card", devtrace.Attrs{"amount": amount})
+//	defer span.End()
+//	...
+//	span.AddEvent("retry", devtrace.Attrs{"attempt": attempt})
+//
+// Spans on a context must end in the reverse order they started. A nil Span,
+// as StartSpan returns while tracing is off, ignores every call.
+type Span struct {
+	ctx   context.Context
+	frame *Frame
+	once  sync.Once
+}
+
+// StartSpan starts a span named name under the current frame of ctx. The
+// attributes are stored as the frame's args, so redaction applies to them.
+func StartSpan(ctx context.Context, name string, attrs Attrs) *Span {
+	cfg := ConfigFromContext(ctx)
+	if !cfg.Enabled || !sampled(cfg) {
+		return nil
+	}
+
+	_, file, line, _ := runtime.Caller(1)
+	file, line = originalPosition(file, line)
+	var args map[string]interface{}
+	if len(attrs) > 0 {
+		args = make(map[string]interface{}, len(attrs))
+		for key, value := range attrs {
+			args[key] = value
+		}
+	}
+
+	frame := CreateFrame(name, "", file, line, args)
+	frame.retain() // the span holds it until End
+	EnterContext(ctx, frame)
+	return &Span{ctx: ctx, frame: frame}
+}
+
+// Frame returns the frame of the span, nil for a nil span
+func (s *Span) Frame() *Frame {
+	if s == nil {
+		return nil
+	}
+	return s.frame
+}
+
+// SetAttr sets an attribute of the span. Like RecordError it changes the
+// frame itself, so only the goroutine that started the span may call it.
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	setOpenArg(s.frame, key, Redact(key, value))
+}
+
+// AddEvent records that name happened now, with optional attributes. It may
+// be called from any goroutine.
+func (s *Span) AddEvent(name string, attrs Attrs) {
+	if s == nil {
+		return
+	}
+	addFrameEvent(s.frame, name, attrs)
+}
+
+// addFrameEvent appends an event to frame with a redacted copy of attrs
+func addFrameEvent(frame *Frame, name string, attrs Attrs) {
+	event := SpanEvent{Time: time.Now(), Name: name}
+	if len(attrs) > 0 {
+		event.Attrs = make(Attrs, len(attrs))
+		for key, value := range attrs {
+			event.Attrs[key] = value
+		}
+		redactArgs(event.Attrs)
+	}
+
+	frameEventsMu.Lock()
+	defer frameEventsMu.Unlock()
+	if len(frame.Events) < maxFrameEvents {
+		frame.Events = append(frame.Events, event)
+	}
+}
+
+// RecordError marks the span as failed with err, which FrameError then reports
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.AddEvent("error", Attrs{"error": err.Error()})
+	s.frame.Results = []interface{}{err}
+	s.frame.Error = err
+}
+
+// End leaves the span's frame. Only the first call has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() { LeaveContext(s.ctx) })
+}
+
+// frameEvents returns a copy of the events recorded on frame
+func frameEvents(frame *Frame) []SpanEvent {
+	frameEventsMu.Lock()
+	defer frameEventsMu.Unlock()
+	if len(frame.Events) == 0 {
+		return nil
+	}
+	return append([]SpanEvent(nil), frame.Events...)
+}
@@ -1,7 +1,6 @@
 package devtrace
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"fmt"
@@ -9,11 +8,11 @@ import (
 	"go/format"
 	"go/parser"
 	"go/token"
-	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 )
 
 // StackLoggerOptions configures the enhanced stack logger
@@ -52,11 +51,13 @@ type fileSignature struct {
 }
 
 type functionSignature struct {
-	name      string
-	startLine int
-	endLine   int
-	signature string
-	params    []string
+	name       string
+	startLine  int
+	endLine    int
+	signature  string
+	params     []string
+	typeParams []string // type parameter names of a generic function
+	doc        string   // first paragraph of the doc comment, on one line
 }
 
 // EnhancedLogger wraps the standard logging with stack trace information
@@ -88,43 +89,11 @@ func getCodeSnippet(filename string, line int, contextLines int) (string, error)
 		return "", nil
 	}
 
-	file, err := os.Open(filename)
+	data, err := readSourceFile(filename)
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	lines := make([]string, 0)
-	currentLine := 0
-
-	for scanner.Scan() {
-		currentLine++
-		lines = append(lines, scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
-		return "", err
-	}
-
-	if line <= 0 || line > len(lines) {
-		return "", fmt.Errorf("line %d out of range", line)
-	}
-
-	start := max(0, line-contextLines-1)
-	end := min(len(lines), line+contextLines)
-
-	snippet := strings.Builder{}
-	for i := start; i < end; i++ {
-		lineNum := i + 1
-		marker := " "
-		if lineNum == line {
-			marker = ">"
-		}
-		snippet.WriteString(fmt.Sprintf("      %s %d %s\n", marker, lineNum, lines[i]))
-	}
-
-	return strings.TrimRight(snippet.String(), "\n"), nil
+	return readSnippet(bytes.NewReader(data), line, contextLines)
 }
 
 // formatFrame formats a single stack frame with optional code snippet
@@ -159,9 +128,50 @@ func (el *EnhancedLogger) formatFrame(frame *Frame, index int) string {
 		parts = append(parts, fmt.Sprintf("     Time: %v", frame.Duration))
 	}
 
+	for _, event := range frameEvents(frame) {
+		line := fmt.Sprintf("     Event: %s +%v", event.Name, event.Time.Sub(frame.StartTime))
+		if len(event.Attrs) > 0 {
+			line += " " + NewDebugVars(event.Attrs).String()
+		}
+		parts = append(parts, line)
+	}
+
+	if frame.Invocation != nil && el.options.ShowMeta {
+		parts = append(parts, fmt.Sprintf("     Invocation: %s (cwd: %s)", strings.Join(frame.Invocation.Argv, " "), frame.Invocation.Cwd))
+	}
+
+	if doc := frameDoc(frame); doc != "" {
+		parts = append(parts, fmt.Sprintf("     Doc: %s", doc))
+	}
+
+	if frame.Allocs > 0 && el.options.ShowMeta {
+		parts = append(parts, fmt.Sprintf("     Allocs: %d (%d B)", frame.Allocs, frame.AllocBytes))
+	}
+
 	return strings.Join(parts, "\n")
 }
 
+// frameDoc returns the doc comment of the frame's function in verbose mode
+// (DebugLevel 2), looking it up from the source when the frame has none yet
+func frameDoc(frame *Frame) string {
+	if frame.Doc == "" {
+		frame.Doc = lookupFrameDoc(frame)
+	}
+	return frame.Doc
+}
+
+// lookupFrameDoc is frameDoc without storing the doc on the frame, for frames
+// that may belong to another goroutine
+func lookupFrameDoc(frame *Frame) string {
+	if frame.Doc != "" || CurrentConfig().DebugLevel < 2 {
+		return frame.Doc
+	}
+	if fnSig := getSignatureForLocation(frame.File, frame.Line, frame.Function); fnSig != nil {
+		return fnSig.doc
+	}
+	return ""
+}
+
 func resolveFrameSignature(frame *Frame) string {
 	if frame == nil {
 		return ""
@@ -215,13 +225,43 @@ func getSignatureForLocation(file string, line int, functionName string) *functi
 }
 
 func parseFileSignatures(file string) *fileSignature {
-	data, err := os.ReadFile(file)
+	data, err := readSourceFile(file)
 	if err != nil {
 		return nil
 	}
 
+	store := currentSignatureStore()
+	var key string
+	if store != nil {
+		key = signatureStoreKey(data)
+		if info := loadStoredSignatures(store, key); info != nil {
+			return info
+		}
+	}
+
+	// go/parser can't be cancelled; give up waiting on pathological input and
+	// let the parse finish in the background
+	done := make(chan *fileSignature, 1)
+	go func() { done <- parseSignatures(file, data) }()
+
+	timer := time.NewTimer(sourceParseTimeout)
+	defer timer.Stop()
+
+	select {
+	case info := <-done:
+		if info != nil && store != nil {
+			saveStoredSignatures(store, key, info)
+		}
+		return info
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseSignatures extracts function signatures and parameter names from Go source
+func parseSignatures(file string, data []byte) *fileSignature {
 	fset := token.NewFileSet()
-	astFile, err := parser.ParseFile(fset, file, data, parser.ParseComments)
+	astFile, err := parser.ParseFile(fset, file, data, parser.SkipObjectResolution|parser.ParseComments)
 	if err != nil {
 		return nil
 	}
@@ -236,15 +276,17 @@ func parseFileSignatures(file string) *fileSignature {
 
 		start := fset.Position(fn.Pos()).Line
 		end := fset.Position(fn.End()).Line
-		signature := formatFuncSignature(fn, fset)
+		signature := sanitizeSourceText(formatFuncSignature(fn, fset))
 		params := extractParamNames(fn)
 
 		info.functions = append(info.functions, functionSignature{
-			name:      fn.Name.Name,
-			startLine: start,
-			endLine:   end,
-			signature: signature,
-			params:    params,
+			name:       fn.Name.Name,
+			startLine:  start,
+			endLine:    end,
+			signature:  signature,
+			params:     params,
+			typeParams: extractTypeParamNames(fn),
+			doc:        sanitizeSourceText(docSummary(fn.Doc)),
 		})
 	}
 
@@ -270,6 +312,29 @@ func extractParamNames(fn *ast.FuncDecl) []string {
 	return names
 }
 
+// docSummary returns the first paragraph of a doc comment on one line
+func docSummary(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	paragraph, _, _ := strings.Cut(doc.Text(), "\n\n")
+	return strings.Join(strings.Fields(paragraph), " ")
+}
+
+func extractTypeParamNames(fn *ast.FuncDecl) []string {
+	if fn.Type.TypeParams == nil {
+		return nil
+	}
+
+	var names []string
+	for _, field := range fn.Type.TypeParams.List {
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
+
 func formatFuncSignature(fn *ast.FuncDecl, fset *token.FileSet) string {
 	if fn == nil {
 		return ""
@@ -364,6 +429,7 @@ func normalizeFrameArgs(frame *Frame, paramNames []string) {
 		normalized[k] = v
 	}
 
+	redactArgs(normalized)
 	frame.Args = normalized
 }
 
@@ -445,11 +511,12 @@ func (el *EnhancedLogger) getStackFrames(ctx context.Context) []*Frame {
 
 	for {
 		rFrame, more := runtimeFrames.Next()
+		file, line := originalPosition(rFrame.File, rFrame.Line)
 
 		frame := &Frame{
 			Function: rFrame.Function,
-			File:     rFrame.File,
-			Line:     rFrame.Line,
+			File:     file,
+			Line:     line,
 			Args:     nil, // No args available from runtime
 		}
 
@@ -487,7 +554,7 @@ func (el *EnhancedLogger) filterFrames(frames []*Frame) []*Frame {
 		appFrames := make([]*Frame, 0)
 
 		for _, frame := range filtered {
-			if strings.Contains(frame.File, el.options.AppPattern) {
+			if el.isAppFrame(frame) {
 				appFrames = append(appFrames, frame)
 			}
 		}
@@ -504,7 +571,7 @@ func (el *EnhancedLogger) filterFrames(frames []*Frame) []*Frame {
 				if appIndex < len(appFrames) {
 					result = append(result, appFrames[appIndex])
 					appIndex++
-				} else if otherIndex < len(filtered) && !strings.Contains(filtered[otherIndex].File, el.options.AppPattern) {
+				} else if otherIndex < len(filtered) && !el.isAppFrame(filtered[otherIndex]) {
 					result = append(result, filtered[otherIndex])
 					otherIndex++
 				}
@@ -546,16 +613,39 @@ func (el *EnhancedLogger) filterFrames(frames []*Frame) []*Frame {
 	return filtered
 }
 
+// isAppFrame reports whether a frame is application code. An explicit AppPattern
+// is matched against the file path; the default "/" (or an empty pattern) defers
+// to the build-info based ClassifyFrame.
+func (el *EnhancedLogger) isAppFrame(frame *Frame) bool {
+	pattern := el.options.AppPattern
+	if pattern == "" || pattern == "/" {
+		return ClassifyFrame(frame) == FrameClassApp
+	}
+	return strings.Contains(frame.File, pattern) || strings.HasPrefix(frame.Function, pattern)
+}
+
 // LogWithStack logs a message with enhanced stack trace information
 func (el *EnhancedLogger) LogWithStack(ctx context.Context, level, message string, args ...interface{}) {
-	if !IsEnabled() {
+	if !ConfigFromContext(ctx).Enabled && len(PackageOverrides()) == 0 {
 		// Fallback to regular logging when devtrace is disabled
 		el.logger.Log(level, message, args...)
 		return
 	}
 
+	if waitingForCondition(ctx, FromContext(ctx)) {
+		el.logger.Log(level, message, args...)
+		return
+	}
+
 	// Get and filter stack frames
 	frames := el.getStackFrames(ctx)
+	site := callSiteFunction(frames, len(FromContext(ctx).Frames) > 0)
+	if !configFor(ctx, site).Enabled {
+		el.logger.Log(level, message, args...)
+		return
+	}
+
+	el = el.scoped(effectiveOverrides(ctx, site))
 	filtered := el.filterFrames(frames)
 
 	// Format the stack trace
@@ -563,6 +653,9 @@ func (el *EnhancedLogger) LogWithStack(ctx context.Context, level, message strin
 	parts = append(parts, el.options.Prefix)
 
 	if route := el.buildRouteLine(filtered); route != "" {
+		if parent := FromContext(ctx).RemoteParent; parent != "" {
+			route = strings.Replace(route, "Route: ", "Route: "+remoteParentMarker+shortFunctionName(parent)+" → ", 1)
+		}
 		parts = append(parts, "  "+route)
 	}
 
@@ -570,24 +663,33 @@ func (el *EnhancedLogger) LogWithStack(ctx context.Context, level, message strin
 		parts = append(parts, el.formatFrame(frame, i))
 	}
 
-	// Remove ShowMeta output (deprecated).
+	if skipped := FromContext(ctx).SkippedFrames(); skipped > 0 {
+		parts = append(parts, fmt.Sprintf("  … %d deeper frames skipped (max depth %d)", skipped, CurrentConfig().MaxDepth))
+	}
 
-	// Separate debug variables from message formatting args
-	debugVars := make([]*DebugVars, 0)
-	messageArgs := make([]interface{}, 0, len(args))
+	if baggage := FromContext(ctx).baggage; len(baggage) > 0 {
+		parts = append(parts, "  Baggage: "+formatBaggage(baggage))
+	}
+
+	// Errors made by Wrap or Errorf show where they were created
 	for _, arg := range args {
-		if dv, ok := arg.(*DebugVars); ok {
-			debugVars = append(debugVars, dv)
-			continue
+		if err, ok := arg.(error); ok {
+			if stack := ErrorStack(err); stack != "" {
+				parts = append(parts, "  Error stack:\n"+stack)
+				break
+			}
 		}
-		messageArgs = append(messageArgs, arg)
 	}
 
-	if len(debugVars) > 0 {
+	// Remove ShowMeta output (deprecated).
+
+	// Separate debug variables from message formatting args
+	fields, messageArgs := DebugFields(args)
+	structured, isStructured := el.logger.(StructuredLogger)
+
+	if len(fields) > 0 && !isStructured {
 		parts = append(parts, "\nVars:")
-		for _, dv := range debugVars {
-			parts = append(parts, dv.String())
-		}
+		parts = append(parts, (&DebugVars{Vars: fields}).String())
 	}
 
 	// Add the actual log message at the end
@@ -599,9 +701,45 @@ func (el *EnhancedLogger) LogWithStack(ctx context.Context, level, message strin
 
 	// Log the complete message
 	completeMessage := strings.Join(parts, "\n")
+	if isStructured {
+		structured.LogFields(level, completeMessage, fields)
+		return
+	}
 	el.logger.Log(level, completeMessage)
 }
 
+// scoped returns a copy of the logger with snippet and limit overrides from ctx applied
+func (el *EnhancedLogger) scoped(overrides *ConfigOverrides) *EnhancedLogger {
+	if overrides == nil || (overrides.ShowSnippet == nil && overrides.StackLimit == nil) {
+		return el
+	}
+
+	scoped := *el
+	if overrides.ShowSnippet != nil {
+		scoped.options.ShowSnippet = *overrides.ShowSnippet
+	}
+	if overrides.StackLimit != nil {
+		scoped.options.Limit = *overrides.StackLimit
+	}
+	return &scoped
+}
+
+// callSiteFunction returns the innermost non-devtrace function of a stack:
+// the last frame of a trace context, or the first frame of a runtime stack
+func callSiteFunction(frames []*Frame, fromContext bool) string {
+	for i := range frames {
+		frame := frames[i]
+		if fromContext {
+			frame = frames[len(frames)-1-i]
+		}
+		pkg := functionPackage(frame.Function)
+		if pkg != selfPackage && pkg != "runtime" && !strings.Contains(frame.Function, "devtrace.") {
+			return frame.Function
+		}
+	}
+	return ""
+}
+
 // Debug logs a debug message with stack trace
 func (el *EnhancedLogger) Debug(ctx context.Context, message string, args ...interface{}) {
 	el.LogWithStack(ctx, "DEBUG", message, args...)
@@ -623,14 +761,22 @@ func (el *EnhancedLogger) Error(ctx context.Context, message string, args ...int
 }
 
 // Global enhanced logger instance
+//
+// Deprecated: use Debug, Info, Warn and Error of github.com/skulidropek/gotrace/v1.
 var GlobalEnhancedLogger = NewEnhancedLogger(nil)
 
-// InstallStackLogger installs the enhanced stack logger globally
-func InstallStackLogger(opts *StackLoggerOptions) {
+// InstallStackLogger installs the enhanced stack logger globally. Invalid
+// options (see StackLoggerOptions.Validate) are rejected and the current
+// logger stays in place.
+func InstallStackLogger(opts *StackLoggerOptions) error {
 	if opts == nil {
 		opts = &DefaultStackLoggerOptions
 	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
 	GlobalEnhancedLogger = NewEnhancedLogger(opts)
+	return nil
 }
 
 // Helper functions for min/max
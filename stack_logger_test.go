@@ -2,6 +2,7 @@ package devtrace
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -158,3 +159,134 @@ func TestStackLoggerCapturesFunctionArgs(t *testing.T) {
 		t.Fatalf("log message missing: %s", entry)
 	}
 }
+
+// traceTestDocumented loads the user from the cache.
+//
+// Details beyond the first paragraph are left out of frames.
+func traceTestDocumented(id int) int {
+	return id
+}
+
+func TestVerboseFramesCarryDocComment(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	var frame *Frame
+	unregister := RegisterHook(Hook{OnExit: func(f *Frame) { frame = f }})
+	t.Cleanup(unregister)
+
+	TraceTyped(traceTestDocumented, nil)(1)
+	if frame == nil || frame.Doc != "" {
+		t.Fatalf("expected no doc outside verbose mode, got %+v", frame)
+	}
+
+	UpdateConfig(func(c *DevTraceConfig) { c.DebugLevel = 2 })
+	TraceTyped(traceTestDocumented, nil)(1)
+	if frame == nil || frame.Doc != "traceTestDocumented loads the user from the cache." {
+		t.Fatalf("expected the first paragraph of the doc comment, got %+v", frame)
+	}
+}
+
+func TestBaggageFollowsTheTraceContext(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	tc := NewTraceContext()
+	ctx := WithTraceContext(context.Background(), tc)
+	tc.SetBaggage("tenant", "acme")
+	tc.SetBaggage("password", "hunter2")
+	tc.SetBaggage("request id", "r-1,2")
+	kept := tc.Baggage()
+	tc.SetBaggage("password", "")
+
+	if len(kept) != 3 || len(tc.Baggage()) != 2 {
+		t.Fatalf("expected Baggage to return a copy, got %v then %v", kept, tc.Baggage())
+	}
+
+	logger := &captureLogger{}
+	EnterContext(ctx, CreateFrame("handle", "", "handle.go", 1, nil))
+	stackLogger := NewEnhancedLogger(&StackLoggerOptions{})
+	stackLogger.SetLogger(logger)
+	stackLogger.LogWithStack(ctx, "INFO", "served")
+	LeaveContext(ctx)
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "Baggage: request id=r-1,2, tenant=acme") {
+		t.Fatalf("expected the baggage in the log output, got %q", logger.messages)
+	}
+
+	remote := FromContext(ExtractTraceHeaders(context.Background(), InjectTraceHeaders(ctx)))
+	if got := remote.Baggage(); got["tenant"] != "acme" || got["request id"] != "r-1,2" {
+		t.Fatalf("expected the baggage to survive propagation, got %v", got)
+	}
+}
+
+func TestLogErrorsLogsStackOfFailingCalls(t *testing.T) {
+	originalConfig := CurrentConfig()
+	originalEnhanced := GlobalEnhancedLogger
+	t.Cleanup(func() {
+		SetConfig(originalConfig)
+		GlobalEnhancedLogger = originalEnhanced
+	})
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled, c.LogErrors = true, true })
+
+	logger := &captureLogger{}
+	GlobalEnhancedLogger = NewEnhancedLogger(&StackLoggerOptions{Limit: 5})
+	GlobalEnhancedLogger.SetLogger(logger)
+
+	load := func(id int) (n int, err error) {
+		GlobalEnter(CreateFrame("pkg.load", "", "load.go", 1, map[string]interface{}{"id": id}))
+		defer func() { GlobalLeaveResults(n, err) }()
+		if id < 0 {
+			return 0, errors.New("negative id")
+		}
+		return id, nil
+	}
+	load(1)
+	if len(logger.messages) != 0 {
+		t.Fatalf("expected no log for a successful call, got %q", logger.messages)
+	}
+	load(-1)
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "pkg.load returned error: negative id") ||
+		!strings.Contains(logger.messages[0], `"id": -1`) {
+		t.Fatalf("expected the error logged with the stack and args, got %q", logger.messages)
+	}
+
+	quiet := NewTracedFunc(func() error { return errors.New("expected") }, &TraceOptions{LogErrors: Override(false)})
+	if result := quiet.Call(context.Background()); result.Error == nil || result.Error.Error() != "expected" {
+		t.Fatalf("expected the returned error in the result, got %v", result.Error)
+	}
+	if len(logger.messages) != 1 {
+		t.Fatalf("expected TraceOptions.LogErrors to silence the log, got %q", logger.messages)
+	}
+}
+
+func TestFrameErrorSurvivesPanic(t *testing.T) {
+	original := CurrentConfig()
+	t.Cleanup(func() { SetConfig(original) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	var exited *Frame
+	t.Cleanup(RegisterHook(Hook{OnExit: func(frame *Frame) {
+		if frame.Function == "explode" {
+			exited = frame
+		}
+	}}))
+	explode := NewTracedFunc(func() (int, error) { panic("boom") }, &TraceOptions{Label: "explode"})
+	func() {
+		defer func() { recover() }()
+		explode.Call(context.Background())
+	}()
+	if exited == nil || exited.Panic != "boom" || exited.Error == nil || exited.Error.Error() != "panic: boom" {
+		t.Fatalf("expected the panic kept as the frame's error, got %+v", exited)
+	}
+
+	// Instrumented functions leave with the zero results after RecordPanic
+	frame := CreateFrame("write", "", "write.go", 1, nil)
+	frame.Error = errors.New("short write")
+	frame.Panic = "boom"
+	frame.setResults([]interface{}{0, nil})
+	if frame.Error == nil || frame.Error.Error() != "short write" || len(frame.Results) != 2 {
+		t.Fatalf("expected the zero results not to clear the error, got %v with %v", frame.Error, frame.Results)
+	}
+}
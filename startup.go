@@ -0,0 +1,194 @@
+package devtrace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StartupSpanKind groups startup spans in the waterfall
+type StartupSpanKind string
+
+// Kinds of startup spans
+const (
+	StartupInit      StartupSpanKind = "init"  // a package init function
+	StartupPhase     StartupSpanKind = "phase" // an application phase such as loading config
+	StartupMilestone StartupSpanKind = "mark"  // a point in time such as ready or first request
+)
+
+// StartupSpan is one entry of the startup waterfall. Offset is measured from
+// ProcessStart.
+type StartupSpan struct {
+	Name     string          `json:"name"`
+	Kind     StartupSpanKind `json:"kind"`
+	Offset   time.Duration   `json:"offset"`
+	Duration time.Duration   `json:"duration"`
+}
+
+// StartupReport is the recorded startup of the process
+type StartupReport struct {
+	Start           time.Time     `json:"start"`
+	Spans           []StartupSpan `json:"spans"`
+	Ready           time.Duration `json:"ready,omitempty"`             // offset of MarkReady
+	FirstRequest    time.Duration `json:"first_request,omitempty"`     // offset of MarkFirstRequest
+	FirstRequestFor string        `json:"first_request_for,omitempty"` // what the first request was
+}
+
+// ProcessStart approximates when the process started: the moment the devtrace
+// package was initialized, which is before every package that imports it
+var ProcessStart = time.Now()
+
+// StartupTracer records the startup waterfall of the process. The zero value is
+// ready to use; the package-level functions use DefaultStartupTracer.
+type StartupTracer struct {
+	mu           sync.Mutex
+	spans        []StartupSpan
+	ready        time.Duration
+	firstRequest time.Duration
+	firstFor     string
+}
+
+// DefaultStartupTracer is used by TraceStartupPhase, TraceInit, MarkReady and MarkFirstRequest
+var DefaultStartupTracer = &StartupTracer{}
+
+// Phase starts a startup phase; call the returned function when it ends
+func (st *StartupTracer) Phase(name string) func() {
+	return st.begin(name, StartupPhase)
+}
+
+// Init times a package init function; instrumented init functions start with
+//
+//	defer devtrace.TraceInit("pkg")()
+func (st *StartupTracer) Init(pkg string) func() {
+	return st.begin(pkg, StartupInit)
+}
+
+func (st *StartupTracer) begin(name string, kind StartupSpanKind) func() {
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			st.add(StartupSpan{Name: name, Kind: kind, Offset: start.Sub(ProcessStart), Duration: time.Since(start)})
+		})
+	}
+}
+
+func (st *StartupTracer) add(span StartupSpan) {
+	st.mu.Lock()
+	st.spans = append(st.spans, span)
+	st.mu.Unlock()
+}
+
+// MarkReady records that the service finished starting up, e.g. right before it
+// starts serving. Only the first call counts.
+func (st *StartupTracer) MarkReady() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.ready == 0 {
+		st.ready = time.Since(ProcessStart)
+		st.spans = append(st.spans, StartupSpan{Name: "ready", Kind: StartupMilestone, Offset: st.ready})
+	}
+}
+
+// MarkFirstRequest records time-to-first-request; what names the request (a
+// route, an RPC method). Only the first call counts.
+func (st *StartupTracer) MarkFirstRequest(what string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.firstRequest == 0 {
+		st.firstRequest = time.Since(ProcessStart)
+		st.firstFor = what
+		st.spans = append(st.spans, StartupSpan{Name: "first request " + what, Kind: StartupMilestone, Offset: st.firstRequest})
+	}
+}
+
+// Report returns the spans recorded so far ordered by start
+func (st *StartupTracer) Report() StartupReport {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	spans := append([]StartupSpan(nil), st.spans...)
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Offset < spans[j].Offset })
+	return StartupReport{
+		Start:           ProcessStart,
+		Spans:           spans,
+		Ready:           st.ready,
+		FirstRequest:    st.firstRequest,
+		FirstRequestFor: st.firstFor,
+	}
+}
+
+// Waterfall renders the startup report as a text waterfall
+func (st *StartupTracer) Waterfall() string {
+	return st.Report().Waterfall()
+}
+
+// Waterfall renders the report as text, one bar per span scaled to the total
+func (r StartupReport) Waterfall() string {
+	const width = 40
+
+	var total time.Duration
+	nameWidth := 0
+	for _, span := range r.Spans {
+		if end := span.Offset + span.Duration; end > total {
+			total = end
+		}
+		if len(span.Name) > nameWidth {
+			nameWidth = len(span.Name)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🚀 startup: %d span(s) over %v", len(r.Spans), total.Round(time.Microsecond))
+	if r.Ready > 0 {
+		fmt.Fprintf(&b, ", ready at %v", r.Ready.Round(time.Microsecond))
+	}
+	if r.FirstRequest > 0 {
+		fmt.Fprintf(&b, ", first request at %v", r.FirstRequest.Round(time.Microsecond))
+	}
+	if total <= 0 {
+		return b.String()
+	}
+
+	for _, span := range r.Spans {
+		from := int(int64(width) * int64(span.Offset) / int64(total))
+		length := int(int64(width) * int64(span.Duration) / int64(total))
+		bar := "█"
+		if span.Kind == StartupMilestone {
+			bar = "◆"
+		} else if length > 1 {
+			bar = strings.Repeat("█", length)
+		}
+		if from+len([]rune(bar)) > width {
+			from = width - len([]rune(bar))
+		}
+		fmt.Fprintf(&b, "\n  %-*s %-5s |%s%s%s| +%v %v", nameWidth, span.Name, span.Kind,
+			strings.Repeat(" ", from), bar, strings.Repeat(" ", width-from-len([]rune(bar))),
+			span.Offset.Round(time.Microsecond), span.Duration.Round(time.Microsecond))
+	}
+	return b.String()
+}
+
+// TraceStartupPhase starts a phase on DefaultStartupTracer:
+//
+//	defer devtrace.TraceStartupPhase("load config")()
+func TraceStartupPhase(name string) func() {
+	return DefaultStartupTracer.Phase(name)
+}
+
+// TraceInit times a package init function on DefaultStartupTracer
+func TraceInit(pkg string) func() {
+	return DefaultStartupTracer.Init(pkg)
+}
+
+// MarkReady records on DefaultStartupTracer that startup finished
+func MarkReady() {
+	DefaultStartupTracer.MarkReady()
+}
+
+// MarkFirstRequest records time-to-first-request on DefaultStartupTracer
+func MarkFirstRequest(what string) {
+	DefaultStartupTracer.MarkFirstRequest(what)
+}
@@ -0,0 +1,20 @@
+//go:build !devtrace_minimal
+
+package devtrace
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// StartupMiddleware records time-to-first-request on DefaultStartupTracer when
+// next serves its first request
+func StartupMiddleware(next http.Handler) http.Handler {
+	var seen atomic.Bool
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !seen.Load() && seen.CompareAndSwap(false, true) {
+			MarkFirstRequest(r.Method + " " + r.URL.Path)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
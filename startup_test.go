@@ -0,0 +1,40 @@
+package devtrace
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartupTracerWaterfall(t *testing.T) {
+	st := &StartupTracer{}
+
+	endInit := st.Init("db")
+	time.Sleep(2 * time.Millisecond)
+	endInit()
+	endInit() // a second call must not add a span
+
+	endPhase := st.Phase("load config")
+	endPhase()
+	st.MarkReady()
+	st.MarkFirstRequest("GET /health")
+	st.MarkFirstRequest("GET /other")
+
+	report := st.Report()
+	if len(report.Spans) != 4 {
+		t.Fatalf("expected init, phase and two milestones, got %+v", report.Spans)
+	}
+	if report.Spans[0].Name != "db" || report.Spans[0].Kind != StartupInit || report.Spans[0].Duration < 2*time.Millisecond {
+		t.Fatalf("unexpected init span: %+v", report.Spans[0])
+	}
+	if report.FirstRequestFor != "GET /health" || report.FirstRequest < report.Ready {
+		t.Fatalf("only the first request should count: %+v", report)
+	}
+
+	waterfall := report.Waterfall()
+	for _, want := range []string{"startup: 4 span(s)", "db", "load config", "first request GET /health", "◆"} {
+		if !strings.Contains(waterfall, want) {
+			t.Fatalf("expected %q in waterfall:\n%s", want, waterfall)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package devtrace
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// FunctionStats aggregates completed calls of a single traced function
+type FunctionStats struct {
+	Function string        `json:"function"`
+	Calls    int64         `json:"calls"`
+	Errors   int64         `json:"errors"`
+	Total    time.Duration `json:"total"`
+	Min      time.Duration `json:"min"`
+	Max      time.Duration `json:"max"`
+}
+
+// Average returns the mean call duration
+func (s FunctionStats) Average() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Calls)
+}
+
+// OperationStats aggregates the calls of one operation (see SetOperation). The
+// totals count the frames that started the operation; Functions breaks the
+// time down by the functions called while serving it.
+type OperationStats struct {
+	Operation string          `json:"operation"`
+	Calls     int64           `json:"calls"`
+	Errors    int64           `json:"errors"`
+	Total     time.Duration   `json:"total"`
+	Min       time.Duration   `json:"min"`
+	Max       time.Duration   `json:"max"`
+	Functions []FunctionStats `json:"functions"`
+}
+
+// Average returns the mean operation duration
+func (s OperationStats) Average() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Calls)
+}
+
+// operationAggregate is the running form of OperationStats
+type operationAggregate struct {
+	stats     FunctionStats // Function holds the operation name
+	functions map[string]*FunctionStats
+}
+
+var (
+	functionStatsMu sync.Mutex
+	functionStats   = make(map[string]*FunctionStats)
+	operationStats  = make(map[string]*operationAggregate)
+	eventStats      = make(map[string]*EventStats)
+)
+
+// addCall counts one completed frame into stats
+func (stats *FunctionStats) addCall(frame *Frame) {
+	if stats.Calls == 0 || frame.Duration < stats.Min {
+		stats.Min = frame.Duration
+	}
+	stats.Calls++
+	stats.Total += frame.Duration
+	if frame.Duration > stats.Max {
+		stats.Max = frame.Duration
+	}
+	if FrameError(frame) != nil {
+		stats.Errors++
+	}
+}
+
+func recordFunctionStats(frame *Frame) {
+	if frame == nil || frame.Function == "" {
+		return
+	}
+
+	functionStatsMu.Lock()
+	defer functionStatsMu.Unlock()
+
+	stats, ok := functionStats[frame.Function]
+	if !ok {
+		stats = &FunctionStats{Function: frame.Function}
+		functionStats[frame.Function] = stats
+	}
+	stats.addCall(frame)
+}
+
+// recordOperationStats counts a frame that just left tc under its operation.
+// The frame is the operation's root when its parent serves another operation.
+func recordOperationStats(tc *TraceContext, frame *Frame) {
+	if frame == nil || frame.Operation == "" || frame.Function == "" {
+		return
+	}
+	root := tc.current() == nil || tc.current().Operation != frame.Operation
+
+	functionStatsMu.Lock()
+	defer functionStatsMu.Unlock()
+
+	op, ok := operationStats[frame.Operation]
+	if !ok {
+		op = &operationAggregate{
+			stats:     FunctionStats{Function: frame.Operation},
+			functions: make(map[string]*FunctionStats),
+		}
+		operationStats[frame.Operation] = op
+	}
+	if root {
+		op.stats.addCall(frame)
+	}
+	stats, ok := op.functions[frame.Function]
+	if !ok {
+		stats = &FunctionStats{Function: frame.Function}
+		op.functions[frame.Function] = stats
+	}
+	stats.addCall(frame)
+}
+
+// AllFunctionStats returns per-function statistics for every completed frame,
+// sorted by total time spent, highest first
+func AllFunctionStats() []FunctionStats {
+	functionStatsMu.Lock()
+	all := make([]FunctionStats, 0, len(functionStats))
+	for _, stats := range functionStats {
+		all = append(all, *stats)
+	}
+	functionStatsMu.Unlock()
+
+	sortFunctionStats(all)
+	return all
+}
+
+// AllOperationStats returns statistics for every operation seen in completed
+// frames, sorted by total time spent, highest first
+func AllOperationStats() []OperationStats {
+	functionStatsMu.Lock()
+	all := make([]OperationStats, 0, len(operationStats))
+	for name, op := range operationStats {
+		stats := OperationStats{
+			Operation: name,
+			Calls:     op.stats.Calls,
+			Errors:    op.stats.Errors,
+			Total:     op.stats.Total,
+			Min:       op.stats.Min,
+			Max:       op.stats.Max,
+			Functions: make([]FunctionStats, 0, len(op.functions)),
+		}
+		for _, fn := range op.functions {
+			stats.Functions = append(stats.Functions, *fn)
+		}
+		all = append(all, stats)
+	}
+	functionStatsMu.Unlock()
+
+	for _, stats := range all {
+		sortFunctionStats(stats.Functions)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Total == all[j].Total {
+			return all[i].Operation < all[j].Operation
+		}
+		return all[i].Total > all[j].Total
+	})
+	return all
+}
+
+func sortFunctionStats(all []FunctionStats) {
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Total == all[j].Total {
+			return all[i].Function < all[j].Function
+		}
+		return all[i].Total > all[j].Total
+	})
+}
+
+// ResetFunctionStats clears the per-function and per-operation statistics and
+// the event counts
+func ResetFunctionStats() {
+	functionStatsMu.Lock()
+	defer functionStatsMu.Unlock()
+
+	functionStats = make(map[string]*FunctionStats)
+	operationStats = make(map[string]*operationAggregate)
+	eventStats = make(map[string]*EventStats)
+}
@@ -0,0 +1,113 @@
+package devtrace
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+)
+
+// TracedStruct holds traced wrappers for every exported method of a value
+type TracedStruct struct {
+	Name    string
+	Target  reflect.Value
+	Methods map[string]interface{}
+}
+
+// TraceStruct wraps every exported method of svc with tracing.
+// Each wrapper keeps the method's signature and is labelled "Type.Method"
+// (or "Label.Method" when options.Label is set).
+// Like NewTracedFunc given a non-function, it panics when svc is nil. A typed
+// nil pointer is wrapped like any other value; its methods get the nil receiver.
+func TraceStruct(svc interface{}, options *TraceOptions) *TracedStruct {
+	if options == nil {
+		opts := DefaultTraceOptions
+		options = &opts
+	}
+
+	target := reflect.ValueOf(svc)
+	if !target.IsValid() {
+		panic("TraceStruct: argument must not be nil")
+	}
+
+	targetType := target.Type()
+	typeName := options.Label
+	if typeName == "" {
+		typeName = structTypeName(targetType)
+	}
+
+	traced := &TracedStruct{
+		Name:    typeName,
+		Target:  target,
+		Methods: make(map[string]interface{}, targetType.NumMethod()),
+	}
+
+	for i := 0; i < targetType.NumMethod(); i++ {
+		method := targetType.Method(i)
+		if !method.IsExported() {
+			continue
+		}
+
+		methodOpts := *options
+		methodOpts.Label = typeName + "." + method.Name
+
+		tracedFunc := NewTracedFunc(target.Method(i).Interface(), &methodOpts)
+		resolveMethodSource(tracedFunc, method)
+
+		traced.Methods[method.Name] = makeTracedFunc(tracedFunc)
+	}
+
+	return traced
+}
+
+// Method returns the traced wrapper for the named method, or nil if it does not exist
+func (ts *TracedStruct) Method(name string) interface{} {
+	if ts == nil {
+		return nil
+	}
+	return ts.Methods[name]
+}
+
+// MethodNames returns the names of all traced methods in sorted order
+func (ts *TracedStruct) MethodNames() []string {
+	if ts == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(ts.Methods))
+	for name := range ts.Methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// structTypeName returns the bare type name of a (possibly pointer) type
+func structTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return t.String()
+}
+
+// resolveMethodSource points a bound method wrapper at the method's declaration,
+// since the bound value itself resolves to a reflect trampoline.
+func resolveMethodSource(tf *TracedFunc, method reflect.Method) {
+	pc := method.Func.Pointer()
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return
+	}
+
+	file, line := originalPosition(fn.FileLine(pc))
+	tf.funcName = fn.Name()
+	tf.SourceFile = file
+	tf.SourceLine = line
+	tf.ParamNames = nil
+	if fnSig := getSignatureForLocation(file, line, method.Name); fnSig != nil {
+		tf.Signature = fnSig.signature
+		tf.ParamNames = append(tf.ParamNames, fnSig.params...)
+	}
+}
@@ -0,0 +1,78 @@
+package devtrace
+
+import (
+	"context"
+	"testing"
+)
+
+type testUserService struct {
+	prefix string
+}
+
+func (s *testUserService) Greet(ctx context.Context, name string) string {
+	return s.prefix + name
+}
+
+func (s *testUserService) Count(items ...string) int {
+	return len(items)
+}
+
+func (s *testUserService) hidden() {}
+
+func TestTraceStructWrapsExportedMethods(t *testing.T) {
+	originalConfig := Config
+	t.Cleanup(func() { SetConfig(originalConfig) })
+	cfg := Config
+	cfg.Enabled = true
+	cfg.ShowTiming = false
+	SetConfig(cfg)
+
+	traced := TraceStruct(&testUserService{prefix: "hi "}, nil)
+
+	if traced.Name != "testUserService" {
+		t.Fatalf("unexpected struct name: %s", traced.Name)
+	}
+
+	names := traced.MethodNames()
+	if len(names) != 2 || names[0] != "Count" || names[1] != "Greet" {
+		t.Fatalf("unexpected methods: %v", names)
+	}
+
+	greet, ok := traced.Method("Greet").(func(context.Context, string) string)
+	if !ok {
+		t.Fatalf("Greet wrapper has wrong type: %T", traced.Method("Greet"))
+	}
+	if got := greet(context.Background(), "bob"); got != "hi bob" {
+		t.Fatalf("unexpected greeting: %q", got)
+	}
+
+	if traced.Method("hidden") != nil {
+		t.Fatalf("unexported method should not be traced")
+	}
+}
+
+func TestTraceStructNil(t *testing.T) {
+	originalConfig := CurrentConfig()
+	t.Cleanup(func() { SetConfig(originalConfig) })
+	UpdateConfig(func(c *DevTraceConfig) { c.Enabled = true })
+
+	func() {
+		defer func() {
+			if r := recover(); r != "TraceStruct: argument must not be nil" {
+				t.Fatalf("expected TraceStruct(nil) to panic, got %v", r)
+			}
+		}()
+		TraceStruct(nil, nil)
+	}()
+
+	// A typed nil pointer has a method set, and methods that don't use the
+	// receiver run as they would untraced
+	traced := TraceStruct((*testUserService)(nil), nil)
+	if names := traced.MethodNames(); len(names) != 2 {
+		t.Fatalf("expected the methods of *testUserService, got %v", names)
+	}
+	count := traced.Method("Count").(func(...string) int)
+	if got := count("a", "b"); got != 2 {
+		t.Fatalf("unexpected count: %d", got)
+	}
+}
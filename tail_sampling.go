@@ -0,0 +1,157 @@
+package devtrace
+
+import (
+	"sync"
+	"time"
+)
+
+// TailSampling holds the frames of each trace in memory until its root frame
+// completes and only then decides whether the recorder keeps the trace.
+// A trace is kept when any of its frames returned an error, when the root frame
+// took at least LatencyThreshold, or when Keep returns true; otherwise it is dropped.
+type TailSampling struct {
+	LatencyThreshold time.Duration              // keep traces whose root took at least this long; 0 disables the check
+	Keep             func([]RecordedFrame) bool // optional rule, called with the trace's frames in completion order
+	MaxFrames        int                        // frames buffered per trace; 0 means DefaultTailSamplingMaxFrames
+	MaxPending       int                        // traces buffered at once; 0 means DefaultTailSamplingMaxPending
+}
+
+// TailSamplingStats counts the decisions taken by the tail sampler
+type TailSamplingStats struct {
+	Kept    int64
+	Dropped int64
+	Pending int
+}
+
+// Defaults for the TailSampling buffer limits
+var (
+	DefaultTailSamplingMaxFrames  = 10000
+	DefaultTailSamplingMaxPending = 1000
+)
+
+// pendingTrace is a trace waiting for its root frame
+type pendingTrace struct {
+	frames  []RecordedFrame
+	errored bool
+}
+
+type tailSampler struct {
+	mu      sync.Mutex
+	policy  *TailSampling
+	pending map[*TraceContext]*pendingTrace
+	kept    int64
+	dropped int64
+}
+
+var tail tailSampler
+
+// SetTailSampling installs a tail sampling policy for the recorder; nil turns it
+// off so every completed frame is recorded immediately again. Traces buffered
+// under the previous policy are discarded.
+func SetTailSampling(policy *TailSampling) {
+	tail.mu.Lock()
+	defer tail.mu.Unlock()
+
+	if policy != nil {
+		copied := *policy
+		policy = &copied
+	}
+	tail.policy = policy
+	tail.pending = make(map[*TraceContext]*pendingTrace)
+	tail.kept = 0
+	tail.dropped = 0
+}
+
+// CurrentTailSamplingStats reports how many traces the tail sampler kept, dropped
+// and is still waiting on
+func CurrentTailSamplingStats() TailSamplingStats {
+	tail.mu.Lock()
+	defer tail.mu.Unlock()
+
+	return TailSamplingStats{Kept: tail.kept, Dropped: tail.dropped, Pending: len(tail.pending)}
+}
+
+// tailSample buffers entry and returns the frames to record now: nil while the
+// trace is still running or when it was dropped, the whole trace once it is kept.
+// ok is false when no policy is installed and entry should be recorded directly.
+func tailSample(tc *TraceContext, entry RecordedFrame) (frames []RecordedFrame, ok bool) {
+	policy, trace := tail.buffer(tc, entry)
+	if policy == nil {
+		return nil, false
+	}
+	if trace == nil {
+		return nil, true
+	}
+
+	// The policy runs unlocked so a Keep rule may itself be traced
+	keep := policy.keeps(trace, entry.Frame)
+
+	tail.mu.Lock()
+	defer tail.mu.Unlock()
+	if tail.policy != policy {
+		// SetTailSampling was called meanwhile; the trace belongs to the old policy
+		return nil, true
+	}
+	if !keep {
+		tail.dropped++
+		return nil, true
+	}
+	tail.kept++
+	return trace.frames, true
+}
+
+// buffer adds entry to its pending trace and returns the trace once entry completes
+// it. The policy is nil when tail sampling is off.
+func (s *tailSampler) buffer(tc *TraceContext, entry RecordedFrame) (*TailSampling, *pendingTrace) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy := s.policy
+	if policy == nil {
+		return nil, nil
+	}
+
+	trace := s.pending[tc]
+	if trace == nil {
+		maxPending := policy.MaxPending
+		if maxPending <= 0 {
+			maxPending = DefaultTailSamplingMaxPending
+		}
+		if len(s.pending) >= maxPending {
+			// Out of room: the trace is dropped without being looked at
+			if entry.IsRoot() {
+				s.dropped++
+			}
+			return policy, nil
+		}
+		trace = &pendingTrace{}
+		s.pending[tc] = trace
+	}
+
+	maxFrames := policy.MaxFrames
+	if maxFrames <= 0 {
+		maxFrames = DefaultTailSamplingMaxFrames
+	}
+	if len(trace.frames) < maxFrames || entry.IsRoot() {
+		trace.frames = append(trace.frames, entry)
+	}
+	if FrameError(entry.Frame) != nil {
+		trace.errored = true
+	}
+
+	if !entry.IsRoot() {
+		return policy, nil
+	}
+	delete(s.pending, tc)
+	return policy, trace
+}
+
+func (p *TailSampling) keeps(trace *pendingTrace, root *Frame) bool {
+	if trace.errored {
+		return true
+	}
+	if p.LatencyThreshold > 0 && root.Duration >= p.LatencyThreshold {
+		return true
+	}
+	return p.Keep != nil && p.Keep(trace.frames)
+}
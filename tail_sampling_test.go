@@ -0,0 +1,62 @@
+package devtrace
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTailSamplingKeepsInterestingTraces(t *testing.T) {
+	originalConfig := Config
+	t.Cleanup(func() {
+		SetConfig(originalConfig)
+		SetTailSampling(nil)
+		DisableRecorder()
+	})
+	cfg := Config
+	cfg.Enabled = true
+	cfg.ShowTiming = false
+	SetConfig(cfg)
+	EnableRecorder(100)
+	SetTailSampling(&TailSampling{
+		LatencyThreshold: 20 * time.Millisecond,
+		Keep: func(frames []RecordedFrame) bool {
+			return frames[len(frames)-1].Frame.Args["arg1"] == "vip"
+		},
+	})
+
+	inner := TraceFunc(func(ctx context.Context, fail bool) error {
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}, "inner").(func(context.Context, bool) error)
+
+	handle := TraceFunc(func(ctx context.Context, kind string) {
+		if kind == "slow" {
+			time.Sleep(25 * time.Millisecond)
+		}
+		inner(ctx, kind == "error")
+	}, "handle").(func(context.Context, string))
+
+	for _, kind := range []string{"ok", "error", "ok", "slow", "vip", "ok"} {
+		handle(WithTraceContext(context.Background(), NewTraceContext()), kind)
+	}
+
+	roots := RecentTraces(TraceFilter{OnlyRoots: true})
+	if len(roots) != 3 {
+		t.Fatalf("expected the error, slow and vip traces, got %d", len(roots))
+	}
+	if all := RecentTraces(TraceFilter{}); len(all) != 6 {
+		t.Fatalf("kept traces should be recorded whole, got %d frames", len(all))
+	}
+	if errs := RecentTraces(TraceFilter{OnlyErrors: true}); len(errs) != 1 || errs[0].Frame.Function != "inner" {
+		t.Fatalf("error in a child frame should keep the trace: %+v", errs)
+	}
+
+	stats := CurrentTailSamplingStats()
+	if stats.Kept != 3 || stats.Dropped != 3 || stats.Pending != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
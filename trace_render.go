@@ -0,0 +1,227 @@
+package devtrace
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TraceFormat selects what RenderTrace draws
+type TraceFormat string
+
+const (
+	// TraceMermaid is a Mermaid sequence diagram: one participant per function,
+	// a call and a return message per frame
+	TraceMermaid TraceFormat = "mermaid"
+	// TraceDOT is a Graphviz call graph: one node per function, one edge per
+	// caller and callee, with call counts and total durations
+	TraceDOT TraceFormat = "dot"
+)
+
+// RenderTrace draws the call tree of a session, nested as ExportMarkdown nests
+// it, in format. Paste Mermaid output into a ```mermaid block, or pipe DOT
+// output to dot -Tsvg.
+func RenderTrace(session *Session, format TraceFormat, w io.Writer) error {
+	var frames []FrameSnapshot
+	if session != nil {
+		frames = session.Frames
+	}
+	roots := buildCallTree(frames)
+
+	var b strings.Builder
+	switch format {
+	case TraceMermaid:
+		writeMermaid(&b, roots)
+	case TraceDOT:
+		writeDOT(&b, roots)
+	default:
+		return fmt.Errorf("unknown trace format %q (want %q or %q)", format, TraceMermaid, TraceDOT)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidCaller is the participant that calls root frames without an operation
+const mermaidCaller = "caller"
+
+func writeMermaid(b *strings.Builder, roots []*callNode) {
+	b.WriteString("sequenceDiagram\n")
+
+	ids := make(map[string]string)
+	var declare func(node *callNode)
+	declare = func(node *callNode) {
+		if _, ok := ids[node.frame.Function]; !ok {
+			id := fmt.Sprintf("f%d", len(ids)+1)
+			ids[node.frame.Function] = id
+			fmt.Fprintf(b, "    participant %s as %s\n", id, mermaidText(node.frame.Function))
+		}
+		for _, child := range node.children {
+			declare(child)
+		}
+	}
+	callers := make(map[string]string)
+	for _, root := range roots {
+		name := root.frame.Operation
+		if name == "" {
+			name = mermaidCaller
+		}
+		if _, ok := callers[name]; !ok {
+			id := fmt.Sprintf("c%d", len(callers)+1)
+			callers[name] = id
+			fmt.Fprintf(b, "    actor %s as %s\n", id, mermaidText(name))
+		}
+	}
+	for _, root := range roots {
+		declare(root)
+	}
+
+	var call func(from string, node *callNode)
+	call = func(from string, node *callNode) {
+		frame := node.frame
+		to := ids[frame.Function]
+		message := frame.Function
+		if args := mermaidArgs(frame.Args); args != "" {
+			message += " " + args
+		}
+		fmt.Fprintf(b, "    %s->>%s: %s\n", from, to, mermaidText(message))
+		for _, child := range node.children {
+			call(to, child)
+		}
+
+		switch {
+		case frame.Unfinished:
+			fmt.Fprintf(b, "    Note over %s: unfinished after %s\n", to, frame.Duration.Round(time.Microsecond))
+		case frame.Error != "":
+			fmt.Fprintf(b, "    %s--x%s: %s error: %s\n", to, from, frame.Duration.Round(time.Microsecond), mermaidText(markdownInline(frame.Error, markdownMaxArgLength)))
+		default:
+			fmt.Fprintf(b, "    %s-->>%s: %s\n", to, from, frame.Duration.Round(time.Microsecond))
+		}
+	}
+	for _, root := range roots {
+		name := root.frame.Operation
+		if name == "" {
+			name = mermaidCaller
+		}
+		call(callers[name], root)
+	}
+}
+
+// mermaidArgs renders the first few args of a frame in parentheses
+func mermaidArgs(args map[string]string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, markdownMaxArgs+1)
+	for i, name := range names {
+		if i == markdownMaxArgs {
+			parts = append(parts, fmt.Sprintf("+%d more", len(names)-markdownMaxArgs))
+			break
+		}
+		parts = append(parts, name+"="+markdownInline(args[name], markdownMaxArgLength))
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// mermaidText keeps a message on one line; ; and # would end or start an
+// entity in Mermaid, so they are written as entities
+func mermaidText(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.ReplaceAll(s, "#", "#35;")
+	return strings.ReplaceAll(s, ";", "#59;")
+}
+
+// dotNode totals the frames of one function in a call graph
+type dotNode struct {
+	id       string
+	calls    int
+	errors   int
+	duration time.Duration
+}
+
+// dotEdge totals the calls from one function to another
+type dotEdge struct {
+	from, to string
+	calls    int
+	duration time.Duration
+}
+
+func writeDOT(b *strings.Builder, roots []*callNode) {
+	nodes := make(map[string]*dotNode)
+	var order []string
+	edges := make(map[[2]string]*dotEdge)
+	var edgeOrder [][2]string
+
+	var visit func(parent string, node *callNode)
+	visit = func(parent string, node *callNode) {
+		frame := node.frame
+		n, ok := nodes[frame.Function]
+		if !ok {
+			n = &dotNode{id: fmt.Sprintf("n%d", len(nodes)+1)}
+			nodes[frame.Function] = n
+			order = append(order, frame.Function)
+		}
+		n.calls++
+		n.duration += frame.Duration
+		if frame.Error != "" {
+			n.errors++
+		}
+
+		if parent != "" {
+			key := [2]string{parent, frame.Function}
+			e, ok := edges[key]
+			if !ok {
+				e = &dotEdge{from: parent, to: frame.Function}
+				edges[key] = e
+				edgeOrder = append(edgeOrder, key)
+			}
+			e.calls++
+			e.duration += frame.Duration
+		}
+		for _, child := range node.children {
+			visit(frame.Function, child)
+		}
+	}
+	for _, root := range roots {
+		visit("", root)
+	}
+
+	b.WriteString("digraph trace {\n")
+	b.WriteString("    node [shape=box, fontname=\"monospace\"];\n")
+	for _, function := range order {
+		n := nodes[function]
+		label := fmt.Sprintf("%s\\n%s · %s", dotEscape(function), callCount(n.calls), n.duration.Round(time.Microsecond))
+		attrs := ""
+		if n.errors > 0 {
+			label += fmt.Sprintf("\\n%d failed", n.errors)
+			attrs = ", color=red"
+		}
+		fmt.Fprintf(b, "    %s [label=\"%s\"%s];\n", n.id, label, attrs)
+	}
+	for _, key := range edgeOrder {
+		e := edges[key]
+		fmt.Fprintf(b, "    %s -> %s [label=\"%s · %s\"];\n", nodes[e.from].id, nodes[e.to].id, callCount(e.calls), e.duration.Round(time.Microsecond))
+	}
+	b.WriteString("}\n")
+}
+
+func callCount(n int) string {
+	if n == 1 {
+		return "1 call"
+	}
+	return fmt.Sprintf("%d calls", n)
+}
+
+// dotEscape makes s safe inside a quoted DOT string
+func dotEscape(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
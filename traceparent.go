@@ -0,0 +1,95 @@
+package devtrace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TraceparentHeader is the W3C Trace Context header naming the trace and the
+// span a request was sent from, as other tracing systems propagate them
+const TraceparentHeader = "traceparent"
+
+// newSpanID returns a random 64-bit hex identifier
+func newSpanID() string {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// EnsureSpanID returns the span ID of the frame, generating one on first use
+func (f *Frame) EnsureSpanID() string {
+	if f == nil {
+		return ""
+	}
+	if f.SpanID == "" {
+		f.SpanID = newSpanID()
+	}
+	return f.SpanID
+}
+
+// Traceparent returns the traceparent header value continuing the trace of ctx
+// from its current frame, or from the remote parent while no frame is open. It
+// returns "" when the trace ID isn't a W3C one, e.g. one set by ExtractTraceHeaders
+// from a workflow run ID.
+func Traceparent(ctx context.Context) string {
+	traceCtx := FromContext(ctx)
+	traceID := traceCtx.EnsureTraceID()
+	if !isTraceparentID(traceID, 32) {
+		return ""
+	}
+
+	parentID := traceCtx.RemoteSpanID
+	if frame := traceCtx.current(); frame != nil {
+		parentID = frame.EnsureSpanID()
+	}
+	if parentID == "" {
+		parentID = newSpanID()
+	}
+	return "00-" + traceID + "-" + parentID + "-01"
+}
+
+// ParseTraceparent splits a traceparent header value into its trace ID, parent
+// span ID and sampled flag
+func ParseTraceparent(value string) (traceID, parentID string, sampled bool, err error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || (parts[0] == "00" && len(parts) != 4) {
+		return "", "", false, fmt.Errorf("traceparent %q: expected version-traceid-parentid-flags", value)
+	}
+	if !isTraceparentHex(parts[0], 2) || parts[0] == "ff" {
+		return "", "", false, fmt.Errorf("traceparent %q: invalid version", value)
+	}
+	if !isTraceparentID(parts[1], 32) {
+		return "", "", false, fmt.Errorf("traceparent %q: invalid trace ID", value)
+	}
+	if !isTraceparentID(parts[2], 16) {
+		return "", "", false, fmt.Errorf("traceparent %q: invalid parent ID", value)
+	}
+	if !isTraceparentHex(parts[3], 2) {
+		return "", "", false, fmt.Errorf("traceparent %q: invalid flags", value)
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	return parts[1], parts[2], flags[0]&1 == 1, nil
+}
+
+// isTraceparentID reports whether s is a valid trace or span ID: n lowercase
+// hex digits, not all zero
+func isTraceparentID(s string, n int) bool {
+	return isTraceparentHex(s, n) && strings.Trim(s, "0") != ""
+}
+
+func isTraceparentHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+//go:build !devtrace_minimal
+
+package devtrace
+
+import (
+	"context"
+	"net/http"
+)
+
+// InjectTraceparent sets the traceparent and baggage headers of an outgoing
+// request from ctx:
+//
+//	devtrace.InjectTraceparent(ctx, req.Header)
+//
+// Transport does this, with the devtrace headers, for every request it sends.
+func InjectTraceparent(ctx context.Context, header http.Header) {
+	if traceparent := Traceparent(ctx); traceparent != "" {
+		header.Set(TraceparentHeader, traceparent)
+	}
+	if baggage := FromContext(ctx).baggage; len(baggage) > 0 {
+		header.Set(BaggageHeader, encodeBaggage(baggage))
+	}
+}
+
+// ExtractTraceparent returns ctx with a new trace context continuing the trace
+// named by the traceparent header of an incoming request, with the baggage of
+// its baggage header, or ctx itself when traceparent is missing or malformed:
+//
+//	ctx := devtrace.ExtractTraceparent(r.Context(), r.Header)
+func ExtractTraceparent(ctx context.Context, header http.Header) context.Context {
+	value := header.Get(TraceparentHeader)
+	if _, _, _, err := ParseTraceparent(value); err != nil {
+		return ctx
+	}
+	return ExtractTraceHeaders(ctx, map[string]string{TraceparentHeader: value, BaggageHeader: header.Get(BaggageHeader)})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -18,6 +19,8 @@ type TracedFunc struct {
 	SourceFile string
 	SourceLine int
 	ParamNames []string
+	Doc        string // doc comment of the function, when its source is available
+	funcName   string // runtime name of the wrapped function, used for package overrides
 }
 
 // TraceResult contains the result of a traced function call
@@ -25,9 +28,10 @@ type TraceResult struct {
 	Duration  time.Duration
 	Args      []interface{}
 	Results   []interface{}
-	Error     error
+	Error     error // the last non-nil error result, or the panic as an error
 	StartTime time.Time
 	EndTime   time.Time
+	Panic     interface{} // value recovered from a panic in the traced function, if any
 }
 
 // NewTracedFunc creates a new traced function wrapper
@@ -43,13 +47,15 @@ func NewTracedFunc(fn interface{}, options *TraceOptions) *TracedFunc {
 	}
 
 	// Try to get function name
+	var funcName string
+	if pc := fnValue.Pointer(); pc != 0 {
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			funcName = fn.Name()
+		}
+	}
 	name := options.Label
 	if name == "" {
-		if pc := fnValue.Pointer(); pc != 0 {
-			if fn := runtime.FuncForPC(pc); fn != nil {
-				name = fn.Name()
-			}
-		}
+		name = funcName
 		if name == "" {
 			name = "<anonymous>"
 		}
@@ -59,12 +65,20 @@ func NewTracedFunc(fn interface{}, options *TraceOptions) *TracedFunc {
 	sourceFile := ""
 	sourceLine := 0
 	var paramNames []string
+	var doc string
 
 	if fn := runtime.FuncForPC(fnValue.Pointer()); fn != nil {
-		sourceFile, sourceLine = fn.FileLine(fnValue.Pointer())
-		if fnSig := getSignatureForLocation(sourceFile, sourceLine, name); fnSig != nil {
+		sourceFile, sourceLine = originalPosition(fn.FileLine(fnValue.Pointer()))
+		if fnSig := getSignatureForLocation(sourceFile, sourceLine, strings.TrimSuffix(name, genericSuffix)); fnSig != nil {
 			signature = fnSig.signature
 			paramNames = append(paramNames, fnSig.params...)
+			doc = fnSig.doc
+			if instName, instSignature, ok := instantiate(funcName, fnSig, fnValue.Type()); ok {
+				if options.Label == "" {
+					name = instName
+				}
+				signature = instSignature
+			}
 		}
 	}
 
@@ -76,6 +90,8 @@ func NewTracedFunc(fn interface{}, options *TraceOptions) *TracedFunc {
 		SourceFile: sourceFile,
 		SourceLine: sourceLine,
 		ParamNames: paramNames,
+		Doc:        doc,
+		funcName:   funcName,
 	}
 }
 
@@ -141,32 +157,18 @@ func simplifyFunctionName(name string) string {
 }
 
 // Call executes the traced function with the given arguments
-func (tf *TracedFunc) Call(ctx context.Context, args ...interface{}) *TraceResult {
+func (tf *TracedFunc) Call(ctx context.Context, args ...interface{}) (result *TraceResult) {
 	startTime := time.Now()
 
 	fnType := tf.Original.Type()
 	numIn := fnType.NumIn()
 
-	createValue := func(arg interface{}, typ reflect.Type) reflect.Value {
-		if arg == nil {
-			return reflect.Zero(typ)
-		}
-		value := reflect.ValueOf(arg)
-		if !value.Type().AssignableTo(typ) {
-			if value.Type().ConvertibleTo(typ) {
-				return value.Convert(typ)
-			}
-			return reflect.Zero(typ)
-		}
-		return value
-	}
-
 	buildArgs := func() []reflect.Value {
 		if fnType.IsVariadic() {
 			vals := make([]reflect.Value, 0, numIn)
 			for i := 0; i < numIn-1; i++ {
 				if i < len(args) {
-					vals = append(vals, createValue(args[i], fnType.In(i)))
+					vals = append(vals, typedValue(args[i], fnType.In(i)))
 				} else {
 					vals = append(vals, reflect.Zero(fnType.In(i)))
 				}
@@ -177,7 +179,7 @@ func (tf *TracedFunc) Call(ctx context.Context, args ...interface{}) *TraceResul
 				variadicCount := len(args) - (numIn - 1)
 				slice := reflect.MakeSlice(variadicType, variadicCount, variadicCount)
 				for idx := 0; idx < variadicCount; idx++ {
-					slice.Index(idx).Set(createValue(args[numIn-1+idx], variadicType.Elem()))
+					slice.Index(idx).Set(typedValue(args[numIn-1+idx], variadicType.Elem()))
 				}
 				vals = append(vals, slice)
 			} else {
@@ -190,7 +192,7 @@ func (tf *TracedFunc) Call(ctx context.Context, args ...interface{}) *TraceResul
 		vals := make([]reflect.Value, numIn)
 		for i := 0; i < numIn; i++ {
 			if i < len(args) {
-				vals[i] = createValue(args[i], fnType.In(i))
+				vals[i] = typedValue(args[i], fnType.In(i))
 			} else {
 				vals[i] = reflect.Zero(fnType.In(i))
 			}
@@ -201,43 +203,87 @@ func (tf *TracedFunc) Call(ctx context.Context, args ...interface{}) *TraceResul
 	reflectArgs := buildArgs()
 
 	// Create frame for tracing
+	cfg := configFor(ctx, tf.funcName)
 	var frame *Frame
-	if IsEnabled() {
+	if cfg.Enabled && sampled(cfg) && (tf.Options.Condition == nil || tf.Options.Condition(args)) {
 		// Get caller information
 		_, file, line, _ := runtime.Caller(tf.Options.SkipFrames)
+		file, line = originalPosition(file, line)
 
-		// Prepare args map
-		argsMap := make(map[string]interface{})
-		for i, arg := range args {
-			argsMap[fmt.Sprintf("arg%d", i)] = arg
+		var argsMap map[string]interface{}
+		if captureArgsFor(ctx, tf.funcName) && len(args) > 0 {
+			argsMap = make(map[string]interface{}, len(args))
+			for i, arg := range args {
+				argsMap[tf.argName(i)] = arg
+			}
 		}
 
 		frame = CreateFrame(tf.Name, tf.Signature, file, line, argsMap)
-		normalizeFrameArgs(frame, tf.ParamNames)
-
-		// Add frame to context
-		traceCtx := FromContext(ctx)
-		traceCtx.Enter(frame)
+		frame.Operation = tf.Options.Operation
+		if cfg.DebugLevel >= 2 {
+			frame.Doc = tf.Doc
+		}
 
-		if Config.ShowTiming && GlobalLogger != nil {
-			GlobalLogger.Debug("▶ trace enter: %s", tf.Name)
+		if traceCtx := FromContext(ctx); skippedByCondition(ctx, traceCtx, frame) {
+			frame = nil // nothing to leave: the call runs untraced
+		} else {
+			enterContextUnchecked(ctx, traceCtx, frame)
+			if cfg.ShowTiming && GlobalLogger != nil {
+				GlobalLogger.Debug("▶ trace enter: %s", tf.Name)
+			}
 		}
 	}
 
+	var allocsBefore allocStats
+	if frame != nil && tf.Options.CaptureAllocs {
+		allocsBefore = readAllocStats()
+	}
+
 	// Execute the function
 	var results []reflect.Value
 	var err error
 	var resultValues []interface{}
 
 	defer func() {
-		if r := recover(); r != nil {
+		r := recover()
+		if r != nil {
 			err = fmt.Errorf("panic: %v", r)
+			if frame != nil {
+				frame.Panic = r
+				if frame.Error == nil {
+					frame.Error = err
+				}
+			}
+			runPanicHooks(frame, r)
+			reportPanic(ctx, r)
 		}
 
 		// Leave the trace context
-		if IsEnabled() && frame != nil {
+		if frame != nil {
+			if tf.Options.CaptureAllocs {
+				frame.Allocs, frame.AllocBytes = readAllocStats().since(allocsBefore)
+			}
+			frame.setResults(resultValues)
 			traceCtx := FromContext(ctx)
-			traceCtx.Leave()
+			if traceCtx.Leave() == frame && cfg.PoolFrames {
+				releaseFrame(frame) // never handed out, so only the recorder or a hook can hold it
+			}
+		}
+
+		if r != nil {
+			clearPanicReport(ctx)
+			endTime := time.Now()
+			result = &TraceResult{
+				Duration:  endTime.Sub(startTime),
+				Args:      args,
+				Error:     err,
+				StartTime: startTime,
+				EndTime:   endTime,
+				Panic:     r,
+			}
+			if frame != nil {
+				recordCall(tf, args, nil, err, r, startTime, result.Duration)
+			}
 		}
 	}()
 
@@ -257,8 +303,18 @@ func (tf *TracedFunc) Call(ctx context.Context, args ...interface{}) *TraceResul
 	endTime := time.Now()
 	duration := endTime.Sub(startTime)
 
+	if err == nil {
+		err = lastError(resultValues)
+	}
+	if frame != nil {
+		warnIfSlow(ctx, frame, duration, tf.Options.SlowThreshold, cfg)
+		frame.Error = err
+		logIfFailed(ctx, frame, tf.funcName, tf.Options.LogErrors)
+		recordCall(tf, args, resultValues, err, nil, startTime, duration)
+	}
+
 	// Log trace information
-	if IsEnabled() && Config.ShowTiming && GlobalLogger != nil {
+	if frame != nil && cfg.ShowTiming && GlobalLogger != nil {
 		GlobalLogger.Debug("▶ trace exit: %s (duration: %v)", tf.Name, duration)
 	}
 
@@ -272,17 +328,49 @@ func (tf *TracedFunc) Call(ctx context.Context, args ...interface{}) *TraceResul
 	}
 }
 
+// typedValue returns v as a reflect.Value of exactly type typ: nil becomes the
+// zero value, and values assignable or convertible to typ are wrapped in it.
+// Anything else becomes the zero value too.
+func typedValue(v interface{}, typ reflect.Type) reflect.Value {
+	if v == nil {
+		return reflect.Zero(typ)
+	}
+	value := reflect.ValueOf(v)
+	switch {
+	case value.Type() == typ:
+		return value
+	case value.Type().AssignableTo(typ):
+		typed := reflect.New(typ).Elem()
+		typed.Set(value)
+		return typed
+	case value.Type().ConvertibleTo(typ):
+		return value.Convert(typ)
+	}
+	return reflect.Zero(typ)
+}
+
 // Trace wraps a function with tracing capabilities
 func Trace(fn interface{}, options *TraceOptions) interface{} {
-	tracedFunc := NewTracedFunc(fn, options)
-	fnType := reflect.TypeOf(fn)
+	return makeTracedFunc(NewTracedFunc(fn, options))
+}
+
+// makeTracedFunc builds a function with the original signature that routes calls through tracedFunc
+func makeTracedFunc(tracedFunc *TracedFunc) interface{} {
+	fnType := tracedFunc.Original.Type()
 
 	// Create a new function with the same signature as the original
 	return reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
-		// Convert reflect values to interface{}
-		interfaceArgs := make([]interface{}, len(args))
+		// Convert reflect values to interface{}; Call takes variadic
+		// arguments one by one, so the variadic slice is spread
+		interfaceArgs := make([]interface{}, 0, len(args))
 		for i, arg := range args {
-			interfaceArgs[i] = arg.Interface()
+			if fnType.IsVariadic() && i == len(args)-1 {
+				for j := 0; j < arg.Len(); j++ {
+					interfaceArgs = append(interfaceArgs, arg.Index(j).Interface())
+				}
+				break
+			}
+			interfaceArgs = append(interfaceArgs, arg.Interface())
 		}
 
 		// Use context.Background() as default context and detect if first arg is a context
@@ -294,22 +382,22 @@ func Trace(fn interface{}, options *TraceOptions) interface{} {
 		}
 
 		result := tracedFunc.Call(ctx, interfaceArgs...)
-
-		// Convert results back to reflect values
-		resultValues := make([]reflect.Value, len(result.Results))
-		for i, res := range result.Results {
-			resultValues[i] = reflect.ValueOf(res)
+		if result.Panic != nil {
+			// Keep the wrapper transparent: callers see the original panic value
+			panic(result.Panic)
 		}
 
-		// Add error as last return value if the function returns error
-		if fnType.NumOut() > 0 && fnType.Out(fnType.NumOut()-1).Implements(reflect.TypeOf((*error)(nil)).Elem()) {
-			if result.Error != nil {
-				resultValues[len(resultValues)-1] = reflect.ValueOf(result.Error)
-			} else {
-				resultValues[len(resultValues)-1] = reflect.Zero(fnType.Out(fnType.NumOut() - 1))
+		// Convert results back to values of the declared result types; nil
+		// interfaces and interface results holding concrete values can't go
+		// through reflect.ValueOf unchanged
+		resultValues := make([]reflect.Value, fnType.NumOut())
+		for i := range resultValues {
+			var res interface{}
+			if i < len(result.Results) {
+				res = result.Results[i]
 			}
+			resultValues[i] = typedValue(res, fnType.Out(i))
 		}
-
 		return resultValues
 	}).Interface()
 }
@@ -339,7 +427,7 @@ func TimeFunc(fn func()) time.Duration {
 	fn()
 	duration := time.Since(start)
 
-	if Config.ShowTiming && GlobalLogger != nil {
+	if CurrentConfig().ShowTiming && GlobalLogger != nil {
 		GlobalLogger.Debug("⏱ function executed in %v", duration)
 	}
 
@@ -356,63 +444,18 @@ func TimeFuncWithResult[T any](fn func() T) (T, time.Duration) {
 	result := fn()
 	duration := time.Since(start)
 
-	if Config.ShowTiming && GlobalLogger != nil {
+	if CurrentConfig().ShowTiming && GlobalLogger != nil {
 		GlobalLogger.Debug("⏱ function executed in %v with result: %+v", duration, result)
 	}
 
 	return result, duration
 }
 
-// Benchmark runs a function multiple times and returns statistics
-type BenchmarkResult struct {
-	Iterations  int
-	TotalTime   time.Duration
-	AverageTime time.Duration
-	MinTime     time.Duration
-	MaxTime     time.Duration
-}
-
-// BenchmarkFunc runs a function multiple times and returns performance statistics
-func BenchmarkFunc(fn func(), iterations int) *BenchmarkResult {
-	if !IsEnabled() || iterations <= 0 {
-		return &BenchmarkResult{}
+// argName is the key argument i of a call is captured under: its parameter
+// name when the source was found, argN otherwise
+func (tf *TracedFunc) argName(i int) string {
+	if i < len(tf.ParamNames) && tf.ParamNames[i] != "" {
+		return tf.ParamNames[i]
 	}
-
-	times := make([]time.Duration, iterations)
-	totalTime := time.Duration(0)
-	minTime := time.Duration(^uint64(0) >> 1) // Max duration
-	maxTime := time.Duration(0)
-
-	for i := 0; i < iterations; i++ {
-		start := time.Now()
-		fn()
-		duration := time.Since(start)
-
-		times[i] = duration
-		totalTime += duration
-
-		if duration < minTime {
-			minTime = duration
-		}
-		if duration > maxTime {
-			maxTime = duration
-		}
-	}
-
-	avgTime := totalTime / time.Duration(iterations)
-
-	result := &BenchmarkResult{
-		Iterations:  iterations,
-		TotalTime:   totalTime,
-		AverageTime: avgTime,
-		MinTime:     minTime,
-		MaxTime:     maxTime,
-	}
-
-	if GlobalLogger != nil {
-		GlobalLogger.Info("📊 Benchmark: %d iterations, avg: %v, min: %v, max: %v, total: %v",
-			iterations, avgTime, minTime, maxTime, totalTime)
-	}
-
-	return result
+	return "arg" + strconv.Itoa(i)
 }
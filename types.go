@@ -1,10 +1,10 @@
 package devtrace
 
 import (
-	"fmt"
+	"context"
 	"reflect"
 	"runtime"
-	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,10 +15,33 @@ type Frame struct {
 	File       string                 `json:"file"`
 	Line       int                    `json:"line"`
 	Args       map[string]interface{} `json:"args,omitempty"`
+	Results    []interface{}          `json:"results,omitempty"`
 	StartTime  time.Time              `json:"start_time,omitempty"`
 	EndTime    time.Time              `json:"end_time,omitempty"`
 	Duration   time.Duration          `json:"duration,omitempty"`
+	Unfinished bool                   `json:"unfinished,omitempty"`
+	Class      FrameClass             `json:"class,omitempty"`
+	Allocs     uint64                 `json:"allocs,omitempty"`
+	AllocBytes uint64                 `json:"alloc_bytes,omitempty"`
+	Invocation *Invocation            `json:"invocation,omitempty"`
+	Goroutine  uint64                 `json:"goroutine,omitempty"`
+	SpanID     string                 `json:"span_id,omitempty"`   // W3C span ID, generated by EnsureSpanID when first propagated
+	Logs       []LogRecord            `json:"logs,omitempty"`      // records attached by NewSlogHandler
+	Events     []SpanEvent            `json:"events,omitempty"`    // events added to a Span
+	Panic      interface{}            `json:"panic,omitempty"`     // value the function panicked with
+	Error      error                  `json:"-"`                   // last non-nil error result, set when the frame's results are recorded
+	Operation  string                 `json:"operation,omitempty"` // what the trace is serving, e.g. "GET /users/:id"; see SetOperation
+	Doc        string                 `json:"doc,omitempty"`       // doc comment of the function, attached in verbose mode (DebugLevel 2)
 	CallerInfo *runtime.Frame         `json:"caller_info,omitempty"`
+
+	// callerPC is the return address CreateFrame saw, resolved by Caller
+	callerPC uintptr
+
+	// labels holds the pprof labels set while the frame runs (see Config.PprofLabels)
+	labels context.Context
+
+	// retained is set once something other than the frame's own call holds it (see releaseFrame)
+	retained uint32
 }
 
 // TracedFunction represents a function that can be traced
@@ -37,6 +60,19 @@ type TraceOptions struct {
 	ShowTiming  bool
 	ShowSnippet int
 	Label       string
+	// SlowThreshold overrides Config.SlowThreshold for this function when non-zero
+	SlowThreshold time.Duration
+	// CaptureAllocs records heap allocation deltas on each frame. Counters are
+	// process-wide, so concurrent goroutines inflate the numbers.
+	CaptureAllocs bool
+	// Operation names what calls of this function serve, e.g. "ConsumeOrderCreated".
+	// Frames entered beneath them inherit it (see SetOperation).
+	Operation string
+	// LogErrors overrides Config.LogErrors for this function when set
+	LogErrors *bool
+	// Condition, when set, traces only the calls whose arguments it accepts;
+	// the others run as if tracing were off
+	Condition func(args []interface{}) bool
 }
 
 // DefaultTraceOptions provides default options for tracing
@@ -49,32 +85,52 @@ var DefaultTraceOptions = TraceOptions{
 	Label:       "",
 }
 
-// DebugVars represents variables to be logged for debugging
-type DebugVars struct {
-	Vars map[string]interface{} `json:"vars"`
-}
-
-// NewDebugVars creates a new DebugVars instance
-func NewDebugVars(vars map[string]interface{}) *DebugVars {
-	return &DebugVars{Vars: vars}
-}
-
 // TraceContext represents the current tracing context
 type TraceContext struct {
-	Frames  []*Frame
-	Depth   int
+	Frames []*Frame
+	Depth  int
+	// mu guards Frames and Depth. Only the goroutine that owns the context
+	// changes them, and it does so under mu; other goroutines (Stack,
+	// GetDepth, the watchdog and DumpActiveContexts) read them under mu.
+	mu      sync.Mutex
 	StartAt time.Time
+	// TraceID identifies the logical trace this context belongs to, across processes
+	TraceID string
+	// RemoteParent names the frame in another goroutine, process or workflow that started this context
+	RemoteParent string
+	// RemoteSpanID is the W3C span ID of the remote parent, taken from a traceparent header
+	RemoteSpanID string
+	// Operation is inherited by root frames entered on this context (see SetOperation)
+	Operation string
+	// Skipped counts frames dropped because the context was already at Config.MaxDepth
+	Skipped int
+	// overflow tracks dropped frames that are still open so Leave stays balanced
+	overflow int
+	// unmatched counts open frames skipped while waiting for a trace condition
+	// (see WithTraceCondition); they are always above every recorded frame
+	unmatched int
+	// panicReported is set once a panic has been dumped, so outer frames don't repeat it
+	panicReported bool
+	// goroutine is set for contexts owned by the goroutine registry (see CurrentContext)
+	goroutine uint64
+	// pinned contexts stay registered while their stack is empty (see TraceFork.Adopt)
+	pinned bool
+	// lastOutbound is the last request WrapTransport sent from this context, to count retries
+	lastOutbound outboundAttempt
+	// labelBase holds the pprof labels the goroutine had before the outermost frame
+	labelBase context.Context
+	// baggage is replaced, never modified, by SetBaggage
+	baggage map[string]string
+	// topChild names the slowest frame left so far directly below the current root frame
+	topChild         string
+	topChildDuration time.Duration
 }
 
-// String returns a string representation of debug variables
-func (dv *DebugVars) String() string {
-	if dv == nil || len(dv.Vars) == 0 {
-		return "{}"
-	}
-
-	parts := make([]string, 0, len(dv.Vars))
-	for k, v := range dv.Vars {
-		parts = append(parts, fmt.Sprintf("%q: %+v", k, v))
-	}
-	return "{" + strings.Join(parts, ", ") + "}"
+// outboundAttempt is the last request WrapTransport sent from a frame
+type outboundAttempt struct {
+	parent  *Frame
+	started time.Time // of parent, which may be a recycled frame
+	key     string
+	retries int
+	failed  bool
 }
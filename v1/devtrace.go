@@ -0,0 +1,200 @@
+// Package devtrace is the stable API of gotrace.
+//
+// The root package github.com/skulidropek/gotrace changes quickly: fields are added to
+// its structs, globals are reworked and helpers are renamed. This package wraps the parts
+// downstream code needs behind types it owns, and follows these rules:
+//
+//   - exported identifiers are never removed or changed incompatibly;
+//   - superseded identifiers are marked Deprecated and keep working;
+//   - struct types only gain fields, so use keyed literals;
+//   - interfaces never gain methods.
+//
+// Import it under its package name:
+//
+//	import devtrace "github.com/skulidropek/gotrace/v1"
+package devtrace
+
+import (
+	"context"
+	"time"
+
+	core "github.com/skulidropek/gotrace"
+)
+
+// Config is the stable subset of the tracer configuration
+type Config struct {
+	Enabled     bool
+	StackLimit  int     // frames printed by the stack logger
+	ShowArgs    bool    // record function arguments
+	ShowTiming  bool    // log enter/exit timing at debug level
+	ShowSnippet int     // lines of source shown around each frame
+	AppPattern  string  // import path prefix of application code; "/" detects it
+	DebugLevel  int     // 0 quiet, 1 info, 2 debug
+	SampleRate  float64 // fraction of traced calls recorded; 0 or 1 records all
+}
+
+// Logger receives everything the tracer logs. Method set is frozen.
+type Logger interface {
+	Log(level string, msg string, args ...interface{})
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Frame is a read-only view of a traced call
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+	Args     map[string]string // rendered and redacted
+	Duration time.Duration     // time spent so far for frames that are still open
+	Error    string
+}
+
+// CurrentConfig returns the configuration in effect
+func CurrentConfig() Config {
+	return fromCore(core.CurrentConfig())
+}
+
+// Update atomically applies fn to the configuration and returns the result.
+// Settings outside Config are left untouched. When the result is invalid, such
+// as a SampleRate above 1, nothing is applied and Update returns the
+// configuration still in effect with an error describing every bad setting.
+func Update(fn func(c *Config)) (Config, error) {
+	cfg, err := core.UpdateConfig(func(c *core.DevTraceConfig) {
+		cfg := fromCore(*c)
+		fn(&cfg)
+		cfg.toCore(c)
+	})
+	return fromCore(cfg), err
+}
+
+// Configure is Update without the error: an invalid result is dropped and the
+// configuration still in effect is returned.
+//
+// Deprecated: use Update, which reports why a configuration was rejected.
+func Configure(fn func(c *Config)) Config {
+	cfg, _ := Update(fn)
+	return cfg
+}
+
+// Enabled reports whether tracing is on
+func Enabled() bool {
+	return core.IsEnabled()
+}
+
+// SetLogger routes the tracer's own output through l
+func SetLogger(l Logger) {
+	core.SetLogger(l)
+	core.GlobalEnhancedLogger.SetLogger(l)
+}
+
+// InstallStackLogger makes Debug, Info, Warn and Error print the call stack with each message
+func InstallStackLogger() {
+	core.InstallStackLogger(nil)
+}
+
+// Vars wraps variables so the stack logger prints them (redacted) with the message.
+// Pass the result as one of the args of Debug, Info, Warn or Error.
+func Vars(vars map[string]interface{}) interface{} {
+	return core.NewDebugVars(vars)
+}
+
+// Debug logs msg with the call stack of ctx
+func Debug(ctx context.Context, msg string, args ...interface{}) {
+	core.GlobalEnhancedLogger.Debug(ctx, msg, args...)
+}
+
+// Info logs msg with the call stack of ctx
+func Info(ctx context.Context, msg string, args ...interface{}) {
+	core.GlobalEnhancedLogger.Info(ctx, msg, args...)
+}
+
+// Warn logs msg with the call stack of ctx
+func Warn(ctx context.Context, msg string, args ...interface{}) {
+	core.GlobalEnhancedLogger.Warn(ctx, msg, args...)
+}
+
+// Error logs msg with the call stack of ctx
+func Error(ctx context.Context, msg string, args ...interface{}) {
+	core.GlobalEnhancedLogger.Error(ctx, msg, args...)
+}
+
+// Trace returns fn wrapped so each call is recorded as a frame. The result has
+// fn's type; label names the frame and may be empty to use the function name.
+func Trace(fn interface{}, label string) interface{} {
+	return core.TraceFunc(fn, label)
+}
+
+// TraceMethods wraps every exported method of svc, keyed by method name.
+// It panics when svc is nil.
+func TraceMethods(svc interface{}) map[string]interface{} {
+	traced := core.TraceStruct(svc, nil)
+	methods := make(map[string]interface{}, len(traced.Methods))
+	for name, method := range traced.Methods {
+		methods[name] = method
+	}
+	return methods
+}
+
+// NewContext returns ctx carrying a fresh trace, so calls traced under it are
+// not mixed with other requests
+func NewContext(ctx context.Context) context.Context {
+	return core.WithTraceContext(ctx, core.NewTraceContext())
+}
+
+// Stack returns the open frames of the trace carried by ctx, outermost first
+func Stack(ctx context.Context) []Frame {
+	frames := core.FromContext(ctx).Stack()
+	stack := make([]Frame, 0, len(frames))
+	for _, frame := range frames {
+		snapshot := core.SnapshotFrame(frame)
+		if !frame.StartTime.IsZero() && frame.EndTime.IsZero() {
+			snapshot.Duration = time.Since(frame.StartTime)
+		}
+		stack = append(stack, Frame{
+			Function: snapshot.Function,
+			File:     snapshot.File,
+			Line:     snapshot.Line,
+			Args:     snapshot.Args,
+			Duration: snapshot.Duration,
+			Error:    snapshot.Error,
+		})
+	}
+	return stack
+}
+
+// InjectHeaders returns the headers that carry the trace of ctx to another process
+func InjectHeaders(ctx context.Context) map[string]string {
+	return core.InjectTraceHeaders(ctx)
+}
+
+// ExtractHeaders continues a trace received from another process
+func ExtractHeaders(ctx context.Context, headers map[string]string) context.Context {
+	return core.ExtractTraceHeaders(ctx, headers)
+}
+
+func fromCore(c core.DevTraceConfig) Config {
+	return Config{
+		Enabled:     c.Enabled,
+		StackLimit:  c.StackLimit,
+		ShowArgs:    c.ShowArgs,
+		ShowTiming:  c.ShowTiming,
+		ShowSnippet: c.ShowSnippet,
+		AppPattern:  c.AppPattern,
+		DebugLevel:  c.DebugLevel,
+		SampleRate:  c.SampleRate,
+	}
+}
+
+func (c Config) toCore(target *core.DevTraceConfig) {
+	target.Enabled = c.Enabled
+	target.StackLimit = c.StackLimit
+	target.ShowArgs = c.ShowArgs
+	target.ShowTiming = c.ShowTiming
+	target.ShowSnippet = c.ShowSnippet
+	target.AppPattern = c.AppPattern
+	target.DebugLevel = c.DebugLevel
+	target.SampleRate = c.SampleRate
+}
@@ -0,0 +1,74 @@
+package devtrace
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	core "github.com/skulidropek/gotrace"
+)
+
+func TestUpdateKeepsCoreOnlySettings(t *testing.T) {
+	original := core.CurrentConfig()
+	t.Cleanup(func() { core.SetConfig(original) })
+
+	core.UpdateConfig(func(c *core.DevTraceConfig) { c.MaxDepth = 42 })
+	cfg, err := Update(func(c *Config) {
+		c.Enabled = true
+		c.StackLimit = 3
+	})
+
+	if err != nil || !cfg.Enabled || cfg.StackLimit != 3 || !Enabled() {
+		t.Fatalf("config not applied: %+v", cfg)
+	}
+	if core.CurrentConfig().MaxDepth != 42 {
+		t.Fatalf("settings outside v1.Config must be preserved")
+	}
+}
+
+func TestUpdateRejectsInvalidConfig(t *testing.T) {
+	original := core.CurrentConfig()
+	t.Cleanup(func() { core.SetConfig(original) })
+
+	before := CurrentConfig()
+	cfg, err := Update(func(c *Config) {
+		c.StackLimit = 7
+		c.SampleRate = 1.5
+	})
+	if err == nil || !strings.Contains(err.Error(), "SampleRate") {
+		t.Fatalf("expected SampleRate to be rejected, got %v", err)
+	}
+	if cfg != before || CurrentConfig() != before {
+		t.Fatalf("expected the config to stay %+v, got %+v", before, CurrentConfig())
+	}
+	if cfg := Configure(func(c *Config) { c.SampleRate = -1 }); cfg != before {
+		t.Fatalf("expected Configure to drop an invalid config, got %+v", cfg)
+	}
+}
+
+func TestTraceAndStack(t *testing.T) {
+	original := core.CurrentConfig()
+	t.Cleanup(func() { core.SetConfig(original) })
+	Update(func(c *Config) {
+		c.Enabled = true
+		c.ShowTiming = false
+		c.SampleRate = 1
+	})
+
+	ctx := NewContext(context.Background())
+	var inside []Frame
+	work := Trace(func(ctx context.Context, id int) int {
+		inside = Stack(ctx)
+		return id * 2
+	}, "work").(func(context.Context, int) int)
+
+	if got := work(ctx, 21); got != 42 {
+		t.Fatalf("unexpected result %d", got)
+	}
+	if len(inside) != 1 || inside[0].Function != "work" {
+		t.Fatalf("unexpected stack inside traced call: %+v", inside)
+	}
+	if len(Stack(ctx)) != 0 {
+		t.Fatalf("frame should be closed after the call")
+	}
+}
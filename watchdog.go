@@ -0,0 +1,101 @@
+package devtrace
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WatchdogOptions configures StartWatchdog
+type WatchdogOptions struct {
+	Deadline time.Duration // frames open longer than this are reported
+	Interval time.Duration // how often active contexts are scanned (defaults to Deadline/2)
+}
+
+// StartWatchdog starts a goroutine that periodically scans active trace contexts and
+// logs a WARN, with the path that led there, for every frame open longer than
+// opts.Deadline. Each stuck frame is reported once. Call the returned function to stop it.
+func StartWatchdog(opts WatchdogOptions) (stop func()) {
+	if opts.Deadline <= 0 {
+		return func() {}
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = opts.Deadline / 2
+	}
+
+	done := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+
+		reported := make(map[stuckFrame]struct{})
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				reported = scanStuckFrames(now, opts.Deadline, reported)
+			}
+		}
+	}()
+
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// stuckFrame identifies an open frame across scans. Frames are recycled
+// through the frame pool, so the pointer alone may name a later call.
+type stuckFrame struct {
+	goroutine uint64
+	start     int64 // StartTime in Unix nanoseconds
+}
+
+// scanStuckFrames reports frames past the deadline and returns the set of frames
+// that are still open and already reported
+func scanStuckFrames(now time.Time, deadline time.Duration, reported map[stuckFrame]struct{}) map[stuckFrame]struct{} {
+	stillOpen := make(map[stuckFrame]struct{}, len(reported))
+
+	for _, tc := range ActiveContexts() {
+		stack := tc.Stack()
+		frames := make([]*Frame, len(stack))
+		for i, frame := range stack {
+			frames[i] = openFrameCopy(frame)
+		}
+		for i, frame := range frames {
+			if frame.StartTime.IsZero() {
+				continue
+			}
+			open := now.Sub(frame.StartTime)
+			if open <= deadline {
+				continue
+			}
+
+			key := stuckFrame{goroutine: frame.Goroutine, start: frame.StartTime.UnixNano()}
+			if _, seen := reported[key]; !seen {
+				reportStuckFrame(tc, frames[:i+1], open, deadline)
+			}
+			stillOpen[key] = struct{}{}
+		}
+	}
+
+	return stillOpen
+}
+
+func reportStuckFrame(tc *TraceContext, path []*Frame, open, deadline time.Duration) {
+	if GlobalEnhancedLogger == nil {
+		return
+	}
+
+	snapshot := &TraceContext{
+		Frames:       path,
+		Depth:        len(path),
+		StartAt:      tc.StartAt,
+		TraceID:      tc.TraceID,
+		RemoteParent: tc.RemoteParent,
+	}
+	ctx := WithTraceContext(context.Background(), snapshot)
+
+	frame := path[len(path)-1]
+	GlobalEnhancedLogger.Warn(ctx, "⏰ frame %s open for %v (deadline %v)", frame.Function, open.Round(time.Millisecond), deadline)
+}
@@ -0,0 +1,38 @@
+package devtrace
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanStuckFramesReportsEachCallOnce(t *testing.T) {
+	originalEnhanced := GlobalEnhancedLogger
+	t.Cleanup(func() { GlobalEnhancedLogger = originalEnhanced })
+	logger := &captureLogger{}
+	GlobalEnhancedLogger = NewEnhancedLogger(&StackLoggerOptions{})
+	GlobalEnhancedLogger.SetLogger(logger)
+
+	tc := NewTraceContext()
+	frame := CreateFrame("syncInventory", "", "inventory.go", 12, nil)
+	tc.Enter(frame)
+
+	now := time.Now().Add(time.Minute)
+	reported := scanStuckFrames(now, time.Second, nil)
+	reported = scanStuckFrames(now, time.Second, reported)
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "frame syncInventory open for") {
+		t.Fatalf("expected one stuck frame warning, got %q", logger.messages)
+	}
+
+	// The same *Frame handed out again by the pool for a later call on the
+	// goroutine is a different call and is reported again
+	tc.Leave()
+	frame.StartTime = frame.StartTime.Add(time.Millisecond)
+	tc.Enter(frame)
+	defer tc.Leave()
+
+	scanStuckFrames(now, time.Second, reported)
+	if len(logger.messages) != 2 {
+		t.Fatalf("expected the reused frame to be reported again, got %q", logger.messages)
+	}
+}